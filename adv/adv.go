@@ -0,0 +1,357 @@
+// Package adv parses and builds raw Bluetooth LE advertising data
+// (AD structures in legacy advertising and scan response payloads)
+package adv
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maximum size of a legacy advertising or scan response payload
+const MaxLength = 31
+
+// AD types
+// https://www.bluetooth.com/specifications/assigned-numbers/generic-access-profile/
+const (
+	TypeFlags              = 0x01
+	TypeIncompleteUUID16   = 0x02
+	TypeCompleteUUID16     = 0x03
+	TypeIncompleteUUID32   = 0x04
+	TypeCompleteUUID32     = 0x05
+	TypeIncompleteUUID128  = 0x06
+	TypeCompleteUUID128    = 0x07
+	TypeShortName          = 0x08
+	TypeCompleteName       = 0x09
+	TypeTxPower            = 0x0a
+	TypeConnectionInterval = 0x12
+	TypeServiceData16      = 0x16
+	TypeAppearance         = 0x19
+	TypeServiceData32      = 0x20
+	TypeServiceData128     = 0x21
+	TypeURI                = 0x24
+	TypeManufacturerData   = 0xff
+)
+
+// Flags bits
+const (
+	LimitedDiscoverable = 1 << iota
+	GeneralDiscoverable
+	BREDRNotSupported
+	SimultaneousController
+	SimultaneousHost
+)
+
+var (
+	ErrTruncated = errors.New("adv: truncated AD structure")
+	ErrTooLong   = errors.New("adv: AD structure too long")
+)
+
+// UUID is a 16, 32 or 128-bit uuid in big-endian (canonical) order
+type UUID []byte
+
+// UUID16 returns a 16-bit uuid
+func UUID16(n uint16) UUID {
+	return UUID{byte(n >> 8), byte(n)}
+}
+
+// ParseUUID parses a 4, 8 or 32 hex digit uuid, dashes are ignored
+func ParseUUID(s string) (UUID, error) {
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil {
+		return nil, err
+	}
+	switch len(b) {
+	case 2, 4, 16:
+		return UUID(b), nil
+	}
+	return nil, fmt.Errorf("adv: invalid uuid %q", s)
+}
+
+func (u UUID) String() string {
+	return hex.EncodeToString(u)
+}
+
+// reverse returns a copy of b in reverse byte order (over-the-air order)
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+// Structure is a single length-type-value AD structure
+type Structure struct {
+	Type byte
+	Data []byte
+}
+
+// ServiceData associated with a service uuid
+type ServiceData struct {
+	UUID UUID
+	Data []byte
+}
+
+// ManufacturerData with its company identifier
+type ManufacturerData struct {
+	CompanyID uint16
+	Data      []byte
+}
+
+// ConnectionInterval is the preferred connection interval range in 1.25 ms
+// units, 0xffff if not specified
+type ConnectionInterval struct {
+	Min, Max uint16
+}
+
+// Packet is a sequence of AD structures
+type Packet []Structure
+
+// Parse splits a payload into AD structures. Parsing stops at the first
+// zero length, the remainder being padding.
+func Parse(b []byte) (Packet, error) {
+	var p Packet
+	for len(b) > 0 {
+		n := int(b[0])
+		if n == 0 {
+			break
+		}
+		if n+1 > len(b) {
+			return p, ErrTruncated
+		}
+		p = append(p, Structure{Type: b[1], Data: b[2 : n+1]})
+		b = b[n+1:]
+	}
+	return p, nil
+}
+
+// Bytes encodes the AD structures
+func (p Packet) Bytes() []byte {
+	var b []byte
+	for _, s := range p {
+		b = append(b, byte(len(s.Data)+1), s.Type)
+		b = append(b, s.Data...)
+	}
+	return b
+}
+
+// Len returns the encoded length
+func (p Packet) Len() int {
+	n := 0
+	for _, s := range p {
+		n += 2 + len(s.Data)
+	}
+	return n
+}
+
+// Get returns the data of the first AD structure of type t
+func (p Packet) Get(t byte) ([]byte, bool) {
+	for _, s := range p {
+		if s.Type == t {
+			return s.Data, true
+		}
+	}
+	return nil, false
+}
+
+// Add appends an AD structure
+func (p *Packet) Add(t byte, data []byte) error {
+	if len(data) > 254 {
+		return ErrTooLong
+	}
+	*p = append(*p, Structure{Type: t, Data: data})
+	return nil
+}
+
+// Flags returns the flags field
+func (p Packet) Flags() (byte, bool) {
+	if d, ok := p.Get(TypeFlags); ok && len(d) > 0 {
+		return d[0], true
+	}
+	return 0, false
+}
+
+// AddFlags appends the flags field
+func (p *Packet) AddFlags(flags byte) error {
+	return p.Add(TypeFlags, []byte{flags})
+}
+
+// uuid list types by size
+var uuidTypes = map[int][2]byte{
+	2:  {TypeIncompleteUUID16, TypeCompleteUUID16},
+	4:  {TypeIncompleteUUID32, TypeCompleteUUID32},
+	16: {TypeIncompleteUUID128, TypeCompleteUUID128},
+}
+
+// uuid sizes of uuid list and service data types
+var uuidSizes = map[byte]int{
+	TypeIncompleteUUID16:  2,
+	TypeCompleteUUID16:    2,
+	TypeIncompleteUUID32:  4,
+	TypeCompleteUUID32:    4,
+	TypeIncompleteUUID128: 16,
+	TypeCompleteUUID128:   16,
+}
+
+var serviceDataSizes = map[byte]int{
+	TypeServiceData16:  2,
+	TypeServiceData32:  4,
+	TypeServiceData128: 16,
+}
+
+// UUIDs returns the advertised service uuids of all sizes, and whether
+// every list present is complete
+func (p Packet) UUIDs() ([]UUID, bool) {
+	var uuids []UUID
+	complete := true
+	for _, s := range p {
+		size, ok := uuidSizes[s.Type]
+		if !ok {
+			continue
+		}
+		if s.Type == uuidTypes[size][0] {
+			complete = false
+		}
+		for b := s.Data; len(b) >= size; b = b[size:] {
+			uuids = append(uuids, UUID(reverse(b[:size])))
+		}
+	}
+	return uuids, complete
+}
+
+// AddUUIDs appends service uuid lists, one per uuid size
+func (p *Packet) AddUUIDs(complete bool, uuids ...UUID) error {
+	lists := map[int][]byte{}
+	var sizes []int
+	for _, u := range uuids {
+		if _, ok := uuidTypes[len(u)]; !ok {
+			return fmt.Errorf("adv: invalid uuid %v", u)
+		}
+		if _, ok := lists[len(u)]; !ok {
+			sizes = append(sizes, len(u))
+		}
+		lists[len(u)] = append(lists[len(u)], reverse(u)...)
+	}
+	for _, size := range sizes {
+		t := uuidTypes[size][0]
+		if complete {
+			t = uuidTypes[size][1]
+		}
+		if err := p.Add(t, lists[size]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LocalName returns the complete or shortened local name
+func (p Packet) LocalName() (name string, complete bool) {
+	if d, ok := p.Get(TypeCompleteName); ok {
+		return string(d), true
+	}
+	if d, ok := p.Get(TypeShortName); ok {
+		return string(d), false
+	}
+	return "", false
+}
+
+// AddLocalName appends the complete or shortened local name
+func (p *Packet) AddLocalName(name string, complete bool) error {
+	if complete {
+		return p.Add(TypeCompleteName, []byte(name))
+	}
+	return p.Add(TypeShortName, []byte(name))
+}
+
+// TxPower returns the TX power level in dBm
+func (p Packet) TxPower() (int8, bool) {
+	if d, ok := p.Get(TypeTxPower); ok && len(d) == 1 {
+		return int8(d[0]), true
+	}
+	return 0, false
+}
+
+// AddTxPower appends the TX power level in dBm
+func (p *Packet) AddTxPower(dBm int8) error {
+	return p.Add(TypeTxPower, []byte{byte(dBm)})
+}
+
+// ServiceData returns service data for 16, 32 and 128-bit uuids
+func (p Packet) ServiceData() []ServiceData {
+	var sd []ServiceData
+	for _, s := range p {
+		if size, ok := serviceDataSizes[s.Type]; ok && len(s.Data) >= size {
+			sd = append(sd, ServiceData{UUID: UUID(reverse(s.Data[:size])), Data: s.Data[size:]})
+		}
+	}
+	return sd
+}
+
+// AddServiceData appends service data for a 16, 32 or 128-bit uuid
+func (p *Packet) AddServiceData(uuid UUID, data []byte) error {
+	for t, size := range serviceDataSizes {
+		if size == len(uuid) {
+			return p.Add(t, append(reverse(uuid), data...))
+		}
+	}
+	return fmt.Errorf("adv: invalid uuid %v", uuid)
+}
+
+// ManufacturerData returns manufacturer specific data split in company
+// identifier and payload
+func (p Packet) ManufacturerData() []ManufacturerData {
+	var md []ManufacturerData
+	for _, s := range p {
+		if s.Type == TypeManufacturerData && len(s.Data) >= 2 {
+			md = append(md, ManufacturerData{
+				CompanyID: binary.LittleEndian.Uint16(s.Data),
+				Data:      s.Data[2:],
+			})
+		}
+	}
+	return md
+}
+
+// AddManufacturerData appends manufacturer specific data
+func (p *Packet) AddManufacturerData(companyID uint16, data []byte) error {
+	b := make([]byte, 2, 2+len(data))
+	binary.LittleEndian.PutUint16(b, companyID)
+	return p.Add(TypeManufacturerData, append(b, data...))
+}
+
+// Appearance returns the external appearance of the device
+func (p Packet) Appearance() (uint16, bool) {
+	if d, ok := p.Get(TypeAppearance); ok && len(d) == 2 {
+		return binary.LittleEndian.Uint16(d), true
+	}
+	return 0, false
+}
+
+// AddAppearance appends the external appearance of the device
+func (p *Packet) AddAppearance(appearance uint16) error {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, appearance)
+	return p.Add(TypeAppearance, b)
+}
+
+// ConnectionInterval returns the slave connection interval range
+func (p Packet) ConnectionInterval() (ConnectionInterval, bool) {
+	if d, ok := p.Get(TypeConnectionInterval); ok && len(d) == 4 {
+		return ConnectionInterval{
+			Min: binary.LittleEndian.Uint16(d),
+			Max: binary.LittleEndian.Uint16(d[2:]),
+		}, true
+	}
+	return ConnectionInterval{}, false
+}
+
+// AddConnectionInterval appends the slave connection interval range
+func (p *Packet) AddConnectionInterval(c ConnectionInterval) error {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint16(b, c.Min)
+	binary.LittleEndian.PutUint16(b[2:], c.Max)
+	return p.Add(TypeConnectionInterval, b)
+}
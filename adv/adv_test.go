@@ -0,0 +1,121 @@
+package adv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	// flags, 16-bit uuids, complete name, tx power, padding
+	b := mustHex("020106" + "050312180f18" + "0509676f6f64" + "020af4" + "0000")
+	p, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags, ok := p.Flags(); !ok || flags != GeneralDiscoverable|BREDRNotSupported {
+		t.Errorf("flags: got %#x", flags)
+	}
+	uuids, complete := p.UUIDs()
+	if want := []UUID{UUID16(0x1812), UUID16(0x180f)}; !complete || !reflect.DeepEqual(uuids, want) {
+		t.Errorf("uuids: got %v %v, want %v", uuids, complete, want)
+	}
+	if name, complete := p.LocalName(); name != "good" || !complete {
+		t.Errorf("name: got %q %v", name, complete)
+	}
+	if tx, ok := p.TxPower(); !ok || tx != -12 {
+		t.Errorf("tx power: got %v", tx)
+	}
+	if got := p.Bytes(); !bytes.Equal(got, b[:len(b)-2]) {
+		t.Errorf("got %x, want %x", got, b)
+	}
+
+	if _, err := Parse(mustHex("0509676f")); err != ErrTruncated {
+		t.Errorf("got %v, want %v", err, ErrTruncated)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	vendor, err := ParseUUID("6e400001-b5a3-f393-e0a9-e50e24dcca9e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p Packet
+	p.AddFlags(GeneralDiscoverable | BREDRNotSupported)
+	if err := p.AddUUIDs(false, UUID16(0x180d), vendor); err != nil {
+		t.Fatal(err)
+	}
+	p.AddAppearance(0x0341)
+	p.AddConnectionInterval(ConnectionInterval{Min: 6, Max: 0xffff})
+	if err := p.AddServiceData(UUID16(0xfeaa), []byte{0x10, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddManufacturerData(0x004c, []byte{0x02, 0x15}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := mustHex("020106" +
+		"03020d18" +
+		"11069ecadc240ee5a9e093f3a3b50100406e" +
+		"03194103" +
+		"05120600ffff" +
+		"0516aafe1000" +
+		"05ff4c000215")
+	if got := p.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+	if p.Len() != len(want) {
+		t.Errorf("len: got %d, want %d", p.Len(), len(want))
+	}
+
+	q, err := Parse(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uuids, complete := q.UUIDs(); complete || len(uuids) != 2 || uuids[1].String() != vendor.String() {
+		t.Errorf("uuids: got %v %v", uuids, complete)
+	}
+	if a, _ := q.Appearance(); a != 0x0341 {
+		t.Errorf("appearance: got %#x", a)
+	}
+	if c, _ := q.ConnectionInterval(); c != (ConnectionInterval{6, 0xffff}) {
+		t.Errorf("connection interval: got %v", c)
+	}
+	if sd := q.ServiceData(); len(sd) != 1 || sd[0].UUID.String() != "feaa" {
+		t.Errorf("service data: got %v", sd)
+	}
+	if md := q.ManufacturerData(); len(md) != 1 || md[0].CompanyID != 0x004c || !bytes.Equal(md[0].Data, []byte{2, 0x15}) {
+		t.Errorf("manufacturer data: got %v", md)
+	}
+}
+
+func TestURI(t *testing.T) {
+	for _, uri := range []string{"https://example.com", "http://goo.gl", "urn:x", "spotify:track:1", "x-unknown:y"} {
+		var p Packet
+		if err := p.AddURI(uri); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := p.URI(); !ok || got != uri {
+			t.Errorf("got %q, want %q", got, uri)
+		}
+	}
+	var p Packet
+	p.AddURI("https://x")
+	if got, want := p.Bytes(), mustHex("0524172f2f78"); !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+	p, _ = Parse(mustHex("0424c2ba78"))
+	if got, ok := p.URI(); !ok || got != "ms-settings-cloudstorage:x" {
+		t.Errorf("got %q", got)
+	}
+}
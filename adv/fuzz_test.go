@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package adv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add(mustHex("020106050312180f180509676f6f64020af40000"))
+	f.Add(mustHex("1aff4c000215e2c56db5dffb48d2b060d0f5a71096e000010001c5"))
+	f.Add(mustHex("0303aafe1116aafe10eb0367676f6f676c6507"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p, err := Parse(b)
+		if err != nil {
+			return
+		}
+		enc := p.Bytes()
+		if !bytes.HasPrefix(b, enc) {
+			t.Fatalf("encoded %x is not a prefix of %x", enc, b)
+		}
+		if len(enc) < len(b) && b[len(enc)] != 0 {
+			t.Fatalf("unparsed data %x after %x", b[len(enc):], enc)
+		}
+		// accessors must not panic on arbitrary data
+		p.Flags()
+		p.UUIDs()
+		p.LocalName()
+		p.TxPower()
+		p.ServiceData()
+		p.ManufacturerData()
+		p.Appearance()
+		p.ConnectionInterval()
+		p.URI()
+	})
+}
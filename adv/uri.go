@@ -0,0 +1,224 @@
+package adv
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// URI scheme name strings, indexed by code point
+// https://www.bluetooth.com/specifications/assigned-numbers/uri-scheme-name-string-mapping/
+var uriSchemes = map[rune]string{
+	0x01: "",
+	0x02: "aaa:",
+	0x03: "aaas:",
+	0x04: "about:",
+	0x05: "acap:",
+	0x06: "acct:",
+	0x07: "cap:",
+	0x08: "cid:",
+	0x09: "coap:",
+	0x0a: "coaps:",
+	0x0b: "crid:",
+	0x0c: "data:",
+	0x0d: "dav:",
+	0x0e: "dict:",
+	0x0f: "dns:",
+	0x10: "file:",
+	0x11: "ftp:",
+	0x12: "geo:",
+	0x13: "go:",
+	0x14: "gopher:",
+	0x15: "h323:",
+	0x16: "http:",
+	0x17: "https:",
+	0x18: "iax:",
+	0x19: "icap:",
+	0x1a: "im:",
+	0x1b: "imap:",
+	0x1c: "info:",
+	0x1d: "ipp:",
+	0x1e: "ipps:",
+	0x1f: "iris:",
+	0x20: "iris.beep:",
+	0x21: "iris.xpc:",
+	0x22: "iris.xpcs:",
+	0x23: "iris.lwz:",
+	0x24: "jabber:",
+	0x25: "ldap:",
+	0x26: "mailto:",
+	0x27: "mid:",
+	0x28: "msrp:",
+	0x29: "msrps:",
+	0x2a: "mtqp:",
+	0x2b: "mupdate:",
+	0x2c: "news:",
+	0x2d: "nfs:",
+	0x2e: "ni:",
+	0x2f: "nih:",
+	0x30: "nntp:",
+	0x31: "opaquelocktoken:",
+	0x32: "pop:",
+	0x33: "pres:",
+	0x34: "reload:",
+	0x35: "rtsp:",
+	0x36: "rtsps:",
+	0x37: "rtspu:",
+	0x38: "service:",
+	0x39: "session:",
+	0x3a: "shttp:",
+	0x3b: "sieve:",
+	0x3c: "sip:",
+	0x3d: "sips:",
+	0x3e: "sms:",
+	0x3f: "snmp:",
+	0x40: "soap.beep:",
+	0x41: "soap.beeps:",
+	0x42: "stun:",
+	0x43: "stuns:",
+	0x44: "tag:",
+	0x45: "tel:",
+	0x46: "telnet:",
+	0x47: "tftp:",
+	0x48: "thismessage:",
+	0x49: "tn3270:",
+	0x4a: "tip:",
+	0x4b: "turn:",
+	0x4c: "turns:",
+	0x4d: "tv:",
+	0x4e: "urn:",
+	0x4f: "vemmi:",
+	0x50: "ws:",
+	0x51: "wss:",
+	0x52: "xcon:",
+	0x53: "xcon-userid:",
+	0x54: "xmlrpc.beep:",
+	0x55: "xmlrpc.beeps:",
+	0x56: "xmpp:",
+	0x57: "z39.50r:",
+	0x58: "z39.50s:",
+	0x59: "acr:",
+	0x5a: "adiumxtra:",
+	0x5b: "afp:",
+	0x5c: "afs:",
+	0x5d: "aim:",
+	0x5e: "apt:",
+	0x5f: "attachment:",
+	0x60: "aw:",
+	0x61: "barion:",
+	0x62: "beshare:",
+	0x63: "bitcoin:",
+	0x64: "bolo:",
+	0x65: "callto:",
+	0x66: "chrome:",
+	0x67: "chrome-extension:",
+	0x68: "com-eventbrite-attendee:",
+	0x69: "content:",
+	0x6a: "cvs:",
+	0x6b: "dlna-playsingle:",
+	0x6c: "dlna-playcontainer:",
+	0x6d: "dtn:",
+	0x6e: "dvb:",
+	0x6f: "ed2k:",
+	0x70: "facetime:",
+	0x71: "feed:",
+	0x72: "feedready:",
+	0x73: "finger:",
+	0x74: "fish:",
+	0x75: "gg:",
+	0x76: "git:",
+	0x77: "gizmoproject:",
+	0x78: "gtalk:",
+	0x79: "ham:",
+	0x7a: "hcp:",
+	0x7b: "icon:",
+	0x7c: "ipn:",
+	0x7d: "irc:",
+	0x7e: "irc6:",
+	0x7f: "ircs:",
+	0x80: "itms:",
+	0x81: "jar:",
+	0x82: "jms:",
+	0x83: "keyparc:",
+	0x84: "lastfm:",
+	0x85: "ldaps:",
+	0x86: "magnet:",
+	0x87: "maps:",
+	0x88: "market:",
+	0x89: "message:",
+	0x8a: "mms:",
+	0x8b: "ms-help:",
+	0x8c: "ms-settings-power:",
+	0x8d: "msnim:",
+	0x8e: "mumble:",
+	0x8f: "mvn:",
+	0x90: "notes:",
+	0x91: "oid:",
+	0x92: "palm:",
+	0x93: "paparazzi:",
+	0x94: "pkcs11:",
+	0x95: "platform:",
+	0x96: "proxy:",
+	0x97: "psyc:",
+	0x98: "query:",
+	0x99: "res:",
+	0x9a: "resource:",
+	0x9b: "rmi:",
+	0x9c: "rsync:",
+	0x9d: "rtmfp:",
+	0x9e: "rtmp:",
+	0x9f: "secondlife:",
+	0xa0: "sftp:",
+	0xa1: "sgn:",
+	0xa2: "skype:",
+	0xa3: "smb:",
+	0xa4: "smtp:",
+	0xa5: "soldat:",
+	0xa6: "spotify:",
+	0xa7: "ssh:",
+	0xa8: "steam:",
+	0xa9: "submit:",
+	0xaa: "svn:",
+	0xab: "teamspeak:",
+	0xac: "teliaeid:",
+	0xad: "things:",
+	0xae: "udp:",
+	0xaf: "unreal:",
+	0xb0: "ut2004:",
+	0xb1: "ventrilo:",
+	0xb2: "view-source:",
+	0xb3: "webcal:",
+	0xb4: "wtai:",
+	0xb5: "wyciwyg:",
+	0xb6: "xfire:",
+	0xb7: "xri:",
+	0xb8: "ymsgr:",
+	0xb9: "example:",
+	0xba: "ms-settings-cloudstorage:",
+}
+
+// URI returns the advertised URI with its scheme expanded
+func (p Packet) URI() (string, bool) {
+	d, ok := p.Get(TypeURI)
+	if !ok || len(d) == 0 {
+		return "", false
+	}
+	r, n := utf8.DecodeRune(d)
+	scheme, ok := uriSchemes[r]
+	if !ok {
+		return "", false
+	}
+	return scheme + string(d[n:]), true
+}
+
+// AddURI appends a URI, compressing its scheme when it has a code point
+func (p *Packet) AddURI(uri string) error {
+	code := rune(0x01)
+	for r, scheme := range uriSchemes {
+		if scheme != "" && strings.HasPrefix(uri, scheme) && len(scheme) > len(uriSchemes[code]) {
+			code = r
+		}
+	}
+	b := make([]byte, utf8.RuneLen(code))
+	utf8.EncodeRune(b, code)
+	return p.Add(TypeURI, append(b, uri[len(uriSchemes[code]):]...))
+}
@@ -0,0 +1,321 @@
+package goble
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dim13/goble/adv"
+	"github.com/dim13/goble/xpc"
+)
+
+// flags inserted by the controller in connectable advertising
+const advertisingFlags = adv.GeneralDiscoverable | adv.BREDRNotSupported
+
+// Placement tells where a field ended up in the legacy advertising payloads
+type Placement int
+
+const (
+	InAdvertising Placement = iota
+	InScanResponse
+	Truncated
+	Omitted
+)
+
+func (p Placement) String() string {
+	switch p {
+	case InAdvertising:
+		return "advertising"
+	case InScanResponse:
+		return "scan response"
+	case Truncated:
+		return "truncated"
+	case Omitted:
+		return "omitted"
+	}
+	return fmt.Sprintf("Placement(%d)", int(p))
+}
+
+// FieldPlacement reports the placement of a single advertised field
+type FieldPlacement struct {
+	Field     string
+	Placement Placement
+}
+
+// AdvertisingPayload is the result of laying out AdvertisingData
+type AdvertisingPayload struct {
+	Advertising  []byte // AD structures including flags
+	ScanResponse []byte // AD structures
+	Fields       []FieldPlacement
+}
+
+// Moved returns the fields that did not fit in the advertising packet
+func (p AdvertisingPayload) Moved() []FieldPlacement {
+	var moved []FieldPlacement
+	for _, f := range p.Fields {
+		if f.Placement != InAdvertising {
+			moved = append(moved, f)
+		}
+	}
+	return moved
+}
+
+// AdvertisingError lists the fields that fit in neither payload
+type AdvertisingError struct {
+	Fields []string
+}
+
+func (e *AdvertisingError) Error() string {
+	return "advertising data too long: " + strings.Join(e.Fields, ", ") + " omitted"
+}
+
+type advServiceData struct {
+	uuid xpc.UUID
+	data []byte
+}
+
+// AdvertisingData builds legacy (31 byte) advertising and scan response payloads
+type AdvertisingData struct {
+	localName        string
+	serviceUuids     []xpc.UUID
+	serviceData      []advServiceData
+	companyID        uint16
+	manufacturerData []byte
+	hasManufacturer  bool
+	txPower          int8
+	hasTxPower       bool
+}
+
+// NewAdvertisingData creates an empty advertising data builder
+func NewAdvertisingData() *AdvertisingData {
+	return &AdvertisingData{}
+}
+
+// SetLocalName sets the device name, shortened if it does not fit
+func (a *AdvertisingData) SetLocalName(name string) *AdvertisingData {
+	a.localName = name
+	return a
+}
+
+// AddServiceUUID adds a service uuid, advertised as 16-bit if derived from
+// the Bluetooth Base UUID and as 128-bit otherwise
+func (a *AdvertisingData) AddServiceUUID(uuids ...xpc.UUID) *AdvertisingData {
+	a.serviceUuids = append(a.serviceUuids, uuids...)
+	return a
+}
+
+// AddServiceData adds service data for a 16-bit or 128-bit service uuid
+func (a *AdvertisingData) AddServiceData(uuid xpc.UUID, data []byte) *AdvertisingData {
+	a.serviceData = append(a.serviceData, advServiceData{uuid: uuid, data: data})
+	return a
+}
+
+// SetManufacturerData sets manufacturer specific data for a company identifier
+func (a *AdvertisingData) SetManufacturerData(companyID uint16, data []byte) *AdvertisingData {
+	a.companyID = companyID
+	a.manufacturerData = data
+	a.hasManufacturer = true
+	return a
+}
+
+// SetTxPower advertises the TX power level in dBm
+func (a *AdvertisingData) SetTxPower(dBm int8) *AdvertisingData {
+	a.txPower = dBm
+	a.hasTxPower = true
+	return a
+}
+
+// advUUID converts a uuid to its shortest advertised form
+func advUUID(uuid xpc.UUID) adv.UUID {
+	if n, ok := Short(uuid); ok {
+		return adv.UUID16(n)
+	}
+	return adv.UUID(uuid.Bytes())
+}
+
+type advLayout struct {
+	packets [2]adv.Packet
+	fields  []FieldPlacement
+	omitted []string
+}
+
+// place puts an AD structure in the first packet with enough room
+func (l *advLayout) place(field string, typ byte, data []byte) bool {
+	for i := range l.packets {
+		if l.packets[i].Len()+2+len(data) <= adv.MaxLength {
+			l.packets[i].Add(typ, data)
+			l.fields = append(l.fields, FieldPlacement{Field: field, Placement: Placement(i)})
+			return true
+		}
+	}
+	return false
+}
+
+// room returns the largest free space in either packet and its index
+func (l *advLayout) room() (int, int) {
+	best, n := 0, adv.MaxLength-l.packets[0].Len()
+	if m := adv.MaxLength - l.packets[1].Len(); m > n {
+		best, n = 1, m
+	}
+	return best, n
+}
+
+// truncate puts the head of an AD structure in the roomiest packet
+func (l *advLayout) truncate(field string, typ byte, data []byte, unit int) {
+	i, n := l.room()
+	if n -= 2; n >= unit {
+		n -= n % unit
+		// a shortened name ends before a split rune
+		for typ == adv.TypeShortName && n > 0 && !utf8.RuneStart(data[n]) {
+			n--
+		}
+		if n > 0 {
+			l.packets[i].Add(typ, data[:n])
+			l.fields = append(l.fields, FieldPlacement{Field: field, Placement: Truncated})
+			return
+		}
+	}
+	l.omit(field)
+}
+
+func (l *advLayout) omit(field string) {
+	l.fields = append(l.fields, FieldPlacement{Field: field, Placement: Omitted})
+	l.omitted = append(l.omitted, field)
+}
+
+// structure returns the single AD structure encoded by add
+func structure(add func(p *adv.Packet)) adv.Structure {
+	var p adv.Packet
+	add(&p)
+	return p[0]
+}
+
+// Build lays out the fields in advertising and scan response payloads.
+//
+// Fields are placed in order of importance (service uuids, service data,
+// manufacturer data, tx power, local name). A field that does not fit in the
+// advertising packet moves to the scan response, uuid lists are marked
+// incomplete and names shortened when they fit in neither. Fields that cannot
+// be sent at all are reported with an *AdvertisingError.
+func (a *AdvertisingData) Build() (AdvertisingPayload, error) {
+	var l advLayout
+	l.packets[0].AddFlags(advertisingFlags)
+
+	var uuids16, uuids128 []adv.UUID
+	for _, uuid := range a.serviceUuids {
+		if u := advUUID(uuid); len(u) == 2 {
+			uuids16 = append(uuids16, u)
+		} else {
+			uuids128 = append(uuids128, u)
+		}
+	}
+	for _, list := range []struct {
+		field string
+		uuids []adv.UUID
+		size  int
+	}{
+		{"16-bit service uuids", uuids16, 2},
+		{"128-bit service uuids", uuids128, 16},
+	} {
+		if len(list.uuids) == 0 {
+			continue
+		}
+		s := structure(func(p *adv.Packet) { p.AddUUIDs(true, list.uuids...) })
+		if !l.place(list.field, s.Type, s.Data) {
+			incomplete := structure(func(p *adv.Packet) { p.AddUUIDs(false, list.uuids...) })
+			l.truncate(list.field, incomplete.Type, s.Data, list.size)
+		}
+	}
+
+	for _, sd := range a.serviceData {
+		s := structure(func(p *adv.Packet) { p.AddServiceData(advUUID(sd.uuid), sd.data) })
+		if field := "service data " + sd.uuid.String(); !l.place(field, s.Type, s.Data) {
+			l.omit(field)
+		}
+	}
+
+	if a.hasManufacturer {
+		s := structure(func(p *adv.Packet) { p.AddManufacturerData(a.companyID, a.manufacturerData) })
+		if !l.place("manufacturer data", s.Type, s.Data) {
+			l.omit("manufacturer data")
+		}
+	}
+
+	if a.hasTxPower && !l.place("tx power", adv.TypeTxPower, []byte{byte(a.txPower)}) {
+		l.omit("tx power")
+	}
+
+	if name := []byte(a.localName); len(name) > 0 && !l.place("local name", adv.TypeCompleteName, name) {
+		l.truncate("local name", adv.TypeShortName, name, 1)
+	}
+
+	payload := AdvertisingPayload{
+		Advertising:  l.packets[0].Bytes(),
+		ScanResponse: l.packets[1].Bytes(),
+		Fields:       l.fields,
+	}
+	if len(l.omitted) > 0 {
+		return payload, &AdvertisingError{Fields: l.omitted}
+	}
+	return payload, nil
+}
+
+// start advertising with name, service uuids, service data, manufacturer data and tx power
+//
+// The payload is validated against the legacy 31 byte budget first; the
+// returned layout reports fields that were moved to the scan response or
+// truncated. Nothing is advertised if a field had to be omitted.
+//
+// The fields are sent as laid out, a shortened name or an incomplete uuid
+// list is advertised as such. blued places them in the packets itself, so
+// on OSX the layout tells what is advertised but not where.
+func (ble *BLE) StartAdvertisingData(a *AdvertisingData) (AdvertisingPayload, error) {
+	payload, err := a.Build()
+	if err != nil {
+		return payload, err
+	}
+	ble.sendCBMsg(startAdvertisingMsg, advertisingArgs(payload))
+	return payload, nil
+}
+
+// advertisingArgs converts the fields of a payload to the arguments of
+// startAdvertising
+func advertisingArgs(payload AdvertisingPayload) xpc.Dict {
+	p, _ := adv.Parse(payload.Advertising)
+	if s, err := adv.Parse(payload.ScanResponse); err == nil {
+		p = append(p, s...)
+	}
+
+	args := xpc.Dict{}
+	if name, _ := p.LocalName(); name != "" {
+		args["kCBAdvDataLocalName"] = name
+	}
+	if uuids, _ := p.UUIDs(); len(uuids) > 0 {
+		list := make([][]byte, len(uuids))
+		for i, uuid := range uuids {
+			list[i] = uuid
+		}
+		args["kCBAdvDataServiceUUIDs"] = list
+	}
+	if sd := p.ServiceData(); len(sd) > 0 {
+		sdata := xpc.Array{}
+		for _, d := range sd {
+			sdata = append(sdata, []byte(d.UUID), d.Data)
+		}
+		args["kCBAdvDataServiceData"] = sdata
+	}
+	// blued takes Apple's data as AD structures and that of others raw
+	for _, d := range p.ManufacturerData() {
+		if d.CompanyID == appleCompanyID {
+			var mp adv.Packet
+			mp.AddManufacturerData(d.CompanyID, d.Data)
+			args["kCBAdvDataAppleMfgData"] = mp.Bytes()
+		} else {
+			args["kCBAdvDataManufacturerData"] = append([]byte{byte(d.CompanyID), byte(d.CompanyID >> 8)}, d.Data...)
+		}
+	}
+	if tx, ok := p.TxPower(); ok {
+		args["kCBAdvDataTxPowerLevel"] = int(tx)
+	}
+	return args
+}
@@ -0,0 +1,128 @@
+package goble
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/dim13/goble/xpc"
+)
+
+func TestAdvertisingDataBuild(t *testing.T) {
+	vendor := xpc.MustUUID("6e400001-b5a3-f393-e0a9-e50e24dcca9e")
+	a := NewAdvertisingData().
+		SetLocalName("goble sensor with a long name").
+		AddServiceUUID(UUID16(0x180f), vendor).
+		SetTxPower(-4)
+
+	p, err := a.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0x02, 0x01, 0x06,
+		0x03, 0x03, 0x0f, 0x18,
+		0x11, 0x07, 0x9e, 0xca, 0xdc, 0x24, 0x0e, 0xe5, 0xa9, 0xe0, 0x93, 0xf3, 0xa3, 0xb5, 0x01, 0x00, 0x40, 0x6e,
+		0x02, 0x0a, 0xfc,
+	}
+	if !bytes.Equal(p.Advertising, want) {
+		t.Errorf("advertising: got %x, want %x", p.Advertising, want)
+	}
+	if want := append([]byte{30, 0x09}, "goble sensor with a long name"...); !bytes.Equal(p.ScanResponse, want) {
+		t.Errorf("scan response: got %x, want %x", p.ScanResponse, want)
+	}
+	moved := p.Moved()
+	if len(moved) != 1 || moved[0].Field != "local name" || moved[0].Placement != InScanResponse {
+		t.Errorf("got %v", moved)
+	}
+}
+
+func TestAdvertisingDataTruncate(t *testing.T) {
+	const name = "a name that is much too long for any packet"
+	a := NewAdvertisingData().
+		SetManufacturerData(0x004c, make([]byte, 24)).
+		SetLocalName(name)
+
+	p, err := a.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Advertising) != 31 || len(p.ScanResponse) != 31 {
+		t.Fatalf("got %d %d bytes", len(p.Advertising), len(p.ScanResponse))
+	}
+	if p.ScanResponse[1] != 0x08 || string(p.ScanResponse[2:]) != name[:29] {
+		t.Errorf("got %x", p.ScanResponse)
+	}
+
+	if p.Fields[1].Placement != Truncated {
+		t.Errorf("got %v", p.Fields)
+	}
+
+	_, err = NewAdvertisingData().SetManufacturerData(0xffff, make([]byte, 28)).Build()
+	if e, ok := err.(*AdvertisingError); !ok || len(e.Fields) != 1 {
+		t.Errorf("got %v", err)
+	}
+}
+
+// recorder keeps the messages sent to blued
+type recorder struct {
+	messages []xpc.Dict
+}
+
+func (r *recorder) Send(msg interface{}, verbose bool) {
+	r.messages = append(r.messages, msg.(xpc.Dict))
+}
+
+func TestStartAdvertisingData(t *testing.T) {
+	r := &recorder{}
+	ble := NewWithTransport(r, "")
+	const name = "a name that is much too long for any packet"
+	vendor := xpc.MustUUID("6e400001-b5a3-f393-e0a9-e50e24dcca9e")
+	if _, err := ble.StartAdvertisingData(NewAdvertisingData().
+		AddServiceUUID(UUID16(0x180f), vendor).
+		SetManufacturerData(0x004c, make([]byte, 10)).
+		SetLocalName(name)); err != nil {
+		t.Fatal(err)
+	}
+	args := r.messages[0]["kCBMsgArgs"].(xpc.Dict)
+	if got := args["kCBAdvDataLocalName"]; got != name[:15] {
+		t.Errorf("got name %q, want %q", got, name[:15])
+	}
+	if uuids := args["kCBAdvDataServiceUUIDs"].([][]byte); len(uuids) != 2 || !bytes.Equal(uuids[0], []byte{0x18, 0x0f}) {
+		t.Errorf("got uuids %x", uuids)
+	}
+	if md := args["kCBAdvDataAppleMfgData"].([]byte); len(md) != 14 {
+		t.Errorf("got manufacturer data %x", md)
+	}
+}
+
+func TestAdvertisingDataTruncateRune(t *testing.T) {
+	name := strings.Repeat("é", 20)
+	p, err := NewAdvertisingData().
+		SetManufacturerData(0x004c, make([]byte, 24)).
+		SetLocalName(name).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	short := p.ScanResponse[2:]
+	if !utf8.Valid(short) || string(short) != name[:28] {
+		t.Errorf("got name %q", short)
+	}
+}
+
+func TestAdvertisingArgsManufacturer(t *testing.T) {
+	p, err := NewAdvertisingData().SetManufacturerData(0x0059, []byte{1, 2}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := advertisingArgs(p)
+	if _, ok := args["kCBAdvDataAppleMfgData"]; ok {
+		t.Error("nordic data sent as apple data")
+	}
+	if md, _ := args["kCBAdvDataManufacturerData"].([]byte); !bytes.Equal(md, []byte{0x59, 0, 1, 2}) {
+		t.Errorf("got manufacturer data %x", md)
+	}
+}
@@ -0,0 +1,90 @@
+package goble
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The lookup tables are generated from subsets of the SIG assigned numbers,
+// see assigned_numbers/README.md for replacing them with the complete files.
+//go:generate go run gen.go
+
+// AssignedNumber is the name and type of a Bluetooth SIG assigned number
+type AssignedNumber struct {
+	Name string // e.g. "Battery Level"
+	Type string // e.g. "org.bluetooth.characteristic.battery_level"
+}
+
+// shortUUID normalizes a uuid to the lowercase hex form used as lookup key,
+// reducing uuids derived from the Bluetooth Base UUID to 16-bit
+func shortUUID(uuid string) string {
+	s := strings.ToLower(strings.Replace(strings.TrimPrefix(uuid, "0x"), "-", "", -1))
+	if len(s) == 32 && strings.HasPrefix(s, "0000") && strings.HasSuffix(s, "00001000800000805f9b34fb") {
+		return s[4:8]
+	}
+	return s
+}
+
+// EqualUUID reports whether two uuids are the same, ignoring case, dashes
+// and the 16-bit or 128-bit form of assigned numbers
+func EqualUUID(a, b string) bool {
+	return shortUUID(a) == shortUUID(b)
+}
+
+// LookupService returns the name and type of a service uuid
+func LookupService(uuid string) (AssignedNumber, bool) {
+	if n, ok := registered(registry.services, uuid); ok {
+		return n, true
+	}
+	key := shortUUID(uuid)
+	if n, ok := knownServices[key]; ok {
+		return n, true
+	}
+	n, ok := knownMembers[key]
+	return n, ok
+}
+
+// LookupCharacteristic returns the name and type of a characteristic uuid
+func LookupCharacteristic(uuid string) (AssignedNumber, bool) {
+	if n, ok := registered(registry.characteristics, uuid); ok {
+		return n, true
+	}
+	n, ok := knownCharacteristics[shortUUID(uuid)]
+	return n, ok
+}
+
+// LookupDescriptor returns the name and type of a descriptor uuid
+func LookupDescriptor(uuid string) (AssignedNumber, bool) {
+	if n, ok := registered(registry.descriptors, uuid); ok {
+		return n, true
+	}
+	n, ok := knownDescriptors[shortUUID(uuid)]
+	return n, ok
+}
+
+// LookupUnit returns the name and type of a unit (e.g. 0x272f for degree Celsius)
+func LookupUnit(unit uint16) (AssignedNumber, bool) {
+	n, ok := knownUnits[unit]
+	return n, ok
+}
+
+// AppearanceName returns the name of an appearance value, falling back to
+// its category for unknown subcategories
+func AppearanceName(appearance uint16) string {
+	if name, ok := knownAppearances[appearance]; ok {
+		return name
+	}
+	if name, ok := knownAppearances[appearance&^0x3f]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown appearance %#04x", appearance)
+}
+
+// CompanyName returns the name of a Bluetooth SIG company identifier, ids
+// missing from assigned_numbers are reported as unknown
+func CompanyName(id uint16) string {
+	if name, ok := knownCompanies[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown company %#04x", id)
+}
@@ -0,0 +1,62 @@
+package goble
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	testCases := []struct {
+		lookup func(string) (AssignedNumber, bool)
+		uuid   string
+		name   string
+	}{
+		{LookupService, "180d", "Heart Rate"},
+		{LookupService, "0x180F", "Battery Service"},
+		{LookupService, "0000181c-0000-1000-8000-00805f9b34fb", "User Data"},
+		{LookupService, "feaa", "Google LLC"},
+		{LookupCharacteristic, "2a37", "Heart Rate Measurement"},
+		{LookupCharacteristic, "00002A19-0000-1000-8000-00805F9B34FB", "Battery Level"},
+		{LookupDescriptor, "2902", "Client Characteristic Configuration"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.uuid, func(t *testing.T) {
+			n, ok := tc.lookup(tc.uuid)
+			if !ok || n.Name != tc.name {
+				t.Errorf("got %q %v, want %q", n.Name, ok, tc.name)
+			}
+		})
+	}
+	if n, ok := LookupService("6e400001b5a3f393e0a9e50e24dcca9e"); ok {
+		t.Errorf("vendor uuid found as %q", n.Name)
+	}
+}
+
+func TestLookupUnit(t *testing.T) {
+	if n, ok := LookupUnit(0x272f); !ok || n.Type != "org.bluetooth.unit.celsius_temperature_degree_celsius" {
+		t.Errorf("got %v %v", n, ok)
+	}
+}
+
+func TestAppearanceName(t *testing.T) {
+	testCases := []struct {
+		appearance uint16
+		name       string
+	}{
+		{0x0341, "Heart Rate Belt"},
+		{0x0340, "Heart Rate Sensor"},
+		{0x037f, "Heart Rate Sensor"},
+		{0xffc0, "unknown appearance 0xffc0"},
+	}
+	for _, tc := range testCases {
+		if got := AppearanceName(tc.appearance); got != tc.name {
+			t.Errorf("%#04x: got %q, want %q", tc.appearance, got, tc.name)
+		}
+	}
+}
+
+func TestCompanyName(t *testing.T) {
+	if got, want := CompanyName(0x004c), "Apple, Inc."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := CompanyName(0xfffe), "unknown company 0xfffe"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,419 @@
+package att
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var custom = xpc.MustUUID("6e400001b5a3f393e0a9e50e24dcca9e")
+
+func TestPDU(t *testing.T) {
+	testCases := []struct {
+		pdu PDU
+		hex string
+	}{
+		{ErrorResponse{Request: OpReadRequest, Handle: 0x0003, Code: goble.ErrReadNotPermitted}, "010a030002"},
+		{ExchangeMTURequest{MTU: 517}, "020502"},
+		{ExchangeMTUResponse{MTU: 23}, "031700"},
+		{FindInformationRequest{Start: 1, End: 0xffff}, "040100ffff"},
+		{FindInformationResponse{Information: []Information{{1, goble.PrimaryServiceUUID}, {2, goble.CharacteristicUUID}}}, "05010100002802000328"},
+		{FindInformationResponse{Information: []Information{{3, custom}}}, "0502" + "0300" + "9ecadc240ee5a9e093f3a3b50100406e"},
+		{FindByTypeValueRequest{Start: 1, End: 0xffff, Type: 0x2800, Value: []byte{0x0f, 0x18}}, "060100ffff00280f18"},
+		{FindByTypeValueResponse{Handles: []HandleRange{{1, 4}, {5, 9}}}, "07010004000500" + "0900"},
+		{ReadByTypeRequest{Start: 1, End: 4, Type: goble.CharacteristicUUID}, "08010004000328"},
+		{ReadByTypeResponse{Data: []AttributeData{{2, mustHex("120300192a")}}}, "0907" + "0200120300192a"},
+		{ReadRequest{Handle: 3}, "0a0300"},
+		{ReadResponse{Value: []byte{0x64}}, "0b64"},
+		{ReadBlobRequest{Handle: 3, Offset: 22}, "0c03001600"},
+		{ReadBlobResponse{Value: []byte{1, 2}}, "0d0102"},
+		{ReadMultipleRequest{Handles: []uint16{3, 5}}, "0e03000500"},
+		{ReadMultipleResponse{Values: []byte{1, 2, 3}}, "0f010203"},
+		{ReadByGroupTypeRequest{Start: 1, End: 0xffff, Type: goble.PrimaryServiceUUID}, "100100ffff0028"},
+		{ReadByGroupTypeResponse{Data: []GroupData{{1, 4, []byte{0x0f, 0x18}}, {5, 9, []byte{0x0a, 0x18}}}}, "1106" + "010004000f18" + "050009000a18"},
+		{WriteRequest{Handle: 4, Value: []byte{1, 0}}, "1204000100"},
+		{WriteResponse{}, "13"},
+		{WriteCommand{Handle: 7, Value: []byte("hi")}, "5207006869"},
+		{SignedWriteCommand{Handle: 7, Value: []byte{1}, Signature: [12]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}}, "d2070001" + "0102030405060708090a0b0c"},
+		{PrepareWriteRequest{Handle: 7, Offset: 18, Value: []byte{1}}, "160700120001"},
+		{PrepareWriteResponse{Handle: 7, Offset: 18, Value: []byte{1}}, "170700120001"},
+		{ExecuteWriteRequest{Execute: true}, "1801"},
+		{ExecuteWriteRequest{}, "1800"},
+		{ExecuteWriteResponse{}, "19"},
+		{ReadMultipleVariableRequest{Handles: []uint16{3, 5}}, "2003000500"},
+		{ReadMultipleVariableResponse{Values: [][]byte{{1}, {2, 3}}}, "21" + "010001" + "02000203"},
+		{MultipleHandleValueNotification{Values: []AttributeData{{3, []byte{1}}, {5, []byte{2, 3}}}}, "23" + "0300010001" + "050002000203"},
+		{HandleValueNotification{Handle: 3, Value: []byte{0x64}}, "1b030064"},
+		{HandleValueIndication{Handle: 3, Value: []byte{0x64}}, "1d030064"},
+		{HandleValueConfirmation{}, "1e"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.pdu.Opcode().String(), func(t *testing.T) {
+			b := tc.pdu.Bytes()
+			if got := hex.EncodeToString(b); got != tc.hex {
+				t.Errorf("got %v, want %v", got, tc.hex)
+			}
+			p, err := Decode(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(p, tc.pdu) {
+				t.Errorf("got %+v, want %+v", p, tc.pdu)
+			}
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	testCases := []struct {
+		hex string
+		err error
+	}{
+		{"", goble.ErrInvalidPDU},
+		{"ff", goble.ErrRequestNotSupported},
+		{"0a03", goble.ErrInvalidPDU},
+		{"0503010000", goble.ErrInvalidPDU},
+		{"0801000400032800", goble.ErrInvalidPDU},
+		{"1802", goble.ErrInvalidPDU},
+		{"230300050001", goble.ErrInvalidPDU},
+	}
+	for _, tc := range testCases {
+		if _, err := Decode(mustHex(tc.hex)); err != tc.err {
+			t.Errorf("%v: got %v, want %v", tc.hex, err, tc.err)
+		}
+	}
+}
+
+// pipe connects a client and a server over net.Pipe
+func pipe(t *testing.T, services ...goble.Service) (*Client, *Server, func()) {
+	a, b := net.Pipe()
+	central := xpc.MustUUID("00000000000000000000c01122334455")
+	s, err := NewServer(b, central, services...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve() }()
+	c := NewClient(a)
+	return c, s, func() {
+		c.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+		b.Close()
+	}
+}
+
+func TestClientServer(t *testing.T) {
+	long := bytes.Repeat([]byte("0123456789"), 10)
+	written := make(chan string, 2)
+
+	battery := goble.NewCharacteristic(goble.UUID16(0x2a19), goble.Read|goble.Notify, []byte{100})
+	battery.SetUserDescription("level")
+	name := goble.NewCharacteristic(goble.UUID16(0x2a00), goble.Read, nil)
+	name.HandleRead(func(central xpc.UUID, offset int) ([]byte, error) {
+		if offset > len(long) {
+			return nil, goble.ErrInvalidOffset
+		}
+		return long[offset:], nil
+	})
+	rx := goble.NewCharacteristic(custom, goble.Write|goble.WriteWithoutResponse|goble.Indicate, nil)
+	rx.HandleWrite(func(central xpc.UUID, data []byte, offset int, withoutResponse bool) error {
+		written <- string(data)
+		return nil
+	})
+	rx.HandleRead(func(central xpc.UUID, offset int) ([]byte, error) {
+		t.Error("read without Read property")
+		return nil, nil
+	})
+
+	c, s, stop := pipe(t,
+		goble.NewService(goble.UUID16(0x180f), battery),
+		goble.NewService(goble.UUID16(0x1800), name),
+		goble.NewService(custom, rx),
+	)
+	defer stop()
+
+	if mtu, err := c.ExchangeMTU(64); err != nil || mtu != 64 || s.MTU() != 64 {
+		t.Fatalf("mtu: got %v %v %v", mtu, s.MTU(), err)
+	}
+
+	// services, 16-bit first
+	groups, err := c.ReadByGroupType(1, 0xffff, goble.PrimaryServiceUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GroupData{{1, 4, []byte{0x01, 0x18}}, {5, 9, []byte{0x0f, 0x18}}, {10, 12, []byte{0x00, 0x18}}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("got %v, want %v", groups, want)
+	}
+	groups, err = c.ReadByGroupType(13, 0xffff, goble.PrimaryServiceUUID)
+	if err != nil || len(groups) != 1 || groups[0].End != 16 {
+		t.Fatalf("got %v %v", groups, err)
+	}
+	if _, err := c.ReadByGroupType(17, 0xffff, goble.PrimaryServiceUUID); err != goble.ErrAttributeNotFound {
+		t.Errorf("got %v, want %v", err, goble.ErrAttributeNotFound)
+	}
+	if _, err := c.ReadByGroupType(1, 0xffff, goble.CharacteristicUUID); err != goble.ErrUnsupportedGroupType {
+		t.Errorf("got %v, want %v", err, goble.ErrUnsupportedGroupType)
+	}
+	found, err := c.FindByTypeValue(1, 0xffff, 0x2800, []byte{0x00, 0x18})
+	if err != nil || !reflect.DeepEqual(found, []HandleRange{{10, 12}}) {
+		t.Errorf("got %v %v", found, err)
+	}
+
+	// characteristics and descriptors
+	chars, err := c.ReadByType(5, 9, goble.CharacteristicUUID)
+	if err != nil || !reflect.DeepEqual(chars, []AttributeData{{6, mustHex("120700192a")}}) {
+		t.Errorf("got %v %v", chars, err)
+	}
+	info, err := c.FindInformation(8, 9)
+	if err != nil || !reflect.DeepEqual(info, []Information{{8, goble.ClientConfigurationUUID}, {9, goble.UserDescriptionUUID}}) {
+		t.Errorf("got %v %v", info, err)
+	}
+	if handle, ok := s.Handle(custom); !ok || handle != 15 {
+		t.Errorf("got %v, want 15", handle)
+	}
+
+	// reads
+	if v, err := c.Read(7); err != nil || !bytes.Equal(v, []byte{100}) {
+		t.Errorf("got %v %v", v, err)
+	}
+	if v, err := c.Read(9); err != nil || string(v) != "level" {
+		t.Errorf("got %q %v", v, err)
+	}
+	v, err := c.Read(12)
+	if err != nil || len(v) != 63 {
+		t.Fatalf("got %v %v", len(v), err)
+	}
+	if v, err := c.ReadBlob(12, 50); err != nil || string(v) != string(long[50:]) {
+		t.Errorf("got %q %v", v, err)
+	}
+	if _, err := c.ReadBlob(12, 101); err != goble.ErrInvalidOffset {
+		t.Errorf("got %v, want %v", err, goble.ErrInvalidOffset)
+	}
+	if v, err := c.ReadMultiple(7, 9); err != nil || string(v) != "dlevel" {
+		t.Errorf("got %q %v", v, err)
+	}
+	if v, err := c.ReadMultipleVariable(7, 9); err != nil || !reflect.DeepEqual(v, [][]byte{{100}, []byte("level")}) {
+		t.Errorf("got %q %v", v, err)
+	}
+	if _, err := c.Read(15); err != goble.ErrReadNotPermitted {
+		t.Errorf("got %v, want %v", err, goble.ErrReadNotPermitted)
+	}
+	if _, err := c.Read(99); err != goble.ErrInvalidHandle {
+		t.Errorf("got %v, want %v", err, goble.ErrInvalidHandle)
+	}
+
+	// writes
+	if err := c.Write(15, []byte("hello")); err != nil {
+		t.Error(err)
+	}
+	if err := c.WriteCommand(15, []byte("world")); err != nil {
+		t.Error(err)
+	}
+	for _, want := range []string{"hello", "world"} {
+		if got := <-written; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if err := c.Write(7, []byte{1}); err != goble.ErrWriteNotPermitted {
+		t.Errorf("got %v, want %v", err, goble.ErrWriteNotPermitted)
+	}
+	if err := c.Write(8, []byte{1}); err != goble.ErrInvalidAttributeValueLength {
+		t.Errorf("got %v, want %v", err, goble.ErrInvalidAttributeValueLength)
+	}
+
+	// notifications and indications
+	type value struct {
+		handle     uint16
+		data       string
+		indication bool
+	}
+	values := make(chan value, 2)
+	subscribed := make(chan value, 2)
+	s.HandleSubscription(func(handle uint16, config goble.ClientConfiguration) {
+		subscribed <- value{handle: handle, data: config.String()}
+	})
+	c.HandleNotification(func(handle uint16, data []byte, indication bool) {
+		values <- value{handle, string(data), indication}
+	})
+	if err := s.Notify(7, []byte{99}); err != ErrNotSubscribed {
+		t.Errorf("got %v, want %v", err, ErrNotSubscribed)
+	}
+	if err := c.Write(8, []byte{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Read(8); err != nil || !bytes.Equal(v, []byte{1, 0}) {
+		t.Errorf("got %v %v", v, err)
+	}
+	if err := c.Write(16, []byte{2, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Subscription(15) != goble.Indications {
+		t.Errorf("got %v, want %v", s.Subscription(15), goble.Indications)
+	}
+	for _, want := range []value{{7, "notifications", false}, {15, "indications", false}} {
+		if got := <-subscribed; got != want {
+			t.Errorf("got subscription %v, want %v", got, want)
+		}
+	}
+	if err := s.Notify(7, []byte{99}); err != nil {
+		t.Error(err)
+	}
+	if err := s.Notify(15, []byte("ping")); err != nil {
+		t.Error(err)
+	}
+	for _, want := range []value{{7, "c", false}, {15, "ping", true}} {
+		select {
+		case got := <-values:
+			if got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestClosed(t *testing.T) {
+	c, _, stop := pipe(t)
+	stop()
+	if _, err := c.Read(1); err == nil {
+		t.Error("read on closed bearer")
+	}
+}
+
+func TestServiceChanged(t *testing.T) {
+	battery := goble.NewService(goble.UUID16(0x180f),
+		goble.NewCharacteristic(goble.UUID16(0x2a19), goble.Read|goble.Notify, []byte{100}))
+	c, s, stop := pipe(t, battery)
+	defer stop()
+
+	changed := make(chan []byte, 1)
+	c.HandleNotification(func(handle uint16, data []byte, indication bool) {
+		if handle == 3 && indication {
+			changed <- data
+		}
+	})
+	if err := c.Write(4, []byte{2, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(8, []byte{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	// unchanged services are not indicated
+	if err := s.SetServices(battery); err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Error("unchanged services indicated")
+	}
+	rx := goble.NewCharacteristic(custom, goble.Read, []byte("rx"))
+	if err := s.SetServices(battery, goble.NewService(custom, rx)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-changed:
+		if want := goble.ServiceChanged(9, 11); !bytes.Equal(v, want) {
+			t.Errorf("got %x, want %x", v, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	if s.Subscription(7) != goble.Notifications {
+		t.Error("subscription outside of the changed range dropped")
+	}
+	if v, err := c.Read(11); err != nil || string(v) != "rx" {
+		t.Errorf("got %q %v", v, err)
+	}
+}
+
+func TestLong(t *testing.T) {
+	var reads int
+	long := goble.NewCharacteristic(custom, goble.Read|goble.Write, nil)
+	long.SetUserDescription("long")
+	long.HandleReadValue(func(central xpc.UUID) ([]byte, error) {
+		reads++
+		return bytes.Repeat([]byte{byte(reads)}, 300), nil
+	})
+	type write struct {
+		data   string
+		offset int
+	}
+	written := make(chan write, 2)
+	long.HandleWrite(func(central xpc.UUID, data []byte, offset int, withoutResponse bool) error {
+		written <- write{string(data), offset}
+		return nil
+	})
+	level := goble.NewCharacteristic(goble.UUID16(0x2a19), goble.Read, []byte{100})
+	c, _, stop := pipe(t, goble.NewService(custom, long, level))
+	defer stop()
+
+	// 5 service, 6 declaration, 7 value, 8 description, 9 declaration, 10 value
+	v, err := c.ReadLong(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, bytes.Repeat([]byte{1}, 300)) || reads != 1 {
+		t.Errorf("got %d bytes after %d reads", len(v), reads)
+	}
+	if v, err := c.ReadLong(8); err != nil || string(v) != "long" {
+		t.Errorf("got %q %v", v, err)
+	}
+	if _, err := c.ReadBlob(8, 0); err != goble.ErrAttributeNotLong {
+		t.Errorf("got %v, want %v", err, goble.ErrAttributeNotLong)
+	}
+
+	value := string(bytes.Repeat([]byte("goble"), 20))
+	if err := c.WriteLong(7, []byte(value)); err != nil {
+		t.Fatal(err)
+	}
+	if w := <-written; w.data != value || w.offset != 0 {
+		t.Errorf("got %q at %v", w.data, w.offset)
+	}
+
+	// queued parts are verified by the server at execution
+	if _, err := c.PrepareWrite(10, 0, []byte{1}); err != goble.ErrWriteNotPermitted {
+		t.Errorf("got %v, want %v", err, goble.ErrWriteNotPermitted)
+	}
+	c.PrepareWrite(7, 0, []byte("ab"))
+	c.PrepareWrite(7, 3, []byte("cd"))
+	if err := c.ExecuteWrite(true); err != goble.ErrInvalidOffset {
+		t.Errorf("got %v, want %v", err, goble.ErrInvalidOffset)
+	}
+	for i := 0; i < prepareQueueSize; i++ {
+		if _, err := c.PrepareWrite(7, uint16(i), []byte{'x'}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.PrepareWrite(7, prepareQueueSize, []byte{'x'}); err != goble.ErrPrepareQueueFull {
+		t.Errorf("got %v, want %v", err, goble.ErrPrepareQueueFull)
+	}
+	if err := c.ExecuteWrite(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReliableWrite(AttributeData{Handle: 7, Value: []byte("reliable")}); err != nil {
+		t.Fatal(err)
+	}
+	if w := <-written; w.data != "reliable" {
+		t.Errorf("got %q, want reliable", w.data)
+	}
+	if len(written) != 0 {
+		t.Error("cancelled writes executed")
+	}
+}
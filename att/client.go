@@ -0,0 +1,387 @@
+package att
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+// ErrPrepareMismatch reports a prepared write echoed with other data, the
+// queue is cancelled
+var ErrPrepareMismatch = errors.New("att: prepared write mismatch")
+
+// NotificationHandler receives notified and indicated values, indications
+// are confirmed when it returns
+type NotificationHandler func(handle uint16, value []byte, indication bool)
+
+// Client runs requests against a server, one at a time. The bearer
+// delivers one PDU per Read and sends one per Write.
+type Client struct {
+	rw io.ReadWriter
+
+	mu  sync.Mutex // one outstanding request
+	rsp chan PDU
+
+	wmu sync.Mutex // serializes writes to the bearer
+
+	smu     sync.Mutex
+	mtu     int
+	notify  NotificationHandler
+	request Opcode // outstanding request
+
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+// NewClient starts reading PDUs from the bearer
+func NewClient(rw io.ReadWriter) *Client {
+	c := &Client{
+		rw:   rw,
+		rsp:  make(chan PDU, 1),
+		mtu:  DefaultMTU,
+		done: make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Close closes the bearer if it is an io.Closer
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if cl, ok := c.rw.(io.Closer); ok {
+			err = cl.Close()
+		}
+	})
+	return err
+}
+
+// Done is closed when the bearer ends
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that ended the bearer
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// MTU returns the ATT MTU of the bearer
+func (c *Client) MTU() int {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	return c.mtu
+}
+
+// HandleNotification sets the handler of notifications and indications
+func (c *Client) HandleNotification(h NotificationHandler) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	c.notify = h
+}
+
+func (c *Client) handler() NotificationHandler {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	return c.notify
+}
+
+func (c *Client) pending() Opcode {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	return c.request
+}
+
+func (c *Client) setPending(op Opcode) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	c.request = op
+}
+
+func (c *Client) send(p PDU) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.rw.Write(p.Bytes()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// run reads PDUs until the bearer fails
+func (c *Client) run() {
+	defer close(c.done)
+	buf := make([]byte, MaxMTU)
+	for {
+		n, err := c.rw.Read(buf)
+		if err != nil {
+			c.err = ErrClosed
+			if err != io.EOF {
+				c.err = err
+			}
+			return
+		}
+		if n == 0 {
+			continue
+		}
+		p, err := Decode(buf[:n])
+		if err != nil {
+			op := Opcode(buf[0])
+			switch pending := c.pending(); {
+			case op == pending.Response() || op == OpErrorResponse && pending != 0:
+				c.dispatch(ErrorResponse{Request: pending, Code: goble.ErrInvalidPDU})
+			case !op.Command() && op.Response() != 0:
+				// the server role is not served here
+				c.send(ErrorResponse{Request: op, Code: err.(goble.ATTError)})
+			}
+			continue
+		}
+		c.dispatch(p)
+	}
+}
+
+func (c *Client) dispatch(p PDU) {
+	switch p := p.(type) {
+	case HandleValueNotification:
+		if h := c.handler(); h != nil {
+			h(p.Handle, p.Value, false)
+		}
+	case HandleValueIndication:
+		if h := c.handler(); h != nil {
+			h(p.Handle, p.Value, true)
+		}
+		c.send(HandleValueConfirmation{})
+	case HandleValueConfirmation:
+	case MultipleHandleValueNotification:
+		if h := c.handler(); h != nil {
+			for _, v := range p.Values {
+				h(v.Handle, v.Value, false)
+			}
+		}
+	default:
+		switch op := p.Opcode(); {
+		case op.Response() != 0:
+			c.send(ErrorResponse{Request: op, Code: goble.ErrRequestNotSupported})
+		case op.Command():
+		default:
+			select {
+			case c.rsp <- p:
+			default: // unsolicited
+			}
+		}
+	}
+}
+
+// Request sends a request and waits for its response, error responses
+// return their goble.ATTError
+func (c *Client) Request(req PDU) (PDU, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.rsp: // stale
+	default:
+	}
+	c.setPending(req.Opcode())
+	defer c.setPending(0)
+	if err := c.send(req); err != nil {
+		return nil, err
+	}
+	t := time.NewTimer(transactionTimeout)
+	defer t.Stop()
+	select {
+	case p := <-c.rsp:
+		if e, ok := p.(ErrorResponse); ok && e.Request == req.Opcode() {
+			return nil, e.Code
+		}
+		if p.Opcode() != req.Opcode().Response() {
+			return nil, goble.ErrInvalidPDU
+		}
+		return p, nil
+	case <-c.done:
+		return nil, c.err
+	case <-t.C:
+		c.Close()
+		return nil, ErrTimeout
+	}
+}
+
+// Command sends a PDU without response
+func (c *Client) Command(cmd PDU) error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+	}
+	return c.send(cmd)
+}
+
+// ExchangeMTU announces the receive MTU and returns the ATT MTU
+func (c *Client) ExchangeMTU(mtu int) (int, error) {
+	p, err := c.Request(ExchangeMTURequest{MTU: uint16(mtu)})
+	if err != nil {
+		return 0, err
+	}
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	c.mtu = negotiate(min(mtu, int(p.(ExchangeMTUResponse).MTU)))
+	return c.mtu, nil
+}
+
+// FindInformation lists the attribute types of a handle range
+func (c *Client) FindInformation(start, end uint16) ([]Information, error) {
+	p, err := c.Request(FindInformationRequest{Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+	return p.(FindInformationResponse).Information, nil
+}
+
+// FindByTypeValue finds attributes of a 16-bit type with a value
+func (c *Client) FindByTypeValue(start, end, typ uint16, value []byte) ([]HandleRange, error) {
+	p, err := c.Request(FindByTypeValueRequest{Start: start, End: end, Type: typ, Value: value})
+	if err != nil {
+		return nil, err
+	}
+	return p.(FindByTypeValueResponse).Handles, nil
+}
+
+// ReadByType reads attributes of a type in a handle range
+func (c *Client) ReadByType(start, end uint16, typ xpc.UUID) ([]AttributeData, error) {
+	p, err := c.Request(ReadByTypeRequest{Start: start, End: end, Type: typ})
+	if err != nil {
+		return nil, err
+	}
+	return p.(ReadByTypeResponse).Data, nil
+}
+
+// ReadByGroupType reads the group declarations of a handle range
+func (c *Client) ReadByGroupType(start, end uint16, typ xpc.UUID) ([]GroupData, error) {
+	p, err := c.Request(ReadByGroupTypeRequest{Start: start, End: end, Type: typ})
+	if err != nil {
+		return nil, err
+	}
+	return p.(ReadByGroupTypeResponse).Data, nil
+}
+
+// Read reads up to MTU-1 bytes of a value
+func (c *Client) Read(handle uint16) ([]byte, error) {
+	p, err := c.Request(ReadRequest{Handle: handle})
+	if err != nil {
+		return nil, err
+	}
+	return p.(ReadResponse).Value, nil
+}
+
+// ReadBlob reads up to MTU-1 bytes of a value from offset
+func (c *Client) ReadBlob(handle, offset uint16) ([]byte, error) {
+	p, err := c.Request(ReadBlobRequest{Handle: handle, Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+	return p.(ReadBlobResponse).Value, nil
+}
+
+// ReadMultiple reads concatenated values of known length
+func (c *Client) ReadMultiple(handles ...uint16) ([]byte, error) {
+	p, err := c.Request(ReadMultipleRequest{Handles: handles})
+	if err != nil {
+		return nil, err
+	}
+	return p.(ReadMultipleResponse).Values, nil
+}
+
+// ReadMultipleVariable reads several values of any length
+func (c *Client) ReadMultipleVariable(handles ...uint16) ([][]byte, error) {
+	p, err := c.Request(ReadMultipleVariableRequest{Handles: handles})
+	if err != nil {
+		return nil, err
+	}
+	return p.(ReadMultipleVariableResponse).Values, nil
+}
+
+// Write writes a value with response
+func (c *Client) Write(handle uint16, value []byte) error {
+	_, err := c.Request(WriteRequest{Handle: handle, Value: value})
+	return err
+}
+
+// WriteCommand writes a value without response
+func (c *Client) WriteCommand(handle uint16, value []byte) error {
+	return c.Command(WriteCommand{Handle: handle, Value: value})
+}
+
+// PrepareWrite queues part of a value and returns the echoed part
+func (c *Client) PrepareWrite(handle, offset uint16, value []byte) (PrepareWriteResponse, error) {
+	p, err := c.Request(PrepareWriteRequest{Handle: handle, Offset: offset, Value: value})
+	if err != nil {
+		return PrepareWriteResponse{}, err
+	}
+	return p.(PrepareWriteResponse), nil
+}
+
+// ExecuteWrite writes or cancels the queued parts
+func (c *Client) ExecuteWrite(execute bool) error {
+	_, err := c.Request(ExecuteWriteRequest{Execute: execute})
+	return err
+}
+
+// ReadLong reads a value of any length, continuing a full read response
+// with Read Blob requests
+func (c *Client) ReadLong(handle uint16) ([]byte, error) {
+	v, err := c.Read(handle)
+	if err != nil {
+		return nil, err
+	}
+	value := v
+	for len(v) == c.MTU()-1 && len(value) < MaxValueLength {
+		v, err = c.ReadBlob(handle, uint16(len(value)))
+		if err == goble.ErrAttributeNotLong {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, v...)
+	}
+	return value, nil
+}
+
+// WriteLong writes a value of any length, with a write request if it fits
+// or with prepared writes otherwise
+func (c *Client) WriteLong(handle uint16, value []byte) error {
+	if len(value) <= c.MTU()-3 {
+		return c.Write(handle, value)
+	}
+	return c.ReliableWrite(AttributeData{Handle: handle, Value: value})
+}
+
+// ReliableWrite queues values in parts with prepared writes, verifies each
+// echoed part and writes them all at once. On failure the queue is
+// cancelled.
+func (c *Client) ReliableWrite(values ...AttributeData) error {
+	n := c.MTU() - 5
+	for _, v := range values {
+		for offset := 0; offset == 0 || offset < len(v.Value); offset += n {
+			part := v.Value[offset:min(offset+n, len(v.Value))]
+			rsp, err := c.PrepareWrite(v.Handle, uint16(offset), part)
+			if err == nil && (rsp.Handle != v.Handle || rsp.Offset != uint16(offset) || !bytes.Equal(rsp.Value, part)) {
+				err = ErrPrepareMismatch
+			}
+			if err != nil {
+				c.ExecuteWrite(false)
+				return err
+			}
+		}
+	}
+	return c.ExecuteWrite(true)
+}
@@ -0,0 +1,763 @@
+// Package att implements the Bluetooth Attribute Protocol: encoding and
+// decoding of ATT PDUs, and a client and a server running on a bearer
+package att
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+// Opcode identifies an ATT PDU
+type Opcode byte
+
+const (
+	OpErrorResponse                   Opcode = 0x01
+	OpExchangeMTURequest              Opcode = 0x02
+	OpExchangeMTUResponse             Opcode = 0x03
+	OpFindInformationRequest          Opcode = 0x04
+	OpFindInformationResponse         Opcode = 0x05
+	OpFindByTypeValueRequest          Opcode = 0x06
+	OpFindByTypeValueResponse         Opcode = 0x07
+	OpReadByTypeRequest               Opcode = 0x08
+	OpReadByTypeResponse              Opcode = 0x09
+	OpReadRequest                     Opcode = 0x0a
+	OpReadResponse                    Opcode = 0x0b
+	OpReadBlobRequest                 Opcode = 0x0c
+	OpReadBlobResponse                Opcode = 0x0d
+	OpReadMultipleRequest             Opcode = 0x0e
+	OpReadMultipleResponse            Opcode = 0x0f
+	OpReadByGroupTypeRequest          Opcode = 0x10
+	OpReadByGroupTypeResponse         Opcode = 0x11
+	OpWriteRequest                    Opcode = 0x12
+	OpWriteResponse                   Opcode = 0x13
+	OpPrepareWriteRequest             Opcode = 0x16
+	OpPrepareWriteResponse            Opcode = 0x17
+	OpExecuteWriteRequest             Opcode = 0x18
+	OpExecuteWriteResponse            Opcode = 0x19
+	OpReadMultipleVariableRequest     Opcode = 0x20
+	OpReadMultipleVariableResponse    Opcode = 0x21
+	OpMultipleHandleValueNotification Opcode = 0x23
+	OpHandleValueNotification         Opcode = 0x1b
+	OpHandleValueIndication           Opcode = 0x1d
+	OpHandleValueConfirmation         Opcode = 0x1e
+	OpWriteCommand                    Opcode = 0x52
+	OpSignedWriteCommand              Opcode = 0xd2
+)
+
+var opcodes = map[Opcode]string{
+	OpErrorResponse:                   "error response",
+	OpExchangeMTURequest:              "exchange mtu request",
+	OpExchangeMTUResponse:             "exchange mtu response",
+	OpFindInformationRequest:          "find information request",
+	OpFindInformationResponse:         "find information response",
+	OpFindByTypeValueRequest:          "find by type value request",
+	OpFindByTypeValueResponse:         "find by type value response",
+	OpReadByTypeRequest:               "read by type request",
+	OpReadByTypeResponse:              "read by type response",
+	OpReadRequest:                     "read request",
+	OpReadResponse:                    "read response",
+	OpReadBlobRequest:                 "read blob request",
+	OpReadBlobResponse:                "read blob response",
+	OpReadMultipleRequest:             "read multiple request",
+	OpReadMultipleResponse:            "read multiple response",
+	OpReadByGroupTypeRequest:          "read by group type request",
+	OpReadByGroupTypeResponse:         "read by group type response",
+	OpWriteRequest:                    "write request",
+	OpWriteResponse:                   "write response",
+	OpPrepareWriteRequest:             "prepare write request",
+	OpPrepareWriteResponse:            "prepare write response",
+	OpExecuteWriteRequest:             "execute write request",
+	OpExecuteWriteResponse:            "execute write response",
+	OpReadMultipleVariableRequest:     "read multiple variable request",
+	OpReadMultipleVariableResponse:    "read multiple variable response",
+	OpMultipleHandleValueNotification: "multiple handle value notification",
+	OpHandleValueNotification:         "handle value notification",
+	OpHandleValueIndication:           "handle value indication",
+	OpHandleValueConfirmation:         "handle value confirmation",
+	OpWriteCommand:                    "write command",
+	OpSignedWriteCommand:              "signed write command",
+}
+
+func (op Opcode) String() string {
+	if s, ok := opcodes[op]; ok {
+		return s
+	}
+	return fmt.Sprintf("Opcode(%#02x)", byte(op))
+}
+
+// Command reports whether the opcode is a command, which gets no response
+func (op Opcode) Command() bool {
+	return op&0x40 != 0
+}
+
+// Response returns the opcode answering a request, zero for PDUs other
+// than requests
+func (op Opcode) Response() Opcode {
+	switch op {
+	case OpExchangeMTURequest, OpFindInformationRequest, OpFindByTypeValueRequest,
+		OpReadByTypeRequest, OpReadRequest, OpReadBlobRequest, OpReadMultipleRequest,
+		OpReadByGroupTypeRequest, OpWriteRequest, OpPrepareWriteRequest,
+		OpExecuteWriteRequest, OpReadMultipleVariableRequest:
+		return op + 1
+	case OpHandleValueIndication:
+		return OpHandleValueConfirmation
+	}
+	return 0
+}
+
+const (
+	DefaultMTU     = 23  // ATT MTU of LE before an exchange
+	MaxMTU         = 517 // largest ATT MTU
+	MaxValueLength = 512 // longest attribute value
+)
+
+// PDU is an attribute protocol data unit
+type PDU interface {
+	Opcode() Opcode
+	Bytes() []byte // encoded PDU with opcode
+}
+
+// ErrorResponse rejects a request
+type ErrorResponse struct {
+	Request Opcode
+	Handle  uint16
+	Code    goble.ATTError
+}
+
+// ExchangeMTURequest announces the receive MTU of the client
+type ExchangeMTURequest struct {
+	MTU uint16
+}
+
+// ExchangeMTUResponse announces the receive MTU of the server
+type ExchangeMTUResponse struct {
+	MTU uint16
+}
+
+// FindInformationRequest lists attribute types in a handle range
+type FindInformationRequest struct {
+	Start, End uint16
+}
+
+// Information is a handle and its attribute type
+type Information struct {
+	Handle uint16
+	UUID   xpc.UUID
+}
+
+// FindInformationResponse lists attribute types, all 16-bit or all 128-bit.
+// Bytes stops at the first entry of the other size.
+type FindInformationResponse struct {
+	Information []Information
+}
+
+// FindByTypeValueRequest finds attributes of a 16-bit type with a value
+type FindByTypeValueRequest struct {
+	Start, End uint16
+	Type       uint16
+	Value      []byte
+}
+
+// HandleRange is a found attribute and the end of its group
+type HandleRange struct {
+	Found, End uint16
+}
+
+// FindByTypeValueResponse lists found attributes
+type FindByTypeValueResponse struct {
+	Handles []HandleRange
+}
+
+// ReadByTypeRequest reads attributes of a type in a handle range
+type ReadByTypeRequest struct {
+	Start, End uint16
+	Type       xpc.UUID
+}
+
+// AttributeData is a handle and its value
+type AttributeData struct {
+	Handle uint16
+	Value  []byte
+}
+
+// ReadByTypeResponse carries values of equal length, Bytes stops at the
+// first value of another length
+type ReadByTypeResponse struct {
+	Data []AttributeData
+}
+
+// ReadRequest reads a value
+type ReadRequest struct {
+	Handle uint16
+}
+
+// ReadResponse carries a value, truncated to MTU-1
+type ReadResponse struct {
+	Value []byte
+}
+
+// ReadBlobRequest reads a value from an offset
+type ReadBlobRequest struct {
+	Handle, Offset uint16
+}
+
+// ReadBlobResponse carries part of a value
+type ReadBlobResponse struct {
+	Value []byte
+}
+
+// ReadMultipleRequest reads values of known length
+type ReadMultipleRequest struct {
+	Handles []uint16
+}
+
+// ReadMultipleResponse carries the concatenated values
+type ReadMultipleResponse struct {
+	Values []byte
+}
+
+// ReadByGroupTypeRequest reads grouping attributes, primary or secondary
+// service declarations
+type ReadByGroupTypeRequest struct {
+	Start, End uint16
+	Type       xpc.UUID
+}
+
+// GroupData is a group declaration, its end and its value
+type GroupData struct {
+	Handle, End uint16
+	Value       []byte
+}
+
+// ReadByGroupTypeResponse carries values of equal length, Bytes stops at the
+// first value of another length
+type ReadByGroupTypeResponse struct {
+	Data []GroupData
+}
+
+// WriteRequest writes a value
+type WriteRequest struct {
+	Handle uint16
+	Value  []byte
+}
+
+// WriteResponse acknowledges a write request
+type WriteResponse struct{}
+
+// WriteCommand writes a value without response
+type WriteCommand struct {
+	Handle uint16
+	Value  []byte
+}
+
+// SignedWriteCommand writes a value without response, authenticated by
+// a signature
+type SignedWriteCommand struct {
+	Handle    uint16
+	Value     []byte
+	Signature [12]byte
+}
+
+// PrepareWriteRequest queues part of a value
+type PrepareWriteRequest struct {
+	Handle, Offset uint16
+	Value          []byte
+}
+
+// PrepareWriteResponse echoes a queued part for verification
+type PrepareWriteResponse struct {
+	Handle, Offset uint16
+	Value          []byte
+}
+
+// ExecuteWriteRequest writes or cancels all queued parts
+type ExecuteWriteRequest struct {
+	Execute bool
+}
+
+// ExecuteWriteResponse acknowledges an execute write request
+type ExecuteWriteResponse struct{}
+
+// ReadMultipleVariableRequest reads values of any length
+type ReadMultipleVariableRequest struct {
+	Handles []uint16
+}
+
+// ReadMultipleVariableResponse carries length prefixed values
+type ReadMultipleVariableResponse struct {
+	Values [][]byte
+}
+
+// HandleValueNotification sends a value unacknowledged
+type HandleValueNotification struct {
+	Handle uint16
+	Value  []byte
+}
+
+// HandleValueIndication sends a value, the client confirms it
+type HandleValueIndication struct {
+	Handle uint16
+	Value  []byte
+}
+
+// HandleValueConfirmation confirms an indication
+type HandleValueConfirmation struct{}
+
+// MultipleHandleValueNotification sends several values unacknowledged
+type MultipleHandleValueNotification struct {
+	Values []AttributeData
+}
+
+func (ErrorResponse) Opcode() Opcode                   { return OpErrorResponse }
+func (ExchangeMTURequest) Opcode() Opcode              { return OpExchangeMTURequest }
+func (ExchangeMTUResponse) Opcode() Opcode             { return OpExchangeMTUResponse }
+func (FindInformationRequest) Opcode() Opcode          { return OpFindInformationRequest }
+func (FindInformationResponse) Opcode() Opcode         { return OpFindInformationResponse }
+func (FindByTypeValueRequest) Opcode() Opcode          { return OpFindByTypeValueRequest }
+func (FindByTypeValueResponse) Opcode() Opcode         { return OpFindByTypeValueResponse }
+func (ReadByTypeRequest) Opcode() Opcode               { return OpReadByTypeRequest }
+func (ReadByTypeResponse) Opcode() Opcode              { return OpReadByTypeResponse }
+func (ReadRequest) Opcode() Opcode                     { return OpReadRequest }
+func (ReadResponse) Opcode() Opcode                    { return OpReadResponse }
+func (ReadBlobRequest) Opcode() Opcode                 { return OpReadBlobRequest }
+func (ReadBlobResponse) Opcode() Opcode                { return OpReadBlobResponse }
+func (ReadMultipleRequest) Opcode() Opcode             { return OpReadMultipleRequest }
+func (ReadMultipleResponse) Opcode() Opcode            { return OpReadMultipleResponse }
+func (ReadByGroupTypeRequest) Opcode() Opcode          { return OpReadByGroupTypeRequest }
+func (ReadByGroupTypeResponse) Opcode() Opcode         { return OpReadByGroupTypeResponse }
+func (WriteRequest) Opcode() Opcode                    { return OpWriteRequest }
+func (WriteResponse) Opcode() Opcode                   { return OpWriteResponse }
+func (WriteCommand) Opcode() Opcode                    { return OpWriteCommand }
+func (SignedWriteCommand) Opcode() Opcode              { return OpSignedWriteCommand }
+func (PrepareWriteRequest) Opcode() Opcode             { return OpPrepareWriteRequest }
+func (PrepareWriteResponse) Opcode() Opcode            { return OpPrepareWriteResponse }
+func (ExecuteWriteRequest) Opcode() Opcode             { return OpExecuteWriteRequest }
+func (ExecuteWriteResponse) Opcode() Opcode            { return OpExecuteWriteResponse }
+func (ReadMultipleVariableRequest) Opcode() Opcode     { return OpReadMultipleVariableRequest }
+func (ReadMultipleVariableResponse) Opcode() Opcode    { return OpReadMultipleVariableResponse }
+func (HandleValueNotification) Opcode() Opcode         { return OpHandleValueNotification }
+func (HandleValueIndication) Opcode() Opcode           { return OpHandleValueIndication }
+func (HandleValueConfirmation) Opcode() Opcode         { return OpHandleValueConfirmation }
+func (MultipleHandleValueNotification) Opcode() Opcode { return OpMultipleHandleValueNotification }
+
+// le16 appends a little-endian uint16
+func le16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+// uuidBytes encodes a uuid little-endian, 16-bit for assigned numbers
+func uuidBytes(uuid xpc.UUID) []byte {
+	if n, ok := goble.Short(uuid); ok {
+		return le16(nil, n)
+	}
+	b := make([]byte, 16)
+	for i := range uuid {
+		b[15-i] = uuid[i]
+	}
+	return b
+}
+
+// parseUUID decodes a 16-bit or 128-bit little-endian uuid
+func parseUUID(b []byte) (xpc.UUID, error) {
+	var uuid xpc.UUID
+	switch len(b) {
+	case 2:
+		return goble.UUID16(binary.LittleEndian.Uint16(b)), nil
+	case 16:
+		for i := range uuid {
+			uuid[i] = b[15-i]
+		}
+		return uuid, nil
+	}
+	return uuid, goble.ErrInvalidPDU
+}
+
+func (p ErrorResponse) Bytes() []byte {
+	b := le16([]byte{byte(OpErrorResponse), byte(p.Request)}, p.Handle)
+	return append(b, byte(p.Code))
+}
+
+func (p ExchangeMTURequest) Bytes() []byte {
+	return le16([]byte{byte(OpExchangeMTURequest)}, p.MTU)
+}
+
+func (p ExchangeMTUResponse) Bytes() []byte {
+	return le16([]byte{byte(OpExchangeMTUResponse)}, p.MTU)
+}
+
+func (p FindInformationRequest) Bytes() []byte {
+	return handleRange(OpFindInformationRequest, p.Start, p.End)
+}
+
+func (p FindInformationResponse) Bytes() []byte {
+	b := []byte{byte(OpFindInformationResponse), 1}
+	for i, info := range p.Information {
+		uuid := uuidBytes(info.UUID)
+		if i == 0 && len(uuid) == 16 {
+			b[1] = 2
+		}
+		if (b[1] == 2) != (len(uuid) == 16) {
+			break
+		}
+		b = append(le16(b, info.Handle), uuid...)
+	}
+	return b
+}
+
+func (p FindByTypeValueRequest) Bytes() []byte {
+	b := le16(handleRange(OpFindByTypeValueRequest, p.Start, p.End), p.Type)
+	return append(b, p.Value...)
+}
+
+func (p FindByTypeValueResponse) Bytes() []byte {
+	b := []byte{byte(OpFindByTypeValueResponse)}
+	for _, h := range p.Handles {
+		b = le16(le16(b, h.Found), h.End)
+	}
+	return b
+}
+
+func (p ReadByTypeRequest) Bytes() []byte {
+	return append(handleRange(OpReadByTypeRequest, p.Start, p.End), uuidBytes(p.Type)...)
+}
+
+func (p ReadByTypeResponse) Bytes() []byte {
+	b := []byte{byte(OpReadByTypeResponse), 0}
+	for i, d := range p.Data {
+		if i == 0 {
+			b[1] = byte(2 + len(d.Value))
+		}
+		if int(b[1]) != 2+len(d.Value) {
+			break
+		}
+		b = append(le16(b, d.Handle), d.Value...)
+	}
+	return b
+}
+
+func (p ReadRequest) Bytes() []byte {
+	return le16([]byte{byte(OpReadRequest)}, p.Handle)
+}
+
+func (p ReadResponse) Bytes() []byte {
+	return append([]byte{byte(OpReadResponse)}, p.Value...)
+}
+
+func (p ReadBlobRequest) Bytes() []byte {
+	return le16(le16([]byte{byte(OpReadBlobRequest)}, p.Handle), p.Offset)
+}
+
+func (p ReadBlobResponse) Bytes() []byte {
+	return append([]byte{byte(OpReadBlobResponse)}, p.Value...)
+}
+
+func (p ReadMultipleRequest) Bytes() []byte {
+	return handles(OpReadMultipleRequest, p.Handles)
+}
+
+func (p ReadMultipleResponse) Bytes() []byte {
+	return append([]byte{byte(OpReadMultipleResponse)}, p.Values...)
+}
+
+func (p ReadByGroupTypeRequest) Bytes() []byte {
+	return append(handleRange(OpReadByGroupTypeRequest, p.Start, p.End), uuidBytes(p.Type)...)
+}
+
+func (p ReadByGroupTypeResponse) Bytes() []byte {
+	b := []byte{byte(OpReadByGroupTypeResponse), 0}
+	for i, d := range p.Data {
+		if i == 0 {
+			b[1] = byte(4 + len(d.Value))
+		}
+		if int(b[1]) != 4+len(d.Value) {
+			break
+		}
+		b = append(le16(le16(b, d.Handle), d.End), d.Value...)
+	}
+	return b
+}
+
+func (p WriteRequest) Bytes() []byte {
+	return append(le16([]byte{byte(OpWriteRequest)}, p.Handle), p.Value...)
+}
+
+func (WriteResponse) Bytes() []byte {
+	return []byte{byte(OpWriteResponse)}
+}
+
+func (p WriteCommand) Bytes() []byte {
+	return append(le16([]byte{byte(OpWriteCommand)}, p.Handle), p.Value...)
+}
+
+func (p SignedWriteCommand) Bytes() []byte {
+	b := append(le16([]byte{byte(OpSignedWriteCommand)}, p.Handle), p.Value...)
+	return append(b, p.Signature[:]...)
+}
+
+func (p PrepareWriteRequest) Bytes() []byte {
+	b := le16(le16([]byte{byte(OpPrepareWriteRequest)}, p.Handle), p.Offset)
+	return append(b, p.Value...)
+}
+
+func (p PrepareWriteResponse) Bytes() []byte {
+	b := le16(le16([]byte{byte(OpPrepareWriteResponse)}, p.Handle), p.Offset)
+	return append(b, p.Value...)
+}
+
+func (p ExecuteWriteRequest) Bytes() []byte {
+	if p.Execute {
+		return []byte{byte(OpExecuteWriteRequest), 1}
+	}
+	return []byte{byte(OpExecuteWriteRequest), 0}
+}
+
+func (ExecuteWriteResponse) Bytes() []byte {
+	return []byte{byte(OpExecuteWriteResponse)}
+}
+
+func (p ReadMultipleVariableRequest) Bytes() []byte {
+	return handles(OpReadMultipleVariableRequest, p.Handles)
+}
+
+func (p ReadMultipleVariableResponse) Bytes() []byte {
+	b := []byte{byte(OpReadMultipleVariableResponse)}
+	for _, v := range p.Values {
+		b = append(le16(b, uint16(len(v))), v...)
+	}
+	return b
+}
+
+func (p HandleValueNotification) Bytes() []byte {
+	return append(le16([]byte{byte(OpHandleValueNotification)}, p.Handle), p.Value...)
+}
+
+func (p HandleValueIndication) Bytes() []byte {
+	return append(le16([]byte{byte(OpHandleValueIndication)}, p.Handle), p.Value...)
+}
+
+func (HandleValueConfirmation) Bytes() []byte {
+	return []byte{byte(OpHandleValueConfirmation)}
+}
+
+func (p MultipleHandleValueNotification) Bytes() []byte {
+	b := []byte{byte(OpMultipleHandleValueNotification)}
+	for _, d := range p.Values {
+		b = append(le16(le16(b, d.Handle), uint16(len(d.Value))), d.Value...)
+	}
+	return b
+}
+
+func handleRange(op Opcode, start, end uint16) []byte {
+	return le16(le16([]byte{byte(op)}, start), end)
+}
+
+func handles(op Opcode, handles []uint16) []byte {
+	b := []byte{byte(op)}
+	for _, h := range handles {
+		b = le16(b, h)
+	}
+	return b
+}
+
+// minimum length of PDUs with opcode
+var minLength = map[Opcode]int{
+	OpErrorResponse:                   5,
+	OpExchangeMTURequest:              3,
+	OpExchangeMTUResponse:             3,
+	OpFindInformationRequest:          5,
+	OpFindInformationResponse:         2,
+	OpFindByTypeValueRequest:          7,
+	OpFindByTypeValueResponse:         1,
+	OpReadByTypeRequest:               7,
+	OpReadByTypeResponse:              2,
+	OpReadRequest:                     3,
+	OpReadResponse:                    1,
+	OpReadBlobRequest:                 5,
+	OpReadBlobResponse:                1,
+	OpReadMultipleRequest:             5,
+	OpReadMultipleResponse:            1,
+	OpReadByGroupTypeRequest:          7,
+	OpReadByGroupTypeResponse:         2,
+	OpWriteRequest:                    3,
+	OpWriteResponse:                   1,
+	OpPrepareWriteRequest:             5,
+	OpPrepareWriteResponse:            5,
+	OpExecuteWriteRequest:             2,
+	OpExecuteWriteResponse:            1,
+	OpReadMultipleVariableRequest:     5,
+	OpReadMultipleVariableResponse:    1,
+	OpMultipleHandleValueNotification: 1,
+	OpHandleValueNotification:         3,
+	OpHandleValueIndication:           3,
+	OpHandleValueConfirmation:         1,
+	OpWriteCommand:                    3,
+	OpSignedWriteCommand:              15,
+}
+
+// Decode parses a PDU. Unknown opcodes fail with ErrRequestNotSupported,
+// malformed PDUs with ErrInvalidPDU.
+func Decode(b []byte) (PDU, error) {
+	if len(b) == 0 {
+		return nil, goble.ErrInvalidPDU
+	}
+	op := Opcode(b[0])
+	n, ok := minLength[op]
+	if !ok {
+		return nil, goble.ErrRequestNotSupported
+	}
+	if len(b) < n {
+		return nil, goble.ErrInvalidPDU
+	}
+	u16 := func(i int) uint16 { return binary.LittleEndian.Uint16(b[i:]) }
+	value := func(i int) []byte { return append([]byte{}, b[i:]...) }
+
+	switch op {
+	case OpErrorResponse:
+		return ErrorResponse{Request: Opcode(b[1]), Handle: u16(2), Code: goble.ATTError(b[4])}, nil
+	case OpExchangeMTURequest:
+		return ExchangeMTURequest{MTU: u16(1)}, nil
+	case OpExchangeMTUResponse:
+		return ExchangeMTUResponse{MTU: u16(1)}, nil
+	case OpFindInformationRequest:
+		return FindInformationRequest{Start: u16(1), End: u16(3)}, nil
+	case OpFindInformationResponse:
+		var size int
+		switch b[1] {
+		case 1:
+			size = 4
+		case 2:
+			size = 18
+		default:
+			return nil, goble.ErrInvalidPDU
+		}
+		if (len(b)-2)%size != 0 {
+			return nil, goble.ErrInvalidPDU
+		}
+		var p FindInformationResponse
+		for i := 2; i < len(b); i += size {
+			uuid, _ := parseUUID(b[i+2 : i+size])
+			p.Information = append(p.Information, Information{Handle: u16(i), UUID: uuid})
+		}
+		return p, nil
+	case OpFindByTypeValueRequest:
+		return FindByTypeValueRequest{Start: u16(1), End: u16(3), Type: u16(5), Value: value(7)}, nil
+	case OpFindByTypeValueResponse:
+		if (len(b)-1)%4 != 0 {
+			return nil, goble.ErrInvalidPDU
+		}
+		var p FindByTypeValueResponse
+		for i := 1; i < len(b); i += 4 {
+			p.Handles = append(p.Handles, HandleRange{Found: u16(i), End: u16(i + 2)})
+		}
+		return p, nil
+	case OpReadByTypeRequest, OpReadByGroupTypeRequest:
+		uuid, err := parseUUID(b[5:])
+		if err != nil {
+			return nil, err
+		}
+		if op == OpReadByGroupTypeRequest {
+			return ReadByGroupTypeRequest{Start: u16(1), End: u16(3), Type: uuid}, nil
+		}
+		return ReadByTypeRequest{Start: u16(1), End: u16(3), Type: uuid}, nil
+	case OpReadByTypeResponse:
+		size := int(b[1])
+		if size < 2 || (len(b)-2)%size != 0 {
+			return nil, goble.ErrInvalidPDU
+		}
+		var p ReadByTypeResponse
+		for i := 2; i < len(b); i += size {
+			p.Data = append(p.Data, AttributeData{Handle: u16(i), Value: append([]byte{}, b[i+2:i+size]...)})
+		}
+		return p, nil
+	case OpReadRequest:
+		return ReadRequest{Handle: u16(1)}, nil
+	case OpReadResponse:
+		return ReadResponse{Value: value(1)}, nil
+	case OpReadBlobRequest:
+		return ReadBlobRequest{Handle: u16(1), Offset: u16(3)}, nil
+	case OpReadBlobResponse:
+		return ReadBlobResponse{Value: value(1)}, nil
+	case OpReadMultipleRequest, OpReadMultipleVariableRequest:
+		if (len(b)-1)%2 != 0 {
+			return nil, goble.ErrInvalidPDU
+		}
+		var hs []uint16
+		for i := 1; i < len(b); i += 2 {
+			hs = append(hs, u16(i))
+		}
+		if op == OpReadMultipleVariableRequest {
+			return ReadMultipleVariableRequest{Handles: hs}, nil
+		}
+		return ReadMultipleRequest{Handles: hs}, nil
+	case OpReadMultipleResponse:
+		return ReadMultipleResponse{Values: value(1)}, nil
+	case OpReadByGroupTypeResponse:
+		size := int(b[1])
+		if size < 4 || (len(b)-2)%size != 0 {
+			return nil, goble.ErrInvalidPDU
+		}
+		var p ReadByGroupTypeResponse
+		for i := 2; i < len(b); i += size {
+			p.Data = append(p.Data, GroupData{Handle: u16(i), End: u16(i + 2), Value: append([]byte{}, b[i+4:i+size]...)})
+		}
+		return p, nil
+	case OpWriteRequest:
+		return WriteRequest{Handle: u16(1), Value: value(3)}, nil
+	case OpWriteResponse:
+		return WriteResponse{}, nil
+	case OpWriteCommand:
+		return WriteCommand{Handle: u16(1), Value: value(3)}, nil
+	case OpSignedWriteCommand:
+		p := SignedWriteCommand{Handle: u16(1), Value: append([]byte{}, b[3:len(b)-12]...)}
+		copy(p.Signature[:], b[len(b)-12:])
+		return p, nil
+	case OpPrepareWriteRequest:
+		return PrepareWriteRequest{Handle: u16(1), Offset: u16(3), Value: value(5)}, nil
+	case OpPrepareWriteResponse:
+		return PrepareWriteResponse{Handle: u16(1), Offset: u16(3), Value: value(5)}, nil
+	case OpExecuteWriteRequest:
+		if b[1] > 1 {
+			return nil, goble.ErrInvalidPDU
+		}
+		return ExecuteWriteRequest{Execute: b[1] == 1}, nil
+	case OpExecuteWriteResponse:
+		return ExecuteWriteResponse{}, nil
+	case OpReadMultipleVariableResponse:
+		var p ReadMultipleVariableResponse
+		for i := 1; i < len(b); {
+			if len(b)-i < 2 {
+				return nil, goble.ErrInvalidPDU
+			}
+			// the last value may be truncated to the MTU
+			n := int(u16(i))
+			i += 2
+			if i+n > len(b) {
+				n = len(b) - i
+			}
+			p.Values = append(p.Values, append([]byte{}, b[i:i+n]...))
+			i += n
+		}
+		return p, nil
+	case OpMultipleHandleValueNotification:
+		var p MultipleHandleValueNotification
+		for i := 1; i < len(b); {
+			if len(b)-i < 4 || len(b)-i-4 < int(u16(i+2)) {
+				return nil, goble.ErrInvalidPDU
+			}
+			n := int(u16(i + 2))
+			p.Values = append(p.Values, AttributeData{Handle: u16(i), Value: append([]byte{}, b[i+4:i+4+n]...)})
+			i += 4 + n
+		}
+		return p, nil
+	case OpHandleValueNotification:
+		return HandleValueNotification{Handle: u16(1), Value: value(3)}, nil
+	case OpHandleValueIndication:
+		return HandleValueIndication{Handle: u16(1), Value: value(3)}, nil
+	case OpHandleValueConfirmation:
+		return HandleValueConfirmation{}, nil
+	}
+	return nil, goble.ErrRequestNotSupported
+}
@@ -0,0 +1,603 @@
+package att
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	ErrClosed        = errors.New("att: bearer closed")
+	ErrTimeout       = errors.New("att: transaction timeout")
+	ErrNotSubscribed = errors.New("att: client not subscribed")
+)
+
+// a transaction not completed within 30 seconds ends the bearer
+const transactionTimeout = 30 * time.Second
+
+// parts a client can queue with prepared writes
+const prepareQueueSize = 64
+
+// Server answers the requests of a client from the attribute database of
+// goble services. The bearer delivers one PDU per Read and sends one per
+// Write.
+type Server struct {
+	rw      io.ReadWriter
+	central xpc.UUID // passed to read and write handlers
+
+	wmu sync.Mutex // serializes writes to the bearer
+	imu sync.Mutex // one outstanding indication
+	cfm chan struct{}
+
+	queue []PrepareWriteRequest // prepared writes
+
+	mu        sync.Mutex
+	db        *goble.Database
+	mtu       int
+	config    map[uint16]goble.ClientConfiguration // by value handle
+	subscribe SubscriptionHandler
+}
+
+// SubscriptionHandler receives the client configuration of a value handle
+// when a client changes it
+type SubscriptionHandler func(handle uint16, config goble.ClientConfiguration)
+
+// NewServer builds the attribute database of services, central identifies
+// the client to read and write handlers. A Generic Attribute service with
+// Service Changed comes first unless services have one.
+func NewServer(rw io.ReadWriter, central xpc.UUID, services ...goble.Service) (*Server, error) {
+	s := &Server{
+		rw:      rw,
+		central: central,
+		cfm:     make(chan struct{}, 1),
+		mtu:     DefaultMTU,
+		config:  map[uint16]goble.ClientConfiguration{},
+	}
+	db, err := database(services)
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+	return s, nil
+}
+
+// database allocates handles to services after the Generic Attribute service
+func database(services []goble.Service) (*goble.Database, error) {
+	for _, svc := range services {
+		if svc.UUID() == goble.GenericAttributeUUID {
+			return goble.NewDatabase(services...)
+		}
+	}
+	gatt := goble.NewService(goble.GenericAttributeUUID,
+		goble.NewCharacteristic(goble.ServiceChangedUUID, goble.Indicate, nil))
+	return goble.NewDatabase(append([]goble.Service{gatt}, services...)...)
+}
+
+// SetServices replaces the services at runtime and indicates the changed
+// handle range to a client subscribed to Service Changed
+func (s *Server) SetServices(services ...goble.Service) error {
+	db, err := database(services)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	start, end, changed := s.db.Changed(db)
+	s.db = db
+	for h := range s.config {
+		if h >= start && h <= end {
+			delete(s.config, h)
+		}
+	}
+	s.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	if a, ok := db.Characteristic(goble.ServiceChangedUUID); ok && s.Subscription(a.Handle) != 0 {
+		return s.Notify(a.Handle, goble.ServiceChanged(start, end))
+	}
+	return nil
+}
+
+// Database returns the attribute database
+func (s *Server) Database() *goble.Database {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db
+}
+
+// Handle returns the value handle of a characteristic
+func (s *Server) Handle(uuid xpc.UUID) (uint16, bool) {
+	a, ok := s.Database().Characteristic(uuid)
+	return a.Handle, ok
+}
+
+// MTU returns the ATT MTU of the bearer
+func (s *Server) MTU() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mtu
+}
+
+// HandleSubscription sets the handler of client configuration changes
+func (s *Server) HandleSubscription(h SubscriptionHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribe = h
+}
+
+// Subscription returns the client configuration of a value handle
+func (s *Server) Subscription(handle uint16) goble.ClientConfiguration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config[handle]
+}
+
+func (s *Server) send(p PDU) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_, err := s.rw.Write(p.Bytes())
+	return err
+}
+
+// Notify sends a value to a subscribed client as notification, or as
+// indication and waits for its confirmation
+func (s *Server) Notify(handle uint16, value []byte) error {
+	config := s.Subscription(handle)
+	value = truncate(value, s.MTU()-3)
+	switch {
+	case config&goble.Notifications != 0:
+		return s.send(HandleValueNotification{Handle: handle, Value: value})
+	case config&goble.Indications != 0:
+		s.imu.Lock()
+		defer s.imu.Unlock()
+		select {
+		case <-s.cfm: // stale
+		default:
+		}
+		if err := s.send(HandleValueIndication{Handle: handle, Value: value}); err != nil {
+			return err
+		}
+		select {
+		case <-s.cfm:
+			return nil
+		case <-time.After(transactionTimeout):
+			return ErrTimeout
+		}
+	}
+	return ErrNotSubscribed
+}
+
+// Serve answers requests until the bearer fails, an end of file returns nil
+func (s *Server) Serve() error {
+	buf := make([]byte, MaxMTU)
+	for {
+		n, err := s.rw.Read(buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+		if err := s.serve(buf[:n]); err != nil {
+			return err
+		}
+	}
+}
+
+// serve answers a PDU, errors are those of the bearer
+func (s *Server) serve(b []byte) error {
+	op := Opcode(b[0])
+	p, err := Decode(b)
+	if err != nil {
+		if op.Command() {
+			return nil
+		}
+		return s.send(ErrorResponse{Request: op, Code: err.(goble.ATTError)})
+	}
+	rsp := s.handle(p)
+	if rsp == nil {
+		return nil
+	}
+	return s.send(rsp)
+}
+
+// fail builds an error response
+func fail(req PDU, handle uint16, err error) PDU {
+	code, ok := err.(goble.ATTError)
+	if !ok {
+		code = goble.ErrUnlikely
+	}
+	return ErrorResponse{Request: req.Opcode(), Handle: handle, Code: code}
+}
+
+// truncate limits a value to n bytes
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
+
+// read returns the value of an attribute from offset
+func (s *Server) read(a goble.Attribute, offset int) ([]byte, error) {
+	value := a.Value
+	switch {
+	case a.Characteristic != nil && a.Handle == a.ValueHandle:
+		if a.Characteristic.Properties()&goble.Read == 0 {
+			return nil, goble.ErrReadNotPermitted
+		}
+		v, err := a.Characteristic.ReadValue(s.central, offset)
+		if err != nil || len(v) < s.MTU()-1 {
+			a.Characteristic.EndRead(s.central)
+		}
+		return v, err
+	case a.Configuration():
+		value = le16(nil, uint16(s.Subscription(a.ValueHandle)))
+	}
+	if offset > len(value) {
+		return nil, goble.ErrInvalidOffset
+	}
+	return value[offset:], nil
+}
+
+// writable reports whether an attribute accepts writes
+func writable(a goble.Attribute, withoutResponse bool) bool {
+	switch {
+	case a.Characteristic != nil && a.Handle == a.ValueHandle:
+		if withoutResponse {
+			return a.Characteristic.Properties()&goble.WriteWithoutResponse != 0
+		}
+		return a.Characteristic.Properties()&goble.Write != 0
+	case a.Configuration():
+		return !withoutResponse
+	}
+	return false
+}
+
+// write stores the value of an attribute from offset
+func (s *Server) write(a goble.Attribute, value []byte, offset int, withoutResponse bool) error {
+	if !writable(a, withoutResponse) {
+		return goble.ErrWriteNotPermitted
+	}
+	switch {
+	case a.Characteristic != nil && a.Handle == a.ValueHandle:
+		return a.Characteristic.WriteValue(s.central, value, offset, withoutResponse)
+	case a.Configuration():
+		if offset != 0 {
+			return goble.ErrInvalidOffset
+		}
+		if len(value) != 2 {
+			return goble.ErrInvalidAttributeValueLength
+		}
+		config := goble.ClientConfiguration(binary.LittleEndian.Uint16(value))
+		s.mu.Lock()
+		old, h := s.config[a.ValueHandle], s.subscribe
+		s.config[a.ValueHandle] = config
+		s.mu.Unlock()
+		if h != nil && config != old {
+			h(a.ValueHandle, config)
+		}
+		return nil
+	}
+	return goble.ErrWriteNotPermitted
+}
+
+// checkRange validates the handle range of a request
+func checkRange(start, end uint16) error {
+	if start == 0 || start > end {
+		return goble.ErrInvalidHandle
+	}
+	return nil
+}
+
+// handle answers a request, nil for commands and confirmations
+func (s *Server) handle(p PDU) PDU {
+	mtu := s.MTU()
+	db := s.Database()
+	switch req := p.(type) {
+	case ExchangeMTURequest:
+		s.mu.Lock()
+		s.mtu = negotiate(int(req.MTU))
+		s.mu.Unlock()
+		return ExchangeMTUResponse{MTU: MaxMTU}
+
+	case FindInformationRequest:
+		if err := checkRange(req.Start, req.End); err != nil {
+			return fail(req, req.Start, err)
+		}
+		var rsp FindInformationResponse
+		size := 0
+		for _, a := range db.Range(req.Start, req.End) {
+			n := 2 + len(uuidBytes(a.Type))
+			if size == 0 {
+				size = n
+			}
+			if n != size || 2+(len(rsp.Information)+1)*size > mtu {
+				break
+			}
+			rsp.Information = append(rsp.Information, Information{Handle: a.Handle, UUID: a.Type})
+		}
+		if len(rsp.Information) == 0 {
+			return fail(req, req.Start, goble.ErrAttributeNotFound)
+		}
+		return rsp
+
+	case FindByTypeValueRequest:
+		if err := checkRange(req.Start, req.End); err != nil {
+			return fail(req, req.Start, err)
+		}
+		var rsp FindByTypeValueResponse
+		typ := goble.UUID16(req.Type)
+		for _, a := range db.Range(req.Start, req.End) {
+			if a.Type != typ || a.Value == nil {
+				continue
+			}
+			if v, err := s.read(a, 0); err != nil || !bytes.Equal(v, req.Value) {
+				continue
+			}
+			if 1+(len(rsp.Handles)+1)*4 > mtu {
+				break
+			}
+			end := a.End
+			if end == 0 {
+				end = a.Handle
+			}
+			rsp.Handles = append(rsp.Handles, HandleRange{Found: a.Handle, End: end})
+		}
+		if len(rsp.Handles) == 0 {
+			return fail(req, req.Start, goble.ErrAttributeNotFound)
+		}
+		return rsp
+
+	case ReadByTypeRequest:
+		if err := checkRange(req.Start, req.End); err != nil {
+			return fail(req, req.Start, err)
+		}
+		var rsp ReadByTypeResponse
+		size := 0
+		for _, a := range db.Range(req.Start, req.End) {
+			if a.Type != req.Type {
+				continue
+			}
+			v, err := s.read(a, 0)
+			if err != nil {
+				if len(rsp.Data) == 0 {
+					return fail(req, a.Handle, err)
+				}
+				break
+			}
+			v = truncate(v, min(mtu-4, 253))
+			if size == 0 {
+				size = 2 + len(v)
+			}
+			if 2+len(v) != size || 2+(len(rsp.Data)+1)*size > mtu {
+				break
+			}
+			rsp.Data = append(rsp.Data, AttributeData{Handle: a.Handle, Value: v})
+		}
+		if len(rsp.Data) == 0 {
+			return fail(req, req.Start, goble.ErrAttributeNotFound)
+		}
+		return rsp
+
+	case ReadByGroupTypeRequest:
+		if err := checkRange(req.Start, req.End); err != nil {
+			return fail(req, req.Start, err)
+		}
+		if req.Type != goble.PrimaryServiceUUID && req.Type != goble.SecondaryServiceUUID {
+			return fail(req, req.Start, goble.ErrUnsupportedGroupType)
+		}
+		var rsp ReadByGroupTypeResponse
+		size := 0
+		for _, a := range db.Range(req.Start, req.End) {
+			if a.Type != req.Type {
+				continue
+			}
+			v := truncate(a.Value, min(mtu-6, 251))
+			if size == 0 {
+				size = 4 + len(v)
+			}
+			if 4+len(v) != size || 2+(len(rsp.Data)+1)*size > mtu {
+				break
+			}
+			rsp.Data = append(rsp.Data, GroupData{Handle: a.Handle, End: a.End, Value: v})
+		}
+		if len(rsp.Data) == 0 {
+			return fail(req, req.Start, goble.ErrAttributeNotFound)
+		}
+		return rsp
+
+	case ReadRequest:
+		a, ok := db.Attribute(req.Handle)
+		if !ok {
+			return fail(req, req.Handle, goble.ErrInvalidHandle)
+		}
+		v, err := s.read(a, 0)
+		if err != nil {
+			return fail(req, req.Handle, err)
+		}
+		return ReadResponse{Value: truncate(v, mtu-1)}
+
+	case ReadBlobRequest:
+		a, ok := db.Attribute(req.Handle)
+		if !ok {
+			return fail(req, req.Handle, goble.ErrInvalidHandle)
+		}
+		// static values of declarations and descriptors are known to fit
+		if a.Characteristic == nil || a.Handle != a.ValueHandle {
+			if v, err := s.read(a, 0); err == nil && len(v) <= mtu-1 {
+				return fail(req, req.Handle, goble.ErrAttributeNotLong)
+			}
+		}
+		v, err := s.read(a, int(req.Offset))
+		if err != nil {
+			return fail(req, req.Handle, err)
+		}
+		return ReadBlobResponse{Value: truncate(v, mtu-1)}
+
+	case ReadMultipleRequest:
+		var rsp ReadMultipleResponse
+		for _, h := range req.Handles {
+			a, ok := db.Attribute(h)
+			if !ok {
+				return fail(req, h, goble.ErrInvalidHandle)
+			}
+			v, err := s.read(a, 0)
+			if err != nil {
+				return fail(req, h, err)
+			}
+			rsp.Values = append(rsp.Values, v...)
+		}
+		rsp.Values = truncate(rsp.Values, mtu-1)
+		return rsp
+
+	case ReadMultipleVariableRequest:
+		var rsp ReadMultipleVariableResponse
+		n := 1
+		for _, h := range req.Handles {
+			a, ok := db.Attribute(h)
+			if !ok {
+				return fail(req, h, goble.ErrInvalidHandle)
+			}
+			v, err := s.read(a, 0)
+			if err != nil {
+				return fail(req, h, err)
+			}
+			if n+2 > mtu {
+				break
+			}
+			v = truncate(v, mtu-n-2)
+			rsp.Values = append(rsp.Values, v)
+			n += 2 + len(v)
+		}
+		return rsp
+
+	case WriteRequest:
+		a, ok := db.Attribute(req.Handle)
+		if !ok {
+			return fail(req, req.Handle, goble.ErrInvalidHandle)
+		}
+		if err := s.write(a, req.Value, 0, false); err != nil {
+			return fail(req, req.Handle, err)
+		}
+		return WriteResponse{}
+
+	case WriteCommand:
+		if a, ok := db.Attribute(req.Handle); ok {
+			s.write(a, req.Value, 0, true)
+		}
+		return nil
+
+	case PrepareWriteRequest:
+		a, ok := db.Attribute(req.Handle)
+		switch {
+		case !ok:
+			return fail(req, req.Handle, goble.ErrInvalidHandle)
+		case !writable(a, false):
+			return fail(req, req.Handle, goble.ErrWriteNotPermitted)
+		case len(s.queue) >= prepareQueueSize:
+			return fail(req, req.Handle, goble.ErrPrepareQueueFull)
+		}
+		s.queue = append(s.queue, req)
+		return PrepareWriteResponse(req)
+
+	case ExecuteWriteRequest:
+		queue := s.queue
+		s.queue = nil
+		if !req.Execute {
+			return ExecuteWriteResponse{}
+		}
+		values, err := assemble(queue)
+		if err != nil {
+			return fail(req, err.(preparedError).handle, err.(preparedError).code)
+		}
+		for _, v := range values {
+			a, ok := db.Attribute(v.Handle)
+			if !ok {
+				return fail(req, v.Handle, goble.ErrInvalidHandle)
+			}
+			if err := s.write(a, v.Value, int(v.Offset), false); err != nil {
+				return fail(req, v.Handle, err)
+			}
+		}
+		return ExecuteWriteResponse{}
+
+	case HandleValueConfirmation:
+		select {
+		case s.cfm <- struct{}{}:
+		default:
+		}
+		return nil
+
+	case HandleValueNotification, HandleValueIndication, MultipleHandleValueNotification:
+		// the client role is not served here
+		return nil
+	}
+	if p.Opcode().Command() || p.Opcode().Response() == 0 {
+		return nil
+	}
+	return fail(p, 0, goble.ErrRequestNotSupported)
+}
+
+// preparedError rejects the prepared writes of a handle
+type preparedError struct {
+	handle uint16
+	code   goble.ATTError
+}
+
+func (e preparedError) Error() string {
+	return e.code.Error()
+}
+
+// assemble joins prepared writes to a value per handle, in the order of
+// their first part. Parts of a handle have to follow each other without
+// gaps.
+func assemble(queue []PrepareWriteRequest) ([]PrepareWriteRequest, error) {
+	var values []PrepareWriteRequest
+	index := map[uint16]int{}
+	for _, p := range queue {
+		i, ok := index[p.Handle]
+		if !ok {
+			index[p.Handle] = len(values)
+			values = append(values, PrepareWriteRequest{Handle: p.Handle, Offset: p.Offset})
+			i = len(values) - 1
+		}
+		v := &values[i]
+		if int(v.Offset)+len(v.Value) != int(p.Offset) {
+			return nil, preparedError{p.Handle, goble.ErrInvalidOffset}
+		}
+		if int(p.Offset)+len(p.Value) > MaxValueLength {
+			return nil, preparedError{p.Handle, goble.ErrInvalidAttributeValueLength}
+		}
+		v.Value = append(v.Value, p.Value...)
+	}
+	return values, nil
+}
+
+// negotiate returns the ATT MTU for the receive MTU of the other side
+func negotiate(mtu int) int {
+	return max(DefaultMTU, min(mtu, MaxMTU))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
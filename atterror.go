@@ -0,0 +1,64 @@
+package goble
+
+import "fmt"
+
+// ATTError is an Attribute Protocol error code
+type ATTError byte
+
+const (
+	ErrInvalidHandle                 ATTError = 0x01
+	ErrReadNotPermitted              ATTError = 0x02
+	ErrWriteNotPermitted             ATTError = 0x03
+	ErrInvalidPDU                    ATTError = 0x04
+	ErrInsufficientAuthentication    ATTError = 0x05
+	ErrRequestNotSupported           ATTError = 0x06
+	ErrInvalidOffset                 ATTError = 0x07
+	ErrInsufficientAuthorization     ATTError = 0x08
+	ErrPrepareQueueFull              ATTError = 0x09
+	ErrAttributeNotFound             ATTError = 0x0a
+	ErrAttributeNotLong              ATTError = 0x0b
+	ErrInsufficientEncryptionKeySize ATTError = 0x0c
+	ErrInvalidAttributeValueLength   ATTError = 0x0d
+	ErrUnlikely                      ATTError = 0x0e
+	ErrInsufficientEncryption        ATTError = 0x0f
+	ErrUnsupportedGroupType          ATTError = 0x10
+	ErrInsufficientResources         ATTError = 0x11
+)
+
+var attErrors = map[ATTError]string{
+	ErrInvalidHandle:                 "invalid handle",
+	ErrReadNotPermitted:              "read not permitted",
+	ErrWriteNotPermitted:             "write not permitted",
+	ErrInvalidPDU:                    "invalid pdu",
+	ErrInsufficientAuthentication:    "insufficient authentication",
+	ErrRequestNotSupported:           "request not supported",
+	ErrInvalidOffset:                 "invalid offset",
+	ErrInsufficientAuthorization:     "insufficient authorization",
+	ErrPrepareQueueFull:              "prepare queue full",
+	ErrAttributeNotFound:             "attribute not found",
+	ErrAttributeNotLong:              "attribute not long",
+	ErrInsufficientEncryptionKeySize: "insufficient encryption key size",
+	ErrInvalidAttributeValueLength:   "invalid attribute value length",
+	ErrUnlikely:                      "unlikely error",
+	ErrInsufficientEncryption:        "insufficient encryption",
+	ErrUnsupportedGroupType:          "unsupported group type",
+	ErrInsufficientResources:         "insufficient resources",
+}
+
+func (e ATTError) Error() string {
+	if s, ok := attErrors[e]; ok {
+		return "att: " + s
+	}
+	return fmt.Sprintf("att: error %#02x", byte(e))
+}
+
+// attResult converts a handler error to a result code
+func attResult(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case ATTError:
+		return int(e)
+	}
+	return int(ErrUnlikely)
+}
@@ -0,0 +1,106 @@
+package goble
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dim13/goble/xpc"
+)
+
+// Beacon is a decoded iBeacon, AltBeacon or Eddystone advertisement
+type Beacon interface {
+	// Calibrated returns the expected RSSI at 1 m in dBm
+	Calibrated() int8
+}
+
+// signal loss between 0 m and 1 m, used to convert eddystone calibration
+const eddystoneLoss1m = 41
+
+// iBeacon advertisement
+type IBeacon struct {
+	UUID          xpc.UUID // proximity uuid
+	Major         uint16
+	Minor         uint16
+	MeasuredPower int8 // RSSI at 1 m
+}
+
+// ParseIBeacon decodes iBeacon manufacturer data
+// (4c 00 02 15 uuid[16] major[2] minor[2] power[1])
+func ParseIBeacon(data []byte) (IBeacon, bool) {
+	if len(data) != 25 || data[0] != 0x4c || data[1] != 0x00 || data[2] != 0x02 || data[3] != 0x15 {
+		return IBeacon{}, false
+	}
+	return IBeacon{
+		UUID:          xpc.NewUUID(data[4:20]),
+		Major:         binary.BigEndian.Uint16(data[20:]),
+		Minor:         binary.BigEndian.Uint16(data[22:]),
+		MeasuredPower: int8(data[24]),
+	}, true
+}
+
+func (b IBeacon) Calibrated() int8 {
+	return b.MeasuredPower
+}
+
+func (b IBeacon) String() string {
+	return fmt.Sprintf("ibeacon %v major %d minor %d (%d dBm)", b.UUID, b.Major, b.Minor, b.MeasuredPower)
+}
+
+// AltBeacon advertisement
+type AltBeacon struct {
+	CompanyID     uint16
+	ID            [20]byte // organizational unit (16 bytes) followed by 4 bytes id
+	ReferenceRSSI int8     // RSSI at 1 m
+	Reserved      byte
+}
+
+// ParseAltBeacon decodes AltBeacon manufacturer data
+// (company[2] be ac id[20] rssi[1] reserved[1])
+func ParseAltBeacon(data []byte) (AltBeacon, bool) {
+	if len(data) != 26 || data[2] != 0xbe || data[3] != 0xac {
+		return AltBeacon{}, false
+	}
+	b := AltBeacon{
+		CompanyID:     binary.LittleEndian.Uint16(data),
+		ReferenceRSSI: int8(data[24]),
+		Reserved:      data[25],
+	}
+	copy(b.ID[:], data[4:24])
+	return b, true
+}
+
+func (b AltBeacon) Calibrated() int8 {
+	return b.ReferenceRSSI
+}
+
+func (b AltBeacon) String() string {
+	return fmt.Sprintf("altbeacon %x (%d dBm)", b.ID, b.ReferenceRSSI)
+}
+
+// eddystoneCalibrated converts power calibrated at 0 m to 1 m
+func eddystoneCalibrated(txPower int8) int8 {
+	if p := int(txPower) - eddystoneLoss1m; p > -128 {
+		return int8(p)
+	}
+	return -128
+}
+
+func (f EddystoneUID) Calibrated() int8 { return eddystoneCalibrated(f.TxPower) }
+func (f EddystoneURL) Calibrated() int8 { return eddystoneCalibrated(f.TxPower) }
+func (f EddystoneEID) Calibrated() int8 { return eddystoneCalibrated(f.TxPower) }
+
+// Beacon returns the beacon decoded from manufacturer or service data, if any
+func (a Advertisement) Beacon() (Beacon, bool) {
+	if b, ok := ParseIBeacon(a.ManufacturerData); ok {
+		return b, true
+	}
+	if b, ok := ParseAltBeacon(a.ManufacturerData); ok {
+		return b, true
+	}
+	if frame, ok := a.Eddystone(); ok {
+		if b, ok := frame.(Beacon); ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
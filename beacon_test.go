@@ -0,0 +1,41 @@
+package goble
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/dim13/goble/xpc"
+)
+
+func TestParseIBeacon(t *testing.T) {
+	data, _ := hex.DecodeString("4c0002151beac099beacbeacbeacbeac09beac090001000ac5")
+	b, ok := ParseIBeacon(data)
+	if !ok {
+		t.Fatal("not an ibeacon")
+	}
+	want := IBeacon{UUID: xpc.MustUUID("1BEAC099-BEAC-BEAC-BEAC-BEAC09BEAC09"), Major: 1, Minor: 10, MeasuredPower: -59}
+	if b != want {
+		t.Errorf("got %v, want %v", b, want)
+	}
+	if _, ok := ParseIBeacon(data[:24]); ok {
+		t.Error("short data parsed as ibeacon")
+	}
+	if _, ok := ParseAltBeacon(data); ok {
+		t.Error("ibeacon parsed as altbeacon")
+	}
+}
+
+func TestParseAltBeacon(t *testing.T) {
+	data, _ := hex.DecodeString("1801beac2f234454cf6d4a0fadf2f4911ba9ffa600010002c500")
+	b, ok := ParseAltBeacon(data)
+	if !ok {
+		t.Fatal("not an altbeacon")
+	}
+	if b.CompanyID != 0x0118 || b.ReferenceRSSI != -59 || hex.EncodeToString(b.ID[:]) != "2f234454cf6d4a0fadf2f4911ba9ffa600010002" {
+		t.Errorf("got %v", b)
+	}
+	ad := Advertisement{ManufacturerData: data}
+	if beacon, ok := ad.Beacon(); !ok || beacon.Calibrated() != -59 {
+		t.Errorf("got %v", beacon)
+	}
+}
@@ -0,0 +1,520 @@
+// Package bluez runs goble on Linux through bluetoothd, using the BlueZ D-Bus
+// API, where the adapter can't be taken away from the daemon.
+//
+// The blued messages sent by goble.BLE are translated to calls on the
+// org.bluez objects, and their signals back to the events goble expects, so
+// the BLE API and event names are the same as on OSX:
+//
+//	a, err := bluez.Open("hci0")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	ble := a.BLE()
+//	ble.Init()
+//
+// BlueZ discovers services on connection and does not report handles, so the
+// handles seen by goble are the ones in the object paths.
+package bluez
+
+import (
+	"encoding/hex"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+	"github.com/godbus/dbus/v5"
+)
+
+// well-known name and interfaces
+const (
+	service = "org.bluez"
+
+	adapterIface            = "org.bluez.Adapter1"
+	deviceIface             = "org.bluez.Device1"
+	serviceIface            = "org.bluez.GattService1"
+	characteristicIface     = "org.bluez.GattCharacteristic1"
+	descriptorIface         = "org.bluez.GattDescriptor1"
+	advertisementIface      = "org.bluez.LEAdvertisement1"
+	advertisingManagerIface = "org.bluez.LEAdvertisingManager1"
+	gattManagerIface        = "org.bluez.GattManager1"
+
+	objectManagerIface = "org.freedesktop.DBus.ObjectManager"
+	propertiesIface    = "org.freedesktop.DBus.Properties"
+)
+
+// blued messages, as sent by goble to darwin releases before 14
+const (
+	initMsg                    = 1
+	startAdvertisingMsg        = 8
+	stopAdvertisingMsg         = 9
+	setServicesMsg             = 10
+	removeServicesMsg          = 12
+	respondToRequestMsg        = 13
+	updateValueMsg             = 15
+	startScanningMsg           = 29
+	stopScanningMsg            = 30
+	connectMsg                 = 31
+	disconnectMsg              = 32
+	updateRssiMsg              = 43
+	discoverServicesMsg        = 44
+	discoverCharacteristicsMsg = 61
+	readMsg                    = 64
+	writeMsg                   = 65
+	notifyMsg                  = 67
+	discoverDescriptorsMsg     = 69
+	readDescriptorMsg          = 76
+)
+
+// blued events
+const (
+	stateChangeEvt             = 6
+	advertisingStartEvt        = 16
+	advertisingStopEvt         = 17
+	readRequestEvt             = 19
+	writeRequestEvt            = 20
+	subscribeEvt               = 21
+	unsubscribeEvt             = 22
+	readyToUpdateEvt           = 23
+	discoverEvt                = 37
+	connectEvt                 = 38
+	disconnectEvt              = 40
+	mtuChangeEvt               = 53
+	servicesDiscoverEvt        = 54
+	rssiUpdateEvt              = 55
+	characteristicsDiscoverEvt = 63
+	readEvt                    = 70
+	writeEvt                   = 71
+	notifyEvt                  = 73
+	descriptorsDiscoverEvt     = 75
+	descriptorReadEvt          = 79
+)
+
+// adapter states, as in CBManagerState
+const (
+	unsupported = 2
+	poweredOff  = 4
+	poweredOn   = 5
+)
+
+// interfaces are the properties of an object by interface
+type interfaces map[string]map[string]dbus.Variant
+
+// Adapter is a BlueZ adapter, it implements goble.Transport
+type Adapter struct {
+	conn    *dbus.Conn
+	name    string
+	path    dbus.ObjectPath // of the adapter
+	base    dbus.ObjectPath // of our objects
+	ble     *goble.BLE
+	signals chan *dbus.Signal
+
+	mu     sync.Mutex
+	queue  []func()
+	wake   chan struct{}
+	closed bool
+
+	tmu          sync.Mutex
+	transactions map[int]chan xpc.Dict // requests forwarded to goble
+	transaction  int
+
+	// owned by the run goroutine
+	matched   bool
+	objects   map[dbus.ObjectPath]interfaces // of bluetoothd
+	scanning  bool
+	filter    []string                     // service uuids to scan for
+	connected map[dbus.ObjectPath]bool     // devices
+	notifying map[dbus.ObjectPath]bool     // characteristics
+	waiting   map[dbus.ObjectPath][]func() // discoveries waiting for ServicesResolved
+
+	advertising bool
+	app         application
+}
+
+// Open connects to bluetoothd on the system bus and uses the adapter name,
+// e.g. hci0
+func Open(name string) (*Adapter, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, err
+	}
+	return New(conn, name), nil
+}
+
+// New uses the adapter name of the BlueZ service on conn
+func New(conn *dbus.Conn, name string) *Adapter {
+	a := &Adapter{
+		conn:         conn,
+		name:         name,
+		path:         dbus.ObjectPath("/org/bluez/" + name),
+		base:         dbus.ObjectPath("/org/goble/" + name),
+		signals:      make(chan *dbus.Signal, 64),
+		wake:         make(chan struct{}, 1),
+		transactions: map[int]chan xpc.Dict{},
+		objects:      map[dbus.ObjectPath]interfaces{},
+		connected:    map[dbus.ObjectPath]bool{},
+		notifying:    map[dbus.ObjectPath]bool{},
+		waiting:      map[dbus.ObjectPath][]func(){},
+	}
+	a.app.init(a)
+	a.ble = goble.NewWithTransport(a, "")
+	conn.Signal(a.signals)
+	go a.run()
+	go a.listen()
+	return a
+}
+
+// BLE returns the goble instance using the adapter
+func (a *Adapter) BLE() *goble.BLE {
+	return a.ble
+}
+
+// Close stops the adapter and closes the D-Bus connection
+func (a *Adapter) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	a.queue = nil
+	a.mu.Unlock()
+	a.signal()
+	return a.conn.Close()
+}
+
+// do queues fn on the run goroutine
+func (a *Adapter) do(fn func()) {
+	a.mu.Lock()
+	if !a.closed {
+		a.queue = append(a.queue, fn)
+	}
+	a.mu.Unlock()
+	a.signal()
+}
+
+func (a *Adapter) signal() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (a *Adapter) run() {
+	for range a.wake {
+		for {
+			a.mu.Lock()
+			if a.closed {
+				a.mu.Unlock()
+				return
+			}
+			if len(a.queue) == 0 {
+				a.mu.Unlock()
+				break
+			}
+			fn := a.queue[0]
+			a.queue = a.queue[1:]
+			a.mu.Unlock()
+			fn()
+		}
+	}
+}
+
+// listen hands the signals of bluetoothd to the run goroutine, until the
+// connection is closed
+func (a *Adapter) listen() {
+	for s := range a.signals {
+		s := s
+		a.do(func() { a.changed(s) })
+	}
+}
+
+// call invokes a method without blocking the run goroutine, done runs on it
+// with the reply
+func (a *Adapter) call(path dbus.ObjectPath, method string, done func(*dbus.Call), args ...interface{}) {
+	c := a.conn.Object(service, path).Go(method, 0, make(chan *dbus.Call, 1), args...)
+	go func() {
+		<-c.Done
+		a.do(func() { done(c) })
+	}()
+}
+
+// Send receives a message from goble (implements goble.Transport)
+func (a *Adapter) Send(msg interface{}, verbose bool) {
+	if m, ok := msg.(xpc.Dict); ok {
+		a.do(func() { a.handle(m) })
+	}
+}
+
+// emit delivers an event to goble
+func (a *Adapter) emit(id int, args xpc.Dict) {
+	a.ble.HandleXpcEvent(xpc.Dict{
+		"kCBMsgId":   int64(id),
+		"kCBMsgArgs": args,
+	}, nil)
+}
+
+// intArg returns an integer argument sent by goble
+func intArg(args xpc.Dict, k string) int {
+	switch v := args[k].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	}
+	return 0
+}
+
+// handle translates a message from goble
+func (a *Adapter) handle(m xpc.Dict) {
+	args, _ := m["kCBMsgArgs"].(xpc.Dict)
+	switch intArg(m, "kCBMsgId") {
+	case initMsg:
+		a.init()
+	case startScanningMsg:
+		a.startScanning(args)
+	case stopScanningMsg:
+		a.stopScanning()
+	case connectMsg:
+		a.connect(args)
+	case disconnectMsg:
+		a.disconnect(args)
+	case updateRssiMsg:
+		a.updateRssi(args)
+	case discoverServicesMsg:
+		a.discoverServices(args)
+	case discoverCharacteristicsMsg:
+		a.discoverCharacteristics(args)
+	case discoverDescriptorsMsg:
+		a.discoverDescriptors(args)
+	case readMsg:
+		a.readValue(args)
+	case writeMsg:
+		a.writeValue(args)
+	case readDescriptorMsg:
+		a.readDescriptor(args)
+	case notifyMsg:
+		a.notify(args)
+	case startAdvertisingMsg:
+		a.startAdvertising(args)
+	case stopAdvertisingMsg:
+		a.stopAdvertising()
+	case setServicesMsg:
+		a.app.addService(args)
+		a.app.register()
+	case removeServicesMsg:
+		a.app.remove()
+	case respondToRequestMsg:
+		a.respond(args)
+	case updateValueMsg:
+		a.app.updateValue(args)
+	}
+}
+
+// result converts a call error to a blued result code
+func result(err error) int {
+	if err != nil {
+		return int(goble.ErrUnlikely)
+	}
+	return 0
+}
+
+// init loads the objects of bluetoothd and reports the adapter state
+func (a *Adapter) init() {
+	state := unsupported
+	if err := a.load(); err != nil {
+		log.Println("bluez: init:", err)
+	} else if props, ok := a.objects[a.path][adapterIface]; ok {
+		state = powerState(props)
+	} else {
+		log.Println("bluez: no adapter", a.name)
+	}
+	a.emit(stateChangeEvt, xpc.Dict{"kCBMsgArgState": int64(state)})
+}
+
+func (a *Adapter) load() error {
+	if !a.matched {
+		if err := a.conn.AddMatchSignal(dbus.WithMatchSender(service)); err != nil {
+			return err
+		}
+		a.matched = true
+	}
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	if err := a.conn.Object(service, "/").Call(objectManagerIface+".GetManagedObjects", 0).Store(&objects); err != nil {
+		return err
+	}
+	a.objects = map[dbus.ObjectPath]interfaces{}
+	for path, ifaces := range objects {
+		a.objects[path] = ifaces
+	}
+	return nil
+}
+
+func powerState(props map[string]dbus.Variant) int {
+	if powered, _ := props["Powered"].Value().(bool); powered {
+		return poweredOn
+	}
+	return poweredOff
+}
+
+// changed tracks the objects of bluetoothd
+func (a *Adapter) changed(s *dbus.Signal) {
+	switch s.Name {
+	case objectManagerIface + ".InterfacesAdded":
+		var path dbus.ObjectPath
+		var added map[string]map[string]dbus.Variant
+		if err := dbus.Store(s.Body, &path, &added); err != nil {
+			return
+		}
+		obj, ok := a.objects[path]
+		if !ok {
+			obj = interfaces{}
+			a.objects[path] = obj
+		}
+		for iface, props := range added {
+			obj[iface] = props
+		}
+		if _, ok := added[deviceIface]; ok {
+			a.discover(path)
+		}
+
+	case objectManagerIface + ".InterfacesRemoved":
+		var path dbus.ObjectPath
+		var removed []string
+		if err := dbus.Store(s.Body, &path, &removed); err != nil {
+			return
+		}
+		for _, iface := range removed {
+			delete(a.objects[path], iface)
+			if iface == deviceIface {
+				a.disconnected(path)
+			}
+		}
+		if len(a.objects[path]) == 0 {
+			delete(a.objects, path)
+		}
+
+	case propertiesIface + ".PropertiesChanged":
+		var iface string
+		var changed map[string]dbus.Variant
+		var invalidated []string
+		if err := dbus.Store(s.Body, &iface, &changed, &invalidated); err != nil {
+			return
+		}
+		obj, ok := a.objects[s.Path]
+		if !ok {
+			obj = interfaces{}
+			a.objects[s.Path] = obj
+		}
+		props, ok := obj[iface]
+		if !ok {
+			props = map[string]dbus.Variant{}
+			obj[iface] = props
+		}
+		for k, v := range changed {
+			props[k] = v
+		}
+		for _, k := range invalidated {
+			delete(props, k)
+		}
+
+		switch iface {
+		case adapterIface:
+			if _, ok := changed["Powered"]; ok && s.Path == a.path {
+				a.emit(stateChangeEvt, xpc.Dict{"kCBMsgArgState": int64(powerState(props))})
+			}
+		case deviceIface:
+			a.deviceChanged(s.Path, changed)
+		case characteristicIface:
+			// Notifying may change before StartNotify returns
+			notifying, _ := props["Notifying"].Value().(bool)
+			if v, ok := changed["Value"]; ok && (notifying || a.notifying[s.Path]) {
+				a.notification(s.Path, v)
+			}
+		}
+	}
+}
+
+// parent returns the path of the parent object
+func parent(path dbus.ObjectPath) dbus.ObjectPath {
+	s := string(path)
+	return dbus.ObjectPath(s[:strings.LastIndex(s, "/")])
+}
+
+// handleOf returns the attribute handle in a path like
+// /org/bluez/hci0/dev_XX_XX_XX_XX_XX_XX/service000a/char000b
+func handleOf(path dbus.ObjectPath) uint16 {
+	s := string(path)
+	if len(s) < 4 {
+		return 0
+	}
+	n, _ := strconv.ParseUint(s[len(s)-4:], 16, 16)
+	return uint16(n)
+}
+
+// children returns the objects with iface whose property key refers to
+// parent, in handle order
+func (a *Adapter) children(iface, key string, parent dbus.ObjectPath) []dbus.ObjectPath {
+	var paths []dbus.ObjectPath
+	for path, obj := range a.objects {
+		if props, ok := obj[iface]; ok {
+			if p, _ := props[key].Value().(dbus.ObjectPath); p == parent {
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return handleOf(paths[i]) < handleOf(paths[j])
+	})
+	return paths
+}
+
+// deviceUUID stands in for the address in goble, like the identifiers
+// CoreBluetooth assigns: the type in byte 9 and the address in the last 6
+// bytes
+func deviceUUID(props map[string]dbus.Variant) xpc.UUID {
+	var u xpc.UUID
+	if typ, _ := props["AddressType"].Value().(string); typ == "random" {
+		u[9] = 1
+	}
+	addr, _ := props["Address"].Value().(string)
+	if b, err := hex.DecodeString(strings.Replace(addr, ":", "", -1)); err == nil && len(b) == 6 {
+		copy(u[10:], b)
+	}
+	return u
+}
+
+// uuidBytes converts a BlueZ uuid to the bytes goble expects, 2 bytes for
+// assigned numbers
+func uuidBytes(s string) []byte {
+	uuid := xpc.MakeUUID(s)
+	if n, ok := goble.Short(uuid); ok {
+		return []byte{byte(n >> 8), byte(n)}
+	}
+	return uuid.Bytes()
+}
+
+// uuidString converts a uuid sent by goble to the 128-bit form of BlueZ
+func uuidString(s string) string {
+	uuid := xpc.MakeUUID(s)
+	if len(s) == 4 {
+		n, _ := strconv.ParseUint(s, 16, 16)
+		uuid = goble.UUID16(uint16(n))
+	}
+	h := uuid.String()
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// matches reports whether a uuid is in the list sent by goble, an empty list
+// matches everything
+func matches(uuid string, list interface{}) bool {
+	uuids, _ := list.([]string)
+	if len(uuids) == 0 {
+		return true
+	}
+	for _, u := range uuids {
+		if goble.EqualUUID(uuid, u) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,559 @@
+package bluez
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/internal/central"
+	"github.com/dim13/goble/xpc"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	adapterPath = dbus.ObjectPath("/org/bluez/hci0")
+	sensorPath  = adapterPath + "/dev_C0_11_22_33_44_55"
+	levelPath   = sensorPath + "/service000a/char000b"
+	commandPath = sensorPath + "/service000a/char000e"
+)
+
+var (
+	batteryUUID = goble.UUID16(0x180f)
+	levelUUID   = goble.UUID16(0x2a19)
+	commandUUID = xpc.MustUUID("6e400002b5a3f393e0a9e50e24dcca9e")
+)
+
+// startBus runs a private bus
+func startBus(t *testing.T) (string, func()) {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("no dbus-daemon")
+	}
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		cmd.Process.Kill()
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(addr), func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}
+}
+
+// registration of an advertisement or application, with the properties or
+// objects read from the caller
+type registration struct {
+	sender  string
+	path    dbus.ObjectPath
+	objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	props   map[string]dbus.Variant
+}
+
+// fakeBlueZ is the object tree of bluetoothd with adapter hci0
+type fakeBlueZ struct {
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+
+	written        chan []byte
+	advertisements chan registration
+	applications   chan registration
+}
+
+func newFakeBlueZ(t *testing.T, addr string) *fakeBlueZ {
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.RequestName(service, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeBlueZ{
+		conn: conn,
+		objects: map[dbus.ObjectPath]map[string]map[string]dbus.Variant{
+			adapterPath: {adapterIface: {
+				"Address": dbus.MakeVariant("00:11:22:33:44:55"),
+				"Powered": dbus.MakeVariant(true),
+			}},
+		},
+		written:        make(chan []byte, 1),
+		advertisements: make(chan registration, 1),
+		applications:   make(chan registration, 1),
+	}
+	path := func(msg dbus.Message) dbus.ObjectPath {
+		return msg.Headers[dbus.FieldPath].Value().(dbus.ObjectPath)
+	}
+	notPermitted := dbus.NewError("org.bluez.Error.NotPermitted", []interface{}{"Write not permitted"})
+
+	conn.ExportMethodTable(map[string]interface{}{
+		"GetManagedObjects": func() (map[dbus.ObjectPath]map[string]map[string]dbus.Variant, *dbus.Error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			return f.objects, nil
+		},
+	}, "/", objectManagerIface)
+
+	conn.ExportMethodTable(map[string]interface{}{
+		"SetDiscoveryFilter": func(map[string]dbus.Variant) *dbus.Error { return nil },
+		"StartDiscovery": func() *dbus.Error {
+			go f.startDiscovery()
+			return nil
+		},
+		"StopDiscovery": func() *dbus.Error { return nil },
+	}, adapterPath, adapterIface)
+
+	conn.ExportMethodTable(map[string]interface{}{
+		"RegisterAdvertisement": func(sender dbus.Sender, path dbus.ObjectPath, options map[string]dbus.Variant) *dbus.Error {
+			r := registration{sender: string(sender), path: path}
+			if err := conn.Object(r.sender, path).Call(propertiesIface+".GetAll", 0, advertisementIface).Store(&r.props); err != nil {
+				return dbus.MakeFailedError(err)
+			}
+			f.advertisements <- r
+			return nil
+		},
+		"UnregisterAdvertisement": func(dbus.ObjectPath) *dbus.Error { return nil },
+	}, adapterPath, advertisingManagerIface)
+
+	conn.ExportMethodTable(map[string]interface{}{
+		"RegisterApplication": func(sender dbus.Sender, path dbus.ObjectPath, options map[string]dbus.Variant) *dbus.Error {
+			r := registration{sender: string(sender), path: path}
+			if err := conn.Object(r.sender, path).Call(objectManagerIface+".GetManagedObjects", 0).Store(&r.objects); err != nil {
+				return dbus.MakeFailedError(err)
+			}
+			f.applications <- r
+			return nil
+		},
+		"UnregisterApplication": func(dbus.ObjectPath) *dbus.Error { return nil },
+	}, adapterPath, gattManagerIface)
+
+	conn.ExportSubtreeMethodTable(map[string]interface{}{
+		"Connect": func(msg dbus.Message) *dbus.Error {
+			f.set(path(msg), deviceIface, map[string]interface{}{"Connected": true})
+			go f.resolve(path(msg))
+			return nil
+		},
+		"Disconnect": func(msg dbus.Message) *dbus.Error {
+			f.set(path(msg), deviceIface, map[string]interface{}{"Connected": false, "ServicesResolved": false})
+			return nil
+		},
+	}, adapterPath, deviceIface)
+
+	conn.ExportSubtreeMethodTable(map[string]interface{}{
+		"ReadValue": func(msg dbus.Message, options map[string]dbus.Variant) ([]byte, *dbus.Error) {
+			if path(msg) != levelPath {
+				return nil, dbus.NewError("org.bluez.Error.NotPermitted", []interface{}{"Read not permitted"})
+			}
+			return []byte{77}, nil
+		},
+		"WriteValue": func(msg dbus.Message, value []byte, options map[string]dbus.Variant) *dbus.Error {
+			if path(msg) != commandPath {
+				return notPermitted
+			}
+			f.written <- value
+			return nil
+		},
+		"StartNotify": func(msg dbus.Message) *dbus.Error {
+			f.set(path(msg), characteristicIface, map[string]interface{}{"Notifying": true})
+			return nil
+		},
+		"StopNotify": func(msg dbus.Message) *dbus.Error {
+			f.set(path(msg), characteristicIface, map[string]interface{}{"Notifying": false})
+			return nil
+		},
+	}, adapterPath, characteristicIface)
+	return f
+}
+
+func variants(props map[string]interface{}) map[string]dbus.Variant {
+	m := map[string]dbus.Variant{}
+	for k, v := range props {
+		m[k] = dbus.MakeVariant(v)
+	}
+	return m
+}
+
+// add adds an object
+func (f *fakeBlueZ) add(path dbus.ObjectPath, iface string, props map[string]interface{}) {
+	added := map[string]map[string]dbus.Variant{iface: variants(props)}
+	f.mu.Lock()
+	f.objects[path] = added
+	f.mu.Unlock()
+	f.conn.Emit("/", objectManagerIface+".InterfacesAdded", path, added)
+}
+
+// set changes properties of an object
+func (f *fakeBlueZ) set(path dbus.ObjectPath, iface string, props map[string]interface{}) {
+	changed := variants(props)
+	f.mu.Lock()
+	for k, v := range changed {
+		f.objects[path][iface][k] = v
+	}
+	f.mu.Unlock()
+	f.conn.Emit(path, propertiesIface+".PropertiesChanged", iface, changed, []string{})
+}
+
+// startDiscovery finds a heart rate sensor, and a battery powered one
+func (f *fakeBlueZ) startDiscovery() {
+	f.add(adapterPath+"/dev_C0_AA_BB_CC_DD_EE", deviceIface, map[string]interface{}{
+		"Address":     "C0:AA:BB:CC:DD:EE",
+		"AddressType": "random",
+		"Name":        "heart",
+		"RSSI":        int16(-70),
+		"UUIDs":       []string{"0000180d-0000-1000-8000-00805f9b34fb"},
+	})
+	f.add(sensorPath, deviceIface, map[string]interface{}{
+		"Address":          "C0:11:22:33:44:55",
+		"AddressType":      "random",
+		"Name":             "sensor",
+		"RSSI":             int16(-60),
+		"UUIDs":            []string{"0000180f-0000-1000-8000-00805f9b34fb"},
+		"ManufacturerData": map[uint16]dbus.Variant{0xffff: dbus.MakeVariant([]byte{1, 2})},
+		"Connected":        false,
+		"ServicesResolved": false,
+	})
+}
+
+// resolve discovers the services of the sensor
+func (f *fakeBlueZ) resolve(device dbus.ObjectPath) {
+	battery := device + "/service000a"
+	f.add(battery, serviceIface, map[string]interface{}{
+		"UUID":    "0000180f-0000-1000-8000-00805f9b34fb",
+		"Device":  device,
+		"Primary": true,
+	})
+	f.add(levelPath, characteristicIface, map[string]interface{}{
+		"UUID":      "00002a19-0000-1000-8000-00805f9b34fb",
+		"Service":   battery,
+		"Flags":     []string{"read", "notify"},
+		"Notifying": false,
+		"MTU":       uint16(185),
+	})
+	f.add(levelPath+"/desc000d", descriptorIface, map[string]interface{}{
+		"UUID":           "00002902-0000-1000-8000-00805f9b34fb",
+		"Characteristic": levelPath,
+	})
+	f.add(commandPath, characteristicIface, map[string]interface{}{
+		"UUID":    "6e400002-b5a3-f393-e0a9-e50e24dcca9e",
+		"Service": battery,
+		"Flags":   []string{"write"},
+	})
+	f.add(device+"/service0010", serviceIface, map[string]interface{}{
+		"UUID":    "0000180a-0000-1000-8000-00805f9b34fb",
+		"Device":  device,
+		"Primary": true,
+	})
+	f.set(device, deviceIface, map[string]interface{}{"ServicesResolved": true})
+}
+
+// setup runs a fake bluetoothd on a private bus, and an adapter connected to
+// it
+func setup(t *testing.T, name string) (*fakeBlueZ, *Adapter, func()) {
+	addr, stop := startBus(t)
+	f := newFakeBlueZ(t, addr)
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	a := New(conn, name)
+	return f, a, func() {
+		a.Close()
+		f.conn.Close()
+		stop()
+	}
+}
+
+// listen collects the events of ble
+func listen(ble *goble.BLE) <-chan goble.Event {
+	events := make(chan goble.Event, 64)
+	ble.Listen(func(ev goble.Event) bool {
+		events <- ev
+		return false
+	})
+	return events
+}
+
+func wait(t *testing.T, events <-chan goble.Event, name string) goble.Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Name == name {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %v event", name)
+		}
+	}
+}
+
+func TestCentral(t *testing.T) {
+	f, a, stop := setup(t, "hci0")
+	defer stop()
+
+	ble := a.BLE()
+	events := listen(ble)
+	ble.Init()
+	if ev := wait(t, events, "stateChange"); ev.State != "poweredOn" {
+		t.Fatalf("got state %v", ev.State)
+	}
+
+	ble.StartScanning([]xpc.UUID{batteryUUID}, false)
+	ev := wait(t, events, "discover")
+	if ad := ev.Peripheral.Advertisement; ad.LocalName != "sensor" || len(ad.ServiceUuids) != 1 || ad.ServiceUuids[0] != "180f" {
+		t.Fatalf("got advertisement %+v", ad)
+	}
+	if ad := ev.Peripheral.Advertisement; ad.CompanyID != 0xffff || !bytes.Equal(ad.ManufacturerPayload, []byte{1, 2}) {
+		t.Errorf("got manufacturer data %x", ad.ManufacturerData)
+	}
+	if ev.DeviceUUID.String() != "00000000000000000001c01122334455" {
+		t.Errorf("got device %v", ev.DeviceUUID)
+	}
+	if ev.Peripheral.Rssi != -60 {
+		t.Errorf("got rssi %v", ev.Peripheral.Rssi)
+	}
+	ble.StopScanning()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := central.Dial(ctx, ble, ev.DeviceUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := wait(t, events, "mtuChange"); ev.Mtu != 185 {
+		t.Errorf("got mtu %v", ev.Mtu)
+	}
+
+	s, err := conn.DiscoverService(ctx, batteryUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch, ok := s.Characteristic(levelUUID.String()); !ok || ch.Properties != goble.Read|goble.Notify {
+		t.Fatalf("got characteristic %+v", ch)
+	}
+	data, err := conn.Read(ctx, s, levelUUID)
+	if err != nil || !bytes.Equal(data, []byte{77}) {
+		t.Fatalf("got read %v, %v", data, err)
+	}
+
+	if err := conn.Write(ctx, s, commandUUID, []byte("hello"), false); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-f.written; string(data) != "hello" {
+		t.Errorf("got write %q", data)
+	}
+	if err := conn.Write(ctx, s, levelUUID, []byte{1}, false); err != goble.ErrWriteNotPermitted {
+		t.Errorf("got write error %v", err)
+	}
+
+	notified := make(chan []byte, 1)
+	if err := conn.Subscribe(ctx, s, levelUUID, func(b []byte) { notified <- b }); err != nil {
+		t.Fatal(err)
+	}
+	f.set(levelPath, characteristicIface, map[string]interface{}{"Value": []byte{76}})
+	select {
+	case b := <-notified:
+		if !bytes.Equal(b, []byte{76}) {
+			t.Errorf("got notification %v", b)
+		}
+	case <-ctx.Done():
+		t.Fatal("no notification")
+	}
+
+	ble.UpdateRssi(ev.DeviceUUID)
+	if ev := wait(t, events, "rssiUpdate"); ev.Peripheral.Rssi != -60 {
+		t.Errorf("got rssi %v", ev.Peripheral.Rssi)
+	}
+
+	conn.Close()
+	wait(t, events, "disconnect")
+}
+
+func TestPeripheral(t *testing.T) {
+	f, a, stop := setup(t, "hci0")
+	defer stop()
+
+	ble := a.BLE()
+	events := listen(ble)
+	ble.Init()
+	wait(t, events, "stateChange")
+
+	level := goble.NewCharacteristic(levelUUID, goble.Read|goble.Notify, nil)
+	centrals := make(chan xpc.UUID, 1)
+	level.HandleRead(func(central xpc.UUID, offset int) ([]byte, error) {
+		centrals <- central
+		return []byte{77}, nil
+	})
+	written := make(chan []byte, 1)
+	command := goble.NewCharacteristic(commandUUID, goble.Write, nil)
+	command.HandleWrite(func(central xpc.UUID, data []byte, offset int, withoutResponse bool) error {
+		written <- data
+		return nil
+	})
+	ble.SetServices([]goble.Service{goble.NewService(batteryUUID, level, command)})
+
+	var app registration
+	select {
+	case app = <-f.applications:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no application")
+	}
+	paths := map[string]dbus.ObjectPath{}
+	for path, obj := range app.objects {
+		for iface, props := range obj {
+			uuid, _ := props["UUID"].Value().(string)
+			paths[iface+" "+uuid] = path
+		}
+	}
+	levelChar := paths[characteristicIface+" 00002a19-0000-1000-8000-00805f9b34fb"]
+	commandChar := paths[characteristicIface+" 6e400002-b5a3-f393-e0a9-e50e24dcca9e"]
+	if _, ok := paths[serviceIface+" 0000180f-0000-1000-8000-00805f9b34fb"]; !ok || levelChar == "" || commandChar == "" {
+		t.Fatalf("got objects %v", paths)
+	}
+	flags, _ := app.objects[levelChar][characteristicIface]["Flags"].Value().([]string)
+	if strings.Join(flags, ",") != "read,notify" {
+		t.Errorf("got flags %v", flags)
+	}
+
+	ble.StartAdvertising("goble", []xpc.UUID{batteryUUID})
+	wait(t, events, "advertisingStart")
+	adv := <-f.advertisements
+	if name, _ := adv.props["LocalName"].Value().(string); name != "goble" {
+		t.Errorf("got name %q", name)
+	}
+	if uuids, _ := adv.props["ServiceUUIDs"].Value().([]string); len(uuids) != 1 || uuids[0] != "0000180f-0000-1000-8000-00805f9b34fb" {
+		t.Errorf("got uuids %v", uuids)
+	}
+
+	// act as a remote client
+	options := map[string]dbus.Variant{"device": dbus.MakeVariant(sensorPath)}
+	var data []byte
+	if err := f.conn.Object(app.sender, levelChar).Call(characteristicIface+".ReadValue", 0, options).Store(&data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{77}) {
+		t.Errorf("got read %v", data)
+	}
+	if c := <-centrals; c.String() != "00000000000000000000c01122334455" {
+		t.Errorf("got central %v", c)
+	}
+
+	options["type"] = dbus.MakeVariant("request")
+	if err := f.conn.Object(app.sender, commandChar).Call(characteristicIface+".WriteValue", 0, []byte("hello"), options).Err; err != nil {
+		t.Fatal(err)
+	}
+	if data := <-written; string(data) != "hello" {
+		t.Errorf("got write %q", data)
+	}
+	err := f.conn.Object(app.sender, levelChar).Call(characteristicIface+".WriteValue", 0, []byte{1}, options).Err
+	if e, ok := err.(dbus.Error); !ok || e.Name != "org.bluez.Error.NotPermitted" {
+		t.Errorf("got write error %v", err)
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	f.conn.Signal(signals)
+	if err := f.conn.AddMatchSignal(dbus.WithMatchSender(app.sender), dbus.WithMatchObjectPath(levelChar)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.conn.Object(app.sender, levelChar).Call(characteristicIface+".StartNotify", 0).Err; err != nil {
+		t.Fatal(err)
+	}
+	if ev := wait(t, events, "subscribe"); ev.CharacteristicUuid != levelUUID.String() {
+		t.Errorf("got subscribe %v", ev.CharacteristicUuid)
+	}
+	if !ble.UpdateValue(levelUUID, []byte{76}) {
+		t.Fatal("no subscriber")
+	}
+	timeout := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case s := <-signals:
+			var iface string
+			var changed map[string]dbus.Variant
+			var invalidated []string
+			if dbus.Store(s.Body, &iface, &changed, &invalidated) != nil {
+				continue
+			}
+			if v, ok := changed["Value"]; ok {
+				if b, _ := v.Value().([]byte); !bytes.Equal(b, []byte{76}) {
+					t.Errorf("got value %v", b)
+				}
+				done = true
+			}
+		case <-timeout:
+			t.Fatal("no notification")
+		}
+	}
+	if err := f.conn.Object(app.sender, levelChar).Call(characteristicIface+".StopNotify", 0).Err; err != nil {
+		t.Fatal(err)
+	}
+	wait(t, events, "unsubscribe")
+
+	ble.StopAdvertising()
+	wait(t, events, "advertisingStop")
+}
+
+func TestIncludes(t *testing.T) {
+	f, a, stop := setup(t, "hci0")
+	defer stop()
+
+	ble := a.BLE()
+	events := listen(ble)
+	ble.Init()
+	wait(t, events, "stateChange")
+
+	// the battery service includes one set after it
+	infoUUID := goble.UUID16(0x180a)
+	battery := goble.NewService(batteryUUID, goble.NewCharacteristic(levelUUID, goble.Read, []byte{77}))
+	battery.Include(infoUUID)
+	ble.SetServices([]goble.Service{battery, goble.NewSecondaryService(infoUUID)})
+
+	var app registration
+	for i := 0; i < 2; i++ {
+		select {
+		case app = <-f.applications:
+		case <-time.After(2 * time.Second):
+			t.Fatal("no application")
+		}
+	}
+	paths := map[string]dbus.ObjectPath{}
+	for path, obj := range app.objects {
+		if props, ok := obj[serviceIface]; ok {
+			uuid, _ := props["UUID"].Value().(string)
+			paths[uuid] = path
+		}
+	}
+	batteryPath, infoPath := paths["0000180f-0000-1000-8000-00805f9b34fb"], paths["0000180a-0000-1000-8000-00805f9b34fb"]
+	includes, _ := app.objects[batteryPath][serviceIface]["Includes"].Value().([]dbus.ObjectPath)
+	if infoPath == "" || len(includes) != 1 || includes[0] != infoPath {
+		t.Errorf("got includes %v, want %v", includes, infoPath)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	_, a, stop := setup(t, "hci1")
+	defer stop()
+
+	ble := a.BLE()
+	events := listen(ble)
+	ble.Init()
+	if ev := wait(t, events, "stateChange"); ev.State != "unsupported" {
+		t.Errorf("got state %v", ev.State)
+	}
+}
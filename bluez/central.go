@@ -0,0 +1,500 @@
+package bluez
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+	"github.com/godbus/dbus/v5"
+)
+
+// characteristic flags of BlueZ by property bit
+var flagProperties = map[string]int{
+	"broadcast":                   0x01,
+	"read":                        0x02,
+	"write-without-response":      0x04,
+	"write":                       0x08,
+	"notify":                      0x10,
+	"indicate":                    0x20,
+	"authenticated-signed-writes": 0x40,
+	"extended-properties":         0x80,
+}
+
+// BlueZ errors by ATT error, read and write not permitted are both
+// org.bluez.Error.NotPermitted
+var bluezErrors = map[string]goble.ATTError{
+	"org.bluez.Error.NotAuthorized":      goble.ErrInsufficientAuthorization,
+	"org.bluez.Error.InvalidOffset":      goble.ErrInvalidOffset,
+	"org.bluez.Error.InvalidValueLength": goble.ErrInvalidAttributeValueLength,
+	"org.bluez.Error.NotSupported":       goble.ErrRequestNotSupported,
+}
+
+// attError converts the error of a GATT call to an ATT error code
+func attError(err error, notPermitted goble.ATTError) goble.ATTError {
+	var e dbus.Error
+	switch v := err.(type) {
+	case nil:
+		return 0
+	case dbus.Error:
+		e = v
+	case *dbus.Error:
+		e = *v
+	default:
+		return goble.ErrUnlikely
+	}
+	if e.Name == "org.bluez.Error.NotPermitted" {
+		return notPermitted
+	}
+	if code, ok := bluezErrors[e.Name]; ok {
+		return code
+	}
+	// e.g. "Operation failed with ATT error: 0x05"
+	if i := strings.Index(e.Error(), "ATT error: "); i >= 0 {
+		var code byte
+		if _, err := fmt.Sscanf(e.Error()[i:], "ATT error: %v", &code); err == nil && code != 0 {
+			return goble.ATTError(code)
+		}
+	}
+	return goble.ErrUnlikely
+}
+
+func (a *Adapter) startScanning(args xpc.Dict) {
+	a.filter, _ = args["kCBMsgArgUUIDs"].([]string)
+	options, _ := args["kCBMsgArgOptions"].(xpc.Dict)
+
+	filter := map[string]dbus.Variant{
+		"Transport":     dbus.MakeVariant("le"),
+		"DuplicateData": dbus.MakeVariant(intArg(options, "kCBScanOptionAllowDuplicates") != 0),
+	}
+	if len(a.filter) > 0 {
+		uuids := make([]string, len(a.filter))
+		for i, u := range a.filter {
+			uuids[i] = uuidString(u)
+		}
+		filter["UUIDs"] = dbus.MakeVariant(uuids)
+	}
+	adapter := a.conn.Object(service, a.path)
+	if err := adapter.Call(adapterIface+".SetDiscoveryFilter", 0, filter).Err; err != nil {
+		log.Println("bluez: discovery filter:", err)
+	}
+	if err := adapter.Call(adapterIface+".StartDiscovery", 0).Err; err != nil {
+		log.Println("bluez: start discovery:", err)
+		return
+	}
+	a.scanning = true
+}
+
+func (a *Adapter) stopScanning() {
+	a.scanning = false
+	if err := a.conn.Object(service, a.path).Call(adapterIface+".StopDiscovery", 0).Err; err != nil {
+		log.Println("bluez: stop discovery:", err)
+	}
+}
+
+// discover emits a discover event with the advertising data of a device
+func (a *Adapter) discover(path dbus.ObjectPath) {
+	props, ok := a.objects[path][deviceIface]
+	if !a.scanning || !ok || parent(path) != a.path {
+		return
+	}
+	rssi, ok := props["RSSI"].Value().(int16)
+	if !ok {
+		// known, but not in range
+		return
+	}
+
+	data := xpc.Dict{"kCBAdvDataIsConnectable": int64(1)}
+	if name, ok := props["Name"].Value().(string); ok {
+		data["kCBAdvDataLocalName"] = name
+	}
+	if tx, ok := props["TxPower"].Value().(int16); ok {
+		data["kCBAdvDataTxPowerLevel"] = int64(tx)
+	}
+	if md, ok := props["ManufacturerData"].Value().(map[uint16]dbus.Variant); ok && len(md) > 0 {
+		ids := make([]int, 0, len(md))
+		for id := range md {
+			ids = append(ids, int(id))
+		}
+		sort.Ints(ids)
+		b, _ := md[uint16(ids[0])].Value().([]byte)
+		data["kCBAdvDataManufacturerData"] = append([]byte{byte(ids[0]), byte(ids[0] >> 8)}, b...)
+	}
+	uuids, _ := props["UUIDs"].Value().([]string)
+	if len(uuids) > 0 {
+		list := xpc.Array{}
+		for _, u := range uuids {
+			list = append(list, uuidBytes(u))
+		}
+		data["kCBAdvDataServiceUUIDs"] = list
+	}
+	if sd, ok := props["ServiceData"].Value().(map[string]dbus.Variant); ok && len(sd) > 0 {
+		keys := make([]string, 0, len(sd))
+		for k := range sd {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		list := xpc.Array{}
+		for _, k := range keys {
+			b, _ := sd[k].Value().([]byte)
+			list = append(list, uuidBytes(k), b)
+		}
+		data["kCBAdvDataServiceData"] = list
+	}
+
+	if len(a.filter) > 0 {
+		found := false
+		for _, u := range uuids {
+			found = found || matches(u, a.filter)
+		}
+		if !found {
+			return
+		}
+	}
+
+	a.emit(discoverEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID":        deviceUUID(props),
+		"kCBMsgArgRssi":              int64(rssi),
+		"kCBMsgArgAdvertisementData": data,
+	})
+}
+
+// device returns the path of the device addressed by a message
+func (a *Adapter) device(args xpc.Dict) (dbus.ObjectPath, bool) {
+	uuid, _ := args["kCBMsgArgDeviceUUID"].(xpc.UUID)
+	for path, obj := range a.objects {
+		if props, ok := obj[deviceIface]; ok && deviceUUID(props) == uuid {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func (a *Adapter) uuidOf(device dbus.ObjectPath) xpc.UUID {
+	return deviceUUID(a.objects[device][deviceIface])
+}
+
+func (a *Adapter) resolved(device dbus.ObjectPath) bool {
+	resolved, _ := a.objects[device][deviceIface]["ServicesResolved"].Value().(bool)
+	return resolved
+}
+
+func (a *Adapter) connect(args xpc.Dict) {
+	device, ok := a.device(args)
+	if !ok {
+		log.Println("bluez: unknown device", args["kCBMsgArgDeviceUUID"])
+		return
+	}
+	a.call(device, deviceIface+".Connect", func(c *dbus.Call) {
+		if c.Err != nil {
+			log.Println("bluez: connect:", c.Err)
+			return
+		}
+		a.connected[device] = true
+		a.emit(connectEvt, xpc.Dict{"kCBMsgArgDeviceUUID": a.uuidOf(device)})
+		if a.resolved(device) {
+			a.servicesResolved(device)
+		}
+	})
+}
+
+func (a *Adapter) disconnect(args xpc.Dict) {
+	device, ok := a.device(args)
+	if !ok {
+		return
+	}
+	// the disconnect event follows the Connected property
+	a.call(device, deviceIface+".Disconnect", func(c *dbus.Call) {
+		if c.Err != nil {
+			log.Println("bluez: disconnect:", c.Err)
+		}
+	})
+}
+
+// deviceChanged follows the properties of a device
+func (a *Adapter) deviceChanged(device dbus.ObjectPath, changed map[string]dbus.Variant) {
+	for _, k := range []string{"RSSI", "ManufacturerData", "ServiceData"} {
+		if _, ok := changed[k]; ok {
+			a.discover(device)
+			break
+		}
+	}
+	if connected, ok := changed["Connected"].Value().(bool); ok && !connected {
+		a.disconnected(device)
+	}
+	if resolved, ok := changed["ServicesResolved"].Value().(bool); ok && resolved && a.connected[device] {
+		a.servicesResolved(device)
+	}
+}
+
+// servicesResolved reports the ATT MTU, if BlueZ has it, and runs the
+// discoveries waiting for the services
+func (a *Adapter) servicesResolved(device dbus.ObjectPath) {
+	for path, obj := range a.objects {
+		if mtu, ok := obj[characteristicIface]["MTU"].Value().(uint16); ok && strings.HasPrefix(string(path), string(device)+"/") {
+			a.emit(mtuChangeEvt, xpc.Dict{
+				"kCBMsgArgDeviceUUID": a.uuidOf(device),
+				"kCBMsgArgATTMTU":     int64(mtu),
+			})
+			break
+		}
+	}
+	waiting := a.waiting[device]
+	delete(a.waiting, device)
+	for _, fn := range waiting {
+		fn()
+	}
+}
+
+func (a *Adapter) disconnected(device dbus.ObjectPath) {
+	if !a.connected[device] {
+		return
+	}
+	delete(a.connected, device)
+	delete(a.waiting, device)
+	for path := range a.notifying {
+		if strings.HasPrefix(string(path), string(device)+"/") {
+			delete(a.notifying, path)
+		}
+	}
+	a.emit(disconnectEvt, xpc.Dict{"kCBMsgArgDeviceUUID": a.uuidOf(device)})
+}
+
+// updateRssi reports the RSSI of the last advertisement, BlueZ doesn't read
+// it on connections
+func (a *Adapter) updateRssi(args xpc.Dict) {
+	device, ok := a.device(args)
+	if !ok {
+		return
+	}
+	rssi := int16(127) // not available, as in CoreBluetooth
+	if v, ok := a.objects[device][deviceIface]["RSSI"].Value().(int16); ok {
+		rssi = v
+	}
+	a.emit(rssiUpdateEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID": a.uuidOf(device),
+		"kCBMsgArgData":       int64(rssi),
+	})
+}
+
+// resolve runs fn once BlueZ has discovered the services of the device
+// addressed by a message
+func (a *Adapter) resolve(args xpc.Dict, fn func(device dbus.ObjectPath)) {
+	device, ok := a.device(args)
+	if !ok || !a.connected[device] {
+		return
+	}
+	if !a.resolved(device) {
+		a.waiting[device] = append(a.waiting[device], func() { fn(device) })
+		return
+	}
+	fn(device)
+}
+
+func (a *Adapter) discoverServices(args xpc.Dict) {
+	a.resolve(args, func(device dbus.ObjectPath) {
+		paths := a.children(serviceIface, "Device", device)
+		services := xpc.Array{}
+		for i, path := range paths {
+			props := a.objects[path][serviceIface]
+			if primary, ok := props["Primary"].Value().(bool); ok && !primary {
+				continue
+			}
+			end := uint16(0xffff)
+			if i+1 < len(paths) {
+				end = handleOf(paths[i+1]) - 1
+			}
+			uuid, _ := props["UUID"].Value().(string)
+			if matches(uuid, args["kCBMsgArgUUIDs"]) {
+				services = append(services, xpc.Dict{
+					"kCBMsgArgUUID":               uuidBytes(uuid),
+					"kCBMsgArgServiceStartHandle": int64(handleOf(path)),
+					"kCBMsgArgServiceEndHandle":   int64(end),
+				})
+			}
+		}
+		a.emit(servicesDiscoverEvt, xpc.Dict{
+			"kCBMsgArgDeviceUUID": a.uuidOf(device),
+			"kCBMsgArgServices":   services,
+			"kCBMsgArgResult":     int64(0),
+		})
+	})
+}
+
+// find returns the object with iface and handle of a device
+func (a *Adapter) find(device dbus.ObjectPath, iface string, handle int) (dbus.ObjectPath, bool) {
+	for path, obj := range a.objects {
+		if _, ok := obj[iface]; ok && strings.HasPrefix(string(path), string(device)+"/") && int(handleOf(path)) == handle {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func (a *Adapter) discoverCharacteristics(args xpc.Dict) {
+	a.resolve(args, func(device dbus.ObjectPath) {
+		start := intArg(args, "kCBMsgArgServiceStartHandle")
+		svc, ok := a.find(device, serviceIface, start)
+		if !ok {
+			return
+		}
+		characteristics := xpc.Array{}
+		for _, path := range a.children(characteristicIface, "Service", svc) {
+			props := a.objects[path][characteristicIface]
+			uuid, _ := props["UUID"].Value().(string)
+			flags, _ := props["Flags"].Value().([]string)
+			properties := 0
+			for _, f := range flags {
+				properties |= flagProperties[f]
+			}
+			if matches(uuid, args["kCBMsgArgUUIDs"]) {
+				characteristics = append(characteristics, xpc.Dict{
+					"kCBMsgArgUUID":                      uuidBytes(uuid),
+					"kCBMsgArgCharacteristicHandle":      int64(handleOf(path)),
+					"kCBMsgArgCharacteristicValueHandle": int64(handleOf(path) + 1),
+					"kCBMsgArgCharacteristicProperties":  int64(properties),
+				})
+			}
+		}
+		a.emit(characteristicsDiscoverEvt, xpc.Dict{
+			"kCBMsgArgDeviceUUID":         a.uuidOf(device),
+			"kCBMsgArgServiceStartHandle": int64(start),
+			"kCBMsgArgCharacteristics":    characteristics,
+			"kCBMsgArgResult":             int64(0),
+		})
+	})
+}
+
+// characteristic returns the characteristic addressed by a message
+func (a *Adapter) characteristic(args xpc.Dict, fn func(device, char dbus.ObjectPath)) {
+	a.resolve(args, func(device dbus.ObjectPath) {
+		handle := intArg(args, "kCBMsgArgCharacteristicHandle")
+		if char, ok := a.find(device, characteristicIface, handle); ok {
+			fn(device, char)
+		}
+	})
+}
+
+func (a *Adapter) discoverDescriptors(args xpc.Dict) {
+	a.characteristic(args, func(device, char dbus.ObjectPath) {
+		descriptors := xpc.Array{}
+		for _, path := range a.children(descriptorIface, "Characteristic", char) {
+			uuid, _ := a.objects[path][descriptorIface]["UUID"].Value().(string)
+			descriptors = append(descriptors, xpc.Dict{
+				"kCBMsgArgUUID":             uuidBytes(uuid),
+				"kCBMsgArgDescriptorHandle": int64(handleOf(path)),
+			})
+		}
+		a.emit(descriptorsDiscoverEvt, xpc.Dict{
+			"kCBMsgArgDeviceUUID":           a.uuidOf(device),
+			"kCBMsgArgCharacteristicHandle": int64(handleOf(char)),
+			"kCBMsgArgDescriptors":          descriptors,
+			"kCBMsgArgResult":               int64(0),
+		})
+	})
+}
+
+func (a *Adapter) readValue(args xpc.Dict) {
+	a.characteristic(args, func(device, char dbus.ObjectPath) {
+		a.call(char, characteristicIface+".ReadValue", func(c *dbus.Call) {
+			var data []byte
+			if c.Err == nil {
+				c.Store(&data)
+			}
+			a.emit(readEvt, xpc.Dict{
+				"kCBMsgArgDeviceUUID":           a.uuidOf(device),
+				"kCBMsgArgCharacteristicHandle": int64(handleOf(char)),
+				"kCBMsgArgData":                 append([]byte{}, data...),
+				"kCBMsgArgIsNotification":       int64(0),
+				"kCBMsgArgResult":               int64(attError(c.Err, goble.ErrReadNotPermitted)),
+			})
+		}, map[string]dbus.Variant{})
+	})
+}
+
+func (a *Adapter) readDescriptor(args xpc.Dict) {
+	a.resolve(args, func(device dbus.ObjectPath) {
+		handle := intArg(args, "kCBMsgArgDescriptorHandle")
+		desc, ok := a.find(device, descriptorIface, handle)
+		if !ok {
+			return
+		}
+		a.call(desc, descriptorIface+".ReadValue", func(c *dbus.Call) {
+			var data []byte
+			if c.Err == nil {
+				c.Store(&data)
+			}
+			a.emit(descriptorReadEvt, xpc.Dict{
+				"kCBMsgArgDeviceUUID":       a.uuidOf(device),
+				"kCBMsgArgDescriptorHandle": int64(handle),
+				"kCBMsgArgData":             append([]byte{}, data...),
+				"kCBMsgArgResult":           int64(attError(c.Err, goble.ErrReadNotPermitted)),
+			})
+		}, map[string]dbus.Variant{})
+	})
+}
+
+func (a *Adapter) writeValue(args xpc.Dict) {
+	a.characteristic(args, func(device, char dbus.ObjectPath) {
+		data, _ := args["kCBMsgArgData"].([]byte)
+		withoutResponse := intArg(args, "kCBMsgArgType") != 0
+		typ := "request"
+		if withoutResponse {
+			typ = "command"
+		}
+		a.call(char, characteristicIface+".WriteValue", func(c *dbus.Call) {
+			if withoutResponse {
+				if c.Err != nil {
+					log.Println("bluez: write:", c.Err)
+				}
+				return
+			}
+			a.emit(writeEvt, xpc.Dict{
+				"kCBMsgArgDeviceUUID":           a.uuidOf(device),
+				"kCBMsgArgCharacteristicHandle": int64(handleOf(char)),
+				"kCBMsgArgResult":               int64(attError(c.Err, goble.ErrWriteNotPermitted)),
+			})
+		}, data, map[string]dbus.Variant{"type": dbus.MakeVariant(typ)})
+	})
+}
+
+// notify starts or stops notifications, or indications, BlueZ picks
+func (a *Adapter) notify(args xpc.Dict) {
+	a.characteristic(args, func(device, char dbus.ObjectPath) {
+		state := intArg(args, "kCBMsgArgState")
+		method := characteristicIface + ".StopNotify"
+		if state != 0 {
+			method = characteristicIface + ".StartNotify"
+		}
+		a.call(char, method, func(c *dbus.Call) {
+			if c.Err == nil && state != 0 {
+				a.notifying[char] = true
+			} else {
+				delete(a.notifying, char)
+				if c.Err != nil {
+					state = 0
+				}
+			}
+			a.emit(notifyEvt, xpc.Dict{
+				"kCBMsgArgDeviceUUID":           a.uuidOf(device),
+				"kCBMsgArgCharacteristicHandle": int64(handleOf(char)),
+				"kCBMsgArgState":                int64(state),
+				"kCBMsgArgResult":               int64(attError(c.Err, goble.ErrWriteNotPermitted)),
+			})
+		})
+	})
+}
+
+// notification delivers a changed value of a notifying characteristic
+func (a *Adapter) notification(char dbus.ObjectPath, v dbus.Variant) {
+	data, _ := v.Value().([]byte)
+	a.emit(readEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID":           a.uuidOf(parent(parent(char))),
+		"kCBMsgArgCharacteristicHandle": int64(handleOf(char)),
+		"kCBMsgArgData":                 append([]byte{}, data...),
+		"kCBMsgArgIsNotification":       int64(1),
+		"kCBMsgArgResult":               int64(0),
+	})
+}
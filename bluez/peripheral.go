@@ -0,0 +1,565 @@
+package bluez
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/adv"
+	"github.com/dim13/goble/xpc"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// time allowed for goble to answer a request of a remote client
+const requestTimeout = 5 * time.Second
+
+// BlueZ errors of ATT errors returned by goble
+var attErrors = map[goble.ATTError]string{
+	goble.ErrReadNotPermitted:            "org.bluez.Error.NotPermitted",
+	goble.ErrWriteNotPermitted:           "org.bluez.Error.NotPermitted",
+	goble.ErrInsufficientAuthorization:   "org.bluez.Error.NotAuthorized",
+	goble.ErrInvalidOffset:               "org.bluez.Error.InvalidOffset",
+	goble.ErrInvalidAttributeValueLength: "org.bluez.Error.InvalidValueLength",
+	goble.ErrRequestNotSupported:         "org.bluez.Error.NotSupported",
+}
+
+// dbusError converts an ATT error to the error of a GATT method
+func dbusError(err goble.ATTError) *dbus.Error {
+	name, ok := attErrors[err]
+	if !ok {
+		name = "org.bluez.Error.Failed"
+	}
+	return dbus.NewError(name, []interface{}{err.Error()})
+}
+
+// advertisement is the LEAdvertisement1 object registered while advertising
+type advertisement struct {
+	a *Adapter
+}
+
+// Release is called when bluetoothd drops the advertisement
+func (adv advertisement) Release() *dbus.Error {
+	adv.a.do(func() { adv.a.advertising = false })
+	return nil
+}
+
+func (a *Adapter) advertisementPath() dbus.ObjectPath {
+	return a.base + "/advertisement0"
+}
+
+func (a *Adapter) startAdvertising(args xpc.Dict) {
+	if a.advertising {
+		a.unregisterAdvertisement()
+	}
+	props := map[string]*prop.Prop{
+		"Type": {Value: "peripheral"},
+	}
+	if name, ok := args["kCBAdvDataLocalName"].(string); ok {
+		props["LocalName"] = &prop.Prop{Value: name}
+	}
+	if uuids, ok := args["kCBAdvDataServiceUUIDs"].([][]byte); ok && len(uuids) > 0 {
+		list := make([]string, len(uuids))
+		for i, u := range uuids {
+			list[i] = uuidString(fmt.Sprintf("%x", u))
+		}
+		props["ServiceUUIDs"] = &prop.Prop{Value: list}
+	}
+	if sd, ok := args["kCBAdvDataServiceData"].(xpc.Array); ok && len(sd) > 0 {
+		data := map[string]dbus.Variant{}
+		for i := 0; i+1 < len(sd); i += 2 {
+			u, _ := sd[i].([]byte)
+			b, _ := sd[i+1].([]byte)
+			data[uuidString(fmt.Sprintf("%x", u))] = dbus.MakeVariant(b)
+		}
+		props["ServiceData"] = &prop.Prop{Value: data}
+	}
+	md := map[uint16]dbus.Variant{}
+	if b, ok := args["kCBAdvDataAppleMfgData"].([]byte); ok {
+		p, _ := adv.Parse(b)
+		for _, m := range p.ManufacturerData() {
+			md[m.CompanyID] = dbus.MakeVariant(m.Data)
+		}
+	}
+	if b, ok := args["kCBAdvDataAppleBeaconKey"].([]byte); ok {
+		md[0x004c] = dbus.MakeVariant(append([]byte{0x02, byte(len(b))}, b...))
+	}
+	if len(md) > 0 {
+		props["ManufacturerData"] = &prop.Prop{Value: md}
+	}
+	if _, ok := args["kCBAdvDataTxPowerLevel"]; ok {
+		props["Includes"] = &prop.Prop{Value: []string{"tx-power"}}
+	}
+
+	err := a.registerAdvertisement(props)
+	if err != nil {
+		log.Println("bluez: advertise:", err)
+	}
+	a.advertising = err == nil
+	a.emit(advertisingStartEvt, xpc.Dict{"kCBMsgArgResult": int64(result(err))})
+}
+
+func (a *Adapter) registerAdvertisement(props map[string]*prop.Prop) error {
+	path := a.advertisementPath()
+	if err := a.conn.Export(advertisement{a}, path, advertisementIface); err != nil {
+		return err
+	}
+	if _, err := prop.Export(a.conn, path, prop.Map{advertisementIface: props}); err != nil {
+		return err
+	}
+	manager := a.conn.Object(service, a.path)
+	return manager.Call(advertisingManagerIface+".RegisterAdvertisement", 0, path, map[string]dbus.Variant{}).Err
+}
+
+func (a *Adapter) unregisterAdvertisement() error {
+	path := a.advertisementPath()
+	err := a.conn.Object(service, a.path).Call(advertisingManagerIface+".UnregisterAdvertisement", 0, path).Err
+	a.conn.Export(nil, path, advertisementIface)
+	a.conn.Export(nil, path, propertiesIface)
+	a.advertising = false
+	return err
+}
+
+func (a *Adapter) stopAdvertising() {
+	var err error
+	if a.advertising {
+		err = a.unregisterAdvertisement()
+	}
+	a.emit(advertisingStopEvt, xpc.Dict{"kCBMsgArgResult": int64(result(err))})
+}
+
+// object of the GATT application
+type object struct {
+	path  dbus.ObjectPath
+	iface string
+	props *prop.Properties
+}
+
+// characteristic of the GATT application
+type characteristic struct {
+	object
+	a          *Adapter
+	id         int // goble attribute id
+	properties int
+	value      []byte // nil for values served by goble
+}
+
+// descriptor of the GATT application, with a static value
+type descriptor struct {
+	object
+	value []byte
+}
+
+// application is the GATT database registered with GattManager1, it is the
+// object manager of its services
+type application struct {
+	a *Adapter
+
+	mu              sync.Mutex
+	objects         []object
+	characteristics []*characteristic
+	services        map[int]dbus.ObjectPath   // by attribute id
+	includes        map[dbus.ObjectPath][]int // included attribute ids by service
+
+	exported   bool
+	registered bool
+}
+
+func (app *application) init(a *Adapter) {
+	app.a = a
+}
+
+// GetManagedObjects lists the services, characteristics and descriptors
+func (app *application) GetManagedObjects() (map[dbus.ObjectPath]map[string]map[string]dbus.Variant, *dbus.Error) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	objects := map[dbus.ObjectPath]map[string]map[string]dbus.Variant{}
+	for _, obj := range app.objects {
+		props, err := obj.props.GetAll(obj.iface)
+		if err != nil {
+			return nil, err
+		}
+		objects[obj.path] = map[string]map[string]dbus.Variant{obj.iface: props}
+	}
+	return objects, nil
+}
+
+// export makes an object of the application available
+func (app *application) export(path dbus.ObjectPath, iface string, v interface{}, props map[string]*prop.Prop) (object, error) {
+	if v != nil {
+		if err := app.a.conn.Export(v, path, iface); err != nil {
+			return object{}, err
+		}
+	}
+	p, err := prop.Export(app.a.conn, path, prop.Map{iface: props})
+	if err != nil {
+		return object{}, err
+	}
+	obj := object{path: path, iface: iface, props: p}
+	app.mu.Lock()
+	app.objects = append(app.objects, obj)
+	app.mu.Unlock()
+	return obj, nil
+}
+
+// flags converts goble properties and permissions to characteristic flags
+func flags(props, permissions int) []string {
+	var f []string
+	if props&0x02 != 0 {
+		if permissions&0x04 != 0 {
+			f = append(f, "encrypt-read")
+		} else {
+			f = append(f, "read")
+		}
+	}
+	if props&0x04 != 0 {
+		f = append(f, "write-without-response")
+	}
+	if props&0x08 != 0 {
+		if permissions&0x08 != 0 {
+			f = append(f, "encrypt-write")
+		} else {
+			f = append(f, "write")
+		}
+	}
+	if props&(0x10|0x100) != 0 {
+		f = append(f, "notify")
+	}
+	if props&(0x20|0x200) != 0 {
+		f = append(f, "indicate")
+	}
+	return f
+}
+
+// addService exports a service set with SetServices
+func (app *application) addService(args xpc.Dict) {
+	if !app.exported {
+		if err := app.a.conn.Export(app, app.a.base, objectManagerIface); err != nil {
+			log.Println("bluez:", err)
+			return
+		}
+		app.exported = true
+	}
+	if app.services == nil {
+		app.services = map[int]dbus.ObjectPath{}
+	}
+	svc := dbus.ObjectPath(fmt.Sprintf("%v/service%d", app.a.base, len(app.services)))
+	app.services[intArg(args, "kCBMsgArgAttributeID")] = svc
+	uuid, _ := args["kCBMsgArgUUID"].(string)
+	if _, err := app.export(svc, serviceIface, nil, map[string]*prop.Prop{
+		"UUID":     {Value: uuidString(uuid)},
+		"Primary":  {Value: intArg(args, "kCBMsgArgType") != 0},
+		"Includes": {Value: []dbus.ObjectPath{}},
+	}); err != nil {
+		log.Println("bluez:", err)
+		return
+	}
+	if app.includes == nil {
+		app.includes = map[dbus.ObjectPath][]int{}
+	}
+	app.includes[svc], _ = args["kCBMsgArgAttributeIDs"].([]int)
+	app.include()
+
+	characteristics, _ := args["kCBMsgArgCharacteristics"].(xpc.Array)
+	for i, v := range characteristics {
+		c, ok := v.(xpc.Dict)
+		if !ok {
+			continue
+		}
+		ch := &characteristic{
+			a:          app.a,
+			id:         intArg(c, "kCBMsgArgAttributeID"),
+			properties: intArg(c, "kCBMsgArgCharacteristicProperties"),
+		}
+		if data, ok := c["kCBMsgArgData"].([]byte); ok && data != nil {
+			ch.value = data
+		}
+		uuid, _ := c["kCBMsgArgUUID"].(string)
+		path := dbus.ObjectPath(fmt.Sprintf("%v/char%d", svc, i))
+		obj, err := app.export(path, characteristicIface, ch, map[string]*prop.Prop{
+			"UUID":      {Value: uuidString(uuid)},
+			"Service":   {Value: svc},
+			"Flags":     {Value: flags(ch.properties, intArg(c, "kCBMsgArgAttributePermissions"))},
+			"Value":     {Value: append([]byte{}, ch.value...), Emit: prop.EmitTrue},
+			"Notifying": {Value: false, Emit: prop.EmitTrue},
+		})
+		if err != nil {
+			log.Println("bluez:", err)
+			return
+		}
+		ch.object = obj
+		app.mu.Lock()
+		app.characteristics = append(app.characteristics, ch)
+		app.mu.Unlock()
+
+		descriptors, _ := c["kCBMsgArgDescriptors"].(xpc.Array)
+		for j, v := range descriptors {
+			d, ok := v.(xpc.Dict)
+			if !ok {
+				continue
+			}
+			desc := &descriptor{}
+			desc.value, _ = d["kCBMsgArgData"].([]byte)
+			uuid, _ := d["kCBMsgArgUUID"].(string)
+			obj, err := app.export(dbus.ObjectPath(fmt.Sprintf("%v/desc%d", path, j)), descriptorIface, desc, map[string]*prop.Prop{
+				"UUID":           {Value: uuidString(uuid)},
+				"Characteristic": {Value: path},
+				"Flags":          {Value: []string{"read"}},
+			})
+			if err != nil {
+				log.Println("bluez:", err)
+				return
+			}
+			desc.object = obj
+		}
+	}
+}
+
+// include resolves the included services of all services, goble may set
+// a service after those including it
+func (app *application) include() {
+	app.mu.Lock()
+	objects := app.objects
+	app.mu.Unlock()
+	for _, obj := range objects {
+		ids, ok := app.includes[obj.path]
+		if !ok || obj.iface != serviceIface {
+			continue
+		}
+		includes := []dbus.ObjectPath{}
+		for _, id := range ids {
+			if path, ok := app.services[id]; ok && path != obj.path {
+				includes = append(includes, path)
+			}
+		}
+		obj.props.SetMust(serviceIface, "Includes", includes)
+	}
+}
+
+// register (re)registers the application with its services
+func (app *application) register() {
+	manager := app.a.conn.Object(service, app.a.path)
+	if app.registered {
+		manager.Call(gattManagerIface+".UnregisterApplication", 0, app.a.base)
+		app.registered = false
+	}
+	if err := manager.Call(gattManagerIface+".RegisterApplication", 0, app.a.base, map[string]dbus.Variant{}).Err; err != nil {
+		log.Println("bluez: register application:", err)
+		return
+	}
+	app.registered = true
+}
+
+// remove unregisters the application and removes its services
+func (app *application) remove() {
+	if app.registered {
+		app.a.conn.Object(service, app.a.path).Call(gattManagerIface+".UnregisterApplication", 0, app.a.base)
+		app.registered = false
+	}
+	app.mu.Lock()
+	objects := app.objects
+	app.objects = nil
+	app.characteristics = nil
+	app.mu.Unlock()
+	for _, obj := range objects {
+		app.a.conn.Export(nil, obj.path, obj.iface)
+		app.a.conn.Export(nil, obj.path, propertiesIface)
+	}
+	app.services = nil
+	app.includes = nil
+}
+
+// characteristicByID returns the characteristic with a goble attribute id
+func (app *application) characteristicByID(id int) (*characteristic, bool) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	for _, ch := range app.characteristics {
+		if ch.id == id {
+			return ch, true
+		}
+	}
+	return nil, false
+}
+
+// updateValue changes the value of a notifying characteristic, bluetoothd
+// sends it to the subscribed centrals
+func (app *application) updateValue(args xpc.Dict) {
+	ch, ok := app.characteristicByID(intArg(args, "kCBMsgArgAttributeID"))
+	if !ok {
+		log.Println("bluez: unknown attribute", args["kCBMsgArgAttributeID"])
+		return
+	}
+	defer app.a.emit(readyToUpdateEvt, xpc.Dict{})
+	if notifying, _ := ch.props.GetMust(characteristicIface, "Notifying").(bool); !notifying {
+		return
+	}
+	data, _ := args["kCBMsgArgData"].([]byte)
+	ch.props.SetMust(characteristicIface, "Value", append([]byte{}, data...))
+}
+
+// offset returns the offset option of a GATT method
+func offset(options map[string]dbus.Variant) int {
+	n, _ := options["offset"].Value().(uint16)
+	return int(n)
+}
+
+// ReadValue answers a read of a remote client, static values are served
+// without goble
+func (ch *characteristic) ReadValue(options map[string]dbus.Variant) ([]byte, *dbus.Error) {
+	off := offset(options)
+	switch {
+	case ch.value != nil:
+		if off > len(ch.value) {
+			return nil, dbusError(goble.ErrInvalidOffset)
+		}
+		return ch.value[off:], nil
+	case ch.properties&int(goble.Read) == 0:
+		return nil, dbusError(goble.ErrReadNotPermitted)
+	}
+	device, _ := options["device"].Value().(dbus.ObjectPath)
+	return ch.a.request(device, readRequestEvt, func(id int) xpc.Dict {
+		args := xpc.Dict{
+			"kCBMsgArgAttributeID":   int64(ch.id),
+			"kCBMsgArgOffset":        int64(off),
+			"kCBMsgArgTransactionID": int64(id),
+		}
+		if mtu, ok := options["mtu"].Value().(uint16); ok {
+			args["kCBMsgArgATTMTU"] = int64(mtu)
+		}
+		return args
+	})
+}
+
+// WriteValue forwards a write of a remote client to goble
+func (ch *characteristic) WriteValue(value []byte, options map[string]dbus.Variant) *dbus.Error {
+	typ, _ := options["type"].Value().(string)
+	withoutResponse := typ == "command"
+	if !withoutResponse && ch.properties&int(goble.Write) == 0 ||
+		withoutResponse && ch.properties&int(goble.WriteWithoutResponse) == 0 {
+		return dbusError(goble.ErrWriteNotPermitted)
+	}
+	device, _ := options["device"].Value().(dbus.ObjectPath)
+	args := func(id int) xpc.Dict {
+		ignore := int64(0)
+		if withoutResponse {
+			ignore = 1
+		}
+		return xpc.Dict{
+			"kCBMsgArgTransactionID": int64(id),
+			"kCBMsgArgATTWrites": xpc.Array{xpc.Dict{
+				"kCBMsgArgAttributeID":    int64(ch.id),
+				"kCBMsgArgData":           append([]byte{}, value...),
+				"kCBMsgArgIgnoreResponse": ignore,
+				"kCBMsgArgOffset":         int64(offset(options)),
+			}},
+		}
+	}
+	if withoutResponse {
+		ch.a.do(func() {
+			args := args(0)
+			args["kCBMsgArgCentralUUID"] = ch.a.centralUUID(device)
+			ch.a.emit(writeRequestEvt, args)
+		})
+		return nil
+	}
+	_, err := ch.a.request(device, writeRequestEvt, args)
+	return err
+}
+
+// StartNotify subscribes the centrals, bluetoothd doesn't tell which one
+func (ch *characteristic) StartNotify() *dbus.Error {
+	return ch.subscribe(true, subscribeEvt)
+}
+
+// StopNotify unsubscribes the centrals
+func (ch *characteristic) StopNotify() *dbus.Error {
+	return ch.subscribe(false, unsubscribeEvt)
+}
+
+func (ch *characteristic) subscribe(notifying bool, ev int) *dbus.Error {
+	if ch.properties&int(goble.Notify|goble.Indicate) == 0 && notifying {
+		return dbusError(goble.ErrRequestNotSupported)
+	}
+	if was, _ := ch.props.GetMust(characteristicIface, "Notifying").(bool); was == notifying {
+		return nil
+	}
+	ch.props.SetMust(characteristicIface, "Notifying", notifying)
+	ch.a.do(func() {
+		ch.a.emit(ev, xpc.Dict{
+			"kCBMsgArgAttributeID": int64(ch.id),
+			"kCBMsgArgCentralUUID": xpc.UUID{},
+		})
+	})
+	return nil
+}
+
+// ReadValue answers a read of a remote client
+func (d *descriptor) ReadValue(options map[string]dbus.Variant) ([]byte, *dbus.Error) {
+	off := offset(options)
+	if off > len(d.value) {
+		return nil, dbusError(goble.ErrInvalidOffset)
+	}
+	return d.value[off:], nil
+}
+
+// centralUUID returns the goble uuid of a remote client
+func (a *Adapter) centralUUID(device dbus.ObjectPath) xpc.UUID {
+	if props, ok := a.objects[device][deviceIface]; ok {
+		return deviceUUID(props)
+	}
+	// not discovered, the address is in the path: dev_XX_XX_XX_XX_XX_XX
+	s := string(device)
+	addr := strings.TrimPrefix(s[strings.LastIndex(s, "/")+1:], "dev_")
+	return deviceUUID(map[string]dbus.Variant{
+		"Address": dbus.MakeVariant(strings.Replace(addr, "_", ":", -1)),
+	})
+}
+
+// request forwards a request of a remote client to goble and waits for the
+// answer
+func (a *Adapter) request(device dbus.ObjectPath, ev int, args func(id int) xpc.Dict) ([]byte, *dbus.Error) {
+	ch := make(chan xpc.Dict, 1)
+	a.tmu.Lock()
+	a.transaction++
+	id := a.transaction
+	a.transactions[id] = ch
+	a.tmu.Unlock()
+	defer func() {
+		a.tmu.Lock()
+		delete(a.transactions, id)
+		a.tmu.Unlock()
+	}()
+
+	a.do(func() {
+		args := args(id)
+		args["kCBMsgArgCentralUUID"] = a.centralUUID(device)
+		a.emit(ev, args)
+	})
+	select {
+	case r := <-ch:
+		if res := intArg(r, "kCBMsgArgResult"); res != 0 {
+			return nil, dbusError(goble.ATTError(res))
+		}
+		data, _ := r["kCBMsgArgData"].([]byte)
+		return data, nil
+	case <-time.After(requestTimeout):
+		return nil, dbusError(goble.ErrUnlikely)
+	}
+}
+
+// respond completes a forwarded request
+func (a *Adapter) respond(args xpc.Dict) {
+	id := intArg(args, "kCBMsgArgTransactionID")
+	a.tmu.Lock()
+	ch, ok := a.transactions[id]
+	a.tmu.Unlock()
+	if ok {
+		select {
+		case ch <- args:
+		default:
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package goble
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble/xpc"
+)
+
+// Database Hash characteristic of the Generic Attribute service
+var DatabaseHashUUID = UUID16(0x2b2a)
+
+// GATTTree is the discovered attribute tree of a peripheral
+type GATTTree struct {
+	Hash     []byte        `json:",omitempty"` // database hash the tree belongs to, if read
+	Services []GATTService `json:",omitempty"`
+}
+
+// GATTService is a discovered service
+type GATTService struct {
+	UUID            string
+	StartHandle     int
+	EndHandle       int
+	Characteristics []GATTCharacteristic `json:",omitempty"`
+}
+
+// GATTCharacteristic is a discovered characteristic
+type GATTCharacteristic struct {
+	UUID        string
+	Properties  Property
+	Handle      int
+	ValueHandle int
+	Descriptors []GATTDescriptor `json:",omitempty"`
+}
+
+// GATTDescriptor is a discovered descriptor
+type GATTDescriptor struct {
+	UUID   string
+	Handle int
+}
+
+// GATTCache keeps the discovered services of peripherals by device uuid,
+// in memory and optionally in a file
+type GATTCache struct {
+	path string
+
+	mu      sync.Mutex
+	trees   map[string]GATTTree
+	pending *time.Timer // saves the updates of a discovery
+}
+
+// updates of the cache file within a second are written together
+const saveDelay = time.Second
+
+// NewGATTCache creates a cache kept in memory
+func NewGATTCache() *GATTCache {
+	return &GATTCache{trees: map[string]GATTTree{}}
+}
+
+// OpenGATTCache creates a cache saved to a JSON file, loading it if it
+// exists
+func OpenGATTCache(path string) (*GATTCache, error) {
+	c := NewGATTCache()
+	c.path = path
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.trees); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load returns the tree of a peripheral
+func (c *GATTCache) Load(device xpc.UUID) (GATTTree, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.trees[device.String()]
+	return t, ok
+}
+
+// Store replaces the tree of a peripheral
+func (c *GATTCache) Store(device xpc.UUID, t GATTTree) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trees[device.String()] = t
+	return c.save()
+}
+
+// update replaces the tree of a peripheral while it is discovered, the file
+// is saved once the updates settle
+func (c *GATTCache) update(device xpc.UUID, t GATTTree) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trees[device.String()] = t
+	if c.path == "" {
+		return
+	}
+	if c.pending != nil {
+		c.pending.Stop()
+	}
+	c.pending = time.AfterFunc(saveDelay, func() {
+		if err := c.Flush(); err != nil {
+			log.Println("gatt cache:", err)
+		}
+	})
+}
+
+// Flush saves updates of discoveries still pending
+func (c *GATTCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		return nil
+	}
+	return c.save()
+}
+
+// Invalidate drops the tree of a peripheral
+func (c *GATTCache) Invalidate(device xpc.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.trees[device.String()]; !ok {
+		return nil
+	}
+	delete(c.trees, device.String())
+	return c.save()
+}
+
+// save writes the file, replacing it at once
+func (c *GATTCache) save() error {
+	if c.pending != nil {
+		c.pending.Stop()
+		c.pending = nil
+	}
+	if c.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(c.trees, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.path)
+}
+
+// services returns the distinct services of a peripheral in handle order
+func (p Peripheral) services() []*ServiceHandle {
+	var services []*ServiceHandle
+	for k, s := range p.Services {
+		if _, ok := k.(int); ok {
+			services = append(services, s)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].startHandle < services[j].startHandle })
+	return services
+}
+
+// characteristics returns the distinct characteristics of a service in
+// handle order
+func (s ServiceHandle) characteristics() []*ServiceCharacteristic {
+	var characteristics []*ServiceCharacteristic
+	for k, c := range s.Characteristics {
+		if h, ok := k.(int); ok && h == c.Handle {
+			characteristics = append(characteristics, c)
+		}
+	}
+	sort.Slice(characteristics, func(i, j int) bool { return characteristics[i].Handle < characteristics[j].Handle })
+	return characteristics
+}
+
+// descriptors returns the distinct descriptors of a characteristic in
+// handle order
+func (c ServiceCharacteristic) descriptors() []*CharacteristicDescriptor {
+	var descriptors []*CharacteristicDescriptor
+	for k, d := range c.Descriptors {
+		if _, ok := k.(int); ok {
+			descriptors = append(descriptors, d)
+		}
+	}
+	sort.Slice(descriptors, func(i, j int) bool { return descriptors[i].Handle < descriptors[j].Handle })
+	return descriptors
+}
+
+// GATTTree returns the services discovered so far
+func (p Peripheral) GATTTree() GATTTree {
+	var t GATTTree
+	for _, s := range p.services() {
+		gs := GATTService{UUID: s.Uuid, StartHandle: s.startHandle, EndHandle: s.endHandle}
+		for _, c := range s.characteristics() {
+			gc := GATTCharacteristic{UUID: c.Uuid, Properties: c.Properties, Handle: c.Handle, ValueHandle: c.ValueHandle}
+			for _, d := range c.descriptors() {
+				gc.Descriptors = append(gc.Descriptors, GATTDescriptor{UUID: d.Uuid, Handle: d.Handle})
+			}
+			gs.Characteristics = append(gs.Characteristics, gc)
+		}
+		t.Services = append(t.Services, gs)
+	}
+	return t
+}
+
+// handles rebuilds the services of a peripheral from a tree, keyed like
+// discovered ones
+func (t GATTTree) handles() map[interface{}]*ServiceHandle {
+	services := map[interface{}]*ServiceHandle{}
+	for _, gs := range t.Services {
+		s := &ServiceHandle{
+			Uuid:            gs.UUID,
+			startHandle:     gs.StartHandle,
+			endHandle:       gs.EndHandle,
+			Characteristics: map[interface{}]*ServiceCharacteristic{},
+		}
+		if nameType, ok := LookupService(s.Uuid); ok {
+			s.Name, s.Type = nameType.Name, nameType.Type
+		}
+		for _, gc := range gs.Characteristics {
+			c := &ServiceCharacteristic{
+				Uuid:        gc.UUID,
+				Properties:  gc.Properties,
+				Handle:      gc.Handle,
+				ValueHandle: gc.ValueHandle,
+				Descriptors: map[interface{}]*CharacteristicDescriptor{},
+			}
+			if nameType, ok := LookupCharacteristic(c.Uuid); ok {
+				c.Name, c.Type = nameType.Name, nameType.Type
+			}
+			for _, gd := range gc.Descriptors {
+				d := &CharacteristicDescriptor{Uuid: gd.UUID, Handle: gd.Handle}
+				if nameType, ok := LookupDescriptor(d.Uuid); ok {
+					d.Name, d.Type = nameType.Name, nameType.Type
+				}
+				c.Descriptors[d.Uuid] = d
+				c.Descriptors[d.Handle] = d
+			}
+			s.Characteristics[c.Uuid] = c
+			s.Characteristics[c.Handle] = c
+			s.Characteristics[c.ValueHandle] = c
+		}
+		services[s.Uuid] = s
+		services[s.startHandle] = s
+	}
+	return services
+}
+
+// SetGATTCache keeps discovered services in c, nil stops caching
+func (ble *BLE) SetGATTCache(c *GATTCache) {
+	ble.mu.Lock()
+	ble.cache = c
+	ble.mu.Unlock()
+}
+
+// GATTCache returns the cache set with SetGATTCache
+func (ble *BLE) GATTCache() *GATTCache {
+	ble.mu.Lock()
+	defer ble.mu.Unlock()
+	return ble.cache
+}
+
+// RestoreServices returns a peripheral with the services restored from the
+// cache on connect, so they can be used without discovery. Services of a
+// peripheral with a Database Hash characteristic are kept apart until a
+// read of the hash confirms them, and dropped if it differs. It returns
+// false if nothing is cached.
+func (ble *BLE) RestoreServices(deviceUuid xpc.UUID) (Peripheral, bool) {
+	ble.mu.Lock()
+	defer ble.mu.Unlock()
+	p, ok := ble.peripherals[deviceUuid.String()]
+	if !ok || ble.cache == nil {
+		return Peripheral{}, false
+	}
+	if p.restored != nil {
+		restored := *p
+		restored.Services = p.restored
+		return restored, true
+	}
+	t, ok := ble.cache.Load(deviceUuid)
+	return *p, ok && len(t.Services) > 0
+}
+
+// restore fills the services of a connected peripheral from the cache,
+// ble.mu is held
+func (ble *BLE) restore(p *Peripheral) {
+	p.restored = nil
+	if ble.cache == nil {
+		return
+	}
+	t, ok := ble.cache.Load(p.Uuid)
+	if !ok || len(t.Services) == 0 {
+		return
+	}
+	services := t.handles()
+	if s, ok := (Peripheral{Services: services}).Service(GenericAttributeUUID.String()); ok {
+		if _, ok := s.Characteristic(DatabaseHashUUID.String()); ok {
+			p.restored = services
+			return
+		}
+	}
+	p.Services = services
+}
+
+// cacheServices stores the services discovered so far, keeping the hash,
+// ble.mu is held
+func (ble *BLE) cacheServices(p *Peripheral) {
+	if ble.cache == nil {
+		return
+	}
+	t := p.GATTTree()
+	if old, ok := ble.cache.Load(p.Uuid); ok {
+		t.Hash = old.Hash
+	}
+	ble.cache.update(p.Uuid, t)
+}
+
+// checkCache invalidates the cached services on a Service Changed
+// indication or a database hash differing from the cached one, and puts
+// the restored services in use once the hash matches, ble.mu is held
+func (ble *BLE) checkCache(p *Peripheral, characteristicUuid string, data []byte, isNotification bool) {
+	if ble.cache == nil {
+		return
+	}
+	switch {
+	case isNotification && EqualUUID(characteristicUuid, ServiceChangedUUID.String()):
+		p.restored = nil
+		if err := ble.cache.Invalidate(p.Uuid); err != nil {
+			log.Println("gatt cache:", err)
+		}
+	case !isNotification && EqualUUID(characteristicUuid, DatabaseHashUUID.String()):
+		t, ok := ble.cache.Load(p.Uuid)
+		if ok && t.Hash != nil && !bytes.Equal(t.Hash, data) {
+			t = GATTTree{}
+		} else if p.restored != nil {
+			p.Services = p.restored
+		}
+		p.restored = nil
+		if !bytes.Equal(t.Hash, data) {
+			t.Hash = append([]byte(nil), data...)
+			if err := ble.cache.Store(p.Uuid, t); err != nil {
+				log.Println("gatt cache:", err)
+			}
+		}
+	}
+}
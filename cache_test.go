@@ -0,0 +1,176 @@
+package goble
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dim13/goble/xpc"
+)
+
+func TestGATTCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gatt.json")
+
+	device := xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	tree := GATTTree{Services: []GATTService{
+		{UUID: "1801", StartHandle: 1, EndHandle: 4, Characteristics: []GATTCharacteristic{
+			{UUID: "2a05", Properties: Indicate, Handle: 2, ValueHandle: 3, Descriptors: []GATTDescriptor{{UUID: "2902", Handle: 4}}},
+		}},
+		{UUID: "180f", StartHandle: 5, EndHandle: 7, Characteristics: []GATTCharacteristic{
+			{UUID: "2a19", Properties: Read, Handle: 6, ValueHandle: 7},
+		}},
+	}}
+	if got := (Peripheral{Services: tree.handles()}).GATTTree(); !reflect.DeepEqual(got, tree) {
+		t.Errorf("got %+v, want %+v", got, tree)
+	}
+
+	c, err := OpenGATTCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Store(device, tree); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = OpenGATTCache(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := c.Load(device); !ok || !reflect.DeepEqual(got, tree) {
+		t.Fatalf("got %+v, want %+v", got, tree)
+	}
+
+	ble := NewWithTransport(&messages{}, "")
+	ble.SetGATTCache(c)
+	p := &Peripheral{Uuid: device}
+	ble.checkCache(p, "2b2a", []byte{1}, false)
+	if got, _ := c.Load(device); len(got.Services) != 2 || got.Hash[0] != 1 {
+		t.Errorf("first hash dropped services: %+v", got)
+	}
+	ble.checkCache(p, "2b2a", []byte{1}, false)
+	if got, _ := c.Load(device); len(got.Services) != 2 {
+		t.Errorf("same hash dropped services: %+v", got)
+	}
+	ble.checkCache(p, "2b2a", []byte{2}, false)
+	if got, _ := c.Load(device); len(got.Services) != 0 || got.Hash[0] != 2 {
+		t.Errorf("changed hash kept services: %+v", got)
+	}
+
+	c.Store(device, tree)
+	ble.checkCache(p, "2a05", []byte{1, 0, 0xff, 0xff}, true)
+	if _, ok := c.Load(device); ok {
+		t.Error("service changed kept services")
+	}
+	if c, err = OpenGATTCache(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Load(device); ok {
+		t.Error("invalidation not saved")
+	}
+}
+
+func TestGATTCacheFlush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gatt.json")
+
+	c, err := OpenGATTCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	for i := 1; i <= 3; i++ {
+		c.update(device, GATTTree{Services: make([]GATTService, i)})
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("saved during discovery: %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = OpenGATTCache(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Load(device); len(got.Services) != 3 {
+		t.Errorf("got %d services, want 3", len(got.Services))
+	}
+}
+
+func TestRestoreOnConnect(t *testing.T) {
+	device := xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	tree := GATTTree{Hash: []byte{1}, Services: []GATTService{
+		{UUID: "1801", StartHandle: 1, EndHandle: 3, Characteristics: []GATTCharacteristic{
+			{UUID: "2b2a", Properties: Read, Handle: 2, ValueHandle: 3},
+		}},
+		{UUID: "180f", StartHandle: 4, EndHandle: 6, Characteristics: []GATTCharacteristic{
+			{UUID: "2a19", Properties: Read, Handle: 5, ValueHandle: 6},
+		}},
+	}}
+	c := NewGATTCache()
+	ble := NewWithTransport(transport{}, "")
+	ble.SetGATTCache(c)
+	ble.peripherals[device.String()] = &Peripheral{Uuid: device, Services: map[interface{}]*ServiceHandle{}}
+	events := make(chan Event, 4)
+	ble.Listen(func(ev Event) bool {
+		events <- ev
+		return false
+	})
+	connect := func() {
+		ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(38), "kCBMsgArgs": xpc.Dict{
+			"kCBMsgArgDeviceUUID": device,
+		}}, nil)
+		<-events
+	}
+	readHash := func(hash byte) {
+		ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(70), "kCBMsgArgs": xpc.Dict{
+			"kCBMsgArgDeviceUUID":           device,
+			"kCBMsgArgCharacteristicHandle": int64(3),
+			"kCBMsgArgData":                 []byte{hash},
+		}}, nil)
+		if ev := <-events; ev.ServiceUuid != "1801" || ev.CharacteristicUuid != "2b2a" {
+			t.Errorf("got read of %v %v", ev.ServiceUuid, ev.CharacteristicUuid)
+		}
+	}
+
+	// the restored services are kept apart until the hash confirms them
+	c.Store(device, tree)
+	connect()
+	if p, ok := ble.RestoreServices(device); !ok || p.Services["180f"] == nil {
+		t.Errorf("got %v %v", p.Services, ok)
+	}
+	if p, _ := ble.peripheral(device); p.Services["180f"] != nil {
+		t.Error("services used before the hash is read")
+	}
+	readHash(1)
+	if p, _ := ble.peripheral(device); p.Services["180f"] == nil || p.restored != nil {
+		t.Errorf("confirmed services not used: %v", p.Services)
+	}
+
+	// and dropped if it differs
+	ble.peripherals[device.String()].Services = map[interface{}]*ServiceHandle{}
+	connect()
+	readHash(2)
+	if _, ok := ble.RestoreServices(device); ok {
+		t.Error("changed hash kept services")
+	}
+	ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(54), "kCBMsgArgs": xpc.Dict{
+		"kCBMsgArgDeviceUUID": device,
+		"kCBMsgArgServices": xpc.Array{xpc.Dict{
+			"kCBMsgArgUUID":               []byte{0x18, 0x01},
+			"kCBMsgArgServiceStartHandle": int64(1),
+			"kCBMsgArgServiceEndHandle":   int64(3),
+		}},
+	}}, nil)
+	<-events
+	if p, _ := ble.peripheral(device); p.Services["180f"] != nil || p.Services[4] != nil {
+		t.Errorf("stale services after discovery: %v", p.Services)
+	}
+}
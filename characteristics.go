@@ -1,295 +1,462 @@
+// Code generated by "go run gen.go"; DO NOT EDIT.
+
 package goble
 
 // A dictionary of known characteristic names and type (keyed by characteristic uuid)
-var knownCharacteristics = map[string]struct {
-	Name, Type string
-}{
-	"2a00": {
-		Name: "Device Name",
-		Type: "org.bluetooth.characteristic.gap.device_name",
-	},
-	"2a01": {
-		Name: "Appearance",
-		Type: "org.bluetooth.characteristic.gap.appearance",
-	},
-	"2a02": {
-		Name: "Peripheral Privacy Flag",
-		Type: "org.bluetooth.characteristic.gap.peripheral_privacy_flag",
-	},
-	"2a03": {
-		Name: "Reconnection Address",
-		Type: "org.bluetooth.characteristic.gap.reconnection_address",
-	},
-	"2a04": {
-		Name: "Peripheral Preferred Connection Parameters",
-		Type: "org.bluetooth.characteristic.gap.peripheral_preferred_connection_parameters",
-	},
-	"2a05": {
-		Name: "Service Changed",
-		Type: "org.bluetooth.characteristic.gatt.service_changed",
-	},
-	"2a06": {
-		Name: "Alert Level",
-		Type: "org.bluetooth.characteristic.alert_level",
-	},
-	"2a07": {
-		Name: "Tx Power Level",
-		Type: "org.bluetooth.characteristic.tx_power_level",
-	},
-	"2a08": {
-		Name: "Date Time",
-		Type: "org.bluetooth.characteristic.date_time",
-	},
-	"2a09": {
-		Name: "Day of Week",
-		Type: "org.bluetooth.characteristic.day_of_week",
-	},
-	"2a0a": {
-		Name: "Day Date Time",
-		Type: "org.bluetooth.characteristic.day_date_time",
-	},
-	"2a0c": {
-		Name: "Exact Time 256",
-		Type: "org.bluetooth.characteristic.exact_time_256",
-	},
-	"2a0d": {
-		Name: "DST Offset",
-		Type: "org.bluetooth.characteristic.dst_offset",
-	},
-	"2a0e": {
-		Name: "Time Zone",
-		Type: "org.bluetooth.characteristic.time_zone",
-	},
-	"2a0f": {
-		Name: "Local Time Information",
-		Type: "org.bluetooth.characteristic.local_time_information",
-	},
-	"2a11": {
-		Name: "Time with DST",
-		Type: "org.bluetooth.characteristic.time_with_dst",
-	},
-	"2a12": {
-		Name: "Time Accuracy",
-		Type: "org.bluetooth.characteristic.time_accuracy",
-	},
-	"2a13": {
-		Name: "Time Source",
-		Type: "org.bluetooth.characteristic.time_source",
-	},
-	"2a14": {
-		Name: "Reference Time Information",
-		Type: "org.bluetooth.characteristic.reference_time_information",
-	},
-	"2a16": {
-		Name: "Time Update Control Point",
-		Type: "org.bluetooth.characteristic.time_update_control_point",
-	},
-	"2a17": {
-		Name: "Time Update State",
-		Type: "org.bluetooth.characteristic.time_update_state",
-	},
-	"2a18": {
-		Name: "Glucose Measurement",
-		Type: "org.bluetooth.characteristic.glucose_measurement",
-	},
-	"2a19": {
-		Name: "Battery Level",
-		Type: "org.bluetooth.characteristic.battery_level",
-	},
-	"2a1c": {
-		Name: "Temperature Measurement",
-		Type: "org.bluetooth.characteristic.temperature_measurement",
-	},
-	"2a1d": {
-		Name: "Temperature Type",
-		Type: "org.bluetooth.characteristic.temperature_type",
-	},
-	"2a1e": {
-		Name: "Intermediate Temperature",
-		Type: "org.bluetooth.characteristic.intermediate_temperature",
-	},
-	"2a21": {
-		Name: "Measurement Interval",
-		Type: "org.bluetooth.characteristic.measurement_interval",
-	},
-	"2a22": {
-		Name: "Boot Keyboard Input Report",
-		Type: "org.bluetooth.characteristic.boot_keyboard_input_report",
-	},
-	"2a23": {
-		Name: "System ID",
-		Type: "org.bluetooth.characteristic.system_id",
-	},
-	"2a24": {
-		Name: "Model Number String",
-		Type: "org.bluetooth.characteristic.model_number_string",
-	},
-	"2a25": {
-		Name: "Serial Number String",
-		Type: "org.bluetooth.characteristic.serial_number_string",
-	},
-	"2a26": {
-		Name: "Firmware Revision String",
-		Type: "org.bluetooth.characteristic.firmware_revision_string",
-	},
-	"2a27": {
-		Name: "Hardware Revision String",
-		Type: "org.bluetooth.characteristic.hardware_revision_string",
-	},
-	"2a28": {
-		Name: "Software Revision String",
-		Type: "org.bluetooth.characteristic.software_revision_string",
-	},
-	"2a29": {
-		Name: "Manufacturer Name String",
-		Type: "org.bluetooth.characteristic.manufacturer_name_string",
-	},
-	"2a2a": {
-		Name: "IEEE 11073-20601 Regulatory Certification Data List",
-		Type: "org.bluetooth.characteristic.ieee_11073-20601_regulatory_certification_data_list",
-	},
-	"2a2b": {
-		Name: "Current Time",
-		Type: "org.bluetooth.characteristic.current_time",
-	},
-	"2a31": {
-		Name: "Scan Refresh",
-		Type: "org.bluetooth.characteristic.scan_refresh",
-	},
-	"2a32": {
-		Name: "Boot Keyboard Output Report",
-		Type: "org.bluetooth.characteristic.boot_keyboard_output_report",
-	},
-	"2a33": {
-		Name: "Boot Mouse Input Report",
-		Type: "org.bluetooth.characteristic.boot_mouse_input_report",
-	},
-	"2a34": {
-		Name: "Glucose Measurement Context",
-		Type: "org.bluetooth.characteristic.glucose_measurement_context",
-	},
-	"2a35": {
-		Name: "Blood Pressure Measurement",
-		Type: "org.bluetooth.characteristic.blood_pressure_measurement",
-	},
-	"2a36": {
-		Name: "Intermediate Cuff Pressure",
-		Type: "org.bluetooth.characteristic.intermediate_blood_pressure",
-	},
-	"2a37": {
-		Name: "Heart Rate Measurement",
-		Type: "org.bluetooth.characteristic.heart_rate_measurement",
-	},
-	"2a38": {
-		Name: "Body Sensor Location",
-		Type: "org.bluetooth.characteristic.body_sensor_location",
-	},
-	"2a39": {
-		Name: "Heart Rate Control Point",
-		Type: "org.bluetooth.characteristic.heart_rate_control_point",
-	},
-	"2a3f": {
-		Name: "Alert Status",
-		Type: "org.bluetooth.characteristic.alert_status",
-	},
-	"2a40": {
-		Name: "Ringer Control Point",
-		Type: "org.bluetooth.characteristic.ringer_control_point",
-	},
-	"2a41": {
-		Name: "Ringer Setting",
-		Type: "org.bluetooth.characteristic.ringer_setting",
-	},
-	"2a42": {
-		Name: "Alert Category ID Bit Mask",
-		Type: "org.bluetooth.characteristic.alert_category_id_bit_mask",
-	},
-	"2a43": {
-		Name: "Alert Category ID",
-		Type: "org.bluetooth.characteristic.alert_category_id",
-	},
-	"2a44": {
-		Name: "Alert Notification Control Point",
-		Type: "org.bluetooth.characteristic.alert_notification_control_point",
-	},
-	"2a45": {
-		Name: "Unread Alert Status",
-		Type: "org.bluetooth.characteristic.unread_alert_status",
-	},
-	"2a46": {
-		Name: "New Alert",
-		Type: "org.bluetooth.characteristic.new_alert",
-	},
-	"2a47": {
-		Name: "Supported New Alert Category",
-		Type: "org.bluetooth.characteristic.supported_new_alert_category",
-	},
-	"2a48": {
-		Name: "Supported Unread Alert Category",
-		Type: "org.bluetooth.characteristic.supported_unread_alert_category",
-	},
-	"2a49": {
-		Name: "Blood Pressure Feature",
-		Type: "org.bluetooth.characteristic.blood_pressure_feature",
-	},
-	"2a4a": {
-		Name: "HID Information",
-		Type: "org.bluetooth.characteristic.hid_information",
-	},
-	"2a4b": {
-		Name: "Report Map",
-		Type: "org.bluetooth.characteristic.report_map",
-	},
-	"2a4c": {
-		Name: "HID Control Point",
-		Type: "org.bluetooth.characteristic.hid_control_point",
-	},
-	"2a4d": {
-		Name: "Report",
-		Type: "org.bluetooth.characteristic.report",
-	},
-	"2a4e": {
-		Name: "Protocol Mode",
-		Type: "org.bluetooth.characteristic.protocol_mode",
-	},
-	"2a4f": {
-		Name: "Scan Interval Window",
-		Type: "org.bluetooth.characteristic.scan_interval_window",
-	},
-	"2a50": {
-		Name: "PnP ID",
-		Type: "org.bluetooth.characteristic.pnp_id",
-	},
-	"2a51": {
-		Name: "Glucose Feature",
-		Type: "org.bluetooth.characteristic.glucose_feature",
-	},
-	"2a52": {
-		Name: "Record Access Control Point",
-		Type: "org.bluetooth.characteristic.record_access_control_point",
-	},
-	"2a53": {
-		Name: "RSC Measurement",
-		Type: "org.bluetooth.characteristic.rsc_measurement",
-	},
-	"2a54": {
-		Name: "RSC Feature",
-		Type: "org.bluetooth.characteristic.rsc_feature",
-	},
-	"2a55": {
-		Name: "SC Control Point",
-		Type: "org.bluetooth.characteristic.sc_control_point",
-	},
-	"2a5b": {
-		Name: "CSC Measurement",
-		Type: "org.bluetooth.characteristic.csc_measurement",
-	},
-	"2a5c": {
-		Name: "CSC Feature",
-		Type: "org.bluetooth.characteristic.csc_feature",
-	},
-	"2a5d": {
-		Name: "Sensor Location",
-		Type: "org.bluetooth.characteristic.sensor_location",
-	},
+var knownCharacteristics = map[string]AssignedNumber{
+	"2a00": {Name: "Device Name", Type: "org.bluetooth.characteristic.gap.device_name"},
+	"2a01": {Name: "Appearance", Type: "org.bluetooth.characteristic.gap.appearance"},
+	"2a02": {Name: "Peripheral Privacy Flag", Type: "org.bluetooth.characteristic.gap.peripheral_privacy_flag"},
+	"2a03": {Name: "Reconnection Address", Type: "org.bluetooth.characteristic.gap.reconnection_address"},
+	"2a04": {Name: "Peripheral Preferred Connection Parameters", Type: "org.bluetooth.characteristic.gap.peripheral_preferred_connection_parameters"},
+	"2a05": {Name: "Service Changed", Type: "org.bluetooth.characteristic.gatt.service_changed"},
+	"2a06": {Name: "Alert Level", Type: "org.bluetooth.characteristic.alert_level"},
+	"2a07": {Name: "Tx Power Level", Type: "org.bluetooth.characteristic.tx_power_level"},
+	"2a08": {Name: "Date Time", Type: "org.bluetooth.characteristic.date_time"},
+	"2a09": {Name: "Day of Week", Type: "org.bluetooth.characteristic.day_of_week"},
+	"2a0a": {Name: "Day Date Time", Type: "org.bluetooth.characteristic.day_date_time"},
+	"2a0b": {Name: "Exact Time 100", Type: "org.bluetooth.characteristic.exact_time_100"},
+	"2a0c": {Name: "Exact Time 256", Type: "org.bluetooth.characteristic.exact_time_256"},
+	"2a0d": {Name: "DST Offset", Type: "org.bluetooth.characteristic.dst_offset"},
+	"2a0e": {Name: "Time Zone", Type: "org.bluetooth.characteristic.time_zone"},
+	"2a0f": {Name: "Local Time Information", Type: "org.bluetooth.characteristic.local_time_information"},
+	"2a10": {Name: "Secondary Time Zone", Type: "org.bluetooth.characteristic.secondary_time_zone"},
+	"2a11": {Name: "Time with DST", Type: "org.bluetooth.characteristic.time_with_dst"},
+	"2a12": {Name: "Time Accuracy", Type: "org.bluetooth.characteristic.time_accuracy"},
+	"2a13": {Name: "Time Source", Type: "org.bluetooth.characteristic.time_source"},
+	"2a14": {Name: "Reference Time Information", Type: "org.bluetooth.characteristic.reference_time_information"},
+	"2a15": {Name: "Time Broadcast", Type: "org.bluetooth.characteristic.time_broadcast"},
+	"2a16": {Name: "Time Update Control Point", Type: "org.bluetooth.characteristic.time_update_control_point"},
+	"2a17": {Name: "Time Update State", Type: "org.bluetooth.characteristic.time_update_state"},
+	"2a18": {Name: "Glucose Measurement", Type: "org.bluetooth.characteristic.glucose_measurement"},
+	"2a19": {Name: "Battery Level", Type: "org.bluetooth.characteristic.battery_level"},
+	"2a1a": {Name: "Battery Power State", Type: "org.bluetooth.characteristic.battery_power_state"},
+	"2a1b": {Name: "Battery Level State", Type: "org.bluetooth.characteristic.battery_level_state"},
+	"2a1c": {Name: "Temperature Measurement", Type: "org.bluetooth.characteristic.temperature_measurement"},
+	"2a1d": {Name: "Temperature Type", Type: "org.bluetooth.characteristic.temperature_type"},
+	"2a1e": {Name: "Intermediate Temperature", Type: "org.bluetooth.characteristic.intermediate_temperature"},
+	"2a1f": {Name: "Temperature Celsius", Type: "org.bluetooth.characteristic.temperature_celsius"},
+	"2a20": {Name: "Temperature Fahrenheit", Type: "org.bluetooth.characteristic.temperature_fahrenheit"},
+	"2a21": {Name: "Measurement Interval", Type: "org.bluetooth.characteristic.measurement_interval"},
+	"2a22": {Name: "Boot Keyboard Input Report", Type: "org.bluetooth.characteristic.boot_keyboard_input_report"},
+	"2a23": {Name: "System ID", Type: "org.bluetooth.characteristic.system_id"},
+	"2a24": {Name: "Model Number String", Type: "org.bluetooth.characteristic.model_number_string"},
+	"2a25": {Name: "Serial Number String", Type: "org.bluetooth.characteristic.serial_number_string"},
+	"2a26": {Name: "Firmware Revision String", Type: "org.bluetooth.characteristic.firmware_revision_string"},
+	"2a27": {Name: "Hardware Revision String", Type: "org.bluetooth.characteristic.hardware_revision_string"},
+	"2a28": {Name: "Software Revision String", Type: "org.bluetooth.characteristic.software_revision_string"},
+	"2a29": {Name: "Manufacturer Name String", Type: "org.bluetooth.characteristic.manufacturer_name_string"},
+	"2a2a": {Name: "IEEE 11073-20601 Regulatory Certification Data List", Type: "org.bluetooth.characteristic.ieee_11073-20601_regulatory_certification_data_list"},
+	"2a2b": {Name: "Current Time", Type: "org.bluetooth.characteristic.current_time"},
+	"2a2c": {Name: "Magnetic Declination", Type: "org.bluetooth.characteristic.magnetic_declination"},
+	"2a2f": {Name: "Position 2D", Type: "org.bluetooth.characteristic.position_2d"},
+	"2a30": {Name: "Position 3D", Type: "org.bluetooth.characteristic.position_3d"},
+	"2a31": {Name: "Scan Refresh", Type: "org.bluetooth.characteristic.scan_refresh"},
+	"2a32": {Name: "Boot Keyboard Output Report", Type: "org.bluetooth.characteristic.boot_keyboard_output_report"},
+	"2a33": {Name: "Boot Mouse Input Report", Type: "org.bluetooth.characteristic.boot_mouse_input_report"},
+	"2a34": {Name: "Glucose Measurement Context", Type: "org.bluetooth.characteristic.glucose_measurement_context"},
+	"2a35": {Name: "Blood Pressure Measurement", Type: "org.bluetooth.characteristic.blood_pressure_measurement"},
+	"2a36": {Name: "Intermediate Cuff Pressure", Type: "org.bluetooth.characteristic.intermediate_blood_pressure"},
+	"2a37": {Name: "Heart Rate Measurement", Type: "org.bluetooth.characteristic.heart_rate_measurement"},
+	"2a38": {Name: "Body Sensor Location", Type: "org.bluetooth.characteristic.body_sensor_location"},
+	"2a39": {Name: "Heart Rate Control Point", Type: "org.bluetooth.characteristic.heart_rate_control_point"},
+	"2a3a": {Name: "Removable", Type: "org.bluetooth.characteristic.removable"},
+	"2a3b": {Name: "Service Required", Type: "org.bluetooth.characteristic.service_required"},
+	"2a3c": {Name: "Scientific Temperature Celsius", Type: "org.bluetooth.characteristic.scientific_temperature_celsius"},
+	"2a3d": {Name: "String", Type: "org.bluetooth.characteristic.string"},
+	"2a3e": {Name: "Network Availability", Type: "org.bluetooth.characteristic.network_availability"},
+	"2a3f": {Name: "Alert Status", Type: "org.bluetooth.characteristic.alert_status"},
+	"2a40": {Name: "Ringer Control point", Type: "org.bluetooth.characteristic.ringer_control_point"},
+	"2a41": {Name: "Ringer Setting", Type: "org.bluetooth.characteristic.ringer_setting"},
+	"2a42": {Name: "Alert Category ID Bit Mask", Type: "org.bluetooth.characteristic.alert_category_id_bit_mask"},
+	"2a43": {Name: "Alert Category ID", Type: "org.bluetooth.characteristic.alert_category_id"},
+	"2a44": {Name: "Alert Notification Control Point", Type: "org.bluetooth.characteristic.alert_notification_control_point"},
+	"2a45": {Name: "Unread Alert Status", Type: "org.bluetooth.characteristic.unread_alert_status"},
+	"2a46": {Name: "New Alert", Type: "org.bluetooth.characteristic.new_alert"},
+	"2a47": {Name: "Supported New Alert Category", Type: "org.bluetooth.characteristic.supported_new_alert_category"},
+	"2a48": {Name: "Supported Unread Alert Category", Type: "org.bluetooth.characteristic.supported_unread_alert_category"},
+	"2a49": {Name: "Blood Pressure Feature", Type: "org.bluetooth.characteristic.blood_pressure_feature"},
+	"2a4a": {Name: "HID Information", Type: "org.bluetooth.characteristic.hid_information"},
+	"2a4b": {Name: "Report Map", Type: "org.bluetooth.characteristic.report_map"},
+	"2a4c": {Name: "HID Control Point", Type: "org.bluetooth.characteristic.hid_control_point"},
+	"2a4d": {Name: "Report", Type: "org.bluetooth.characteristic.report"},
+	"2a4e": {Name: "Protocol Mode", Type: "org.bluetooth.characteristic.protocol_mode"},
+	"2a4f": {Name: "Scan Interval Window", Type: "org.bluetooth.characteristic.scan_interval_window"},
+	"2a50": {Name: "PnP ID", Type: "org.bluetooth.characteristic.pnp_id"},
+	"2a51": {Name: "Glucose Feature", Type: "org.bluetooth.characteristic.glucose_feature"},
+	"2a52": {Name: "Record Access Control Point", Type: "org.bluetooth.characteristic.record_access_control_point"},
+	"2a53": {Name: "RSC Measurement", Type: "org.bluetooth.characteristic.rsc_measurement"},
+	"2a54": {Name: "RSC Feature", Type: "org.bluetooth.characteristic.rsc_feature"},
+	"2a55": {Name: "SC Control Point", Type: "org.bluetooth.characteristic.sc_control_point"},
+	"2a56": {Name: "Digital", Type: "org.bluetooth.characteristic.digital"},
+	"2a57": {Name: "Digital Output", Type: "org.bluetooth.characteristic.digital_output"},
+	"2a58": {Name: "Analog", Type: "org.bluetooth.characteristic.analog"},
+	"2a59": {Name: "Analog Output", Type: "org.bluetooth.characteristic.analog_output"},
+	"2a5a": {Name: "Aggregate", Type: "org.bluetooth.characteristic.aggregate"},
+	"2a5b": {Name: "CSC Measurement", Type: "org.bluetooth.characteristic.csc_measurement"},
+	"2a5c": {Name: "CSC Feature", Type: "org.bluetooth.characteristic.csc_feature"},
+	"2a5d": {Name: "Sensor Location", Type: "org.bluetooth.characteristic.sensor_location"},
+	"2a5e": {Name: "PLX Spot-Check Measurement", Type: "org.bluetooth.characteristic.plx_spot_check_measurement"},
+	"2a5f": {Name: "PLX Continuous Measurement Characteristic", Type: "org.bluetooth.characteristic.plx_continuous_measurement_characteristic"},
+	"2a60": {Name: "PLX Features", Type: "org.bluetooth.characteristic.plx_features"},
+	"2a62": {Name: "Pulse Oximetry Control Point", Type: "org.bluetooth.characteristic.pulse_oximetry_control_point"},
+	"2a63": {Name: "Cycling Power Measurement", Type: "org.bluetooth.characteristic.cycling_power_measurement"},
+	"2a64": {Name: "Cycling Power Vector", Type: "org.bluetooth.characteristic.cycling_power_vector"},
+	"2a65": {Name: "Cycling Power Feature", Type: "org.bluetooth.characteristic.cycling_power_feature"},
+	"2a66": {Name: "Cycling Power Control Point", Type: "org.bluetooth.characteristic.cycling_power_control_point"},
+	"2a67": {Name: "Location and Speed Characteristic", Type: "org.bluetooth.characteristic.location_and_speed_characteristic"},
+	"2a68": {Name: "Navigation", Type: "org.bluetooth.characteristic.navigation"},
+	"2a69": {Name: "Position Quality", Type: "org.bluetooth.characteristic.position_quality"},
+	"2a6a": {Name: "LN Feature", Type: "org.bluetooth.characteristic.ln_feature"},
+	"2a6b": {Name: "LN Control Point", Type: "org.bluetooth.characteristic.ln_control_point"},
+	"2a6c": {Name: "Elevation", Type: "org.bluetooth.characteristic.elevation"},
+	"2a6d": {Name: "Pressure", Type: "org.bluetooth.characteristic.pressure"},
+	"2a6e": {Name: "Temperature", Type: "org.bluetooth.characteristic.temperature"},
+	"2a6f": {Name: "Humidity", Type: "org.bluetooth.characteristic.humidity"},
+	"2a70": {Name: "True Wind Speed", Type: "org.bluetooth.characteristic.true_wind_speed"},
+	"2a71": {Name: "True Wind Direction", Type: "org.bluetooth.characteristic.true_wind_direction"},
+	"2a72": {Name: "Apparent Wind Speed", Type: "org.bluetooth.characteristic.apparent_wind_speed"},
+	"2a73": {Name: "Apparent Wind Direction", Type: "org.bluetooth.characteristic.apparent_wind_direction"},
+	"2a74": {Name: "Gust Factor", Type: "org.bluetooth.characteristic.gust_factor"},
+	"2a75": {Name: "Pollen Concentration", Type: "org.bluetooth.characteristic.pollen_concentration"},
+	"2a76": {Name: "UV Index", Type: "org.bluetooth.characteristic.uv_index"},
+	"2a77": {Name: "Irradiance", Type: "org.bluetooth.characteristic.irradiance"},
+	"2a78": {Name: "Rainfall", Type: "org.bluetooth.characteristic.rainfall"},
+	"2a79": {Name: "Wind Chill", Type: "org.bluetooth.characteristic.wind_chill"},
+	"2a7a": {Name: "Heat Index", Type: "org.bluetooth.characteristic.heat_index"},
+	"2a7b": {Name: "Dew Point", Type: "org.bluetooth.characteristic.dew_point"},
+	"2a7d": {Name: "Descriptor Value Changed", Type: "org.bluetooth.characteristic.descriptor_value_changed"},
+	"2a7e": {Name: "Aerobic Heart Rate Lower Limit", Type: "org.bluetooth.characteristic.aerobic_heart_rate_lower_limit"},
+	"2a7f": {Name: "Aerobic Threshold", Type: "org.bluetooth.characteristic.aerobic_threshold"},
+	"2a80": {Name: "Age", Type: "org.bluetooth.characteristic.age"},
+	"2a81": {Name: "Anaerobic Heart Rate Lower Limit", Type: "org.bluetooth.characteristic.anaerobic_heart_rate_lower_limit"},
+	"2a82": {Name: "Anaerobic Heart Rate Upper Limit", Type: "org.bluetooth.characteristic.anaerobic_heart_rate_upper_limit"},
+	"2a83": {Name: "Anaerobic Threshold", Type: "org.bluetooth.characteristic.anaerobic_threshold"},
+	"2a84": {Name: "Aerobic Heart Rate Upper Limit", Type: "org.bluetooth.characteristic.aerobic_heart_rate_upper_limit"},
+	"2a85": {Name: "Date of Birth", Type: "org.bluetooth.characteristic.date_of_birth"},
+	"2a86": {Name: "Date of Threshold Assessment", Type: "org.bluetooth.characteristic.date_of_threshold_assessment"},
+	"2a87": {Name: "Email Address", Type: "org.bluetooth.characteristic.email_address"},
+	"2a88": {Name: "Fat Burn Heart Rate Lower Limit", Type: "org.bluetooth.characteristic.fat_burn_heart_rate_lower_limit"},
+	"2a89": {Name: "Fat Burn Heart Rate Upper Limit", Type: "org.bluetooth.characteristic.fat_burn_heart_rate_upper_limit"},
+	"2a8a": {Name: "First Name", Type: "org.bluetooth.characteristic.first_name"},
+	"2a8b": {Name: "Five Zone Heart Rate Limits", Type: "org.bluetooth.characteristic.five_zone_heart_rate_limits"},
+	"2a8c": {Name: "Gender", Type: "org.bluetooth.characteristic.gender"},
+	"2a8d": {Name: "Heart Rate Max", Type: "org.bluetooth.characteristic.heart_rate_max"},
+	"2a8e": {Name: "Height", Type: "org.bluetooth.characteristic.height"},
+	"2a8f": {Name: "Hip Circumference", Type: "org.bluetooth.characteristic.hip_circumference"},
+	"2a90": {Name: "Last Name", Type: "org.bluetooth.characteristic.last_name"},
+	"2a91": {Name: "Maximum Recommended Heart Rate", Type: "org.bluetooth.characteristic.maximum_recommended_heart_rate"},
+	"2a92": {Name: "Resting Heart Rate", Type: "org.bluetooth.characteristic.resting_heart_rate"},
+	"2a93": {Name: "Sport Type for Aerobic and Anaerobic Thresholds", Type: "org.bluetooth.characteristic.sport_type_for_aerobic_and_anaerobic_thresholds"},
+	"2a94": {Name: "Three Zone Heart Rate Limits", Type: "org.bluetooth.characteristic.three_zone_heart_rate_limits"},
+	"2a95": {Name: "Two Zone Heart Rate Limit", Type: "org.bluetooth.characteristic.two_zone_heart_rate_limit"},
+	"2a96": {Name: "VO2 Max", Type: "org.bluetooth.characteristic.vo2_max"},
+	"2a97": {Name: "Waist Circumference", Type: "org.bluetooth.characteristic.waist_circumference"},
+	"2a98": {Name: "Weight", Type: "org.bluetooth.characteristic.weight"},
+	"2a99": {Name: "Database Change Increment", Type: "org.bluetooth.characteristic.database_change_increment"},
+	"2a9a": {Name: "User Index", Type: "org.bluetooth.characteristic.user_index"},
+	"2a9b": {Name: "Body Composition Feature", Type: "org.bluetooth.characteristic.body_composition_feature"},
+	"2a9c": {Name: "Body Composition Measurement", Type: "org.bluetooth.characteristic.body_composition_measurement"},
+	"2a9d": {Name: "Weight Measurement", Type: "org.bluetooth.characteristic.weight_measurement"},
+	"2a9e": {Name: "Weight Scale Feature", Type: "org.bluetooth.characteristic.weight_scale_feature"},
+	"2a9f": {Name: "User Control Point", Type: "org.bluetooth.characteristic.user_control_point"},
+	"2aa0": {Name: "Magnetic Flux Density - 2D", Type: "org.bluetooth.characteristic.magnetic_flux_density_2d"},
+	"2aa1": {Name: "Magnetic Flux Density - 3D", Type: "org.bluetooth.characteristic.magnetic_flux_density_3d"},
+	"2aa2": {Name: "Language", Type: "org.bluetooth.characteristic.language"},
+	"2aa3": {Name: "Barometric Pressure Trend", Type: "org.bluetooth.characteristic.barometric_pressure_trend"},
+	"2aa4": {Name: "Bond Management Control Point", Type: "org.bluetooth.characteristic.bond_management_control_point"},
+	"2aa5": {Name: "Bond Management Features", Type: "org.bluetooth.characteristic.bond_management_features"},
+	"2aa6": {Name: "Central Address Resolution", Type: "org.bluetooth.characteristic.central_address_resolution"},
+	"2aa7": {Name: "CGM Measurement", Type: "org.bluetooth.characteristic.cgm_measurement"},
+	"2aa8": {Name: "CGM Feature", Type: "org.bluetooth.characteristic.cgm_feature"},
+	"2aa9": {Name: "CGM Status", Type: "org.bluetooth.characteristic.cgm_status"},
+	"2aaa": {Name: "CGM Session Start Time", Type: "org.bluetooth.characteristic.cgm_session_start_time"},
+	"2aab": {Name: "CGM Session Run Time", Type: "org.bluetooth.characteristic.cgm_session_run_time"},
+	"2aac": {Name: "CGM Specific Ops Control Point", Type: "org.bluetooth.characteristic.cgm_specific_ops_control_point"},
+	"2aad": {Name: "Indoor Positioning Configuration", Type: "org.bluetooth.characteristic.indoor_positioning_configuration"},
+	"2aae": {Name: "Latitude", Type: "org.bluetooth.characteristic.latitude"},
+	"2aaf": {Name: "Longitude", Type: "org.bluetooth.characteristic.longitude"},
+	"2ab0": {Name: "Local North Coordinate", Type: "org.bluetooth.characteristic.local_north_coordinate"},
+	"2ab1": {Name: "Local East Coordinate", Type: "org.bluetooth.characteristic.local_east_coordinate"},
+	"2ab2": {Name: "Floor Number", Type: "org.bluetooth.characteristic.floor_number"},
+	"2ab3": {Name: "Altitude", Type: "org.bluetooth.characteristic.altitude"},
+	"2ab4": {Name: "Uncertainty", Type: "org.bluetooth.characteristic.uncertainty"},
+	"2ab5": {Name: "Location Name", Type: "org.bluetooth.characteristic.location_name"},
+	"2ab6": {Name: "URI", Type: "org.bluetooth.characteristic.uri"},
+	"2ab7": {Name: "HTTP Headers", Type: "org.bluetooth.characteristic.http_headers"},
+	"2ab8": {Name: "HTTP Status Code", Type: "org.bluetooth.characteristic.http_status_code"},
+	"2ab9": {Name: "HTTP Entity Body", Type: "org.bluetooth.characteristic.http_entity_body"},
+	"2aba": {Name: "HTTP Control Point", Type: "org.bluetooth.characteristic.http_control_point"},
+	"2abb": {Name: "HTTPS Security", Type: "org.bluetooth.characteristic.https_security"},
+	"2abc": {Name: "TDS Control Point", Type: "org.bluetooth.characteristic.tds_control_point"},
+	"2abd": {Name: "OTS Feature", Type: "org.bluetooth.characteristic.ots_feature"},
+	"2abe": {Name: "Object Name", Type: "org.bluetooth.characteristic.object_name"},
+	"2abf": {Name: "Object Type", Type: "org.bluetooth.characteristic.object_type"},
+	"2ac0": {Name: "Object Size", Type: "org.bluetooth.characteristic.object_size"},
+	"2ac1": {Name: "Object First-Created", Type: "org.bluetooth.characteristic.object_first_created"},
+	"2ac2": {Name: "Object Last-Modified", Type: "org.bluetooth.characteristic.object_last_modified"},
+	"2ac3": {Name: "Object ID", Type: "org.bluetooth.characteristic.object_id"},
+	"2ac4": {Name: "Object Properties", Type: "org.bluetooth.characteristic.object_properties"},
+	"2ac5": {Name: "Object Action Control Point", Type: "org.bluetooth.characteristic.object_action_control_point"},
+	"2ac6": {Name: "Object List Control Point", Type: "org.bluetooth.characteristic.object_list_control_point"},
+	"2ac7": {Name: "Object List Filter", Type: "org.bluetooth.characteristic.object_list_filter"},
+	"2ac8": {Name: "Object Changed", Type: "org.bluetooth.characteristic.object_changed"},
+	"2ac9": {Name: "Resolvable Private Address Only", Type: "org.bluetooth.characteristic.resolvable_private_address_only"},
+	"2acc": {Name: "Fitness Machine Feature", Type: "org.bluetooth.characteristic.fitness_machine_feature"},
+	"2acd": {Name: "Treadmill Data", Type: "org.bluetooth.characteristic.treadmill_data"},
+	"2ace": {Name: "Cross Trainer Data", Type: "org.bluetooth.characteristic.cross_trainer_data"},
+	"2acf": {Name: "Step Climber Data", Type: "org.bluetooth.characteristic.step_climber_data"},
+	"2ad0": {Name: "Stair Climber Data", Type: "org.bluetooth.characteristic.stair_climber_data"},
+	"2ad1": {Name: "Rower Data", Type: "org.bluetooth.characteristic.rower_data"},
+	"2ad2": {Name: "Indoor Bike Data", Type: "org.bluetooth.characteristic.indoor_bike_data"},
+	"2ad3": {Name: "Training Status", Type: "org.bluetooth.characteristic.training_status"},
+	"2ad4": {Name: "Supported Speed Range", Type: "org.bluetooth.characteristic.supported_speed_range"},
+	"2ad5": {Name: "Supported Inclination Range", Type: "org.bluetooth.characteristic.supported_inclination_range"},
+	"2ad6": {Name: "Supported Resistance Level Range", Type: "org.bluetooth.characteristic.supported_resistance_level_range"},
+	"2ad7": {Name: "Supported Heart Rate Range", Type: "org.bluetooth.characteristic.supported_heart_rate_range"},
+	"2ad8": {Name: "Supported Power Range", Type: "org.bluetooth.characteristic.supported_power_range"},
+	"2ad9": {Name: "Fitness Machine Control Point", Type: "org.bluetooth.characteristic.fitness_machine_control_point"},
+	"2ada": {Name: "Fitness Machine Status", Type: "org.bluetooth.characteristic.fitness_machine_status"},
+	"2adb": {Name: "Mesh Provisioning Data In", Type: "org.bluetooth.characteristic.mesh_provisioning_data_in"},
+	"2adc": {Name: "Mesh Provisioning Data Out", Type: "org.bluetooth.characteristic.mesh_provisioning_data_out"},
+	"2add": {Name: "Mesh Proxy Data In", Type: "org.bluetooth.characteristic.mesh_proxy_data_in"},
+	"2ade": {Name: "Mesh Proxy Data Out", Type: "org.bluetooth.characteristic.mesh_proxy_data_out"},
+	"2ae0": {Name: "Average Current", Type: "org.bluetooth.characteristic.average_current"},
+	"2ae1": {Name: "Average Voltage", Type: "org.bluetooth.characteristic.average_voltage"},
+	"2ae2": {Name: "Boolean", Type: "org.bluetooth.characteristic.boolean"},
+	"2ae3": {Name: "Chromatic Distance From Planckian", Type: "org.bluetooth.characteristic.chromatic_distance_from_planckian"},
+	"2ae4": {Name: "Chromaticity Coordinates", Type: "org.bluetooth.characteristic.chromaticity_coordinates"},
+	"2ae5": {Name: "Chromaticity In CCT And Duv Values", Type: "org.bluetooth.characteristic.chromaticity_in_cct_and_duv_values"},
+	"2ae6": {Name: "Chromaticity Tolerance", Type: "org.bluetooth.characteristic.chromaticity_tolerance"},
+	"2ae7": {Name: "CIE 13.3-1995 Color Rendering Index", Type: "org.bluetooth.characteristic.cie_13_3_1995_color_rendering_index"},
+	"2ae8": {Name: "Coefficient", Type: "org.bluetooth.characteristic.coefficient"},
+	"2ae9": {Name: "Correlated Color Temperature", Type: "org.bluetooth.characteristic.correlated_color_temperature"},
+	"2aea": {Name: "Count 16", Type: "org.bluetooth.characteristic.count_16"},
+	"2aeb": {Name: "Count 24", Type: "org.bluetooth.characteristic.count_24"},
+	"2aec": {Name: "Country Code", Type: "org.bluetooth.characteristic.country_code"},
+	"2aed": {Name: "Date UTC", Type: "org.bluetooth.characteristic.date_utc"},
+	"2aee": {Name: "Electric Current", Type: "org.bluetooth.characteristic.electric_current"},
+	"2aef": {Name: "Electric Current Range", Type: "org.bluetooth.characteristic.electric_current_range"},
+	"2af0": {Name: "Electric Current Specification", Type: "org.bluetooth.characteristic.electric_current_specification"},
+	"2af1": {Name: "Electric Current Statistics", Type: "org.bluetooth.characteristic.electric_current_statistics"},
+	"2af2": {Name: "Energy", Type: "org.bluetooth.characteristic.energy"},
+	"2af3": {Name: "Energy In A Period Of Day", Type: "org.bluetooth.characteristic.energy_in_a_period_of_day"},
+	"2af4": {Name: "Event Statistics", Type: "org.bluetooth.characteristic.event_statistics"},
+	"2af5": {Name: "Fixed String 16", Type: "org.bluetooth.characteristic.fixed_string_16"},
+	"2af6": {Name: "Fixed String 24", Type: "org.bluetooth.characteristic.fixed_string_24"},
+	"2af7": {Name: "Fixed String 36", Type: "org.bluetooth.characteristic.fixed_string_36"},
+	"2af8": {Name: "Fixed String 8", Type: "org.bluetooth.characteristic.fixed_string_8"},
+	"2af9": {Name: "Generic Level", Type: "org.bluetooth.characteristic.generic_level"},
+	"2afa": {Name: "Global Trade Item Number", Type: "org.bluetooth.characteristic.global_trade_item_number"},
+	"2afb": {Name: "Illuminance", Type: "org.bluetooth.characteristic.illuminance"},
+	"2afc": {Name: "Luminous Efficacy", Type: "org.bluetooth.characteristic.luminous_efficacy"},
+	"2afd": {Name: "Luminous Energy", Type: "org.bluetooth.characteristic.luminous_energy"},
+	"2afe": {Name: "Luminous Exposure", Type: "org.bluetooth.characteristic.luminous_exposure"},
+	"2aff": {Name: "Luminous Flux", Type: "org.bluetooth.characteristic.luminous_flux"},
+	"2b00": {Name: "Luminous Flux Range", Type: "org.bluetooth.characteristic.luminous_flux_range"},
+	"2b01": {Name: "Luminous Intensity", Type: "org.bluetooth.characteristic.luminous_intensity"},
+	"2b02": {Name: "B02 Mass Flow", Type: "org.bluetooth.characteristic.b02_mass_flow"},
+	"2b03": {Name: "Perceived Lightness", Type: "org.bluetooth.characteristic.perceived_lightness"},
+	"2b04": {Name: "Percentage 8", Type: "org.bluetooth.characteristic.percentage_8"},
+	"2b05": {Name: "Power", Type: "org.bluetooth.characteristic.power"},
+	"2b06": {Name: "Power Specification", Type: "org.bluetooth.characteristic.power_specification"},
+	"2b07": {Name: "Relative Runtime In A Current Range", Type: "org.bluetooth.characteristic.relative_runtime_in_a_current_range"},
+	"2b08": {Name: "Relative Runtime In A Generic Level Range", Type: "org.bluetooth.characteristic.relative_runtime_in_a_generic_level_range"},
+	"2b09": {Name: "Relative Value In A Voltage Range", Type: "org.bluetooth.characteristic.relative_value_in_a_voltage_range"},
+	"2b0a": {Name: "Relative Value In An Illuminance Range", Type: "org.bluetooth.characteristic.relative_value_in_an_illuminance_range"},
+	"2b0b": {Name: "Relative Value In A Period Of Day", Type: "org.bluetooth.characteristic.relative_value_in_a_period_of_day"},
+	"2b0c": {Name: "Relative Value In A Temperature Range", Type: "org.bluetooth.characteristic.relative_value_in_a_temperature_range"},
+	"2b0d": {Name: "Temperature 8", Type: "org.bluetooth.characteristic.temperature_8"},
+	"2b0e": {Name: "Temperature 8 In A Period Of Day", Type: "org.bluetooth.characteristic.temperature_8_in_a_period_of_day"},
+	"2b0f": {Name: "Temperature 8 Statistics", Type: "org.bluetooth.characteristic.temperature_8_statistics"},
+	"2b10": {Name: "Temperature Range", Type: "org.bluetooth.characteristic.temperature_range"},
+	"2b11": {Name: "Temperature Statistics", Type: "org.bluetooth.characteristic.temperature_statistics"},
+	"2b12": {Name: "Time Decihour 8", Type: "org.bluetooth.characteristic.time_decihour_8"},
+	"2b13": {Name: "Time Exponential 8", Type: "org.bluetooth.characteristic.time_exponential_8"},
+	"2b14": {Name: "Time Hour 24", Type: "org.bluetooth.characteristic.time_hour_24"},
+	"2b15": {Name: "Time Millisecond 24", Type: "org.bluetooth.characteristic.time_millisecond_24"},
+	"2b16": {Name: "Time Second 16", Type: "org.bluetooth.characteristic.time_second_16"},
+	"2b17": {Name: "Time Second 8", Type: "org.bluetooth.characteristic.time_second_8"},
+	"2b18": {Name: "Voltage", Type: "org.bluetooth.characteristic.voltage"},
+	"2b19": {Name: "Voltage Specification", Type: "org.bluetooth.characteristic.voltage_specification"},
+	"2b1a": {Name: "Voltage Statistics", Type: "org.bluetooth.characteristic.voltage_statistics"},
+	"2b1b": {Name: "Volume Flow", Type: "org.bluetooth.characteristic.volume_flow"},
+	"2b1c": {Name: "Chromaticity Coordinate", Type: "org.bluetooth.characteristic.chromaticity_coordinate"},
+	"2b1d": {Name: "RC Feature", Type: "org.bluetooth.characteristic.rc_feature"},
+	"2b1e": {Name: "RC Settings", Type: "org.bluetooth.characteristic.rc_settings"},
+	"2b1f": {Name: "Reconnection Configuration Control Point", Type: "org.bluetooth.characteristic.reconnection_configuration_control_point"},
+	"2b20": {Name: "IDD Status Changed", Type: "org.bluetooth.characteristic.idd_status_changed"},
+	"2b21": {Name: "IDD Status", Type: "org.bluetooth.characteristic.idd_status"},
+	"2b22": {Name: "IDD Annunciation Status", Type: "org.bluetooth.characteristic.idd_annunciation_status"},
+	"2b23": {Name: "IDD Features", Type: "org.bluetooth.characteristic.idd_features"},
+	"2b24": {Name: "IDD Status Reader Control Point", Type: "org.bluetooth.characteristic.idd_status_reader_control_point"},
+	"2b25": {Name: "IDD Command Control Point", Type: "org.bluetooth.characteristic.idd_command_control_point"},
+	"2b26": {Name: "IDD Command Data", Type: "org.bluetooth.characteristic.idd_command_data"},
+	"2b27": {Name: "IDD Record Access Control Point", Type: "org.bluetooth.characteristic.idd_record_access_control_point"},
+	"2b28": {Name: "IDD History Data", Type: "org.bluetooth.characteristic.idd_history_data"},
+	"2b29": {Name: "Client Supported Features", Type: "org.bluetooth.characteristic.client_supported_features"},
+	"2b2a": {Name: "Database Hash", Type: "org.bluetooth.characteristic.database_hash"},
+	"2b2b": {Name: "BSS Control Point", Type: "org.bluetooth.characteristic.bss_control_point"},
+	"2b2c": {Name: "BSS Response", Type: "org.bluetooth.characteristic.bss_response"},
+	"2b2d": {Name: "Emergency ID", Type: "org.bluetooth.characteristic.emergency_id"},
+	"2b2e": {Name: "Emergency Text", Type: "org.bluetooth.characteristic.emergency_text"},
+	"2b34": {Name: "Enhanced Blood Pressure Measurement", Type: "org.bluetooth.characteristic.enhanced_blood_pressure_measurement"},
+	"2b35": {Name: "Enhanced Intermediate Cuff Pressure", Type: "org.bluetooth.characteristic.enhanced_intermediate_cuff_pressure"},
+	"2b36": {Name: "Blood Pressure Record", Type: "org.bluetooth.characteristic.blood_pressure_record"},
+	"2b38": {Name: "BR-EDR Handover Data", Type: "org.bluetooth.characteristic.br_edr_handover_data"},
+	"2b39": {Name: "Bluetooth SIG Data", Type: "org.bluetooth.characteristic.bluetooth_sig_data"},
+	"2b3a": {Name: "Server Supported Features", Type: "org.bluetooth.characteristic.server_supported_features"},
+	"2b3b": {Name: "Physical Activity Monitor Features", Type: "org.bluetooth.characteristic.physical_activity_monitor_features"},
+	"2b3c": {Name: "General Activity Instantaneous Data", Type: "org.bluetooth.characteristic.general_activity_instantaneous_data"},
+	"2b3d": {Name: "General Activity Summary Data", Type: "org.bluetooth.characteristic.general_activity_summary_data"},
+	"2b3e": {Name: "CardioRespiratory Activity Instantaneous Data", Type: "org.bluetooth.characteristic.cardiorespiratory_activity_instantaneous_data"},
+	"2b3f": {Name: "CardioRespiratory Activity Summary Data", Type: "org.bluetooth.characteristic.cardiorespiratory_activity_summary_data"},
+	"2b40": {Name: "Step Counter Activity Summary Data", Type: "org.bluetooth.characteristic.step_counter_activity_summary_data"},
+	"2b41": {Name: "Sleep Activity Instantaneous Data", Type: "org.bluetooth.characteristic.sleep_activity_instantaneous_data"},
+	"2b42": {Name: "Sleep Activity Summary Data", Type: "org.bluetooth.characteristic.sleep_activity_summary_data"},
+	"2b43": {Name: "Physical Activity Monitor Control Point", Type: "org.bluetooth.characteristic.physical_activity_monitor_control_point"},
+	"2b44": {Name: "Activity Current Session", Type: "org.bluetooth.characteristic.activity_current_session"},
+	"2b45": {Name: "Physical Activity Session Descriptor", Type: "org.bluetooth.characteristic.physical_activity_session_descriptor"},
+	"2b46": {Name: "Preferred Units", Type: "org.bluetooth.characteristic.preferred_units"},
+	"2b47": {Name: "High Resolution Height", Type: "org.bluetooth.characteristic.high_resolution_height"},
+	"2b48": {Name: "Middle Name", Type: "org.bluetooth.characteristic.middle_name"},
+	"2b49": {Name: "Stride Length", Type: "org.bluetooth.characteristic.stride_length"},
+	"2b4a": {Name: "Handedness", Type: "org.bluetooth.characteristic.handedness"},
+	"2b4b": {Name: "Device Wearing Position", Type: "org.bluetooth.characteristic.device_wearing_position"},
+	"2b4c": {Name: "Four Zone Heart Rate Limits", Type: "org.bluetooth.characteristic.four_zone_heart_rate_limits"},
+	"2b4d": {Name: "High Intensity Exercise Threshold", Type: "org.bluetooth.characteristic.high_intensity_exercise_threshold"},
+	"2b4e": {Name: "Activity Goal", Type: "org.bluetooth.characteristic.activity_goal"},
+	"2b4f": {Name: "Sedentary Interval Notification", Type: "org.bluetooth.characteristic.sedentary_interval_notification"},
+	"2b50": {Name: "Caloric Intake", Type: "org.bluetooth.characteristic.caloric_intake"},
+	"2b51": {Name: "TMAP Role", Type: "org.bluetooth.characteristic.tmap_role"},
+	"2b77": {Name: "Audio Input State", Type: "org.bluetooth.characteristic.audio_input_state"},
+	"2b78": {Name: "Gain Settings Attribute", Type: "org.bluetooth.characteristic.gain_settings_attribute"},
+	"2b79": {Name: "Audio Input Type", Type: "org.bluetooth.characteristic.audio_input_type"},
+	"2b7a": {Name: "Audio Input Status", Type: "org.bluetooth.characteristic.audio_input_status"},
+	"2b7b": {Name: "Audio Input Control Point", Type: "org.bluetooth.characteristic.audio_input_control_point"},
+	"2b7c": {Name: "Audio Input Description", Type: "org.bluetooth.characteristic.audio_input_description"},
+	"2b7d": {Name: "Volume State", Type: "org.bluetooth.characteristic.volume_state"},
+	"2b7e": {Name: "Volume Control Point", Type: "org.bluetooth.characteristic.volume_control_point"},
+	"2b7f": {Name: "Volume Flags", Type: "org.bluetooth.characteristic.volume_flags"},
+	"2b80": {Name: "Volume Offset State", Type: "org.bluetooth.characteristic.volume_offset_state"},
+	"2b81": {Name: "Audio Location", Type: "org.bluetooth.characteristic.audio_location"},
+	"2b82": {Name: "Volume Offset Control Point", Type: "org.bluetooth.characteristic.volume_offset_control_point"},
+	"2b83": {Name: "Audio Output Description", Type: "org.bluetooth.characteristic.audio_output_description"},
+	"2b84": {Name: "Set Identity Resolving Key", Type: "org.bluetooth.characteristic.set_identity_resolving_key"},
+	"2b85": {Name: "Coordinated Set Size", Type: "org.bluetooth.characteristic.coordinated_set_size"},
+	"2b86": {Name: "Set Member Lock", Type: "org.bluetooth.characteristic.set_member_lock"},
+	"2b87": {Name: "Set Member Rank", Type: "org.bluetooth.characteristic.set_member_rank"},
+	"2b8e": {Name: "Device Time Feature", Type: "org.bluetooth.characteristic.device_time_feature"},
+	"2b8f": {Name: "Device Time Parameters", Type: "org.bluetooth.characteristic.device_time_parameters"},
+	"2b90": {Name: "Device Time", Type: "org.bluetooth.characteristic.device_time"},
+	"2b91": {Name: "Device Time Control Point", Type: "org.bluetooth.characteristic.device_time_control_point"},
+	"2b92": {Name: "Time Change Log Data", Type: "org.bluetooth.characteristic.time_change_log_data"},
+	"2b93": {Name: "Media Player Name", Type: "org.bluetooth.characteristic.media_player_name"},
+	"2b94": {Name: "Media Player Icon Object ID", Type: "org.bluetooth.characteristic.media_player_icon_object_id"},
+	"2b95": {Name: "Media Player Icon URL", Type: "org.bluetooth.characteristic.media_player_icon_url"},
+	"2b96": {Name: "Track Changed", Type: "org.bluetooth.characteristic.track_changed"},
+	"2b97": {Name: "Track Title", Type: "org.bluetooth.characteristic.track_title"},
+	"2b98": {Name: "Track Duration", Type: "org.bluetooth.characteristic.track_duration"},
+	"2b99": {Name: "Track Position", Type: "org.bluetooth.characteristic.track_position"},
+	"2b9a": {Name: "Playback Speed", Type: "org.bluetooth.characteristic.playback_speed"},
+	"2b9b": {Name: "Seeking Speed", Type: "org.bluetooth.characteristic.seeking_speed"},
+	"2b9c": {Name: "Current Track Segments Object ID", Type: "org.bluetooth.characteristic.current_track_segments_object_id"},
+	"2b9d": {Name: "Current Track Object ID", Type: "org.bluetooth.characteristic.current_track_object_id"},
+	"2b9e": {Name: "Next Track Object ID", Type: "org.bluetooth.characteristic.next_track_object_id"},
+	"2b9f": {Name: "Parent Group Object ID", Type: "org.bluetooth.characteristic.parent_group_object_id"},
+	"2ba0": {Name: "Current Group Object ID", Type: "org.bluetooth.characteristic.current_group_object_id"},
+	"2ba1": {Name: "Playing Order", Type: "org.bluetooth.characteristic.playing_order"},
+	"2ba2": {Name: "Playing Orders Supported", Type: "org.bluetooth.characteristic.playing_orders_supported"},
+	"2ba3": {Name: "Media State", Type: "org.bluetooth.characteristic.media_state"},
+	"2ba4": {Name: "Media Control Point", Type: "org.bluetooth.characteristic.media_control_point"},
+	"2ba5": {Name: "Media Control Point Opcodes Supported", Type: "org.bluetooth.characteristic.media_control_point_opcodes_supported"},
+	"2ba6": {Name: "Search Results Object ID", Type: "org.bluetooth.characteristic.search_results_object_id"},
+	"2ba7": {Name: "Search Control Point", Type: "org.bluetooth.characteristic.search_control_point"},
+	"2ba9": {Name: "Media Player Icon Object Type", Type: "org.bluetooth.characteristic.media_player_icon_object_type"},
+	"2baa": {Name: "Track Segments Object Type", Type: "org.bluetooth.characteristic.track_segments_object_type"},
+	"2bab": {Name: "Track Object Type", Type: "org.bluetooth.characteristic.track_object_type"},
+	"2bac": {Name: "Group Object Type", Type: "org.bluetooth.characteristic.group_object_type"},
+	"2bad": {Name: "Constant Tone Extension Enable", Type: "org.bluetooth.characteristic.constant_tone_extension_enable"},
+	"2bae": {Name: "Advertising Constant Tone Extension Minimum Length", Type: "org.bluetooth.characteristic.advertising_constant_tone_extension_minimum_length"},
+	"2baf": {Name: "Advertising Constant Tone Extension Minimum Transmit Count", Type: "org.bluetooth.characteristic.advertising_constant_tone_extension_minimum_transmit_count"},
+	"2bb0": {Name: "Advertising Constant Tone Extension Transmit Duration", Type: "org.bluetooth.characteristic.advertising_constant_tone_extension_transmit_duration"},
+	"2bb1": {Name: "Advertising Constant Tone Extension Interval", Type: "org.bluetooth.characteristic.advertising_constant_tone_extension_interval"},
+	"2bb2": {Name: "Advertising Constant Tone Extension PHY", Type: "org.bluetooth.characteristic.advertising_constant_tone_extension_phy"},
+	"2bb3": {Name: "Bearer Provider Name", Type: "org.bluetooth.characteristic.bearer_provider_name"},
+	"2bb4": {Name: "Bearer UCI", Type: "org.bluetooth.characteristic.bearer_uci"},
+	"2bb5": {Name: "Bearer Technology", Type: "org.bluetooth.characteristic.bearer_technology"},
+	"2bb6": {Name: "Bearer URI Schemes Supported List", Type: "org.bluetooth.characteristic.bearer_uri_schemes_supported_list"},
+	"2bb7": {Name: "Bearer Signal Strength", Type: "org.bluetooth.characteristic.bearer_signal_strength"},
+	"2bb8": {Name: "Bearer Signal Strength Reporting Interval", Type: "org.bluetooth.characteristic.bearer_signal_strength_reporting_interval"},
+	"2bb9": {Name: "Bearer List Current Calls", Type: "org.bluetooth.characteristic.bearer_list_current_calls"},
+	"2bba": {Name: "Content Control ID", Type: "org.bluetooth.characteristic.content_control_id"},
+	"2bbb": {Name: "Status Flags", Type: "org.bluetooth.characteristic.status_flags"},
+	"2bbc": {Name: "Incoming Call Target Bearer URI", Type: "org.bluetooth.characteristic.incoming_call_target_bearer_uri"},
+	"2bbd": {Name: "Call State", Type: "org.bluetooth.characteristic.call_state"},
+	"2bbe": {Name: "Call Control Point", Type: "org.bluetooth.characteristic.call_control_point"},
+	"2bbf": {Name: "Call Control Point Optional Opcodes", Type: "org.bluetooth.characteristic.call_control_point_optional_opcodes"},
+	"2bc0": {Name: "Termination Reason", Type: "org.bluetooth.characteristic.termination_reason"},
+	"2bc1": {Name: "Incoming Call", Type: "org.bluetooth.characteristic.incoming_call"},
+	"2bc2": {Name: "Call Friendly Name", Type: "org.bluetooth.characteristic.call_friendly_name"},
+	"2bc3": {Name: "Mute", Type: "org.bluetooth.characteristic.mute"},
+	"2bc4": {Name: "Sink ASE", Type: "org.bluetooth.characteristic.sink_ase"},
+	"2bc5": {Name: "Source ASE", Type: "org.bluetooth.characteristic.source_ase"},
+	"2bc6": {Name: "ASE Control Point", Type: "org.bluetooth.characteristic.ase_control_point"},
+	"2bc7": {Name: "Broadcast Audio Scan Control Point", Type: "org.bluetooth.characteristic.broadcast_audio_scan_control_point"},
+	"2bc8": {Name: "Broadcast Receive State", Type: "org.bluetooth.characteristic.broadcast_receive_state"},
+	"2bc9": {Name: "Sink PAC", Type: "org.bluetooth.characteristic.sink_pac"},
+	"2bca": {Name: "Sink Audio Locations", Type: "org.bluetooth.characteristic.sink_audio_locations"},
+	"2bcb": {Name: "Source PAC", Type: "org.bluetooth.characteristic.source_pac"},
+	"2bcc": {Name: "Source Audio Locations", Type: "org.bluetooth.characteristic.source_audio_locations"},
+	"2bcd": {Name: "Available Audio Contexts", Type: "org.bluetooth.characteristic.available_audio_contexts"},
+	"2bce": {Name: "Supported Audio Contexts", Type: "org.bluetooth.characteristic.supported_audio_contexts"},
+	"2bcf": {Name: "Ammonia Concentration", Type: "org.bluetooth.characteristic.ammonia_concentration"},
+	"2bd0": {Name: "Carbon Monoxide Concentration", Type: "org.bluetooth.characteristic.carbon_monoxide_concentration"},
+	"2bd1": {Name: "Methane Concentration", Type: "org.bluetooth.characteristic.methane_concentration"},
+	"2bd2": {Name: "Nitrogen Dioxide Concentration", Type: "org.bluetooth.characteristic.nitrogen_dioxide_concentration"},
+	"2bd3": {Name: "Non-Methane Volatile Organic Compounds Concentration", Type: "org.bluetooth.characteristic.non_methane_volatile_organic_compounds_concentration"},
+	"2bd4": {Name: "Ozone Concentration", Type: "org.bluetooth.characteristic.ozone_concentration"},
+	"2bd5": {Name: "Particulate Matter - PM1 Concentration", Type: "org.bluetooth.characteristic.particulate_matter_pm1_concentration"},
+	"2bd6": {Name: "Particulate Matter - PM2.5 Concentration", Type: "org.bluetooth.characteristic.particulate_matter_pm2_5_concentration"},
+	"2bd7": {Name: "Particulate Matter - PM10 Concentration", Type: "org.bluetooth.characteristic.particulate_matter_pm10_concentration"},
+	"2bd8": {Name: "Sulfur Dioxide Concentration", Type: "org.bluetooth.characteristic.sulfur_dioxide_concentration"},
+	"2bd9": {Name: "Sulfur Hexafluoride Concentration", Type: "org.bluetooth.characteristic.sulfur_hexafluoride_concentration"},
+	"2bda": {Name: "Hearing Aid Features", Type: "org.bluetooth.characteristic.hearing_aid_features"},
+	"2bdb": {Name: "Hearing Aid Preset Control Point", Type: "org.bluetooth.characteristic.hearing_aid_preset_control_point"},
+	"2bdc": {Name: "Active Preset Index", Type: "org.bluetooth.characteristic.active_preset_index"},
+	"2bdd": {Name: "Stored Health Observations", Type: "org.bluetooth.characteristic.stored_health_observations"},
+	"2bde": {Name: "Fixed String 64", Type: "org.bluetooth.characteristic.fixed_string_64"},
+	"2bdf": {Name: "High Temperature", Type: "org.bluetooth.characteristic.high_temperature"},
+	"2be0": {Name: "High Voltage", Type: "org.bluetooth.characteristic.high_voltage"},
+	"2be1": {Name: "Light Distribution", Type: "org.bluetooth.characteristic.light_distribution"},
+	"2be2": {Name: "Light Output", Type: "org.bluetooth.characteristic.light_output"},
+	"2be3": {Name: "Light Source Type", Type: "org.bluetooth.characteristic.light_source_type"},
+	"2be4": {Name: "Noise", Type: "org.bluetooth.characteristic.noise"},
+	"2be5": {Name: "Relative Runtime in a Correlated Color Temperature Range", Type: "org.bluetooth.characteristic.relative_runtime_in_a_correlated_color_temperature_range"},
+	"2be6": {Name: "Time Second 32", Type: "org.bluetooth.characteristic.time_second_32"},
+	"2be7": {Name: "VOC Concentration", Type: "org.bluetooth.characteristic.voc_concentration"},
+	"2be8": {Name: "Voltage Frequency", Type: "org.bluetooth.characteristic.voltage_frequency"},
+	"2be9": {Name: "Battery Critical Status", Type: "org.bluetooth.characteristic.battery_critical_status"},
+	"2bea": {Name: "Battery Health Status", Type: "org.bluetooth.characteristic.battery_health_status"},
+	"2beb": {Name: "Battery Health Information", Type: "org.bluetooth.characteristic.battery_health_information"},
+	"2bec": {Name: "Battery Information", Type: "org.bluetooth.characteristic.battery_information"},
+	"2bed": {Name: "Battery Level Status", Type: "org.bluetooth.characteristic.battery_level_status"},
+	"2bee": {Name: "Battery Time Status", Type: "org.bluetooth.characteristic.battery_time_status"},
+	"2bef": {Name: "Estimated Service Date", Type: "org.bluetooth.characteristic.estimated_service_date"},
+	"2bf0": {Name: "Battery Energy Status", Type: "org.bluetooth.characteristic.battery_energy_status"},
+	"2bf1": {Name: "Observation Schedule Changed", Type: "org.bluetooth.characteristic.observation_schedule_changed"},
+	"2bf2": {Name: "Current Elapsed Time", Type: "org.bluetooth.characteristic.current_elapsed_time"},
+	"2bf3": {Name: "Health Sensor Features", Type: "org.bluetooth.characteristic.health_sensor_features"},
+	"2bf4": {Name: "GHS Control Point", Type: "org.bluetooth.characteristic.ghs_control_point"},
+	"2bf5": {Name: "LE GATT Security Levels", Type: "org.bluetooth.characteristic.le_gatt_security_levels"},
+	"2bf6": {Name: "ESL Address", Type: "org.bluetooth.characteristic.esl_address"},
+	"2bf7": {Name: "AP Sync Key Material", Type: "org.bluetooth.characteristic.ap_sync_key_material"},
+	"2bf8": {Name: "ESL Response Key Material", Type: "org.bluetooth.characteristic.esl_response_key_material"},
+	"2bf9": {Name: "ESL Current Absolute Time", Type: "org.bluetooth.characteristic.esl_current_absolute_time"},
+	"2bfa": {Name: "ESL Display Information", Type: "org.bluetooth.characteristic.esl_display_information"},
+	"2bfb": {Name: "ESL Image Information", Type: "org.bluetooth.characteristic.esl_image_information"},
+	"2bfc": {Name: "ESL Sensor Information", Type: "org.bluetooth.characteristic.esl_sensor_information"},
+	"2bfd": {Name: "ESL LED Information", Type: "org.bluetooth.characteristic.esl_led_information"},
+	"2bfe": {Name: "ESL Control Point", Type: "org.bluetooth.characteristic.esl_control_point"},
+	"2bff": {Name: "UDI for Medical Devices", Type: "org.bluetooth.characteristic.udi_for_medical_devices"},
 }
@@ -0,0 +1,482 @@
+package goble
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoCodec     = errors.New("no codec for characteristic")
+	ErrValueLength = errors.New("invalid characteristic value length")
+)
+
+// Codec decodes a characteristic value into a Go value
+type Codec func(data []byte) (interface{}, error)
+
+// codecs keyed by short characteristic uuid
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{
+	m: map[string]Codec{
+		"2a00": decodeString,                   // Device Name
+		"2a01": decodeAppearance,               // Appearance
+		"2a07": decodeTxPower,                  // Tx Power Level
+		"2a08": decodeDateTimeValue,            // Date Time
+		"2a0f": decodeLocalTimeInformation,     // Local Time Information
+		"2a14": decodeReferenceTimeInformation, // Reference Time Information
+		"2a19": decodeBatteryLevel,             // Battery Level
+		"2a1c": decodeTemperatureMeasurement,   // Temperature Measurement
+		"2a1d": decodeTemperatureType,          // Temperature Type
+		"2a1e": decodeTemperatureMeasurement,   // Intermediate Temperature
+		"2a23": decodeSystemID,                 // System ID
+		"2a24": decodeString,                   // Model Number String
+		"2a25": decodeString,                   // Serial Number String
+		"2a26": decodeString,                   // Firmware Revision String
+		"2a27": decodeString,                   // Hardware Revision String
+		"2a28": decodeString,                   // Software Revision String
+		"2a29": decodeString,                   // Manufacturer Name String
+		"2a2b": decodeCurrentTime,              // Current Time
+		"2a37": decodeHeartRateMeasurement,     // Heart Rate Measurement
+		"2a38": decodeBodySensorLocation,       // Body Sensor Location
+		"2a50": decodePnPID,                    // PnP ID
+	},
+}
+
+// RegisterCodec sets the codec used for a characteristic uuid
+func RegisterCodec(uuid string, c Codec) {
+	codecs.Lock()
+	codecs.m[shortUUID(uuid)] = c
+	codecs.Unlock()
+}
+
+// Decode decodes the value of a characteristic with a registered codec
+func Decode(uuid string, data []byte) (interface{}, error) {
+	codecs.RLock()
+	c, ok := codecs.m[shortUUID(uuid)]
+	codecs.RUnlock()
+	if !ok {
+		return nil, ErrNoCodec
+	}
+	return c(data)
+}
+
+// Value decodes the data of a "read" event, see Decode
+func (ev Event) Value() (interface{}, error) {
+	return Decode(ev.CharacteristicUuid, ev.Data)
+}
+
+func decodeString(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+func decodeTxPower(data []byte) (interface{}, error) {
+	if len(data) != 1 {
+		return nil, ErrValueLength
+	}
+	return int8(data[0]), nil
+}
+
+// Battery Level (0x2A19) in percent
+type BatteryLevel uint8
+
+func (b BatteryLevel) String() string {
+	return fmt.Sprintf("%d%%", uint8(b))
+}
+
+func decodeBatteryLevel(data []byte) (interface{}, error) {
+	if len(data) != 1 {
+		return nil, ErrValueLength
+	}
+	return BatteryLevel(data[0]), nil
+}
+
+// Appearance (0x2A01), category in the upper 10 bits
+type Appearance uint16
+
+// Category returns the appearance with the subcategory cleared
+func (a Appearance) Category() Appearance {
+	return a &^ 0x3f
+}
+
+func (a Appearance) String() string {
+	return AppearanceName(uint16(a))
+}
+
+func decodeAppearance(data []byte) (interface{}, error) {
+	if len(data) != 2 {
+		return nil, ErrValueLength
+	}
+	return Appearance(binary.LittleEndian.Uint16(data)), nil
+}
+
+// Body Sensor Location (0x2A38)
+type BodySensorLocation uint8
+
+var bodySensorLocations = []string{"Other", "Chest", "Wrist", "Finger", "Hand", "Ear Lobe", "Foot"}
+
+func (l BodySensorLocation) String() string {
+	if int(l) < len(bodySensorLocations) {
+		return bodySensorLocations[l]
+	}
+	return fmt.Sprintf("BodySensorLocation(%d)", uint8(l))
+}
+
+func decodeBodySensorLocation(data []byte) (interface{}, error) {
+	if len(data) != 1 {
+		return nil, ErrValueLength
+	}
+	return BodySensorLocation(data[0]), nil
+}
+
+// Temperature Type (0x2A1D)
+type TemperatureType uint8
+
+var temperatureTypes = []string{"", "Armpit", "Body", "Ear", "Finger", "Gastro-intestinal Tract", "Mouth", "Rectum", "Toe", "Tympanum"}
+
+func (t TemperatureType) String() string {
+	if t > 0 && int(t) < len(temperatureTypes) {
+		return temperatureTypes[t]
+	}
+	return fmt.Sprintf("TemperatureType(%d)", uint8(t))
+}
+
+func decodeTemperatureType(data []byte) (interface{}, error) {
+	if len(data) != 1 {
+		return nil, ErrValueLength
+	}
+	return TemperatureType(data[0]), nil
+}
+
+// decodeDateTime decodes a 7 byte Date Time (0x2A08), the zero time is
+// returned if the date is not known
+func decodeDateTime(b []byte) time.Time {
+	year := int(binary.LittleEndian.Uint16(b))
+	month, day := time.Month(b[2]), int(b[3])
+	if year == 0 || month == 0 || day == 0 {
+		return time.Time{}
+	}
+	return time.Date(year, month, day, int(b[4]), int(b[5]), int(b[6]), 0, time.UTC)
+}
+
+// encodeDateTime encodes the wall clock of t as 7 byte Date Time
+func encodeDateTime(t time.Time) []byte {
+	b := make([]byte, 7)
+	binary.LittleEndian.PutUint16(b, uint16(t.Year()))
+	b[2], b[3] = byte(t.Month()), byte(t.Day())
+	b[4], b[5], b[6] = byte(t.Hour()), byte(t.Minute()), byte(t.Second())
+	return b
+}
+
+func decodeDateTimeValue(data []byte) (interface{}, error) {
+	if len(data) != 7 {
+		return nil, ErrValueLength
+	}
+	return decodeDateTime(data), nil
+}
+
+// Temperature Measurement (0x2A1C) and Intermediate Temperature (0x2A1E)
+type TemperatureMeasurement struct {
+	Value      float64
+	Fahrenheit bool            // Value in °F instead of °C
+	Timestamp  time.Time       // zero if not present
+	Type       TemperatureType // 0 if not present
+}
+
+func (m TemperatureMeasurement) String() string {
+	unit := "°C"
+	if m.Fahrenheit {
+		unit = "°F"
+	}
+	s := fmt.Sprintf("%g%s", m.Value, unit)
+	if m.Type != 0 {
+		s += " " + m.Type.String()
+	}
+	if !m.Timestamp.IsZero() {
+		s += " " + m.Timestamp.Format("2006-01-02 15:04:05")
+	}
+	return s
+}
+
+func decodeTemperatureMeasurement(data []byte) (interface{}, error) {
+	if len(data) < 5 {
+		return nil, ErrValueLength
+	}
+	flags := data[0]
+	m := TemperatureMeasurement{
+		Value:      Float(binary.LittleEndian.Uint32(data[1:])).Float64(),
+		Fahrenheit: flags&0x01 != 0,
+	}
+	data = data[5:]
+	if flags&0x02 != 0 {
+		if len(data) < 7 {
+			return nil, ErrValueLength
+		}
+		m.Timestamp = decodeDateTime(data)
+		data = data[7:]
+	}
+	if flags&0x04 != 0 {
+		if len(data) < 1 {
+			return nil, ErrValueLength
+		}
+		m.Type = TemperatureType(data[0])
+	}
+	return m, nil
+}
+
+// Heart Rate Measurement (0x2A37)
+type HeartRateMeasurement struct {
+	HeartRate        uint16 // beats per minute
+	ContactSupported bool
+	Contact          bool // skin contact detected, if supported
+	EnergyPresent    bool
+	EnergyExpended   uint16 // kJ since last reset
+	RRIntervals      []time.Duration
+}
+
+func (m HeartRateMeasurement) String() string {
+	s := fmt.Sprintf("%d bpm", m.HeartRate)
+	if m.ContactSupported && !m.Contact {
+		s += " no contact"
+	}
+	if m.EnergyPresent {
+		s += fmt.Sprintf(" %d kJ", m.EnergyExpended)
+	}
+	if len(m.RRIntervals) > 0 {
+		s += fmt.Sprintf(" rr %v", m.RRIntervals)
+	}
+	return s
+}
+
+func decodeHeartRateMeasurement(data []byte) (interface{}, error) {
+	if len(data) < 2 {
+		return nil, ErrValueLength
+	}
+	flags := data[0]
+	var m HeartRateMeasurement
+	if flags&0x01 != 0 {
+		if len(data) < 3 {
+			return nil, ErrValueLength
+		}
+		m.HeartRate = binary.LittleEndian.Uint16(data[1:])
+		data = data[3:]
+	} else {
+		m.HeartRate = uint16(data[1])
+		data = data[2:]
+	}
+	m.ContactSupported = flags&0x04 != 0
+	m.Contact = m.ContactSupported && flags&0x02 != 0
+	if flags&0x08 != 0 {
+		if len(data) < 2 {
+			return nil, ErrValueLength
+		}
+		m.EnergyPresent = true
+		m.EnergyExpended = binary.LittleEndian.Uint16(data)
+		data = data[2:]
+	}
+	if flags&0x10 != 0 {
+		if len(data)%2 != 0 {
+			return nil, ErrValueLength
+		}
+		for ; len(data) >= 2; data = data[2:] {
+			rr := time.Duration(binary.LittleEndian.Uint16(data)) * time.Second / 1024
+			m.RRIntervals = append(m.RRIntervals, rr)
+		}
+	}
+	return m, nil
+}
+
+// PnP ID (0x2A50)
+type PnPID struct {
+	VendorIDSource uint8 // 1 for Bluetooth SIG, 2 for USB Implementer's Forum
+	VendorID       uint16
+	ProductID      uint16
+	ProductVersion uint16
+}
+
+func (p PnPID) String() string {
+	vendor := fmt.Sprintf("vendor %#04x", p.VendorID)
+	if p.VendorIDSource == 1 {
+		vendor = CompanyName(p.VendorID)
+	}
+	return fmt.Sprintf("%s product %#04x version %#04x", vendor, p.ProductID, p.ProductVersion)
+}
+
+func decodePnPID(data []byte) (interface{}, error) {
+	if len(data) != 7 {
+		return nil, ErrValueLength
+	}
+	return PnPID{
+		VendorIDSource: data[0],
+		VendorID:       binary.LittleEndian.Uint16(data[1:]),
+		ProductID:      binary.LittleEndian.Uint16(data[3:]),
+		ProductVersion: binary.LittleEndian.Uint16(data[5:]),
+	}, nil
+}
+
+// System ID (0x2A23)
+type SystemID struct {
+	Manufacturer uint64 // 40-bit manufacturer defined identifier
+	OUI          uint32 // 24-bit organizationally unique identifier
+}
+
+func (id SystemID) String() string {
+	return fmt.Sprintf("%06x-%010x", id.OUI, id.Manufacturer)
+}
+
+func decodeSystemID(data []byte) (interface{}, error) {
+	if len(data) != 8 {
+		return nil, ErrValueLength
+	}
+	v := binary.LittleEndian.Uint64(data)
+	return SystemID{Manufacturer: v & 0xffffffffff, OUI: uint32(v >> 40)}, nil
+}
+
+// AdjustReason tells why the Current Time changed
+type AdjustReason uint8
+
+const (
+	ManualTimeUpdate AdjustReason = 1 << iota
+	ExternalReferenceTimeUpdate
+	ChangeOfTimeZone
+	ChangeOfDST
+)
+
+func (r AdjustReason) String() string {
+	var s []string
+	for i, name := range []string{"manual", "external reference", "time zone", "dst"} {
+		if r&(1<<uint(i)) != 0 {
+			s = append(s, name)
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// Current Time (0x2A2B)
+type CurrentTime struct {
+	Time         time.Time // local wall clock, in UTC location, with 1/256 s resolution
+	AdjustReason AdjustReason
+}
+
+// Bytes returns the 10 byte characteristic value
+func (c CurrentTime) Bytes() []byte {
+	b := encodeDateTime(c.Time)
+	weekday := byte(c.Time.Weekday())
+	if weekday == 0 {
+		weekday = 7 // sunday
+	}
+	fractions := byte(int64(c.Time.Nanosecond()) * 256 / int64(time.Second))
+	return append(b, weekday, fractions, byte(c.AdjustReason))
+}
+
+func (c CurrentTime) String() string {
+	s := c.Time.Format("2006-01-02 15:04:05.000 Monday")
+	if c.AdjustReason != 0 {
+		s += " (" + c.AdjustReason.String() + ")"
+	}
+	return s
+}
+
+func decodeCurrentTime(data []byte) (interface{}, error) {
+	if len(data) < 10 {
+		return nil, ErrValueLength
+	}
+	t := decodeDateTime(data)
+	if !t.IsZero() {
+		t = t.Add(time.Duration(data[8]) * time.Second / 256)
+	}
+	return CurrentTime{Time: t, AdjustReason: AdjustReason(data[9])}, nil
+}
+
+// Local Time Information (0x2A0F)
+type LocalTimeInformation struct {
+	TimeZone  int8  // offset from UTC in 15 minutes, -128 if unknown
+	DSTOffset uint8 // 0 standard time, 2 half an hour, 4 one hour, 8 two hours, 255 unknown
+}
+
+// LocalTimeInformationOf returns the time zone and DST offset of t
+func LocalTimeInformationOf(t time.Time) LocalTimeInformation {
+	_, offset := t.Zone()
+	// standard time has the smaller offset of january and july
+	_, jan := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location()).Zone()
+	_, jul := time.Date(t.Year(), time.July, 1, 0, 0, 0, 0, t.Location()).Zone()
+	std := jan
+	if jul < std {
+		std = jul
+	}
+	return LocalTimeInformation{
+		TimeZone:  int8(std / (15 * 60)),
+		DSTOffset: uint8((offset - std) / (15 * 60)),
+	}
+}
+
+// Offset returns the total offset from UTC, false if unknown
+func (l LocalTimeInformation) Offset() (time.Duration, bool) {
+	if l.TimeZone == -128 || l.DSTOffset == 255 {
+		return 0, false
+	}
+	return time.Duration(int(l.TimeZone)+int(l.DSTOffset)) * 15 * time.Minute, true
+}
+
+// Bytes returns the 2 byte characteristic value
+func (l LocalTimeInformation) Bytes() []byte {
+	return []byte{byte(l.TimeZone), l.DSTOffset}
+}
+
+func (l LocalTimeInformation) String() string {
+	offset, ok := l.Offset()
+	if !ok {
+		return "unknown time zone"
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	zone := fmt.Sprintf("UTC%c%d", sign, int(offset/time.Hour))
+	if m := int(offset % time.Hour / time.Minute); m != 0 {
+		zone += fmt.Sprintf(":%02d", m)
+	}
+	return fmt.Sprintf("%s dst %v", zone, time.Duration(l.DSTOffset)*15*time.Minute)
+}
+
+func decodeLocalTimeInformation(data []byte) (interface{}, error) {
+	if len(data) != 2 {
+		return nil, ErrValueLength
+	}
+	return LocalTimeInformation{TimeZone: int8(data[0]), DSTOffset: data[1]}, nil
+}
+
+// time sources of the Reference Time Information
+const (
+	TimeSourceUnknown = iota
+	TimeSourceNTP
+	TimeSourceGPS
+	TimeSourceRadio
+	TimeSourceManual
+	TimeSourceAtomicClock
+	TimeSourceCellular
+)
+
+// Reference Time Information (0x2A14)
+type ReferenceTimeInformation struct {
+	Source           uint8 // TimeSourceNTP ...
+	Accuracy         uint8 // drift in 1/8 s, 254 larger, 255 unknown
+	DaysSinceUpdate  uint8 // 255 if 255 or more days
+	HoursSinceUpdate uint8 // 255 if 255 or more days
+}
+
+// Bytes returns the 4 byte characteristic value
+func (r ReferenceTimeInformation) Bytes() []byte {
+	return []byte{r.Source, r.Accuracy, r.DaysSinceUpdate, r.HoursSinceUpdate}
+}
+
+func decodeReferenceTimeInformation(data []byte) (interface{}, error) {
+	if len(data) != 4 {
+		return nil, ErrValueLength
+	}
+	return ReferenceTimeInformation{data[0], data[1], data[2], data[3]}, nil
+}
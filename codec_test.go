@@ -0,0 +1,155 @@
+package goble
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		uuid string
+		data []byte
+		want interface{}
+	}{
+		{"2a19", []byte{87}, BatteryLevel(87)},
+		{"2a00", []byte("goble"), "goble"},
+		{"2a07", []byte{0xf4}, int8(-12)},
+		{"2a01", []byte{0x41, 0x03}, Appearance(0x0341)},
+		{"2a38", []byte{1}, BodySensorLocation(1)},
+		{"2a08", []byte{0xe2, 0x07, 12, 24, 18, 30, 5}, time.Date(2018, 12, 24, 18, 30, 5, 0, time.UTC)},
+		{"2a08", []byte{0, 0, 0, 0, 0, 0, 0}, time.Time{}},
+		{"2a50", []byte{0x01, 0x59, 0x00, 0x34, 0x12, 0x01, 0x00}, PnPID{1, 0x0059, 0x1234, 0x0001}},
+		{"2a23", []byte{0x05, 0x04, 0x03, 0x02, 0x01, 0x0c, 0x0b, 0x0a}, SystemID{0x0102030405, 0x0a0b0c}},
+		{"2a37", []byte{0x00, 72}, HeartRateMeasurement{HeartRate: 72}},
+		{"2a37", []byte{0x1f, 0x2c, 0x01, 0x10, 0x00, 0x00, 0x04, 0x00, 0x02},
+			HeartRateMeasurement{
+				HeartRate:        300,
+				ContactSupported: true,
+				Contact:          true,
+				EnergyPresent:    true,
+				EnergyExpended:   16,
+				RRIntervals:      []time.Duration{time.Second, time.Second / 2},
+			}},
+		{"2a1c", []byte{0x06, 0x72, 0x0e, 0x00, 0xfe, 0xe2, 0x07, 12, 24, 18, 30, 5, 2},
+			TemperatureMeasurement{
+				Value:     36.98,
+				Timestamp: time.Date(2018, 12, 24, 18, 30, 5, 0, time.UTC),
+				Type:      2,
+			}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.uuid, func(t *testing.T) {
+			got, err := Event{CharacteristicUuid: tc.uuid, Data: tc.data}.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m, ok := got.(TemperatureMeasurement); ok {
+				m.Value = math.Round(m.Value*100) / 100
+				got = m
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	testCases := []struct {
+		uuid string
+		data []byte
+		err  error
+	}{
+		{"ffff", []byte{1}, ErrNoCodec},
+		{"2a19", nil, ErrValueLength},
+		{"2a37", []byte{0x01, 72}, ErrValueLength},
+		{"2a37", []byte{0x10, 72, 1}, ErrValueLength},
+		{"2a1c", []byte{0x02, 0, 0, 0, 0}, ErrValueLength},
+		{"2a50", []byte{1, 2, 3}, ErrValueLength},
+	}
+	for _, tc := range testCases {
+		if _, err := Decode(tc.uuid, tc.data); err != tc.err {
+			t.Errorf("%s %x: got %v, want %v", tc.uuid, tc.data, err, tc.err)
+		}
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	const uuid = "6e400003-b5a3-f393-e0a9-e50e24dcca9e"
+	defer func() {
+		codecs.Lock()
+		delete(codecs.m, shortUUID(uuid))
+		codecs.Unlock()
+	}()
+	RegisterCodec(uuid, decodeString)
+	if v, err := Decode(uuid, []byte("hi")); err != nil || v != "hi" {
+		t.Errorf("got %v %v", v, err)
+	}
+}
+
+func TestCurrentTime(t *testing.T) {
+	ct := CurrentTime{
+		Time:         time.Date(2020, 2, 29, 23, 59, 58, int(time.Second/2), time.UTC),
+		AdjustReason: ManualTimeUpdate | ChangeOfDST,
+	}
+	b := ct.Bytes()
+	if want := []byte{0xe4, 0x07, 2, 29, 23, 59, 58, 6, 128, 0x09}; !reflect.DeepEqual(b, want) {
+		t.Errorf("got %x, want %x", b, want)
+	}
+	v, err := Decode("2a2b", b)
+	if err != nil || !reflect.DeepEqual(v, ct) {
+		t.Errorf("got %v %v, want %v", v, err, ct)
+	}
+}
+
+func TestLocalTimeInformation(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*3600)
+	testCases := []struct {
+		t    time.Time
+		want LocalTimeInformation
+	}{
+		{time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC), LocalTimeInformation{0, 0}},
+		{time.Date(2020, 7, 1, 0, 0, 0, 0, berlin), LocalTimeInformation{8, 0}},
+		{time.Date(2020, 7, 1, 0, 0, 0, 0, time.FixedZone("IST", 5*3600+1800)), LocalTimeInformation{22, 0}},
+	}
+	for _, tc := range testCases {
+		if got := LocalTimeInformationOf(tc.t); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.t, got, tc.want)
+		}
+	}
+
+	l := LocalTimeInformation{TimeZone: 4, DSTOffset: 4}
+	if offset, ok := l.Offset(); !ok || offset != 2*time.Hour {
+		t.Errorf("got %v %v, want 2h", offset, ok)
+	}
+	if _, ok := (LocalTimeInformation{TimeZone: -128}).Offset(); ok {
+		t.Error("unknown time zone has offset")
+	}
+	for _, tc := range []struct {
+		l    LocalTimeInformation
+		want string
+	}{
+		{l, "UTC+2 dst 1h0m0s"},
+		{LocalTimeInformation{TimeZone: 23}, "UTC+5:45 dst 0s"},
+		{LocalTimeInformation{TimeZone: 51}, "UTC+12:45 dst 0s"},
+		{LocalTimeInformation{TimeZone: -14}, "UTC-3:30 dst 0s"},
+	} {
+		if got := tc.l.String(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+	v, err := Decode("2a0f", l.Bytes())
+	if err != nil || v != l {
+		t.Errorf("got %v %v, want %v", v, err, l)
+	}
+}
+
+func TestReferenceTimeInformation(t *testing.T) {
+	r := ReferenceTimeInformation{TimeSourceGPS, 8, 1, 2}
+	v, err := Decode("2a14", r.Bytes())
+	if err != nil || v != r {
+		t.Errorf("got %v %v, want %v", v, err, r)
+	}
+}
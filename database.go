@@ -0,0 +1,248 @@
+package goble
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/dim13/goble/xpc"
+)
+
+// GATT declarations
+var (
+	PrimaryServiceUUID   = UUID16(0x2800)
+	SecondaryServiceUUID = UUID16(0x2801)
+	IncludeUUID          = UUID16(0x2802)
+	CharacteristicUUID   = UUID16(0x2803)
+)
+
+// Generic Attribute service and its Service Changed characteristic
+var (
+	GenericAttributeUUID = UUID16(0x1801)
+	ServiceChangedUUID   = UUID16(0x2a05)
+)
+
+var (
+	ErrIncludeNotFound = errors.New("included service not found")
+	ErrDatabaseFull    = errors.New("attribute handles exhausted")
+)
+
+// Attribute is an entry of a GATT database
+type Attribute struct {
+	Handle uint16
+	Type   xpc.UUID // declaration, value or descriptor uuid
+	Value  []byte   // static value, nil for characteristic values
+	End    uint16   // last handle of a service declaration
+
+	// characteristic of a declaration, value or descriptor, and its value
+	// handle
+	Characteristic *Characteristic
+	ValueHandle    uint16
+}
+
+// Configuration reports whether the attribute is the client characteristic
+// configuration of a characteristic
+func (a Attribute) Configuration() bool {
+	return a.Characteristic != nil && a.Type == ClientConfigurationUUID
+}
+
+// Database allocates attribute handles to services: declarations of
+// services, included services and characteristics, characteristic values
+// and descriptors. Characteristics with Notify or Indicate properties get
+// a client characteristic configuration.
+type Database struct {
+	attributes []Attribute // by handle-1
+}
+
+// size returns the number of handles of a service
+func size(s Service) int {
+	n := 1 + len(s.includes)
+	for _, c := range s.characteristics {
+		n += 2 + len(c.descriptors)
+		if c.properties&(Notify|Indicate) != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// NewDatabase allocates handles to services in order
+func NewDatabase(services ...Service) (*Database, error) {
+	// service ranges first, included services may follow
+	start := make([]uint16, len(services))
+	handle := 1
+	for i, s := range services {
+		start[i] = uint16(handle)
+		handle += size(s)
+		if handle-1 > 0xffff {
+			return nil, ErrDatabaseFull
+		}
+	}
+	find := func(uuid xpc.UUID) (int, bool) {
+		for i, s := range services {
+			if s.uuid == uuid {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	db := &Database{}
+	for i, s := range services {
+		typ := PrimaryServiceUUID
+		if s.secondary {
+			typ = SecondaryServiceUUID
+		}
+		end := start[i] + uint16(size(s)) - 1
+		db.add(Attribute{Type: typ, Value: uuidValue(s.uuid), End: end})
+		for _, uuid := range s.includes {
+			j, ok := find(uuid)
+			if !ok || j == i {
+				return nil, ErrIncludeNotFound
+			}
+			v := make([]byte, 4)
+			binary.LittleEndian.PutUint16(v, start[j])
+			binary.LittleEndian.PutUint16(v[2:], start[j]+uint16(size(services[j]))-1)
+			if n, ok := Short(uuid); ok {
+				v = append(v, byte(n), byte(n>>8))
+			}
+			db.add(Attribute{Type: IncludeUUID, Value: v})
+		}
+		for _, c := range s.characteristics {
+			c := c
+			decl := uint16(len(db.attributes) + 1)
+			value := decl + 1
+			v := []byte{byte(c.properties), byte(value), byte(value >> 8)}
+			db.add(Attribute{Type: CharacteristicUUID, Value: append(v, uuidValue(c.uuid)...), Characteristic: &c, ValueHandle: value})
+			db.add(Attribute{Type: c.uuid, Characteristic: &c, ValueHandle: value})
+			if c.properties&(Notify|Indicate) != 0 {
+				db.add(Attribute{Type: ClientConfigurationUUID, Characteristic: &c, ValueHandle: value})
+			}
+			for _, d := range c.descriptors {
+				db.add(Attribute{Type: d.uuid, Value: d.value, Characteristic: &c, ValueHandle: value})
+			}
+		}
+	}
+	return db, nil
+}
+
+func (db *Database) add(a Attribute) {
+	a.Handle = uint16(len(db.attributes) + 1)
+	db.attributes = append(db.attributes, a)
+}
+
+// uuidValue encodes a uuid little-endian, 16-bit for assigned numbers
+func uuidValue(uuid xpc.UUID) []byte {
+	if n, ok := Short(uuid); ok {
+		return []byte{byte(n), byte(n >> 8)}
+	}
+	b := make([]byte, 16)
+	for i := range uuid {
+		b[15-i] = uuid[i]
+	}
+	return b
+}
+
+// Len returns the number of attributes, which is also the last handle
+func (db *Database) Len() int {
+	return len(db.attributes)
+}
+
+// Attributes returns a copy of all attributes in handle order
+func (db *Database) Attributes() []Attribute {
+	return append([]Attribute(nil), db.attributes...)
+}
+
+// Attribute returns the attribute with handle
+func (db *Database) Attribute(handle uint16) (Attribute, bool) {
+	if handle == 0 || int(handle) > len(db.attributes) {
+		return Attribute{}, false
+	}
+	return db.attributes[handle-1], true
+}
+
+// Range returns the attributes from start to end handle
+func (db *Database) Range(start, end uint16) []Attribute {
+	if start == 0 {
+		start = 1
+	}
+	if int(end) > len(db.attributes) {
+		end = uint16(len(db.attributes))
+	}
+	if start > end {
+		return nil
+	}
+	return append([]Attribute(nil), db.attributes[start-1:end]...)
+}
+
+// Find returns the attributes of a type
+func (db *Database) Find(typ xpc.UUID) []Attribute {
+	var found []Attribute
+	for _, a := range db.attributes {
+		if a.Type == typ {
+			found = append(found, a)
+		}
+	}
+	return found
+}
+
+// Service returns the declaration of a primary or secondary service
+func (db *Database) Service(uuid xpc.UUID) (Attribute, bool) {
+	v := uuidValue(uuid)
+	for _, a := range db.attributes {
+		if (a.Type == PrimaryServiceUUID || a.Type == SecondaryServiceUUID) && bytes.Equal(a.Value, v) {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Characteristic returns the value attribute of a characteristic
+func (db *Database) Characteristic(uuid xpc.UUID) (Attribute, bool) {
+	for _, a := range db.attributes {
+		if a.Characteristic != nil && a.Handle == a.ValueHandle && a.Type == uuid {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// equal reports whether a client sees two attributes alike
+func (a Attribute) equal(b Attribute) bool {
+	if a.Type != b.Type || a.End != b.End || a.ValueHandle != b.ValueHandle || !bytes.Equal(a.Value, b.Value) {
+		return false
+	}
+	if a.Characteristic != nil && b.Characteristic != nil {
+		return a.Characteristic.properties == b.Characteristic.properties
+	}
+	return a.Characteristic == b.Characteristic
+}
+
+// Changed returns the handle range a client has to rediscover when the
+// database is replaced by next, ok is false if nothing changed
+func (db *Database) Changed(next *Database) (start, end uint16, ok bool) {
+	n := len(db.attributes)
+	if len(next.attributes) > n {
+		n = len(next.attributes)
+	}
+	for h := 1; h <= n; h++ {
+		a, _ := db.Attribute(uint16(h))
+		b, _ := next.Attribute(uint16(h))
+		if a.equal(b) {
+			continue
+		}
+		if start == 0 {
+			start = uint16(h)
+		}
+		end = uint16(h)
+	}
+	return start, end, start != 0
+}
+
+// ServiceChanged encodes the value of the Service Changed characteristic
+func ServiceChanged(start, end uint16) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint16(b, start)
+	binary.LittleEndian.PutUint16(b[2:], end)
+	return b
+}
@@ -0,0 +1,137 @@
+package goble
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/dim13/goble/xpc"
+)
+
+// messages records what BLE sends to blued
+type messages []xpc.Dict
+
+func (m *messages) Send(msg interface{}, verbose bool) {
+	*m = append(*m, msg.(xpc.Dict))
+}
+
+func TestDatabase(t *testing.T) {
+	level := NewCharacteristic(UUID16(0x2a19), Read|Notify, []byte{100})
+	level.SetUserDescription("level")
+	battery := NewSecondaryService(UUID16(0x180f), level)
+	device := NewService(UUID16(0x180a), NewCharacteristic(UUID16(0x2a29), Read, []byte("goble")))
+	device.Include(battery.UUID())
+
+	db, err := NewDatabase(device, battery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		typ   uint16
+		value string
+		end   uint16
+	}{
+		{0x2800, "0a18", 4},
+		{0x2802, "050009000f18", 0},
+		{0x2803, "020400292a", 0},
+		{0x2a29, "", 0},
+		{0x2801, "0f18", 9},
+		{0x2803, "120700192a", 0},
+		{0x2a19, "", 0},
+		{0x2902, "", 0},
+		{0x2901, "6c6576656c", 0},
+	}
+	if db.Len() != len(want) {
+		t.Fatalf("got %d attributes, want %d", db.Len(), len(want))
+	}
+	for i, w := range want {
+		a, ok := db.Attribute(uint16(i + 1))
+		if !ok || a.Type != UUID16(w.typ) || hex.EncodeToString(a.Value) != w.value || a.End != w.end {
+			t.Errorf("%d: got %v %x %v", i+1, a.Type, a.Value, a.End)
+		}
+	}
+	if _, ok := db.Attribute(10); ok {
+		t.Error("attribute beyond the last handle")
+	}
+
+	if a, ok := db.Service(UUID16(0x180f)); !ok || a.Handle != 5 {
+		t.Errorf("got %v %v, want handle 5", a.Handle, ok)
+	}
+	a, ok := db.Characteristic(UUID16(0x2a19))
+	if !ok || a.Handle != 7 || a.ValueHandle != 7 {
+		t.Fatalf("got %v %v, want handle 7", a.Handle, ok)
+	}
+	if v, err := a.Characteristic.ReadValue(UUID16(0), 0); err != nil || v[0] != 100 {
+		t.Errorf("got %v %v", v, err)
+	}
+	if a, _ := db.Attribute(8); !a.Configuration() || a.ValueHandle != 7 {
+		t.Errorf("handle 8 is not the configuration of 7")
+	}
+	if n := len(db.Find(CharacteristicUUID)); n != 2 {
+		t.Errorf("got %d characteristic declarations, want 2", n)
+	}
+	if n := len(db.Range(3, 6)); n != 4 {
+		t.Errorf("got %d attributes in range, want 4", n)
+	}
+
+	orphan := NewService(UUID16(0x1800))
+	orphan.Include(UUID16(0x1801))
+	if _, err := NewDatabase(orphan); err != ErrIncludeNotFound {
+		t.Errorf("got %v, want %v", err, ErrIncludeNotFound)
+	}
+}
+
+func TestDatabaseChanged(t *testing.T) {
+	gatt := NewService(GenericAttributeUUID, NewCharacteristic(ServiceChangedUUID, Indicate, nil))
+	battery := NewService(UUID16(0x180f), NewCharacteristic(UUID16(0x2a19), Read, []byte{100}))
+	device := NewService(UUID16(0x180a), NewCharacteristic(UUID16(0x2a29), Read, []byte("goble")))
+
+	old, _ := NewDatabase(gatt, battery)
+	same, _ := NewDatabase(gatt, battery)
+	if _, _, ok := old.Changed(same); ok {
+		t.Error("equal databases changed")
+	}
+	added, _ := NewDatabase(gatt, battery, device)
+	if start, end, ok := old.Changed(added); !ok || start != 8 || end != 10 {
+		t.Errorf("got %v-%v %v, want 8-10", start, end, ok)
+	}
+	removed, _ := NewDatabase(gatt, device)
+	if start, end, ok := added.Changed(removed); !ok || start != 5 || end != 10 {
+		t.Errorf("got %v-%v %v, want 5-10", start, end, ok)
+	}
+	if got := hex.EncodeToString(ServiceChanged(5, 10)); got != "05000a00" {
+		t.Errorf("got %v, want 05000a00", got)
+	}
+}
+
+func TestSetServicesIncludes(t *testing.T) {
+	var sent messages
+	ble := NewWithTransport(&sent, "")
+	battery := NewSecondaryService(UUID16(0x180f), NewCharacteristic(UUID16(0x2a19), Read, []byte{100}))
+	device := NewService(UUID16(0x180a), NewCharacteristic(UUID16(0x2a29), Read, []byte("goble")))
+	device.Include(battery.UUID())
+	ble.SetServices([]Service{device, battery})
+
+	var services []xpc.Dict
+	for _, m := range sent {
+		if m["kCBMsgId"] == setServicesMsg {
+			services = append(services, m["kCBMsgArgs"].(xpc.Dict))
+		}
+	}
+	if len(services) != 2 {
+		t.Fatalf("got %d services, want 2", len(services))
+	}
+	if ids := services[0]["kCBMsgArgAttributeIDs"]; !reflect.DeepEqual(ids, []int{3}) {
+		t.Errorf("got includes %v, want [3]", ids)
+	}
+	if services[0]["kCBMsgArgType"] != 1 || services[1]["kCBMsgArgType"] != 0 {
+		t.Errorf("got types %v %v, want 1 0", services[0]["kCBMsgArgType"], services[1]["kCBMsgArgType"])
+	}
+	c, ok := ble.characteristic(4)
+	if !ok || c.UUID() != UUID16(0x2a19) {
+		t.Errorf("got %v %v for attribute id 4", c.UUID(), ok)
+	}
+	if a, ok := ble.Database().Characteristic(UUID16(0x2a19)); !ok || a.Handle != 7 {
+		t.Errorf("got handle %v %v, want 7", a.Handle, ok)
+	}
+}
@@ -0,0 +1,307 @@
+package goble
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// https://github.com/google/eddystone/blob/master/protocol-specification.md
+
+// Eddystone service uuid (0xFEAA)
+var EddystoneUUID = UUID16(0xfeaa)
+
+// Eddystone frame types
+const (
+	eddystoneUID = 0x00
+	eddystoneURL = 0x10
+	eddystoneTLM = 0x20
+	eddystoneEID = 0x30
+)
+
+var (
+	ErrEddystoneFrame = errors.New("invalid eddystone frame")
+	ErrEddystoneURL   = errors.New("url cannot be encoded as eddystone url")
+)
+
+// maximum length of an encoded eddystone url (after the scheme byte)
+const maxEddystoneURLLength = 17
+
+// URL scheme prefixes
+var eddystoneSchemes = []string{
+	"http://www.",
+	"https://www.",
+	"http://",
+	"https://",
+}
+
+// URL expansion codes, longer expansions first
+var eddystoneExpansions = []string{
+	".com/",
+	".org/",
+	".edu/",
+	".net/",
+	".info/",
+	".biz/",
+	".gov/",
+	".com",
+	".org",
+	".edu",
+	".net",
+	".info",
+	".biz",
+	".gov",
+}
+
+// EddystoneFrame is one of EddystoneUID, EddystoneURL, EddystoneTLM or EddystoneEID
+type EddystoneFrame interface {
+	encoding.BinaryMarshaler
+}
+
+// Eddystone-UID frame
+type EddystoneUID struct {
+	TxPower   int8 // calibrated at 0 m
+	Namespace [10]byte
+	Instance  [6]byte
+}
+
+func (f EddystoneUID) MarshalBinary() ([]byte, error) {
+	b := []byte{eddystoneUID, byte(f.TxPower)}
+	b = append(b, f.Namespace[:]...)
+	b = append(b, f.Instance[:]...)
+	return append(b, 0, 0), nil // RFU
+}
+
+func (f *EddystoneUID) UnmarshalBinary(b []byte) error {
+	if len(b) < 18 || b[0] != eddystoneUID {
+		return ErrEddystoneFrame
+	}
+	f.TxPower = int8(b[1])
+	copy(f.Namespace[:], b[2:12])
+	copy(f.Instance[:], b[12:18])
+	return nil
+}
+
+func (f EddystoneUID) String() string {
+	return fmt.Sprintf("eddystone-uid %x %x (%d dBm)", f.Namespace, f.Instance, f.TxPower)
+}
+
+// Eddystone-URL frame
+type EddystoneURL struct {
+	TxPower int8 // calibrated at 0 m
+	URL     string
+}
+
+func (f EddystoneURL) MarshalBinary() ([]byte, error) {
+	scheme := -1
+	for i, s := range eddystoneSchemes {
+		if strings.HasPrefix(f.URL, s) && (scheme < 0 || len(s) > len(eddystoneSchemes[scheme])) {
+			scheme = i
+		}
+	}
+	if scheme < 0 {
+		return nil, ErrEddystoneURL
+	}
+
+	b := []byte{eddystoneURL, byte(f.TxPower), byte(scheme)}
+	rest := f.URL[len(eddystoneSchemes[scheme]):]
+	n := 0
+next:
+	for len(rest) > 0 {
+		if n++; n > maxEddystoneURLLength {
+			return nil, ErrEddystoneURL
+		}
+		for code, exp := range eddystoneExpansions {
+			if strings.HasPrefix(rest, exp) {
+				b = append(b, byte(code))
+				rest = rest[len(exp):]
+				continue next
+			}
+		}
+		if c := rest[0]; c <= 0x20 || c >= 0x7f {
+			return nil, ErrEddystoneURL
+		}
+		b = append(b, rest[0])
+		rest = rest[1:]
+	}
+	return b, nil
+}
+
+func (f *EddystoneURL) UnmarshalBinary(b []byte) error {
+	if len(b) < 3 || b[0] != eddystoneURL || int(b[2]) >= len(eddystoneSchemes) {
+		return ErrEddystoneFrame
+	}
+	f.TxPower = int8(b[1])
+	url := eddystoneSchemes[b[2]]
+	for _, c := range b[3:] {
+		if int(c) < len(eddystoneExpansions) {
+			url += eddystoneExpansions[c]
+		} else {
+			url += string(rune(c))
+		}
+	}
+	f.URL = url
+	return nil
+}
+
+func (f EddystoneURL) String() string {
+	return fmt.Sprintf("eddystone-url %s (%d dBm)", f.URL, f.TxPower)
+}
+
+// Eddystone-TLM frame
+//
+// Version 0 carries plain telemetry, version 1 (encrypted TLM) only the raw
+// encrypted telemetry, salt and check in Encrypted.
+type EddystoneTLM struct {
+	Version     uint8
+	Battery     uint16  // battery voltage in mV, 0 if not supported
+	Temperature float64 // beacon temperature in °C, NaN if not supported
+	AdvCount    uint32  // advertising PDUs since power-up
+	Uptime      time.Duration
+	Encrypted   []byte
+}
+
+func (f EddystoneTLM) MarshalBinary() ([]byte, error) {
+	if f.Version != 0 {
+		return append([]byte{eddystoneTLM, f.Version}, f.Encrypted...), nil
+	}
+	b := make([]byte, 14)
+	b[0] = eddystoneTLM
+	binary.BigEndian.PutUint16(b[2:], f.Battery)
+	temp := uint16(0x8000)
+	if !math.IsNaN(f.Temperature) {
+		temp = uint16(int16(f.Temperature * 256))
+	}
+	binary.BigEndian.PutUint16(b[4:], temp)
+	binary.BigEndian.PutUint32(b[6:], f.AdvCount)
+	binary.BigEndian.PutUint32(b[10:], uint32(f.Uptime/(100*time.Millisecond)))
+	return b, nil
+}
+
+func (f *EddystoneTLM) UnmarshalBinary(b []byte) error {
+	if len(b) < 2 || b[0] != eddystoneTLM {
+		return ErrEddystoneFrame
+	}
+	f.Version = b[1]
+	if f.Version != 0 {
+		f.Encrypted = append([]byte(nil), b[2:]...)
+		return nil
+	}
+	if len(b) < 14 {
+		return ErrEddystoneFrame
+	}
+	f.Battery = binary.BigEndian.Uint16(b[2:])
+	if temp := binary.BigEndian.Uint16(b[4:]); temp == 0x8000 {
+		f.Temperature = math.NaN()
+	} else {
+		f.Temperature = float64(int16(temp)) / 256
+	}
+	f.AdvCount = binary.BigEndian.Uint32(b[6:])
+	f.Uptime = time.Duration(binary.BigEndian.Uint32(b[10:])) * 100 * time.Millisecond
+	return nil
+}
+
+func (f EddystoneTLM) String() string {
+	if f.Version != 0 {
+		return fmt.Sprintf("eddystone-tlm encrypted %x", f.Encrypted)
+	}
+	return fmt.Sprintf("eddystone-tlm %d mV %.2f°C %d pdus up %v", f.Battery, f.Temperature, f.AdvCount, f.Uptime)
+}
+
+// Eddystone-EID frame
+type EddystoneEID struct {
+	TxPower int8 // calibrated at 0 m
+	EID     [8]byte
+}
+
+func (f EddystoneEID) MarshalBinary() ([]byte, error) {
+	return append([]byte{eddystoneEID, byte(f.TxPower)}, f.EID[:]...), nil
+}
+
+func (f *EddystoneEID) UnmarshalBinary(b []byte) error {
+	if len(b) < 10 || b[0] != eddystoneEID {
+		return ErrEddystoneFrame
+	}
+	f.TxPower = int8(b[1])
+	copy(f.EID[:], b[2:10])
+	return nil
+}
+
+func (f EddystoneEID) String() string {
+	return fmt.Sprintf("eddystone-eid %x (%d dBm)", f.EID, f.TxPower)
+}
+
+// ParseEddystone decodes the service data of an Eddystone (0xFEAA) service
+func ParseEddystone(data []byte) (EddystoneFrame, error) {
+	if len(data) == 0 {
+		return nil, ErrEddystoneFrame
+	}
+	var frame interface {
+		EddystoneFrame
+		encoding.BinaryUnmarshaler
+	}
+	switch data[0] {
+	case eddystoneUID:
+		frame = &EddystoneUID{}
+	case eddystoneURL:
+		frame = &EddystoneURL{}
+	case eddystoneTLM:
+		frame = &EddystoneTLM{}
+	case eddystoneEID:
+		frame = &EddystoneEID{}
+	default:
+		return nil, ErrEddystoneFrame
+	}
+	if err := frame.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// Eddystone returns the Eddystone frame found in the advertised service data
+func (a Advertisement) Eddystone() (EddystoneFrame, bool) {
+	short := EddystoneUUID.String()[4:8]
+	for _, sd := range a.ServiceData {
+		if sd.Uuid == short || sd.Uuid == EddystoneUUID.String() {
+			frame, err := ParseEddystone(sd.Data)
+			return frame, err == nil
+		}
+	}
+	return nil, false
+}
+
+// start advertising an Eddystone frame
+func (ble *BLE) StartAdvertisingEddystone(frame EddystoneFrame) error {
+	data, err := frame.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = ble.StartAdvertisingData(NewAdvertisingData().
+		AddServiceUUID(EddystoneUUID).
+		AddServiceData(EddystoneUUID, data))
+	return err
+}
+
+// start advertising as Eddystone-UID
+func (ble *BLE) StartAdvertisingEddystoneUID(namespace [10]byte, instance [6]byte, txPower int8) error {
+	return ble.StartAdvertisingEddystone(EddystoneUID{TxPower: txPower, Namespace: namespace, Instance: instance})
+}
+
+// start advertising as Eddystone-URL
+func (ble *BLE) StartAdvertisingEddystoneURL(url string, txPower int8) error {
+	return ble.StartAdvertisingEddystone(EddystoneURL{TxPower: txPower, URL: url})
+}
+
+// start advertising as Eddystone-TLM
+func (ble *BLE) StartAdvertisingEddystoneTLM(tlm EddystoneTLM) error {
+	return ble.StartAdvertisingEddystone(tlm)
+}
+
+// start advertising as Eddystone-EID
+func (ble *BLE) StartAdvertisingEddystoneEID(eid [8]byte, txPower int8) error {
+	return ble.StartAdvertisingEddystone(EddystoneEID{TxPower: txPower, EID: eid})
+}
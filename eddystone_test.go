@@ -0,0 +1,96 @@
+package goble
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble/xpc"
+)
+
+func TestEddystoneURL(t *testing.T) {
+	testCases := []struct {
+		url  string
+		data []byte
+	}{
+		{"https://www.google.com/", []byte{0x10, 0xeb, 0x01, 'g', 'o', 'o', 'g', 'l', 'e', 0x00}},
+		{"http://goo.gl/abc", []byte{0x10, 0xeb, 0x02, 'g', 'o', 'o', '.', 'g', 'l', '/', 'a', 'b', 'c'}},
+		{"https://example.org", []byte{0x10, 0xeb, 0x03, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x08}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			data, err := EddystoneURL{TxPower: -21, URL: tc.url}.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tc.data) {
+				t.Errorf("got %x, want %x", data, tc.data)
+			}
+			frame, err := ParseEddystone(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u, ok := frame.(*EddystoneURL); !ok || u.URL != tc.url || u.TxPower != -21 {
+				t.Errorf("got %v", frame)
+			}
+		})
+	}
+
+	for _, url := range []string{"ftp://example.com", "https://a-very-long-host-name.example.com/"} {
+		if _, err := (EddystoneURL{URL: url}).MarshalBinary(); err != ErrEddystoneURL {
+			t.Errorf("%v: got %v, want %v", url, err, ErrEddystoneURL)
+		}
+	}
+}
+
+func TestEddystoneFrames(t *testing.T) {
+	uid := EddystoneUID{TxPower: -20, Namespace: [10]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, Instance: [6]byte{1, 2, 3, 4, 5, 6}}
+	tlm := EddystoneTLM{Battery: 3000, Temperature: 21.5, AdvCount: 1000, Uptime: time.Hour}
+	eid := EddystoneEID{TxPower: -10, EID: [8]byte{8, 7, 6, 5, 4, 3, 2, 1}}
+
+	for _, frame := range []EddystoneFrame{uid, tlm, eid} {
+		data, err := frame.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ad := Advertisement{ServiceData: []ServiceData{{Uuid: "feaa", Data: data}}}
+		got, ok := ad.Eddystone()
+		if !ok {
+			t.Fatalf("no eddystone frame in %x", data)
+		}
+		switch f := got.(type) {
+		case *EddystoneUID:
+			if *f != uid {
+				t.Errorf("got %v, want %v", f, uid)
+			}
+		case *EddystoneTLM:
+			if f.Battery != tlm.Battery || f.Temperature != tlm.Temperature || f.AdvCount != tlm.AdvCount || f.Uptime != tlm.Uptime {
+				t.Errorf("got %v, want %v", f, tlm)
+			}
+		case *EddystoneEID:
+			if *f != eid {
+				t.Errorf("got %v, want %v", f, eid)
+			}
+		}
+	}
+}
+
+func TestDiscoverTelemetry(t *testing.T) {
+	ble := NewWithTransport(transport{}, "")
+	events := make(chan Event, 1)
+	ble.Listen(func(ev Event) bool {
+		events <- ev
+		return false
+	})
+	tlm, _ := EddystoneTLM{Battery: 3000, Temperature: 21.5, AdvCount: 1000, Uptime: time.Hour}.MarshalBinary()
+	ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(37), "kCBMsgArgs": xpc.Dict{
+		"kCBMsgArgDeviceUUID": xpc.MustUUID("0123456789abcdef0123456789abcdef"),
+		"kCBMsgArgAdvertisementData": xpc.Dict{
+			"kCBAdvDataServiceData": xpc.Array{[]byte{0xfe, 0xaa}, tlm},
+		},
+	}}, nil)
+	ev := <-events
+	if ev.Telemetry == nil || ev.Telemetry.Battery != 3000 || ev.Telemetry.Uptime != time.Hour {
+		t.Errorf("got telemetry %+v", ev.Telemetry)
+	}
+}
@@ -2,6 +2,8 @@ package goble
 
 import (
 	"log"
+	"sort"
+	"sync"
 
 	"github.com/dim13/goble/xpc"
 )
@@ -15,10 +17,16 @@ type Event struct {
 	DeviceUUID         xpc.UUID
 	ServiceUuid        string
 	CharacteristicUuid string
+	DescriptorUuid     string
+	Handle             int // declaration of the characteristic, or the descriptor
 	Peripheral         Peripheral
 	Data               []byte
 	Mtu                int
-	IsNotification     bool
+	IsNotification     bool // notification value, or notifications enabled
+	Result             int  // ATT error code of a read, write or notify request, or status of a failed connect
+	Configuration      ClientConfiguration
+	Beacon             Beacon
+	Telemetry          *EddystoneTLM // Eddystone-TLM frame of a discover event
 }
 
 // The event handler function.
@@ -28,34 +36,52 @@ type EventHandlerFunc func(Event) bool
 // Emitter is an object to emit and handle Event(s)
 type Emitter struct {
 	handlers map[string]EventHandlerFunc
-	event    chan Event
 	verbose  bool
+
+	qmu     sync.Mutex
+	queue   []Event       // events not yet handled, unbounded so none is lost
+	ready   chan struct{} // signals queued events
+	stop    chan struct{} // closed by a handler returning true
+	stopped bool
+
+	mu        sync.Mutex
+	listeners map[int]EventHandlerFunc
+	nextID    int
 }
 
 // Init initialize the emitter and start a goroutine to execute the event handlers
 func (e *Emitter) Init() {
 	e.handlers = make(map[string]EventHandlerFunc)
-	e.event = make(chan Event)
+	e.listeners = make(map[int]EventHandlerFunc)
+	e.ready = make(chan struct{}, 1)
+	e.stop = make(chan struct{})
 
 	// event handler
 	go func() {
-		for ev := range e.event {
-			fn, ok := e.handlers[ev.Name]
-			if !ok {
-				fn, ok = e.handlers[ALL]
+		for {
+			select {
+			case <-e.ready:
+			case <-e.stop:
+				return
 			}
-			if ok {
-				go func(ev Event) {
-					if fn(ev) {
-						close(e.event)
-					}
-				}(ev)
-			} else if e.verbose {
-				log.Println("unhandled Emit", ev)
+			for ev, more := e.next(); more; ev, more = e.next() {
+				e.notify(ev)
+
+				fn, ok := e.handlers[ev.Name]
+				if !ok {
+					fn, ok = e.handlers[ALL]
+				}
+				if ok {
+					go func(ev Event) {
+						if fn(ev) {
+							e.terminate()
+						}
+					}(ev)
+				} else if e.verbose {
+					log.Println("unhandled Emit", ev)
+				}
 			}
 		}
-
-		close(e.event) // TOFIX: this causes new "emits" to panic.
 	}()
 }
 
@@ -63,12 +89,45 @@ func (e *Emitter) SetVerbose(v bool) {
 	e.verbose = v
 }
 
-// Emit sends the event on the 'event' channel
+// Emit queues the event for the handlers, it never blocks and no event is
+// dropped, so that responses awaited by a request arrive however many
+// events come in between
 func (e *Emitter) Emit(ev Event) {
+	e.qmu.Lock()
+	if e.stopped {
+		e.qmu.Unlock()
+		return
+	}
+	e.queue = append(e.queue, ev)
+	e.qmu.Unlock()
 	select {
-	case e.event <- ev:
+	case e.ready <- struct{}{}:
 	default:
-		log.Println("skip Event", ev)
+	}
+}
+
+// next dequeues the oldest event
+func (e *Emitter) next() (Event, bool) {
+	e.qmu.Lock()
+	defer e.qmu.Unlock()
+	if len(e.queue) == 0 {
+		e.queue = nil
+		return Event{}, false
+	}
+	ev := e.queue[0]
+	e.queue[0] = Event{}
+	e.queue = e.queue[1:]
+	return ev, true
+}
+
+// terminate stops the event loop, later events are discarded
+func (e *Emitter) terminate() {
+	e.qmu.Lock()
+	defer e.qmu.Unlock()
+	if !e.stopped {
+		e.stopped = true
+		e.queue = nil
+		close(e.stop)
 	}
 }
 
@@ -81,3 +140,43 @@ func (e *Emitter) On(event string, fn EventHandlerFunc) {
 func (e *Emitter) Off(event string) {
 	delete(e.handlers, event)
 }
+
+// Listen registers fn for all events, in addition to the handler set with On.
+// Listeners are called in order of arrival from the event loop and must not
+// block; a listener returning true is removed. The returned function removes
+// the listener.
+func (e *Emitter) Listen(fn EventHandlerFunc) (cancel func()) {
+	e.mu.Lock()
+	id := e.nextID
+	e.nextID++
+	e.listeners[id] = fn
+	e.mu.Unlock()
+
+	return func() {
+		e.mu.Lock()
+		delete(e.listeners, id)
+		e.mu.Unlock()
+	}
+}
+
+// notify calls the listeners in registration order
+func (e *Emitter) notify(ev Event) {
+	e.mu.Lock()
+	ids := make([]int, 0, len(e.listeners))
+	for id := range e.listeners {
+		ids = append(ids, id)
+	}
+	e.mu.Unlock()
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		e.mu.Lock()
+		fn, ok := e.listeners[id]
+		e.mu.Unlock()
+		if ok && fn(ev) {
+			e.mu.Lock()
+			delete(e.listeners, id)
+			e.mu.Unlock()
+		}
+	}
+}
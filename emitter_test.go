@@ -0,0 +1,78 @@
+package goble
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListen(t *testing.T) {
+	var e Emitter
+	e.Init()
+
+	handled := make(chan string, 4)
+	e.On("read", func(ev Event) bool {
+		handled <- "handler " + ev.Name
+		return false
+	})
+	cancel := e.Listen(func(ev Event) bool {
+		handled <- "listener " + ev.Name
+		return false
+	})
+	once := e.Listen(func(ev Event) bool {
+		handled <- "once " + ev.Name
+		return true
+	})
+	defer once()
+
+	e.Emit(Event{Name: "read"})
+	got := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		select {
+		case s := <-handled:
+			got[s] = true
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	for _, s := range []string{"handler read", "listener read", "once read"} {
+		if !got[s] {
+			t.Errorf("%s not called", s)
+		}
+	}
+
+	cancel()
+	e.Emit(Event{Name: "write"})
+	select {
+	case s := <-handled:
+		t.Errorf("unexpected %s", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEmitFlood(t *testing.T) {
+	var e Emitter
+	e.Init()
+
+	const n = 1000
+	got := make(chan int, n)
+	release := make(chan struct{})
+	e.Listen(func(ev Event) bool {
+		<-release // hold the event loop while the flood comes in
+		got <- ev.Mtu
+		return false
+	})
+	for i := 0; i < n; i++ {
+		e.Emit(Event{Name: "read", Mtu: i})
+	}
+	close(release)
+	for i := 0; i < n; i++ {
+		select {
+		case m := <-got:
+			if m != i {
+				t.Fatalf("got event %d, want %d", m, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d lost", i)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Package estimator turns RSSI readings into distance and proximity estimates
+package estimator
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+)
+
+// Proximity zone of a device
+type Proximity int
+
+const (
+	Unknown Proximity = iota
+	Immediate
+	Near
+	Far
+)
+
+func (p Proximity) String() string {
+	switch p {
+	case Unknown:
+		return "unknown"
+	case Immediate:
+		return "immediate"
+	case Near:
+		return "near"
+	case Far:
+		return "far"
+	}
+	return fmt.Sprintf("Proximity(%d)", int(p))
+}
+
+// PathLoss is a log-distance path loss model:
+//
+//	rssi = calibrated - 10 * Exponent * log10(distance)
+//
+// where calibrated is the expected RSSI at 1 m.
+type PathLoss struct {
+	Exponent float64 // 2 in free space, 2.7 to 4 indoors
+}
+
+// Distance returns the distance in meters for a RSSI reading
+func (m PathLoss) Distance(rssi, calibrated float64) float64 {
+	return math.Pow(10, (calibrated-rssi)/(10*m.Exponent))
+}
+
+// Estimate of a single device
+type Estimate struct {
+	RSSI      float64 // smoothed RSSI
+	Distance  float64 // meters, 0 if unknown
+	Proximity Proximity
+	Updated   time.Time
+}
+
+type device struct {
+	filter     Filter
+	calibrated int
+	estimate   Estimate
+}
+
+// Estimator keeps per-device smoothed RSSI and proximity. Zero values of
+// Model, NewFilter, Immediate and Near take the defaults of New, so the zero
+// Estimator is usable.
+type Estimator struct {
+	Model         PathLoss
+	NewFilter     func() Filter // filter created for every new device
+	Immediate     float64       // upper bound of the immediate zone (m)
+	Near          float64       // upper bound of the near zone (m)
+	Hysteresis    float64       // fraction a zone boundary must be crossed by to change zones
+	DefaultPower  int           // RSSI at 1 m when a device advertises none, 0 to leave unknown
+	TxPowerLoss1m int           // loss subtracted from advertised TX power level to get RSSI at 1 m
+	mu            sync.Mutex
+	devices       map[string]*device
+}
+
+// defaults of New
+var (
+	defaultModel     = PathLoss{Exponent: 2.5}
+	defaultFilter    = func() Filter { return NewKalman(0.008, 4) }
+	defaultImmediate = 0.5
+	defaultNear      = 3.0
+)
+
+// New creates an estimator with an indoor path loss model and Kalman smoothing
+func New() *Estimator {
+	return &Estimator{
+		Model:         defaultModel,
+		NewFilter:     defaultFilter,
+		Immediate:     defaultImmediate,
+		Near:          defaultNear,
+		Hysteresis:    0.1,
+		TxPowerLoss1m: 41,
+		devices:       map[string]*device{},
+	}
+}
+
+// model returns the path loss model, the default one if unset
+func (e *Estimator) model() PathLoss {
+	if e.Model.Exponent == 0 {
+		return defaultModel
+	}
+	return e.Model
+}
+
+// newFilter creates the filter of a new device
+func (e *Estimator) newFilter() Filter {
+	if e.NewFilter == nil {
+		return defaultFilter()
+	}
+	return e.NewFilter()
+}
+
+// zone classifies a distance, keeping the previous zone near its boundaries
+func (e *Estimator) zone(distance float64, previous Proximity) Proximity {
+	bounds := []float64{e.Immediate, e.Near}
+	if bounds[0] == 0 {
+		bounds[0] = defaultImmediate
+	}
+	if bounds[1] == 0 {
+		bounds[1] = defaultNear
+	}
+	p := Far
+	for i, b := range bounds {
+		if distance < b {
+			p = Proximity(i + 1)
+			break
+		}
+	}
+	if previous == Unknown || p == previous {
+		return p
+	}
+	// only leave the previous zone when clearly outside of it
+	lo, hi := 0.0, math.Inf(1)
+	if previous > Immediate {
+		lo = bounds[previous-2]
+	}
+	if previous < Far {
+		hi = bounds[previous-1]
+	}
+	if distance >= lo*(1-e.Hysteresis) && distance < hi*(1+e.Hysteresis) {
+		return previous
+	}
+	return p
+}
+
+// Add feeds a RSSI reading for device id. calibrated is the expected RSSI
+// at 1 m, 0 to keep the last known value.
+func (e *Estimator) Add(id string, rssi, calibrated int) Estimate {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.devices == nil {
+		e.devices = map[string]*device{}
+	}
+	d, ok := e.devices[id]
+	if !ok {
+		d = &device{filter: e.newFilter(), calibrated: e.DefaultPower}
+		e.devices[id] = d
+	}
+	if calibrated != 0 {
+		d.calibrated = calibrated
+	}
+	if rssi >= 0 { // 127 is reported when RSSI is not available
+		return d.estimate
+	}
+
+	est := Estimate{RSSI: d.filter.Update(float64(rssi)), Updated: time.Now()}
+	if d.calibrated != 0 {
+		est.Distance = e.model().Distance(est.RSSI, float64(d.calibrated))
+		est.Proximity = e.zone(est.Distance, d.estimate.Proximity)
+	}
+	d.estimate = est
+	return est
+}
+
+// Update feeds discover and rssiUpdate events. Discover events calibrate
+// with the beacon or the advertised TX power level when present, rssiUpdate
+// events carry no beacon and keep the calibration of the device.
+func (e *Estimator) Update(ev goble.Event) (Estimate, bool) {
+	calibrated := 0
+	switch ev.Name {
+	case "discover":
+		if ev.Beacon != nil {
+			calibrated = int(ev.Beacon.Calibrated())
+		} else if tx := ev.Peripheral.Advertisement.TxPowerLevel; tx != 0 {
+			calibrated = tx - e.TxPowerLoss1m
+		}
+	case "rssiUpdate":
+	default:
+		return Estimate{}, false
+	}
+	return e.Add(ev.DeviceUUID.String(), ev.Peripheral.Rssi, calibrated), true
+}
+
+// Estimate returns the last estimate for device id
+func (e *Estimator) Estimate(id string) (Estimate, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	d, ok := e.devices[id]
+	if !ok {
+		return Estimate{}, false
+	}
+	return d.estimate, true
+}
+
+// Forget drops the state of device id
+func (e *Estimator) Forget(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.devices, id)
+}
@@ -0,0 +1,106 @@
+package estimator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dim13/goble"
+)
+
+func TestPathLoss(t *testing.T) {
+	m := PathLoss{Exponent: 2}
+	testCases := []struct {
+		rssi, distance float64
+	}{
+		{-59, 1},
+		{-79, 10},
+		{-39, 0.1},
+	}
+	for _, tc := range testCases {
+		if d := m.Distance(tc.rssi, -59); math.Abs(d-tc.distance) > 1e-9 {
+			t.Errorf("%v dBm: got %v, want %v", tc.rssi, d, tc.distance)
+		}
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	m := NewMovingAverage(3)
+	for i, want := range []float64{-60, -65, -70, -75, -80} {
+		rssi := []float64{-60, -70, -80, -75, -85}[i]
+		if got := m.Update(rssi); got != want {
+			t.Errorf("%d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestKalman(t *testing.T) {
+	k := NewKalman(0.008, 4)
+	var got float64
+	for i := 0; i < 100; i++ {
+		rssi := -70.0
+		if i%2 == 0 {
+			rssi = -60
+		}
+		got = k.Update(rssi)
+	}
+	if math.Abs(got+65) > 1 {
+		t.Errorf("got %v, want about -65", got)
+	}
+}
+
+func TestProximityHysteresis(t *testing.T) {
+	e := New()
+	e.Model = PathLoss{Exponent: 2}
+	e.NewFilter = func() Filter { return NewMovingAverage(1) }
+	e.Hysteresis = 0.2
+
+	// 3 m boundary is crossed by -68.5 dBm with calibration -59
+	steps := []struct {
+		rssi int
+		want Proximity
+	}{
+		{-60, Near},
+		{-69, Near}, // 2.8 m
+		{-70, Near}, // 3.5 m, but within hysteresis of 3.6 m
+		{-72, Far},  // 4.5 m
+		{-68, Far},  // 2.8 m, within hysteresis of 2.4 m
+		{-66, Near}, // 2.2 m
+		{-50, Immediate},
+	}
+	for i, s := range steps {
+		est := e.Add("dev", s.rssi, -59)
+		if est.Proximity != s.want {
+			t.Errorf("%d: %v dBm %.2f m: got %v, want %v", i, s.rssi, est.Distance, est.Proximity, s.want)
+		}
+	}
+
+	if est := e.Add("other", -60, 0); est.Proximity != Unknown {
+		t.Errorf("got %v, want %v", est.Proximity, Unknown)
+	}
+}
+
+func TestZeroValues(t *testing.T) {
+	var m MovingAverage
+	if got := m.Update(-60); got != -60 {
+		t.Errorf("got %v, want -60", got)
+	}
+	if got := m.Update(-70); got != -70 {
+		t.Errorf("got %v, want -70", got)
+	}
+
+	var e Estimator
+	if est := e.Add("a", -59, -59); est.Proximity != Near || math.Abs(est.Distance-1) > 1e-9 {
+		t.Errorf("got %+v", est)
+	}
+}
+
+func TestRSSIUpdateKeepsBeacon(t *testing.T) {
+	e := New()
+	e.NewFilter = func() Filter { return NewMovingAverage(1) }
+	p := goble.Peripheral{Rssi: -59, Advertisement: goble.Advertisement{TxPowerLevel: 4}}
+	e.Update(goble.Event{Name: "discover", Peripheral: p, Beacon: goble.IBeacon{MeasuredPower: -59}})
+	est, _ := e.Update(goble.Event{Name: "rssiUpdate", Peripheral: p})
+	if math.Abs(est.Distance-1) > 1e-9 {
+		t.Errorf("got %v m, want 1 m with the beacon calibration", est.Distance)
+	}
+}
@@ -0,0 +1,68 @@
+package estimator
+
+// Filter smooths a stream of RSSI readings
+type Filter interface {
+	// Update adds a reading and returns the smoothed value
+	Update(rssi float64) float64
+}
+
+// MovingAverage averages the last Size readings
+type MovingAverage struct {
+	Size    int
+	samples []float64
+	next    int
+	sum     float64
+}
+
+// NewMovingAverage creates a moving average over size readings
+func NewMovingAverage(size int) *MovingAverage {
+	if size < 1 {
+		size = 1
+	}
+	return &MovingAverage{Size: size}
+}
+
+// Update adds a reading, a Size below 1 averages only the last one
+func (m *MovingAverage) Update(rssi float64) float64 {
+	size := m.Size
+	if size < 1 {
+		size = 1
+	}
+	if len(m.samples) < size {
+		m.samples = append(m.samples, rssi)
+		m.sum += rssi
+	} else {
+		m.next %= size
+		m.sum += rssi - m.samples[m.next]
+		m.samples[m.next] = rssi
+		m.next = (m.next + 1) % size
+	}
+	return m.sum / float64(len(m.samples))
+}
+
+// Kalman is a one dimensional Kalman filter assuming a constant signal
+type Kalman struct {
+	Q float64 // process noise, how fast the true RSSI is expected to change
+	R float64 // measurement noise, variance of the readings
+
+	x    float64 // estimate
+	p    float64 // estimate covariance
+	init bool
+}
+
+// NewKalman creates a Kalman filter with process noise q and measurement noise r
+func NewKalman(q, r float64) *Kalman {
+	return &Kalman{Q: q, R: r}
+}
+
+func (k *Kalman) Update(rssi float64) float64 {
+	if !k.init {
+		k.x, k.p, k.init = rssi, k.R, true
+		return k.x
+	}
+	p := k.p + k.Q
+	gain := p / (p + k.R)
+	k.x += gain * (rssi - k.x)
+	k.p = (1 - gain) * p
+	return k.x
+}
@@ -76,14 +76,27 @@ func main() {
 			for _, d := range serviceData {
 				fmt.Println(prefix, d.Uuid, ":", d.Data)
 			}
+
+			if frame, ok := ev.Peripheral.Advertisement.Eddystone(); ok {
+				fmt.Println(prefix, frame)
+			}
 		}
 
-		if len(ev.Peripheral.Advertisement.ManufacturerData) > 0 {
+		if ad := ev.Peripheral.Advertisement; len(ad.ManufacturerData) > 0 {
 			if *compact {
-				fmt.Printf("  manufacturer data: %x\n", ev.Peripheral.Advertisement.ManufacturerData)
+				fmt.Printf("  manufacturer data: %s %x\n", goble.CompanyName(ad.CompanyID), ad.ManufacturerPayload)
 			} else {
 				fmt.Println("\there is my manufacturer data:")
-				fmt.Println("\t\t", ev.Peripheral.Advertisement.ManufacturerData)
+				fmt.Println("\t\t", goble.CompanyName(ad.CompanyID), ad.ManufacturerPayload)
+			}
+		}
+
+		if ev.Beacon != nil {
+			if *compact {
+				fmt.Println("  beacon:", ev.Beacon)
+			} else {
+				fmt.Println("\tI am a beacon:")
+				fmt.Println("\t\t", ev.Beacon)
 			}
 		}
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -8,12 +9,15 @@ import (
 	"time"
 
 	"github.com/dim13/goble"
+	"github.com/dim13/goble/explorer"
 )
 
 var (
 	debug   = flag.Bool("debug", false, "log debug messages")
 	verbose = flag.Bool("verbose", false, "dump all events")
 	dups    = flag.Bool("allow-duplicates", false, "allow duplicates when scanning")
+	names   = flag.String("names", "", "JSON file with vendor uuid names")
+	format  = flag.String("format", "text", "output format: text, json or markdown")
 )
 
 func DebugPrint(params ...interface{}) {
@@ -22,172 +26,27 @@ func DebugPrint(params ...interface{}) {
 	}
 }
 
-type Result struct {
-	count int
-	data  string
-}
-
 func explore(ble *goble.BLE, peripheral *goble.Peripheral) {
-	results := map[string]Result{}
-
-	// connect
-	ble.On("connect", func(ev goble.Event) (done bool) {
-		DebugPrint("connected", ev)
-		ble.DiscoverServices(ev.DeviceUUID, nil)
-
-		go func() {
-			time.Sleep(2 * time.Minute)
-			ble.Disconnect(ev.DeviceUUID)
-		}()
-
-		return
-	})
-
-	// discover services
-	ble.On("servicesDiscover", func(ev goble.Event) (done bool) {
-		DebugPrint("serviceDiscovered", ev)
-		for sid, service := range ev.Peripheral.Services {
-			// this is a map that contains services UUIDs (string) and service startHandle (int)
-			// for now we only process the "strings"
-			if _, ok := sid.(string); ok {
-				serviceInfo := service.Uuid
-
-				if len(service.Name) > 0 {
-					serviceInfo += " (" + service.Name + ")"
-				}
-
-				results[service.Uuid] = Result{data: serviceInfo}
-				ble.DiscoverCharacteristics(ev.DeviceUUID, service.Uuid, nil)
-			}
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
 
-		return
+	dump, err := explorer.Explore(ctx, ble, peripheral.Uuid, explorer.Options{
+		ReadTimeout: 10 * time.Second,
 	})
-
-	// discover characteristics
-	ble.On("characteristicsDiscover", func(ev goble.Event) (done bool) {
-		DebugPrint("characteristicsDiscovered", ev)
-		serviceUuid := ev.ServiceUuid
-		serviceResult := results[serviceUuid]
-
-		for cid, characteristic := range ev.Peripheral.Services[serviceUuid].Characteristics {
-			// this is a map that contains services UUIDs (string) and service startHandle (int)
-			// for now we only process the "strings"
-			if _, ok := cid.(string); ok {
-				characteristicInfo := "  " + characteristic.Uuid
-
-				if len(characteristic.Name) > 0 {
-					characteristicInfo += " (" + characteristic.Name + ")"
-				}
-
-				characteristicInfo += "\n    properties  " + characteristic.Properties.String()
-				serviceResult.data += characteristicInfo
-
-				if characteristic.Properties.Readable() {
-					serviceResult.count += 1
-					ble.Read(ev.DeviceUUID, serviceUuid, characteristic.Uuid)
-				}
-
-				//ble.DiscoverDescriptors(ev.DeviceUUID, serviceUuid, characteristic.Uuid)
-				results[serviceUuid] = serviceResult
-
-				if *verbose {
-					log.Println(results[serviceUuid])
-				}
-			}
-		}
-
-		return
-	})
-
-	// discover descriptors
-	ble.On("descriptorsDiscover", func(ev goble.Event) (done bool) {
-		DebugPrint("descriptorsDiscovered", ev)
-		fmt.Println("    descriptors  ", ev.Peripheral.Services[ev.ServiceUuid].Characteristics[ev.CharacteristicUuid].Descriptors)
-		return
-	})
-
-	// read
-	ble.On("read", func(ev goble.Event) (done bool) {
-		DebugPrint("read", ev)
-		serviceUuid := ev.ServiceUuid
-		serviceResult := results[serviceUuid]
-		serviceResult.data += fmt.Sprintf("    value        %x | %q\n", ev.Data, ev.Data)
-		serviceResult.count -= 1
-
-		if serviceResult.count <= 0 {
-			fmt.Println(serviceResult.data)
-			return true
-		} else {
-			results[serviceUuid] = serviceResult
-		}
-
-		return
-	})
-
-	// disconnect
-	ble.On("disconnect", func(ev goble.Event) (done bool) {
-		DebugPrint("disconnected", ev)
-		os.Exit(0)
-		return true
-	})
-
-	fmt.Println("services and characteristics:")
-	ble.Connect(peripheral.Uuid)
-
-	/*
-	           async.series([
-	             function(callback) {
-	               characteristic.discoverDescriptors(function(error, descriptors) {
-	                 async.detect(
-	                   descriptors,
-	                   function(descriptor, callback) {
-	                     return callback(descriptor.uuid === '2901")
-	                   },
-	                   function(userDescriptionDescriptor){
-	                     if (userDescriptionDescriptor) {
-	                       userDescriptionDescriptor.readValue(function(error, data) {
-	                         characteristicInfo += ' (' + data.toString() + ')';
-	                         callback();
-	                       });
-	                     } else {
-	                       callback();
-	                     }
-	                   }
-	                 );
-	               });
-	             },
-	             function(callback) {
-	                   characteristicInfo += '\n    properties  ' + characteristic.properties.join(', ")
-
-	               if (characteristic.properties.indexOf('read') !== -1) {
-	                 characteristic.read(function(error, data) {
-	                   if (data) {
-	                     var string = data.toString('ascii")
-
-	                     characteristicInfo += '\n    value       ' + data.toString('hex') + ' | \'' + string + '\'';
-	                   }
-	                   callback();
-	                 });
-	               } else {
-	                 callback();
-	               }
-	             },
-	             function() {
-	               console.log(characteristicInfo);
-	               characteristicIndex++;
-	               callback();
-	             }
-	           ]);
-	         },
-	         function(error) {
-	           serviceIndex++;
-	           callback();
-	         }
-	       );
-	     });
-	   },
-	*/
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch *format {
+	case "json":
+		err = dump.WriteJSON(os.Stdout)
+	case "markdown":
+		err = dump.WriteMarkdown(os.Stdout)
+	default:
+		err = dump.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 func main() {
@@ -200,7 +59,13 @@ func main() {
 
 	peripheralUuid := flag.Args()[0]
 
-	var done chan bool
+	if *names != "" {
+		if err := goble.LoadNamesFile(*names); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	explored := make(chan bool)
 
 	ble := goble.New()
 	ble.SetVerbose(*verbose)
@@ -264,7 +129,11 @@ func main() {
 			fmt.Println()
 
 			DebugPrint("explore", ev.Peripheral)
-			explore(ble, &ev.Peripheral)
+			p := ev.Peripheral
+			go func() {
+				explore(ble, &p)
+				close(explored)
+			}()
 		}
 
 		return
@@ -277,7 +146,7 @@ func main() {
 	ble.Init()
 
 	fmt.Println("waiting...")
-	<-done
+	<-explored
 
 	fmt.Println("goodbye!")
 	os.Exit(0)
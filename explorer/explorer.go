@@ -0,0 +1,233 @@
+// Package explorer discovers the complete attribute tree of a peripheral,
+// reads every readable value and returns it as a structured dump.
+//
+//	dump, err := explorer.Explore(ctx, ble, p.Uuid, explorer.Options{})
+//	dump.WriteText(os.Stdout)
+package explorer
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/internal/central"
+	"github.com/dim13/goble/xpc"
+)
+
+// Options of Explore
+type Options struct {
+	ReadTimeout time.Duration // time allowed for a single read, 0 for none
+}
+
+// Hex is a value rendered as hex string
+type Hex []byte
+
+func (h Hex) String() string {
+	return hex.EncodeToString(h)
+}
+
+// MarshalText renders the value as hex string in JSON
+func (h Hex) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// GATTDump is the attribute tree of a peripheral with the values read
+type GATTDump struct {
+	Device   string
+	Name     string `json:",omitempty"` // advertised local name
+	Services []Service
+}
+
+// Service is a dumped service
+type Service struct {
+	UUID            string
+	Name            string `json:",omitempty"`
+	StartHandle     int
+	EndHandle       int
+	Characteristics []Characteristic `json:",omitempty"`
+}
+
+// Characteristic is a dumped characteristic, Value is set if it was read
+type Characteristic struct {
+	UUID        string
+	Name        string `json:",omitempty"`
+	Properties  string
+	Handle      int
+	ValueHandle int
+	Value       Hex          `json:",omitempty"`
+	Decoded     string       `json:",omitempty"` // value decoded by codec or presentation format
+	Error       string       `json:",omitempty"` // error of the read
+	Descriptors []Descriptor `json:",omitempty"`
+
+	readable bool
+}
+
+// Descriptor is a dumped descriptor
+type Descriptor struct {
+	UUID    string
+	Name    string `json:",omitempty"`
+	Handle  int
+	Value   Hex    `json:",omitempty"`
+	Decoded string `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+// Explore connects to a discovered peripheral, discovers all services,
+// characteristics and descriptors, reads every readable characteristic and
+// all descriptors one after another, and disconnects. Errors of single reads
+// are recorded in the dump.
+func Explore(ctx context.Context, ble *goble.BLE, deviceUuid xpc.UUID, opts Options) (*GATTDump, error) {
+	conn, err := central.Dial(ctx, ble, deviceUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	p, err := conn.DiscoverAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dump := newDump(p)
+
+	// a read of a characteristic or descriptor value, by handle so
+	// instances of the same uuid are told apart
+	type read struct {
+		c *Characteristic
+		d *Descriptor
+	}
+	var reads []read
+	for i := range dump.Services {
+		s := &dump.Services[i]
+		for j := range s.Characteristics {
+			c := &s.Characteristics[j]
+			if c.readable {
+				reads = append(reads, read{c: c})
+			}
+			for k := range c.Descriptors {
+				reads = append(reads, read{c: c, d: &c.Descriptors[k]})
+			}
+		}
+	}
+
+	// ATT allows a single outstanding request on a link
+	for _, r := range reads {
+		if ctx.Err() != nil {
+			break
+		}
+		rctx, cancel := ctx, func() {}
+		if opts.ReadTimeout > 0 {
+			rctx, cancel = context.WithTimeout(ctx, opts.ReadTimeout)
+		}
+		if r.d == nil {
+			v, err := conn.ReadHandle(rctx, r.c.Handle)
+			r.c.Value, r.c.Error = v, errorString(err)
+		} else {
+			v, err := conn.ReadDescriptorHandle(rctx, r.d.Handle)
+			r.d.Value, r.d.Error = v, errorString(err)
+		}
+		cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return dump, err
+	}
+
+	for i := range dump.Services {
+		for j := range dump.Services[i].Characteristics {
+			decode(&dump.Services[i].Characteristics[j])
+		}
+	}
+	return dump, nil
+}
+
+// newDump builds the tree of a discovered peripheral
+func newDump(p goble.Peripheral) *GATTDump {
+	dump := &GATTDump{Device: p.Uuid.String(), Name: p.Advertisement.LocalName}
+	for _, gs := range p.GATTTree().Services {
+		s := Service{UUID: gs.UUID, StartHandle: gs.StartHandle, EndHandle: gs.EndHandle}
+		if n, ok := goble.LookupService(gs.UUID); ok {
+			s.Name = n.Name
+		}
+		for _, gc := range gs.Characteristics {
+			c := Characteristic{
+				UUID:        gc.UUID,
+				Properties:  gc.Properties.String(),
+				Handle:      gc.Handle,
+				ValueHandle: gc.ValueHandle,
+				readable:    gc.Properties.Readable(),
+			}
+			if n, ok := goble.LookupCharacteristic(gc.UUID); ok {
+				c.Name = n.Name
+			}
+			for _, gd := range gc.Descriptors {
+				d := Descriptor{UUID: gd.UUID, Handle: gd.Handle}
+				if n, ok := goble.LookupDescriptor(gd.UUID); ok {
+					d.Name = n.Name
+				}
+				c.Descriptors = append(c.Descriptors, d)
+			}
+			s.Characteristics = append(s.Characteristics, c)
+		}
+		dump.Services = append(dump.Services, s)
+	}
+	return dump
+}
+
+// decode decodes the values of a characteristic and its descriptors.
+// Values without codec are decoded by a presentation format descriptor.
+func decode(c *Characteristic) {
+	var format *goble.PresentationFormat
+	for i := range c.Descriptors {
+		d := &c.Descriptors[i]
+		if d.Error != "" {
+			continue
+		}
+		switch uuid := parseUUID(d.UUID); uuid {
+		case goble.UserDescriptionUUID:
+			d.Decoded = string(d.Value)
+		case goble.ClientConfigurationUUID, goble.ServerConfigurationUUID, goble.ExtendedPropertiesUUID:
+			if len(d.Value) == 2 {
+				v := binary.LittleEndian.Uint16(d.Value)
+				if uuid == goble.ClientConfigurationUUID {
+					d.Decoded = goble.ClientConfiguration(v).String()
+				} else {
+					d.Decoded = fmt.Sprintf("%#04x", v)
+				}
+			}
+		case goble.PresentationFormatUUID:
+			if f, err := goble.ParsePresentationFormat(d.Value); err == nil {
+				format = &f
+				d.Decoded = fmt.Sprintf("%v, exponent %d, %s", f.Format, f.Exponent, goble.UnitSymbol(f.Unit))
+			}
+		}
+	}
+	if c.Value == nil || c.Error != "" {
+		return
+	}
+	if v, err := goble.Decode(c.UUID, c.Value); err == nil {
+		c.Decoded = fmt.Sprint(v)
+	} else if format != nil {
+		if v, err := format.Decode(c.Value); err == nil {
+			c.Decoded = v.String()
+		}
+	}
+}
+
+// parseUUID expands a discovered uuid, 16-bit or full
+func parseUUID(s string) xpc.UUID {
+	if len(s) == 4 {
+		n, _ := strconv.ParseUint(s, 16, 16)
+		return goble.UUID16(uint16(n))
+	}
+	return xpc.MakeUUID(s)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
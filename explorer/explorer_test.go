@@ -0,0 +1,188 @@
+package explorer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/simulator"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	deviceUUID  = xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	customUUID  = xpc.MustUUID("f000aa0104514000b000000000000000")
+	controlUUID = xpc.MustUUID("f000aa0204514000b000000000000000")
+)
+
+// discovered returns a simulated BLE that knows p
+func discovered(t *testing.T, release string, p *simulator.Peripheral) (*simulator.Simulator, *goble.BLE) {
+	sim := simulator.New(release)
+	sim.Add(p)
+	ble := sim.BLE()
+	found := make(chan bool, 1)
+	cancel := ble.Listen(func(ev goble.Event) bool {
+		if ev.Name == "discover" {
+			select {
+			case found <- true:
+			default:
+			}
+		}
+		return false
+	})
+	defer cancel()
+	ble.Init()
+	ble.StartScanning(nil, false)
+	select {
+	case <-found:
+	case <-time.After(time.Second):
+		t.Fatal("not discovered")
+	}
+	ble.StopScanning()
+	return sim, ble
+}
+
+func TestExplore(t *testing.T) {
+	format := goble.PresentationFormat{Format: goble.FormatSint16, Exponent: -2, Unit: 0x272f, Namespace: 1}
+	p := &simulator.Peripheral{
+		UUID:          deviceUUID,
+		Advertisement: simulator.Advertisement{LocalName: "sensor"},
+		Services: []*simulator.Service{{
+			UUID: goble.UUID16(0x180a),
+			Characteristics: []*simulator.Characteristic{{
+				UUID:       goble.UUID16(0x2a29),
+				Properties: goble.Read,
+				Value:      []byte("goble"),
+			}},
+		}, {
+			UUID: goble.UUID16(0x180f),
+			Characteristics: []*simulator.Characteristic{{
+				UUID:       goble.UUID16(0x2a19),
+				Properties: goble.Read | goble.Notify,
+				Value:      []byte{100},
+				Descriptors: []*simulator.Descriptor{
+					{UUID: goble.UserDescriptionUUID, Value: []byte("battery | main")},
+					{UUID: goble.ClientConfigurationUUID},
+				},
+			}},
+		}, {
+			UUID: customUUID,
+			Characteristics: []*simulator.Characteristic{{
+				UUID:        customUUID,
+				Properties:  goble.Read,
+				Value:       []byte{0x29, 0x09},
+				Descriptors: []*simulator.Descriptor{{UUID: goble.PresentationFormatUUID, Value: format.Bytes()}},
+			}, {
+				UUID:       controlUUID,
+				Properties: goble.Write,
+			}},
+		}},
+	}
+
+	for _, release := range []string{simulator.HighSierra, simulator.Mojave} {
+		t.Run(release, func(t *testing.T) {
+			sim, ble := discovered(t, release, p)
+			defer sim.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			dump, err := Explore(ctx, ble, deviceUUID, Options{ReadTimeout: time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dump.Name != "sensor" || len(dump.Services) != 3 {
+				t.Fatalf("got %+v", dump)
+			}
+
+			info := dump.Services[0].Characteristics[0]
+			if info.Name == "" || string(info.Value) != "goble" || info.Decoded != "goble" {
+				t.Errorf("got %+v", info)
+			}
+			level := dump.Services[1].Characteristics[0]
+			if level.Decoded != "100%" || len(level.Descriptors) != 2 {
+				t.Fatalf("got %+v", level)
+			}
+			if d := level.Descriptors[0]; d.Decoded != "battery | main" {
+				t.Errorf("got %+v", d)
+			}
+			if d := level.Descriptors[1]; d.Decoded != "none" {
+				t.Errorf("got %+v", d)
+			}
+			custom := dump.Services[2].Characteristics
+			if custom[0].Decoded != "23.45 °C" {
+				t.Errorf("got %+v", custom[0])
+			}
+			if custom[1].Value != nil || custom[1].Error != "" {
+				t.Errorf("write only characteristic read: %+v", custom[1])
+			}
+
+			var buf bytes.Buffer
+			if err := dump.WriteJSON(&buf); err != nil {
+				t.Fatal(err)
+			}
+			var back struct {
+				Services []struct{ Characteristics []struct{ Value string } }
+			}
+			if err := json.Unmarshal(buf.Bytes(), &back); err != nil {
+				t.Fatal(err)
+			}
+			if v := back.Services[2].Characteristics[0].Value; v != "2909" {
+				t.Errorf("got value %q", v)
+			}
+
+			buf.Reset()
+			dump.WriteText(&buf)
+			for _, s := range []string{"peripheral " + deviceUUID.String() + " (sensor)", "676f626c65 | goble", "properties  read notify"} {
+				if !strings.Contains(buf.String(), s) {
+					t.Errorf("text has no %q:\n%s", s, buf.String())
+				}
+			}
+
+			buf.Reset()
+			dump.WriteMarkdown(&buf)
+			for _, s := range []string{"# sensor", "| `64` | 100% |", `battery \| main`} {
+				if !strings.Contains(buf.String(), s) {
+					t.Errorf("markdown has no %q:\n%s", s, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestExploreInstances(t *testing.T) {
+	level := func(v byte) *simulator.Characteristic {
+		return &simulator.Characteristic{UUID: goble.UUID16(0x2a19), Properties: goble.Read, Value: []byte{v}}
+	}
+	locked := &simulator.Characteristic{
+		UUID:       customUUID,
+		Properties: goble.Read,
+		OnRead:     func() ([]byte, error) { return nil, goble.ErrInsufficientAuthentication },
+	}
+	p := &simulator.Peripheral{
+		UUID: deviceUUID,
+		Services: []*simulator.Service{{
+			UUID:            goble.UUID16(0x180f),
+			Characteristics: []*simulator.Characteristic{level(100), level(50), locked},
+		}},
+	}
+	sim, ble := discovered(t, simulator.Mojave, p)
+	defer sim.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dump, err := Explore(ctx, ble, deviceUUID, Options{ReadTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dump.Services[0].Characteristics
+	if len(c) != 3 || !bytes.Equal(c[0].Value, []byte{100}) || !bytes.Equal(c[1].Value, []byte{50}) {
+		t.Fatalf("got %+v", c)
+	}
+	if c[2].Value != nil || c[2].Error != goble.ErrInsufficientAuthentication.Error() {
+		t.Errorf("got %+v", c[2])
+	}
+}
@@ -0,0 +1,95 @@
+package explorer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// named renders a uuid with its assigned name
+func named(uuid, name string) string {
+	if name == "" {
+		return uuid
+	}
+	return uuid + " (" + name + ")"
+}
+
+// value renders a read value, decoded or quoted, or the read error
+func value(v Hex, decoded, err string) string {
+	switch {
+	case err != "":
+		return "error: " + err
+	case decoded != "":
+		return fmt.Sprintf("%v | %v", v, decoded)
+	}
+	return fmt.Sprintf("%v | %q", v, []byte(v))
+}
+
+// WriteText renders the dump like the noble peripheral explorer
+func (d *GATTDump) WriteText(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "peripheral", named(d.Device, d.Name))
+	for _, s := range d.Services {
+		fmt.Fprintf(b, "%s, handles %d-%d\n", named(s.UUID, s.Name), s.StartHandle, s.EndHandle)
+		for _, c := range s.Characteristics {
+			fmt.Fprintln(b, "  "+named(c.UUID, c.Name))
+			fmt.Fprintln(b, "    properties  "+c.Properties)
+			if c.Value != nil || c.Error != "" {
+				fmt.Fprintln(b, "    value       "+value(c.Value, c.Decoded, c.Error))
+			}
+			for _, desc := range c.Descriptors {
+				fmt.Fprintln(b, "    descriptor  "+named(desc.UUID, desc.Name))
+				if desc.Value != nil || desc.Error != "" {
+					fmt.Fprintln(b, "      value     "+value(desc.Value, desc.Decoded, desc.Error))
+				}
+			}
+		}
+	}
+	return b.Flush()
+}
+
+// WriteJSON renders the dump as indented JSON
+func (d *GATTDump) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(d)
+}
+
+// cell escapes a markdown table cell
+func cell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// WriteMarkdown renders the dump as a table per service
+func (d *GATTDump) WriteMarkdown(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	title := d.Name
+	if title == "" {
+		title = d.Device
+	}
+	fmt.Fprintf(b, "# %s\n\nDevice `%s`\n", cell(title), d.Device)
+	row := func(handle int, uuid, name, properties string, v Hex, decoded, err string) {
+		val := ""
+		if v != nil {
+			val = "`" + v.String() + "`"
+		}
+		if err != "" {
+			decoded = "error: " + err
+		}
+		fmt.Fprintf(b, "| %d | %s | %s | %s | %s | %s |\n", handle, uuid, cell(name), properties, val, cell(decoded))
+	}
+	for _, s := range d.Services {
+		fmt.Fprintf(b, "\n## %s\n\nHandles %d-%d\n\n", cell(named(s.UUID, s.Name)), s.StartHandle, s.EndHandle)
+		fmt.Fprintln(b, "| Handle | UUID | Name | Properties | Value | Decoded |")
+		fmt.Fprintln(b, "|---|---|---|---|---|---|")
+		for _, c := range s.Characteristics {
+			row(c.ValueHandle, c.UUID, c.Name, c.Properties, c.Value, c.Decoded, c.Error)
+			for _, desc := range c.Descriptors {
+				row(desc.Handle, desc.UUID, desc.Name, "descriptor", desc.Value, desc.Decoded, desc.Error)
+			}
+		}
+	}
+	return b.Flush()
+}
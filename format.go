@@ -0,0 +1,292 @@
+package goble
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Format is a GATT format type of the presentation format descriptor (0x2904)
+type Format uint8
+
+const (
+	FormatBoolean Format = 0x01
+	Format2Bit    Format = 0x02
+	FormatNibble  Format = 0x03
+	FormatUint8   Format = 0x04
+	FormatUint12  Format = 0x05
+	FormatUint16  Format = 0x06
+	FormatUint24  Format = 0x07
+	FormatUint32  Format = 0x08
+	FormatUint48  Format = 0x09
+	FormatUint64  Format = 0x0a
+	FormatUint128 Format = 0x0b
+	FormatSint8   Format = 0x0c
+	FormatSint12  Format = 0x0d
+	FormatSint16  Format = 0x0e
+	FormatSint24  Format = 0x0f
+	FormatSint32  Format = 0x10
+	FormatSint48  Format = 0x11
+	FormatSint64  Format = 0x12
+	FormatSint128 Format = 0x13
+	FormatFloat32 Format = 0x14
+	FormatFloat64 Format = 0x15
+	FormatSFloat  Format = 0x16 // IEEE-11073 16-bit SFLOAT
+	FormatFloat   Format = 0x17 // IEEE-11073 32-bit FLOAT
+	FormatDuint16 Format = 0x18
+	FormatUTF8s   Format = 0x19
+	FormatUTF16s  Format = 0x1a
+	FormatStruct  Format = 0x1b
+)
+
+var formatNames = map[Format]string{
+	FormatBoolean: "boolean",
+	Format2Bit:    "2bit",
+	FormatNibble:  "nibble",
+	FormatUint8:   "uint8",
+	FormatUint12:  "uint12",
+	FormatUint16:  "uint16",
+	FormatUint24:  "uint24",
+	FormatUint32:  "uint32",
+	FormatUint48:  "uint48",
+	FormatUint64:  "uint64",
+	FormatUint128: "uint128",
+	FormatSint8:   "sint8",
+	FormatSint12:  "sint12",
+	FormatSint16:  "sint16",
+	FormatSint24:  "sint24",
+	FormatSint32:  "sint32",
+	FormatSint48:  "sint48",
+	FormatSint64:  "sint64",
+	FormatSint128: "sint128",
+	FormatFloat32: "float32",
+	FormatFloat64: "float64",
+	FormatSFloat:  "SFLOAT",
+	FormatFloat:   "FLOAT",
+	FormatDuint16: "duint16",
+	FormatUTF8s:   "utf8s",
+	FormatUTF16s:  "utf16s",
+	FormatStruct:  "struct",
+}
+
+func (f Format) String() string {
+	if s, ok := formatNames[f]; ok {
+		return s
+	}
+	return fmt.Sprintf("Format(%#02x)", uint8(f))
+}
+
+// size returns the encoded size of fixed size formats, 0 otherwise
+func (f Format) size() int {
+	switch f {
+	case FormatBoolean, Format2Bit, FormatNibble, FormatUint8, FormatSint8:
+		return 1
+	case FormatUint12, FormatUint16, FormatSint12, FormatSint16, FormatSFloat:
+		return 2
+	case FormatUint24, FormatSint24:
+		return 3
+	case FormatUint32, FormatSint32, FormatFloat32, FormatFloat, FormatDuint16:
+		return 4
+	case FormatUint48, FormatSint48:
+		return 6
+	case FormatUint64, FormatSint64, FormatFloat64:
+		return 8
+	case FormatUint128, FormatSint128:
+		return 16
+	}
+	return 0
+}
+
+// common unit symbols, other units are rendered by name
+var unitSymbols = map[uint16]string{
+	0x2700: "",
+	0x2701: "m",
+	0x2702: "kg",
+	0x2703: "s",
+	0x2704: "A",
+	0x2705: "K",
+	0x2712: "m/s",
+	0x2722: "Hz",
+	0x2724: "Pa",
+	0x2725: "J",
+	0x2726: "W",
+	0x2728: "V",
+	0x272f: "°C",
+	0x2731: "lx",
+	0x2781: "mmHg",
+	0x27a7: "mph",
+	0x27a8: "rpm",
+	0x27ac: "°F",
+	0x27ad: "%",
+	0x27af: "bpm",
+}
+
+// UnitSymbol returns a short symbol for a unit assigned number (e.g. "°C"
+// for 0x272f), the unit name for units without symbol
+func UnitSymbol(unit uint16) string {
+	if s, ok := unitSymbols[unit]; ok {
+		return s
+	}
+	if n, ok := LookupUnit(unit); ok {
+		if i := strings.Index(n.Name, "("); i >= 0 {
+			return strings.TrimSuffix(n.Name[i+1:], ")")
+		}
+		return n.Name
+	}
+	return fmt.Sprintf("unit(%#04x)", unit)
+}
+
+// ParsePresentationFormat decodes a 7 byte presentation format descriptor value
+func ParsePresentationFormat(b []byte) (PresentationFormat, error) {
+	if len(b) != 7 {
+		return PresentationFormat{}, ErrValueLength
+	}
+	return PresentationFormat{
+		Format:      Format(b[0]),
+		Exponent:    int8(b[1]),
+		Unit:        binary.LittleEndian.Uint16(b[2:]),
+		Namespace:   b[4],
+		Description: binary.LittleEndian.Uint16(b[5:]),
+	}, nil
+}
+
+// FormattedValue is a characteristic value decoded by its presentation format
+type FormattedValue struct {
+	PresentationFormat
+	// bool, uint64, int64, *big.Int (128-bit), float64, SFloat, Float,
+	// [2]uint16 (duint16), string or []byte (struct and unknown formats)
+	Value interface{}
+}
+
+// Decode decodes a characteristic value according to the presentation format
+func (f PresentationFormat) Decode(data []byte) (FormattedValue, error) {
+	v := FormattedValue{PresentationFormat: f}
+	if n := f.Format.size(); n > 0 && len(data) != n {
+		return v, ErrValueLength
+	}
+	switch f.Format {
+	case FormatBoolean:
+		v.Value = data[0]&0x01 != 0
+	case Format2Bit:
+		v.Value = uint64(data[0] & 0x03)
+	case FormatNibble:
+		v.Value = uint64(data[0] & 0x0f)
+	case FormatUint12:
+		v.Value = uint64(binary.LittleEndian.Uint16(data) & 0x0fff)
+	case FormatUint8, FormatUint16, FormatUint24, FormatUint32, FormatUint48, FormatUint64:
+		v.Value = littleEndian(data)
+	case FormatSint12:
+		v.Value = int64(int16(binary.LittleEndian.Uint16(data)<<4) >> 4)
+	case FormatSint8, FormatSint16, FormatSint24, FormatSint32, FormatSint48, FormatSint64:
+		shift := uint(64 - 8*len(data))
+		v.Value = int64(littleEndian(data)<<shift) >> shift
+	case FormatUint128, FormatSint128:
+		be := make([]byte, 16)
+		for i, c := range data {
+			be[15-i] = c
+		}
+		n := new(big.Int).SetBytes(be)
+		if f.Format == FormatSint128 && be[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 128))
+		}
+		v.Value = n
+	case FormatFloat32:
+		v.Value = float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	case FormatFloat64:
+		v.Value = math.Float64frombits(binary.LittleEndian.Uint64(data))
+	case FormatSFloat:
+		v.Value = SFloat(binary.LittleEndian.Uint16(data))
+	case FormatFloat:
+		v.Value = Float(binary.LittleEndian.Uint32(data))
+	case FormatDuint16:
+		v.Value = [2]uint16{binary.LittleEndian.Uint16(data), binary.LittleEndian.Uint16(data[2:])}
+	case FormatUTF8s:
+		v.Value = string(data)
+	case FormatUTF16s:
+		if len(data)%2 != 0 {
+			return v, ErrValueLength
+		}
+		u := make([]uint16, len(data)/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+		v.Value = string(utf16.Decode(u))
+	default:
+		v.Value = append([]byte(nil), data...)
+	}
+	return v, nil
+}
+
+// littleEndian decodes an unsigned integer of up to 8 bytes
+func littleEndian(b []byte) uint64 {
+	var n uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		n = n<<8 | uint64(b[i])
+	}
+	return n
+}
+
+// Float64 returns the numeric value with the exponent applied to integer
+// formats
+func (v FormattedValue) Float64() (float64, bool) {
+	switch x := v.Value.(type) {
+	case uint64:
+		return scale(float64(x), v.Exponent), true
+	case int64:
+		return scale(float64(x), v.Exponent), true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(x).Float64()
+		return scale(f, v.Exponent), true
+	case float64:
+		return x, true
+	case SFloat:
+		return x.Float64(), true
+	case Float:
+		return x.Float64(), true
+	}
+	return 0, false
+}
+
+// String renders the value with exponent and unit, e.g. "23.45 °C"
+func (v FormattedValue) String() string {
+	var s string
+	switch x := v.Value.(type) {
+	case bool:
+		s = strconv.FormatBool(x)
+	case string:
+		return x
+	case []byte:
+		return hex.EncodeToString(x)
+	case [2]uint16:
+		s = fmt.Sprintf("%d %d", x[0], x[1])
+	case SFloat:
+		s = x.String()
+	case Float:
+		s = x.String()
+	case *big.Int:
+		if v.Exponent != 0 {
+			f, _ := v.Float64()
+			s = strconv.FormatFloat(f, 'g', -1, 64)
+		} else {
+			s = x.String()
+		}
+	case float64:
+		s = strconv.FormatFloat(x, 'g', -1, 64)
+	default:
+		f, _ := v.Float64()
+		prec := 0
+		if v.Exponent < 0 {
+			prec = -int(v.Exponent)
+		}
+		s = strconv.FormatFloat(f, 'f', prec, 64)
+	}
+	if unit := UnitSymbol(v.Unit); unit != "" && v.Unit != 0 {
+		s += " " + unit
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package goble
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePresentationFormat(t *testing.T) {
+	f := PresentationFormat{Format: FormatSint16, Exponent: -2, Unit: 0x272f, Namespace: 1}
+	got, err := ParsePresentationFormat(f.Bytes())
+	if err != nil || got != f {
+		t.Errorf("got %v %v, want %v", got, err, f)
+	}
+	if _, err := ParsePresentationFormat([]byte{1, 2}); err != ErrValueLength {
+		t.Errorf("got %v, want %v", err, ErrValueLength)
+	}
+}
+
+func TestFormattedValue(t *testing.T) {
+	testCases := []struct {
+		format PresentationFormat
+		data   []byte
+		value  interface{}
+		want   string
+	}{
+		{PresentationFormat{Format: FormatBoolean}, []byte{1}, true, "true"},
+		{PresentationFormat{Format: FormatNibble}, []byte{0xf7}, uint64(7), "7"},
+		{PresentationFormat{Format: FormatUint8, Unit: 0x27ad}, []byte{87}, uint64(87), "87 %"},
+		{PresentationFormat{Format: FormatUint12}, []byte{0xff, 0xff}, uint64(0xfff), "4095"},
+		{PresentationFormat{Format: FormatSint12}, []byte{0xff, 0x0f}, int64(-1), "-1"},
+		{PresentationFormat{Format: FormatSint16, Exponent: -2, Unit: 0x272f}, []byte{0x29, 0x09}, int64(2345), "23.45 °C"},
+		{PresentationFormat{Format: FormatSint24}, []byte{0xfe, 0xff, 0xff}, int64(-2), "-2"},
+		{PresentationFormat{Format: FormatUint48, Exponent: 3, Unit: 0x2701}, []byte{1, 0, 0, 0, 0, 0}, uint64(1), "1000 m"},
+		{PresentationFormat{Format: FormatSFloat, Unit: 0x27ac}, []byte{0x71, 0xf1}, SFloat(0xf171), "36.9 °F"},
+		{PresentationFormat{Format: FormatFloat}, []byte{0x00, 0x00, 0x80, 0x00}, FloatNRes, "NRes"},
+		{PresentationFormat{Format: FormatFloat32, Unit: 0x2728}, []byte{0x00, 0x00, 0x40, 0x40}, float64(3), "3 V"},
+		{PresentationFormat{Format: FormatDuint16}, []byte{1, 0, 2, 0}, [2]uint16{1, 2}, "1 2"},
+		{PresentationFormat{Format: FormatUTF8s}, []byte("goble"), "goble", "goble"},
+		{PresentationFormat{Format: FormatUTF16s}, []byte{'h', 0, 'i', 0}, "hi", "hi"},
+		{PresentationFormat{Format: FormatStruct}, []byte{0xca, 0xfe}, []byte{0xca, 0xfe}, "cafe"},
+		{PresentationFormat{Format: FormatUint16, Unit: 0x2727}, []byte{5, 0}, uint64(5), "5 coulomb"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.format.Format.String(), func(t *testing.T) {
+			v, err := tc.format.Decode(tc.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v.Value, tc.value) {
+				t.Errorf("got %#v, want %#v", v.Value, tc.value)
+			}
+			if got := v.String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormattedValue128(t *testing.T) {
+	data := make([]byte, 16)
+	for i := range data {
+		data[i] = 0xff
+	}
+	v, err := PresentationFormat{Format: FormatSint128}.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := v.Float64(); !ok || f != -1 {
+		t.Errorf("got %v %v, want -1", f, ok)
+	}
+	v, _ = PresentationFormat{Format: FormatUint128}.Decode(data)
+	if got, want := v.String(), "340282366920938463463374607431768211455"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFormattedValueLength(t *testing.T) {
+	for _, format := range []Format{FormatUint16, FormatSint24, FormatFloat, FormatUTF16s} {
+		if _, err := (PresentationFormat{Format: format}).Decode([]byte{1, 2, 3, 4, 5}); err != ErrValueLength {
+			t.Errorf("%v: got %v, want %v", format, err, ErrValueLength)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package goble
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dim13/goble/xpc"
+)
+
+// Bluetooth Base UUID (0000xxxx-0000-1000-8000-00805f9b34fb)
+var baseUUID = xpc.MustUUID("0000000000001000800000805f9b34fb")
+
+// UUID16 expands a 16-bit assigned number to a full 128-bit UUID
+func UUID16(n uint16) xpc.UUID {
+	uuid := baseUUID
+	binary.BigEndian.PutUint16(uuid[2:], n)
+	return uuid
+}
+
+// Short returns the 16-bit assigned number of a UUID derived from the Bluetooth Base UUID
+func Short(uuid xpc.UUID) (uint16, bool) {
+	n := binary.BigEndian.Uint16(uuid[2:])
+	return n, uuid == UUID16(n)
+}
+
+// standard descriptors
+var (
+	ExtendedPropertiesUUID  = UUID16(0x2900)
+	UserDescriptionUUID     = UUID16(0x2901)
+	ClientConfigurationUUID = UUID16(0x2902)
+	ServerConfigurationUUID = UUID16(0x2903)
+	PresentationFormatUUID  = UUID16(0x2904)
+	AggregateFormatUUID     = UUID16(0x2905)
+)
+
+var (
+	ErrManagedDescriptor      = errors.New("descriptor is managed by blued")
+	errDescriptorValueTooLong = errors.New("descriptor value too long")
+)
+
+// descriptors created and maintained by blued itself
+var managedDescriptors = map[xpc.UUID]bool{
+	ExtendedPropertiesUUID:  true,
+	ClientConfigurationUUID: true,
+	ServerConfigurationUUID: true,
+}
+
+// Client Characteristic Configuration (0x2902) bits
+type ClientConfiguration uint16
+
+const (
+	Notifications ClientConfiguration = 1 << iota
+	Indications
+)
+
+func (c ClientConfiguration) String() string {
+	switch c {
+	case 0:
+		return "none"
+	case Notifications:
+		return "notifications"
+	case Indications:
+		return "indications"
+	case Notifications | Indications:
+		return "notifications indications"
+	}
+	return fmt.Sprintf("ClientConfiguration(%#04x)", uint16(c))
+}
+
+// Characteristic Presentation Format (0x2904)
+type PresentationFormat struct {
+	Format      Format // format type (e.g. FormatSint16)
+	Exponent    int8   // base 10 exponent applied to the value
+	Unit        uint16 // unit assigned number (e.g. 0x272f for degree celsius)
+	Namespace   uint8  // 0x01 for Bluetooth SIG
+	Description uint16 // namespace description
+}
+
+// Bytes returns the 7 byte descriptor value
+func (f PresentationFormat) Bytes() []byte {
+	b := make([]byte, 7)
+	b[0] = byte(f.Format)
+	b[1] = byte(f.Exponent)
+	binary.LittleEndian.PutUint16(b[2:], f.Unit)
+	b[4] = f.Namespace
+	binary.LittleEndian.PutUint16(b[5:], f.Description)
+	return b
+}
+
+// NewDescriptor creates a GATT descriptor with a static value
+func NewDescriptor(uuid xpc.UUID, value []byte) Descriptor {
+	return Descriptor{uuid: uuid, value: value}
+}
+
+// NewCharacteristic creates a GATT characteristic
+//
+// blued adds the Client Characteristic Configuration descriptor (0x2902) to
+// characteristics with Notify or Indicate properties, subscriptions are
+// reported with "subscribe" and "unsubscribe" events.
+func NewCharacteristic(uuid xpc.UUID, properties Property, value []byte) Characteristic {
+	return Characteristic{uuid: uuid, properties: properties, value: value}
+}
+
+// UUID returns the characteristic uuid
+func (c Characteristic) UUID() xpc.UUID {
+	return c.uuid
+}
+
+// Properties returns the characteristic properties
+func (c Characteristic) Properties() Property {
+	return c.properties
+}
+
+// SetSecure requires encryption for the given properties
+func (c *Characteristic) SetSecure(secure Property) {
+	c.secure = secure
+}
+
+// Descriptors returns a copy of the characteristic descriptors
+func (c Characteristic) Descriptors() []Descriptor {
+	return append([]Descriptor(nil), c.descriptors...)
+}
+
+// AddDescriptor attaches a descriptor, replacing one with the same uuid.
+// Descriptors managed by blued (0x2900, 0x2902, 0x2903) are rejected.
+func (c *Characteristic) AddDescriptor(uuid xpc.UUID, value []byte) error {
+	if managedDescriptors[uuid] {
+		return ErrManagedDescriptor
+	}
+	if len(value) > maxAttributeLength {
+		return errDescriptorValueTooLong
+	}
+	for i, d := range c.descriptors {
+		if d.uuid == uuid {
+			c.descriptors[i].value = value
+			return nil
+		}
+	}
+	c.descriptors = append(c.descriptors, NewDescriptor(uuid, value))
+	return nil
+}
+
+// SetUserDescription attaches a Characteristic User Description (0x2901)
+func (c *Characteristic) SetUserDescription(s string) error {
+	return c.AddDescriptor(UserDescriptionUUID, []byte(s))
+}
+
+// SetPresentationFormat attaches a Characteristic Presentation Format (0x2904)
+func (c *Characteristic) SetPresentationFormat(f PresentationFormat) error {
+	return c.AddDescriptor(PresentationFormatUUID, f.Bytes())
+}
+
+// ClientConfiguration returns the configuration a subscribing client gets
+// from blued, which prefers notifications over indications. It stands in
+// for the value written by the client where the backend doesn't report it.
+func (c Characteristic) ClientConfiguration() ClientConfiguration {
+	switch {
+	case c.properties&Notify != 0:
+		return Notifications
+	case c.properties&Indicate != 0:
+		return Indications
+	}
+	return 0
+}
+
+// ReadHandler returns the value of a characteristic read by a central,
+// starting at offset. Return an ATTError to reject the read.
+type ReadHandler func(central xpc.UUID, offset int) ([]byte, error)
+
+// WriteHandler receives a value written by a central. Return an ATTError to
+// reject the write; errors of writes without response are not reported.
+type WriteHandler func(central xpc.UUID, data []byte, offset int, withoutResponse bool) error
+
+// HandleRead sets the handler for reads, replacing the static value
+func (c *Characteristic) HandleRead(h ReadHandler) {
+	c.readHandler = h
+	c.value = nil
+	c.release = nil
+}
+
+// ValueHandler returns the whole value of a characteristic read by a central
+type ValueHandler func(central xpc.UUID) ([]byte, error)
+
+// HandleReadValue sets a handler for reads of whole values. Long reads
+// continuing at an offset are served from the value read at offset zero,
+// so a central gets a consistent value even if it changes in between. The
+// value is kept until the read ends or the central unsubscribes.
+func (c *Characteristic) HandleReadValue(h ValueHandler) {
+	var mu sync.Mutex
+	values := map[xpc.UUID][]byte{}
+	c.HandleRead(func(central xpc.UUID, offset int) ([]byte, error) {
+		mu.Lock()
+		value, ok := values[central]
+		mu.Unlock()
+		if offset == 0 || !ok {
+			var err error
+			if value, err = h(central); err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			values[central] = value
+			mu.Unlock()
+		}
+		if offset > len(value) {
+			return nil, ErrInvalidOffset
+		}
+		return value[offset:], nil
+	})
+	c.release = func(central xpc.UUID) {
+		mu.Lock()
+		delete(values, central)
+		mu.Unlock()
+	}
+}
+
+// EndRead tells that the long read of a value by central ended, a value
+// read with HandleReadValue is forgotten
+func (c Characteristic) EndRead(central xpc.UUID) {
+	if c.release != nil {
+		c.release(central)
+	}
+}
+
+// HandleWrite sets the handler for writes
+func (c *Characteristic) HandleWrite(h WriteHandler) {
+	c.writeHandler = h
+}
+
+// ReadValue serves a read by central from offset, by the read handler or
+// from the static value
+func (c Characteristic) ReadValue(central xpc.UUID, offset int) ([]byte, error) {
+	return c.read(central, offset)
+}
+
+// WriteValue passes a write by central to the write handler
+func (c Characteristic) WriteValue(central xpc.UUID, data []byte, offset int, withoutResponse bool) error {
+	return c.write(central, data, offset, withoutResponse)
+}
+
+func (c Characteristic) read(central xpc.UUID, offset int) ([]byte, error) {
+	if c.readHandler != nil {
+		return c.readHandler(central, offset)
+	}
+	if c.properties&Read == 0 {
+		return nil, ErrReadNotPermitted
+	}
+	if offset > len(c.value) {
+		return nil, ErrInvalidOffset
+	}
+	return c.value[offset:], nil
+}
+
+func (c Characteristic) write(central xpc.UUID, data []byte, offset int, withoutResponse bool) error {
+	if c.writeHandler == nil || c.properties&(Write|WriteWithoutResponse) == 0 {
+		return ErrWriteNotPermitted
+	}
+	return c.writeHandler(central, data, offset, withoutResponse)
+}
+
+// attributeWrite is a write of a central to a local characteristic
+type attributeWrite struct {
+	attributeId     int
+	data            []byte
+	offset          int
+	withoutResponse bool
+}
+
+// assembleWrites joins the parts of prepared writes to one write per
+// attribute, in the order of their first part. Parts have to follow each
+// other without gaps and fit the longest attribute value.
+func assembleWrites(writes xpc.Array) ([]attributeWrite, error) {
+	var joined []attributeWrite
+	index := map[int]int{}
+	for _, v := range writes {
+		w := v.(xpc.Dict)
+		id := w.MustGetInt("kCBMsgArgAttributeID")
+		data := w.MustGetBytes("kCBMsgArgData")
+		offset := w.GetInt("kCBMsgArgOffset", 0)
+		i, ok := index[id]
+		if !ok {
+			index[id] = len(joined)
+			joined = append(joined, attributeWrite{
+				attributeId:     id,
+				offset:          offset,
+				withoutResponse: w.GetInt("kCBMsgArgIgnoreResponse", 0) != 0,
+			})
+			i = len(joined) - 1
+		}
+		j := &joined[i]
+		if j.offset+len(j.data) != offset {
+			return nil, ErrInvalidOffset
+		}
+		if offset+len(data) > maxAttributeLength {
+			return nil, ErrInvalidAttributeValueLength
+		}
+		j.data = append(j.data, data...)
+	}
+	return joined, nil
+}
+
+// UUID returns the descriptor uuid
+func (d Descriptor) UUID() xpc.UUID {
+	return d.uuid
+}
+
+// Value returns the descriptor value
+func (d Descriptor) Value() []byte {
+	return d.value
+}
+
+// NewService creates a primary GATT service
+func NewService(uuid xpc.UUID, characteristics ...Characteristic) Service {
+	return Service{uuid: uuid, characteristics: characteristics}
+}
+
+// NewSecondaryService creates a secondary GATT service, which is only
+// discovered through services including it
+func NewSecondaryService(uuid xpc.UUID, characteristics ...Characteristic) Service {
+	return Service{uuid: uuid, secondary: true, characteristics: characteristics}
+}
+
+// UUID returns the service uuid
+func (s Service) UUID() xpc.UUID {
+	return s.uuid
+}
+
+// Primary reports whether the service is a primary service
+func (s Service) Primary() bool {
+	return !s.secondary
+}
+
+// Include references another service set along with this one
+func (s *Service) Include(uuid xpc.UUID) {
+	s.includes = append(s.includes, uuid)
+}
+
+// Includes returns the uuids of included services
+func (s Service) Includes() []xpc.UUID {
+	return append([]xpc.UUID(nil), s.includes...)
+}
+
+// Characteristics returns a copy of the service characteristics
+func (s Service) Characteristics() []Characteristic {
+	return append([]Characteristic(nil), s.characteristics...)
+}
+
+// Service returns a discovered service by uuid
+func (p Peripheral) Service(uuid string) (*ServiceHandle, bool) {
+	for k, s := range p.Services {
+		if k, ok := k.(string); ok && EqualUUID(k, uuid) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Characteristic returns a discovered characteristic by uuid
+func (s ServiceHandle) Characteristic(uuid string) (*ServiceCharacteristic, bool) {
+	for k, c := range s.Characteristics {
+		if k, ok := k.(string); ok && EqualUUID(k, uuid) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Descriptor returns a discovered descriptor by uuid
+func (c ServiceCharacteristic) Descriptor(uuid string) (*CharacteristicDescriptor, bool) {
+	for k, d := range c.Descriptors {
+		if k, ok := k.(string); ok && EqualUUID(k, uuid) {
+			return d, true
+		}
+	}
+	return nil, false
+}
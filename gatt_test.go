@@ -0,0 +1,52 @@
+package goble
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUUID16(t *testing.T) {
+	uuid := UUID16(0x2902)
+	if got, want := uuid.String(), "0000290200001000800000805f9b34fb"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n, ok := Short(uuid); !ok || n != 0x2902 {
+		t.Errorf("got %#04x %v, want 0x2902 true", n, ok)
+	}
+	uuid[15] ^= 1
+	if _, ok := Short(uuid); ok {
+		t.Errorf("%v is not a short uuid", uuid)
+	}
+}
+
+func TestAddDescriptor(t *testing.T) {
+	c := NewCharacteristic(UUID16(0x2a19), Read|Notify, nil)
+	for _, uuid := range []uint16{0x2900, 0x2902, 0x2903} {
+		if err := c.AddDescriptor(UUID16(uuid), []byte{1, 0}); err != ErrManagedDescriptor {
+			t.Errorf("%#04x: got %v, want %v", uuid, err, ErrManagedDescriptor)
+		}
+	}
+	if err := c.SetUserDescription("battery"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetUserDescription("battery level"); err != nil {
+		t.Fatal(err)
+	}
+	format := PresentationFormat{Format: 0x04, Exponent: 0, Unit: 0x27ad, Namespace: 0x01}
+	if err := c.SetPresentationFormat(format); err != nil {
+		t.Fatal(err)
+	}
+	d := c.Descriptors()
+	if len(d) != 2 {
+		t.Fatalf("got %d descriptors, want 2", len(d))
+	}
+	if d[0].UUID() != UserDescriptionUUID || string(d[0].Value()) != "battery level" {
+		t.Errorf("got %v %q", d[0].UUID(), d[0].Value())
+	}
+	if want := []byte{0x04, 0x00, 0xad, 0x27, 0x01, 0x00, 0x00}; !bytes.Equal(d[1].Value(), want) {
+		t.Errorf("got %x, want %x", d[1].Value(), want)
+	}
+	if c.ClientConfiguration() != Notifications {
+		t.Errorf("got %v, want %v", c.ClientConfiguration(), Notifications)
+	}
+}
@@ -0,0 +1,256 @@
+//go:build ignore
+// +build ignore
+
+// gen generates the assigned numbers tables from the Bluetooth SIG YAML files
+// checked in under assigned_numbers (https://bitbucket.org/bluetooth-SIG/public),
+// with -fetch the files are first replaced by those of the SIG repository
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var fetch = flag.Bool("fetch", false, "download the assigned numbers files from the SIG repository")
+
+// raw files of the SIG repository
+const sigRepository = "https://bitbucket.org/bluetooth-SIG/public/raw/main/"
+
+// sigFiles are replaced with -fetch
+var sigFiles = []string{
+	"assigned_numbers/company_identifiers/company_identifiers.yaml",
+	"assigned_numbers/uuids/service_uuids.yaml",
+	"assigned_numbers/uuids/member_uuids.yaml",
+	"assigned_numbers/uuids/characteristic_uuids.yaml",
+	"assigned_numbers/uuids/descriptors.yaml",
+	"assigned_numbers/uuids/units.yaml",
+	"assigned_numbers/core/appearance_values.yaml",
+}
+
+// download replaces an assigned numbers file by the one of the SIG repository
+func download(name string) error {
+	resp, err := http.Get(sigRepository + name)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", name, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, b, 0644)
+}
+
+// entry is a single list item of an assigned numbers file
+type entry struct {
+	fields   map[string]string
+	children []entry // nested list, e.g. appearance subcategories
+}
+
+// readYAML reads the lists of mappings found in the assigned numbers files:
+//
+//	key:
+//	  - value: 0x004C
+//	    name: 'Apple, Inc.'
+//	    subcategory:
+//	      - value: 0x01
+//	        name: ...
+//
+// Only the subset of YAML used by these files is supported.
+func readYAML(name string) ([]entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []entry
+	top := -1 // indentation of top level list items
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		text := s.Text()
+		line := strings.TrimSpace(text)
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		if line == "" || strings.HasPrefix(line, "#") || indent == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "- ") {
+			line = strings.TrimPrefix(line, "- ")
+			if top < 0 {
+				top = indent
+			}
+			if indent == top {
+				entries = append(entries, entry{fields: map[string]string{}})
+			} else if len(entries) > 0 {
+				last := &entries[len(entries)-1]
+				last.children = append(last.children, entry{fields: map[string]string{}})
+			}
+		}
+		i := strings.Index(line, ":")
+		if i < 0 || len(entries) == 0 {
+			continue
+		}
+		e := &entries[len(entries)-1]
+		if indent > top+2 && len(e.children) > 0 {
+			e = &e.children[len(e.children)-1]
+		}
+		e.fields[line[:i]] = unquote(strings.TrimSpace(line[i+1:]))
+	}
+	return entries, s.Err()
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1)
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	return s
+}
+
+func number(s string) uint64 {
+	n, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		log.Fatalf("invalid number %q: %v", s, err)
+	}
+	return n
+}
+
+// sortBy sorts entries by a numeric key, which must be unique
+func sortBy(entries []entry, key string) {
+	sort.Slice(entries, func(i, j int) bool {
+		return number(entries[i].fields[key]) < number(entries[j].fields[key])
+	})
+	for i := 1; i < len(entries); i++ {
+		if entries[i].fields[key] == entries[i-1].fields[key] {
+			log.Fatalf("duplicate %s %s", key, entries[i].fields[key])
+		}
+	}
+}
+
+func companies(w *bytes.Buffer) error {
+	entries, err := readYAML("assigned_numbers/company_identifiers/company_identifiers.yaml")
+	if err != nil {
+		return err
+	}
+	sortBy(entries, "value")
+	fmt.Fprintln(w, "// Bluetooth SIG company identifiers (keyed by company id)")
+	fmt.Fprintln(w, "var knownCompanies = map[uint16]string{")
+	for _, e := range entries {
+		fmt.Fprintf(w, "\t%#04x: %q,\n", number(e.fields["value"]), e.fields["name"])
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
+
+// uuids generates a map of assigned numbers keyed by lowercase hex uuid
+func uuids(file, comment, name string) func(*bytes.Buffer) error {
+	return func(w *bytes.Buffer) error {
+		entries, err := readYAML(file)
+		if err != nil {
+			return err
+		}
+		sortBy(entries, "uuid")
+		fmt.Fprintln(w, "//", comment)
+		fmt.Fprintf(w, "var %s = map[string]AssignedNumber{\n", name)
+		for _, e := range entries {
+			if id := e.fields["id"]; id != "" {
+				fmt.Fprintf(w, "\t\"%04x\": {Name: %q, Type: %q},\n", number(e.fields["uuid"]), e.fields["name"], id)
+			} else {
+				fmt.Fprintf(w, "\t\"%04x\": {Name: %q},\n", number(e.fields["uuid"]), e.fields["name"])
+			}
+		}
+		fmt.Fprintln(w, "}")
+		return nil
+	}
+}
+
+func units(w *bytes.Buffer) error {
+	entries, err := readYAML("assigned_numbers/uuids/units.yaml")
+	if err != nil {
+		return err
+	}
+	sortBy(entries, "uuid")
+	fmt.Fprintln(w, "// A dictionary of known units (keyed by unit uuid)")
+	fmt.Fprintln(w, "var knownUnits = map[uint16]AssignedNumber{")
+	for _, e := range entries {
+		fmt.Fprintf(w, "\t%#04x: {Name: %q, Type: %q},\n", number(e.fields["uuid"]), e.fields["name"], e.fields["id"])
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
+
+func appearances(w *bytes.Buffer) error {
+	entries, err := readYAML("assigned_numbers/core/appearance_values.yaml")
+	if err != nil {
+		return err
+	}
+	sortBy(entries, "category")
+	fmt.Fprintln(w, "// A dictionary of known appearance values (category << 6 | subcategory)")
+	fmt.Fprintln(w, "var knownAppearances = map[uint16]string{")
+	for _, e := range entries {
+		category := number(e.fields["category"]) << 6
+		fmt.Fprintf(w, "\t%#04x: %q,\n", category, e.fields["name"])
+		for _, sub := range e.children {
+			fmt.Fprintf(w, "\t%#04x: %q,\n", category|number(sub.fields["value"]), sub.fields["name"])
+		}
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
+
+func generate(name string, gen func(*bytes.Buffer) error) {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by \"go run gen.go\"; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package goble")
+	fmt.Fprintln(&buf)
+	if err := gen(&buf); err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	flag.Parse()
+	if *fetch {
+		for _, name := range sigFiles {
+			if err := download(name); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	generate("companies.go", companies)
+	generate("services.go", uuids("assigned_numbers/uuids/service_uuids.yaml",
+		"A dictionary of known service names and type (keyed by service uuid)", "knownServices"))
+	generate("members.go", uuids("assigned_numbers/uuids/member_uuids.yaml",
+		"A dictionary of 16-bit uuids assigned to SIG members (keyed by service uuid)", "knownMembers"))
+	generate("characteristics.go", uuids("assigned_numbers/uuids/characteristic_uuids.yaml",
+		"A dictionary of known characteristic names and type (keyed by characteristic uuid)", "knownCharacteristics"))
+	generate("descriptors.go", uuids("assigned_numbers/uuids/descriptors.yaml",
+		"A dictionary of known descriptor names and type (keyed by descriptor uuid)", "knownDescriptors"))
+	generate("units.go", units)
+	generate("appearances.go", appearances)
+}
@@ -1,3 +1,5 @@
 module github.com/dim13/goble
 
 go 1.13
+
+require github.com/godbus/dbus/v5 v5.1.0
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/dim13/goble/adv"
 	"github.com/dim13/goble/uname"
 	"github.com/dim13/goble/xpc"
 )
@@ -81,6 +83,8 @@ type ServiceData struct {
 
 type CharacteristicDescriptor struct {
 	Uuid   string
+	Name   string
+	Type   string
 	Handle int
 }
 
@@ -104,11 +108,13 @@ type ServiceHandle struct {
 }
 
 type Advertisement struct {
-	LocalName        string
-	TxPowerLevel     int
-	ManufacturerData []byte
-	ServiceData      []ServiceData
-	ServiceUuids     []string
+	LocalName           string
+	TxPowerLevel        int
+	ManufacturerData    []byte // company id (little-endian) followed by payload
+	CompanyID           uint16
+	ManufacturerPayload []byte
+	ServiceData         []ServiceData
+	ServiceUuids        []string
 }
 
 type Peripheral struct {
@@ -119,6 +125,31 @@ type Peripheral struct {
 	Advertisement Advertisement
 	Rssi          int
 	Services      map[interface{}]*ServiceHandle
+	Mtu           int // ATT MTU of the connection, 23 until negotiated
+
+	// services restored from the cache on connect, until a read of the
+	// database hash confirms them
+	restored map[interface{}]*ServiceHandle
+}
+
+// ATT MTU before negotiation and the longest attribute value
+const (
+	defaultMTU         = 23
+	maxAttributeLength = 512
+)
+
+// MaximumWriteLength returns the longest value a write to the peripheral
+// carries. Writes without response fit into a single Write Command, writes
+// with response are split into prepared writes by blued.
+func (p Peripheral) MaximumWriteLength(withoutResponse bool) int {
+	if !withoutResponse {
+		return maxAttributeLength
+	}
+	mtu := p.Mtu
+	if mtu < defaultMTU {
+		mtu = defaultMTU
+	}
+	return mtu - 3
 }
 
 // GATT Descriptor
@@ -129,40 +160,71 @@ type Descriptor struct {
 
 // GATT Characteristic
 type Characteristic struct {
-	uuid        xpc.UUID
-	properties  Property
-	secure      Property
-	descriptors []Descriptor
-	value       []byte
+	uuid         xpc.UUID
+	properties   Property
+	secure       Property
+	descriptors  []Descriptor
+	value        []byte
+	readHandler  ReadHandler
+	writeHandler WriteHandler
+	release      func(central xpc.UUID) // forgets the value of a long read
 }
 
 // GATT Service
 type Service struct {
 	uuid            xpc.UUID
+	secondary       bool
+	includes        []xpc.UUID
 	characteristics []Characteristic
 }
 
+// Transport carries messages to blued, implemented by *xpc.XPC
+type Transport interface {
+	Send(msg interface{}, verbose bool)
+}
+
 type BLE struct {
 	Emitter
-	conn    xpc.XPC
+	conn    Transport
 	verbose bool
 
-	peripherals            map[string]*Peripheral
-	attributes             xpc.Array
-	lastServiceAttributeId int
-	allowDuplicates        bool
+	// guards the fields below, event handling and the requests of
+	// other goroutines share them
+	mu              sync.Mutex
+	peripherals     map[string]*Peripheral
+	db              *Database
+	attributeIds    []uint16 // handles of services and characteristic values by attribute id
+	allowDuplicates bool
+	subscribers     map[int][]xpc.UUID // centrals subscribed by attribute id
+	cache           *GATTCache
 
 	utsname uname.Utsname
 }
 
 func New() *BLE {
-	ble := &BLE{peripherals: map[string]*Peripheral{}, Emitter: Emitter{}}
-	ble.Emitter.Init()
-	ble.conn = xpc.XpcConnect("com.apple.blued", ble)
+	ble := newBLE()
+	conn := xpc.XpcConnect("com.apple.blued", ble)
+	ble.conn = &conn
 	uname.Uname(&ble.utsname)
 	return ble
 }
 
+// NewWithTransport creates a BLE sending messages over t, speaking the
+// protocol of the given darwin kernel release (e.g. "18.7.0"). Events are
+// fed back with HandleXpcEvent.
+func NewWithTransport(t Transport, release string) *BLE {
+	ble := newBLE()
+	ble.conn = t
+	ble.utsname.Release = release
+	return ble
+}
+
+func newBLE() *BLE {
+	ble := &BLE{peripherals: map[string]*Peripheral{}, subscribers: map[int][]xpc.UUID{}, Emitter: Emitter{}}
+	ble.Emitter.Init()
+	return ble
+}
+
 func (ble *BLE) SetVerbose(v bool) {
 	ble.verbose = v
 	ble.Emitter.SetVerbose(v)
@@ -174,6 +236,11 @@ const (
 	stateChangeEvt             = 6
 	advertisingStartEvt        = 16
 	advertisingStopEvt         = 17
+	readRequestEvt             = 19
+	writeRequestEvt            = 20
+	subscribeEvt               = 21
+	unsubscribeEvt             = 22
+	readyToUpdateEvt           = 23
 	discoverEvt                = 37
 	connectEvt                 = 38
 	disconnectEvt              = 40
@@ -182,7 +249,10 @@ const (
 	serviceDiscoverEvt         = 55
 	characteristicsDiscoverEvt = 63
 	descriptorDiscoverEvt      = 75
+	descriptorReadEvt          = 79
 	readEvt                    = 70
+	writeEvt                   = 71
+	notifyEvt                  = 73
 )
 
 // process BLE events and asynchronous errors
@@ -203,6 +273,14 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 		defer log.Printf("done event: %v", id)
 	}
 
+	switch id {
+	case readRequestEvt, writeRequestEvt:
+		// handlers of local characteristics run unlocked, they may use ble
+	default:
+		ble.mu.Lock()
+		defer ble.mu.Unlock()
+	}
+
 	switch id {
 	case 4, 6: // state change
 		state := args.MustGetInt("kCBMsgArgState")
@@ -231,6 +309,97 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 			})
 		}
 
+	case readRequestEvt:
+		attributeId := args.MustGetInt("kCBMsgArgAttributeID")
+		offset := args.GetInt("kCBMsgArgOffset", 0)
+		centralUuid := args.GetUUID("kCBMsgArgCentralUUID")
+
+		mtu := args.GetInt("kCBMsgArgATTMTU", defaultMTU)
+
+		var data []byte
+		err := error(ErrAttributeNotFound)
+		if c, ok := ble.lockedCharacteristic(attributeId); ok {
+			data, err = c.read(centralUuid, offset)
+			// a response shorter than the MTU allows ends a long read
+			if err != nil || len(data) < mtu-1 {
+				c.EndRead(centralUuid)
+			}
+		}
+		ble.sendCBMsg(respondToRequestMsg, xpc.Dict{
+			"kCBMsgArgAttributeID":   attributeId,
+			"kCBMsgArgData":          data,
+			"kCBMsgArgTransactionID": args.MustGetInt("kCBMsgArgTransactionID"),
+			"kCBMsgArgResult":        attResult(err),
+		})
+
+	case writeRequestEvt:
+		centralUuid := args.GetUUID("kCBMsgArgCentralUUID")
+		writes := args.MustGetArray("kCBMsgArgATTWrites")
+
+		// parts of prepared writes are joined to one value per attribute
+		values, err := assembleWrites(writes)
+		for _, w := range values {
+			c, ok := ble.lockedCharacteristic(w.attributeId)
+			if !ok {
+				err = ErrAttributeNotFound
+				break
+			}
+			if err = c.write(centralUuid, w.data, w.offset, w.withoutResponse); err != nil {
+				break
+			}
+		}
+		if len(writes) > 0 {
+			first := writes[0].(xpc.Dict)
+			if first.GetInt("kCBMsgArgIgnoreResponse", 0) == 0 {
+				ble.sendCBMsg(respondToRequestMsg, xpc.Dict{
+					"kCBMsgArgAttributeID":   first.MustGetInt("kCBMsgArgAttributeID"),
+					"kCBMsgArgTransactionID": args.MustGetInt("kCBMsgArgTransactionID"),
+					"kCBMsgArgResult":        attResult(err),
+				})
+			}
+		}
+
+	case subscribeEvt:
+		attributeId := args.MustGetInt("kCBMsgArgAttributeID")
+		centralUuid := args.MustGetUUID("kCBMsgArgCentralUUID")
+		mtu := args.GetInt("kCBMsgArgATTMTU", defaultMTU)
+
+		if c, ok := ble.characteristic(attributeId); ok {
+			ble.subscribe(attributeId, centralUuid)
+			// blued doesn't pass the configuration written by the central
+			ble.Emit(Event{
+				Name:               "subscribe",
+				DeviceUUID:         centralUuid,
+				CharacteristicUuid: c.uuid.String(),
+				Mtu:                mtu,
+				Configuration:      c.ClientConfiguration(),
+			})
+		}
+
+	case unsubscribeEvt:
+		attributeId := args.MustGetInt("kCBMsgArgAttributeID")
+		centralUuid := args.MustGetUUID("kCBMsgArgCentralUUID")
+
+		if c, ok := ble.characteristic(attributeId); ok {
+			centrals := ble.subscribers[attributeId][:0]
+			for _, uuid := range ble.subscribers[attributeId] {
+				if uuid != centralUuid {
+					centrals = append(centrals, uuid)
+				}
+			}
+			ble.subscribers[attributeId] = centrals
+			c.EndRead(centralUuid)
+			ble.Emit(Event{
+				Name:               "unsubscribe",
+				DeviceUUID:         centralUuid,
+				CharacteristicUuid: c.uuid.String(),
+			})
+		}
+
+	case readyToUpdateEvt:
+		// the transmit queue has room for further updates
+		ble.Emit(Event{Name: "readyToUpdate"})
+
 	case 37, 48, 51, 57: // discover
 		advdata := args.MustGetDict("kCBMsgArgAdvertisementData")
 		if len(advdata) == 0 {
@@ -248,6 +417,11 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 			ServiceUuids:     []string{},
 		}
 
+		if md := advertisement.ManufacturerData; len(md) >= 2 {
+			advertisement.CompanyID = binary.LittleEndian.Uint16(md)
+			advertisement.ManufacturerPayload = md[2:]
+		}
+
 		connectable := advdata.GetInt("kCBAdvDataIsConnectable", 0) > 0
 		rssi := args.GetInt("kCBMsgArgRssi", 0)
 
@@ -282,6 +456,7 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 				Advertisement: advertisement,
 				Rssi:          rssi,
 				Services:      map[interface{}]*ServiceHandle{},
+				Mtu:           defaultMTU,
 			}
 
 			ble.peripherals[pid] = p
@@ -292,10 +467,17 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 		}
 
 		if emit {
+			beacon, _ := advertisement.Beacon()
+			var telemetry *EddystoneTLM
+			if frame, ok := advertisement.Eddystone(); ok {
+				telemetry, _ = frame.(*EddystoneTLM)
+			}
 			ble.Emit(Event{
 				Name:       "discover",
 				DeviceUUID: deviceUuid,
 				Peripheral: *p,
+				Beacon:     beacon,
+				Telemetry:  telemetry,
 			})
 		}
 
@@ -307,37 +489,31 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 		}
 
 		deviceUuid := args.MustGetUUID("kCBMsgArgDeviceUUID")
+		if p, ok := ble.peripherals[deviceUuid.String()]; ok {
+			p.Mtu = defaultMTU
+			ble.restore(p)
+		}
 		ble.Emit(Event{
 			Name:       "connect",
 			DeviceUUID: deviceUuid,
 		})
+		// some releases report the MTU of the link along with the connect
+		if mtu := args.GetInt("kCBMsgArgATTMTU", defaultMTU); mtu != defaultMTU {
+			ble.updateMtu(deviceUuid, mtu)
+		}
 
 	case disconnectEvt:
-		deviceUuid := args.MustGetUUID("kCBMsgArgDeviceUUID")
-		ble.Emit(Event{
-			Name:       "disconnect",
-			DeviceUUID: deviceUuid,
-		})
+		ble.disconnected(args.MustGetUUID("kCBMsgArgDeviceUUID"), args.GetInt("kCBMsgArgResult", 0))
 
 	case 53: // mtuChange
-		if ble.utsname.Release >= "14." {
-			// this is actually a disconnect
-			deviceUuid := args.MustGetUUID("kCBMsgArgDeviceUUID")
-			ble.Emit(Event{Name: "disconnect", DeviceUUID: deviceUuid})
-			break
-		}
 		deviceUuid := args.MustGetUUID("kCBMsgArgDeviceUUID")
-		mtu := args.MustGetInt("kCBMsgArgATTMTU")
-
-		// bleno here converts the deviceUuid to an address
-		if p, ok := ble.peripherals[deviceUuid.String()]; ok {
-			ble.Emit(Event{
-				Name:       "mtuChange",
-				DeviceUUID: deviceUuid,
-				Peripheral: *p,
-				Mtu:        mtu,
-			})
+		if !args.Contains("kCBMsgArgATTMTU") {
+			// since darwin 14 the id is shared with a disconnect, which
+			// carries no MTU
+			ble.disconnected(deviceUuid, args.GetInt("kCBMsgArgResult", 0))
+			break
 		}
+		ble.updateMtu(deviceUuid, args.MustGetInt("kCBMsgArgATTMTU"))
 
 	case 54, 82: // serviceDiscover
 		deviceUuid := args.MustGetUUID("kCBMsgArgDeviceUUID")
@@ -354,7 +530,7 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 					Characteristics: map[interface{}]*ServiceCharacteristic{},
 				}
 
-				if nameType, ok := knownServices[serviceHandle.Uuid]; ok {
+				if nameType, ok := LookupService(serviceHandle.Uuid); ok {
 					serviceHandle.Name = nameType.Name
 					serviceHandle.Type = nameType.Type
 				}
@@ -367,7 +543,15 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 		}
 
 		if p, ok := ble.peripherals[deviceUuid.String()]; ok {
+			// keep services found by earlier discoveries, like CoreBluetooth does
+			for k, s := range p.Services {
+				_, found := servicesHandles[s.Uuid]
+				if _, taken := servicesHandles[k]; !found && !taken {
+					servicesHandles[k] = s
+				}
+			}
 			p.Services = servicesHandles
+			ble.cacheServices(p)
 			ble.Emit(Event{
 				Name:       "servicesDiscover",
 				DeviceUUID: deviceUuid,
@@ -403,7 +587,7 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 					Descriptors: map[interface{}]*CharacteristicDescriptor{},
 				}
 
-				if nameType, ok := knownCharacteristics[characteristic.Uuid]; ok {
+				if nameType, ok := LookupCharacteristic(characteristic.Uuid); ok {
 					characteristic.Name = nameType.Name
 					characteristic.Type = nameType.Type
 				}
@@ -419,6 +603,7 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 			}
 
 			if service != nil {
+				ble.cacheServices(p)
 				ble.Emit(Event{
 					Name:        "characteristicsDiscover",
 					DeviceUUID:  deviceUuid,
@@ -447,10 +632,16 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 							Handle: dDict.MustGetInt("kCBMsgArgDescriptorHandle"),
 						}
 
+						if nameType, ok := LookupDescriptor(descriptor.Uuid); ok {
+							descriptor.Name = nameType.Name
+							descriptor.Type = nameType.Type
+						}
+
 						c.Descriptors[descriptor.Uuid] = &descriptor
 						c.Descriptors[descriptor.Handle] = &descriptor
 					}
 
+					ble.cacheServices(p)
 					ble.Emit(Event{
 						Name:               "descriptorsDiscover",
 						DeviceUUID:         deviceUuid,
@@ -468,26 +659,138 @@ func (ble *BLE) HandleXpcEvent(event xpc.Dict, err error) {
 	case 70, 95: // read
 		deviceUuid := args.MustGetUUID("kCBMsgArgDeviceUUID")
 		characteristicsHandle := args.MustGetInt("kCBMsgArgCharacteristicHandle")
-		//result := args.MustGetInt("kCBMsgArgResult")
 		isNotification := args.GetInt("kCBMsgArgIsNotification", 0) != 0
-		data := args.MustGetBytes("kCBMsgArgData")
+		data, _ := args["kCBMsgArgData"].([]byte)
 
 		if p, ok := ble.peripherals[deviceUuid.String()]; ok {
-			for _, s := range p.Services {
-				if c, ok := s.Characteristics[characteristicsHandle]; ok {
-					ble.Emit(Event{
-						Name:               "read",
-						DeviceUUID:         deviceUuid,
-						ServiceUuid:        s.Uuid,
-						CharacteristicUuid: c.Uuid,
-						Peripheral:         *p,
-						Data:               data,
-						IsNotification:     isNotification,
-					})
-					break
+			if s, c, ok := p.characteristicAt(characteristicsHandle); ok {
+				ble.checkCache(p, c.Uuid, data, isNotification)
+				ble.Emit(Event{
+					Name:               "read",
+					DeviceUUID:         deviceUuid,
+					ServiceUuid:        s.Uuid,
+					CharacteristicUuid: c.Uuid,
+					Handle:             c.Handle,
+					Peripheral:         *p,
+					Data:               data,
+					IsNotification:     isNotification,
+					Result:             args.GetInt("kCBMsgArgResult", 0),
+				})
+			}
+		}
+
+	case descriptorReadEvt, 102: // descriptorRead
+		deviceUuid := args.MustGetUUID("kCBMsgArgDeviceUUID")
+		descriptorHandle := args.MustGetInt("kCBMsgArgDescriptorHandle")
+		data, _ := args["kCBMsgArgData"].([]byte)
+
+		if p, ok := ble.peripherals[deviceUuid.String()]; ok {
+		search:
+			for _, services := range p.serviceSets() {
+				for _, s := range services {
+					for _, c := range s.Characteristics {
+						if d, ok := c.Descriptors[descriptorHandle]; ok {
+							ble.Emit(Event{
+								Name:               "descriptorRead",
+								DeviceUUID:         deviceUuid,
+								ServiceUuid:        s.Uuid,
+								CharacteristicUuid: c.Uuid,
+								DescriptorUuid:     d.Uuid,
+								Handle:             d.Handle,
+								Peripheral:         *p,
+								Data:               data,
+								Result:             args.GetInt("kCBMsgArgResult", 0),
+							})
+							break search
+						}
+					}
 				}
 			}
 		}
+
+	case writeEvt, 96: // write
+		ble.emitCharacteristicEvent("write", args, 0)
+
+	case notifyEvt, 98: // notify
+		ble.emitCharacteristicEvent("notify", args, args.GetInt("kCBMsgArgState", 0))
+	}
+}
+
+// subscribe records a subscribed central, once per attribute
+func (ble *BLE) subscribe(attributeId int, centralUuid xpc.UUID) {
+	for _, uuid := range ble.subscribers[attributeId] {
+		if uuid == centralUuid {
+			return
+		}
+	}
+	ble.subscribers[attributeId] = append(ble.subscribers[attributeId], centralUuid)
+}
+
+// updateMtu records the MTU of a connected peripheral and emits mtuChange
+func (ble *BLE) updateMtu(deviceUuid xpc.UUID, mtu int) {
+	// bleno here converts the deviceUuid to an address
+	if p, ok := ble.peripherals[deviceUuid.String()]; ok {
+		p.Mtu = mtu
+		ble.Emit(Event{
+			Name:       "mtuChange",
+			DeviceUUID: deviceUuid,
+			Peripheral: *p,
+			Mtu:        mtu,
+		})
+	}
+}
+
+// Mtu returns the ATT MTU of a connected peripheral, 23 until negotiated
+func (ble *BLE) Mtu(deviceUuid xpc.UUID) int {
+	if p, ok := ble.peripheral(deviceUuid); ok {
+		return p.Mtu
+	}
+	return defaultMTU
+}
+
+// peripheral returns a copy of a known peripheral, its service maps are
+// shared and only read with ble.mu held
+func (ble *BLE) peripheral(deviceUuid xpc.UUID) (Peripheral, bool) {
+	ble.mu.Lock()
+	defer ble.mu.Unlock()
+	if p, ok := ble.peripherals[deviceUuid.String()]; ok {
+		return *p, true
+	}
+	return Peripheral{}, false
+}
+
+// disconnected forgets the MTU and the unconfirmed services of a
+// peripheral and emits disconnect
+func (ble *BLE) disconnected(deviceUuid xpc.UUID, result int) {
+	if p, ok := ble.peripherals[deviceUuid.String()]; ok {
+		p.Mtu = defaultMTU
+		p.restored = nil
+	}
+	ble.Emit(Event{
+		Name:       "disconnect",
+		DeviceUUID: deviceUuid,
+		Result:     result,
+	})
+}
+
+// emitCharacteristicEvent emits the result of a write or notify request
+func (ble *BLE) emitCharacteristicEvent(name string, args xpc.Dict, state int) {
+	deviceUuid := args.MustGetUUID("kCBMsgArgDeviceUUID")
+	characteristicsHandle := args.MustGetInt("kCBMsgArgCharacteristicHandle")
+
+	if p, ok := ble.peripherals[deviceUuid.String()]; ok {
+		if s, c, ok := p.characteristicAt(characteristicsHandle); ok {
+			ble.Emit(Event{
+				Name:               name,
+				DeviceUUID:         deviceUuid,
+				ServiceUuid:        s.Uuid,
+				CharacteristicUuid: c.Uuid,
+				Handle:             c.Handle,
+				Peripheral:         *p,
+				Result:             args.GetInt("kCBMsgArgResult", 0),
+				IsNotification:     state != 0,
+			})
+		}
 	}
 }
 
@@ -517,9 +820,14 @@ const (
 	discoverServicesMsg        = 44
 	discoverCharacteristicsMsg = 61
 	discoverDescriptorsMsg     = 69
+	readDescriptorMsg          = 76
 	readMsg                    = 64
+	writeMsg                   = 65
+	notifyMsg                  = 67
 	removeServicesMsg          = 12
 	setServicesMsg             = 10
+	respondToRequestMsg        = 13
+	updateValueMsg             = 15
 )
 
 // initialize BLE
@@ -543,14 +851,16 @@ func (ble *BLE) StartAdvertising(name string, serviceUuids []xpc.UUID) {
 	})
 }
 
+// Apple, Inc. company identifier
+const appleCompanyID = 0x004c
+
 // start advertising as IBeacon (raw data)
 func (ble *BLE) StartAdvertisingIBeaconData(data []byte) {
 	if ble.utsname.Release >= "14." {
-		l := len(data)
-		buf := bytes.NewBuffer([]byte{byte(l + 5), 0xFF, 0x4C, 0x00, 0x02, byte(l)})
-		buf.Write(data)
+		var p adv.Packet
+		p.AddManufacturerData(appleCompanyID, append([]byte{0x02, byte(len(data))}, data...))
 		ble.sendCBMsg(startAdvertisingMsg, xpc.Dict{
-			"kCBAdvDataAppleMfgData": buf.Bytes(),
+			"kCBAdvDataAppleMfgData": p.Bytes(),
 		})
 	} else {
 		ble.sendCBMsg(startAdvertisingMsg, xpc.Dict{
@@ -590,7 +900,9 @@ func (ble *BLE) StartScanning(serviceUuids []xpc.UUID, allowDuplicates bool) {
 		args["kCBMsgArgOptions"] = xpc.Dict{}
 	}
 
+	ble.mu.Lock()
 	ble.allowDuplicates = allowDuplicates
+	ble.mu.Unlock()
 	msg := 29
 	if ble.utsname.Release >= "19." {
 		msg = 51
@@ -615,12 +927,11 @@ func (ble *BLE) StopScanning() {
 
 // connect
 func (ble *BLE) Connect(deviceUuid xpc.UUID) {
-	uuid := deviceUuid.String()
 	msg := 31
 	if ble.utsname.Release >= "18." {
 		msg = 48
 	}
-	if p, ok := ble.peripherals[uuid]; ok {
+	if p, ok := ble.peripheral(deviceUuid); ok {
 		ble.sendCBMsg(msg, xpc.Dict{"kCBMsgArgOptions": xpc.Dict{"kCBConnectOptionNotifyOnDisconnection": 1}, "kCBMsgArgDeviceUUID": p.Uuid})
 	} else {
 		log.Println("no peripheral", deviceUuid)
@@ -629,12 +940,11 @@ func (ble *BLE) Connect(deviceUuid xpc.UUID) {
 
 // disconnect
 func (ble *BLE) Disconnect(deviceUuid xpc.UUID) {
-	uuid := deviceUuid.String()
 	msg := 32
 	if ble.utsname.Release >= "18." {
 		msg = 49
 	}
-	if p, ok := ble.peripherals[uuid]; ok {
+	if p, ok := ble.peripheral(deviceUuid); ok {
 		ble.sendCBMsg(msg, xpc.Dict{"kCBMsgArgDeviceUUID": p.Uuid})
 	} else {
 		log.Println("no peripheral", deviceUuid)
@@ -643,12 +953,11 @@ func (ble *BLE) Disconnect(deviceUuid xpc.UUID) {
 
 // update rssi
 func (ble *BLE) UpdateRssi(deviceUuid xpc.UUID) {
-	uuid := deviceUuid.String()
 	msg := 43
 	if ble.utsname.Release >= "18." {
 		msg = 71
 	}
-	if p, ok := ble.peripherals[uuid]; ok {
+	if p, ok := ble.peripheral(deviceUuid); ok {
 		ble.sendCBMsg(msg, xpc.Dict{"kCBMsgArgDeviceUUID": p.Uuid})
 	} else {
 		log.Println("no peripheral", deviceUuid)
@@ -657,12 +966,11 @@ func (ble *BLE) UpdateRssi(deviceUuid xpc.UUID) {
 
 // discover services
 func (ble *BLE) DiscoverServices(deviceUuid xpc.UUID, uuids []xpc.UUID) {
-	sUuid := deviceUuid.String()
 	msg := 44
 	if ble.utsname.Release >= "18." {
 		msg = 72
 	}
-	if p, ok := ble.peripherals[sUuid]; ok {
+	if p, ok := ble.peripheral(deviceUuid); ok {
 		sUuids := make([]string, len(uuids))
 		for i, uuid := range uuids {
 			sUuids[i] = uuid.String() // uuids may be a list of []byte (2 bytes)
@@ -675,94 +983,383 @@ func (ble *BLE) DiscoverServices(deviceUuid xpc.UUID, uuids []xpc.UUID) {
 
 // discover characteristics
 func (ble *BLE) DiscoverCharacteristics(deviceUuid xpc.UUID, serviceUuid string, characteristicUuids []string) {
-	sUuid := deviceUuid.String()
 	msg := 61
 	if ble.utsname.Release >= "18." {
 		msg = 87
 	}
-	if p, ok := ble.peripherals[sUuid]; ok {
-		cUuids := make([]string, len(characteristicUuids))
-		for i, cuuid := range characteristicUuids {
-			cUuids[i] = cuuid // characteristicUuids may be a list of []byte (2 bytes)
+	ble.mu.Lock()
+	p, ok := ble.peripherals[deviceUuid.String()]
+	var s *ServiceHandle
+	if ok {
+		s, ok = p.Services[serviceUuid]
+		if !ok {
+			log.Println("no service", serviceUuid)
 		}
-
-		ble.sendCBMsg(msg, xpc.Dict{
-			"kCBMsgArgDeviceUUID":         p.Uuid,
-			"kCBMsgArgServiceStartHandle": p.Services[serviceUuid].startHandle,
-			"kCBMsgArgServiceEndHandle":   p.Services[serviceUuid].endHandle,
-			"kCBMsgArgUUIDs":              cUuids,
-		})
-
 	} else {
 		log.Println("no peripheral", deviceUuid)
 	}
+	ble.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	cUuids := make([]string, len(characteristicUuids))
+	for i, cuuid := range characteristicUuids {
+		cUuids[i] = cuuid // characteristicUuids may be a list of []byte (2 bytes)
+	}
+
+	ble.sendCBMsg(msg, xpc.Dict{
+		"kCBMsgArgDeviceUUID":         deviceUuid,
+		"kCBMsgArgServiceStartHandle": s.startHandle,
+		"kCBMsgArgServiceEndHandle":   s.endHandle,
+		"kCBMsgArgUUIDs":              cUuids,
+	})
 }
 
 // discover descriptors
 func (ble *BLE) DiscoverDescriptors(deviceUuid xpc.UUID, serviceUuid, characteristicUuid string) {
-	sUuid := deviceUuid.String()
 	msg := 69
 	if ble.utsname.Release >= "18." {
 		msg = 94
 	}
-	if p, ok := ble.peripherals[sUuid]; ok {
-		s := p.Services[serviceUuid]
-		c := s.Characteristics[characteristicUuid]
-
+	if p, c, ok := ble.discovered(deviceUuid, serviceUuid, characteristicUuid); ok {
 		ble.sendCBMsg(msg, xpc.Dict{
 			"kCBMsgArgDeviceUUID":                p.Uuid,
 			"kCBMsgArgCharacteristicHandle":      c.Handle,
 			"kCBMsgArgCharacteristicValueHandle": c.ValueHandle,
 		})
-	} else {
-		log.Println("no peripheral", deviceUuid)
 	}
 }
 
-// read
+// read, the value arrives as "read" event
 func (ble *BLE) Read(deviceUuid xpc.UUID, serviceUuid, characteristicUuid string) {
-	sUuid := deviceUuid.String()
-	msg := 64
+	if p, c, ok := ble.discovered(deviceUuid, serviceUuid, characteristicUuid); ok {
+		ble.readHandle(p, c)
+	}
+}
+
+// ReadHandle reads the characteristic declared at handle, telling apart
+// characteristics of the same uuid
+func (ble *BLE) ReadHandle(deviceUuid xpc.UUID, handle int) {
+	ble.mu.Lock()
+	p, ok := ble.peripherals[deviceUuid.String()]
+	var c ServiceCharacteristic
+	found := false
+	if ok {
+		if _, ch, ok := p.characteristicAt(handle); ok && ch.Handle == handle {
+			c, found = *ch, true
+		}
+	}
+	ble.mu.Unlock()
+
+	switch {
+	case !ok:
+		log.Println("no peripheral", deviceUuid)
+	case !found:
+		log.Println("no characteristic", handle)
+	default:
+		ble.readHandle(*p, c)
+	}
+}
+
+func (ble *BLE) readHandle(p Peripheral, c ServiceCharacteristic) {
+	msg := readMsg
 	if ble.utsname.Release >= "18." {
 		msg = 100
 	}
-	if p, ok := ble.peripherals[sUuid]; ok {
-		s := p.Services[serviceUuid]
-		c := s.Characteristics[characteristicUuid]
+	ble.sendCBMsg(msg, xpc.Dict{
+		"kCBMsgArgDeviceUUID":                p.Uuid,
+		"kCBMsgArgCharacteristicHandle":      c.Handle,
+		"kCBMsgArgCharacteristicValueHandle": c.ValueHandle,
+	})
+}
+
+// read a descriptor value, the value arrives as "descriptorRead" event
+func (ble *BLE) ReadDescriptor(deviceUuid xpc.UUID, serviceUuid, characteristicUuid, descriptorUuid string) {
+	ble.mu.Lock()
+	handle, ok := 0, false
+	if p, found := ble.peripherals[deviceUuid.String()]; !found {
+		log.Println("no peripheral", deviceUuid)
+	} else if c, found := p.characteristic(serviceUuid, characteristicUuid); found {
+		if d, found := c.Descriptors[descriptorUuid]; found {
+			handle, ok = d.Handle, true
+		} else {
+			log.Println("no descriptor", descriptorUuid)
+		}
+	}
+	ble.mu.Unlock()
+	if ok {
+		ble.ReadDescriptorHandle(deviceUuid, handle)
+	}
+}
+
+// ReadDescriptorHandle reads the descriptor at handle
+func (ble *BLE) ReadDescriptorHandle(deviceUuid xpc.UUID, handle int) {
+	msg := readDescriptorMsg
+	if ble.utsname.Release >= "18." {
+		msg = 106
+	}
+	ble.sendCBMsg(msg, xpc.Dict{
+		"kCBMsgArgDeviceUUID":       deviceUuid,
+		"kCBMsgArgDescriptorHandle": handle,
+	})
+}
+
+// write, withoutResponse sends a Write Command and no "write" event follows.
+// Values without response longer than MaximumWriteLength are split into
+// consecutive Write Commands, values with response longer than the longest
+// attribute value are not written.
+func (ble *BLE) Write(deviceUuid xpc.UUID, serviceUuid, characteristicUuid string, data []byte, withoutResponse bool) {
+	msg := writeMsg
+	if ble.utsname.Release >= "18." {
+		msg = 101
+	}
+	if p, c, ok := ble.discovered(deviceUuid, serviceUuid, characteristicUuid); ok {
+		writeType := 0
+		parts := [][]byte{data}
+		if withoutResponse {
+			writeType = 1
+			parts = chunks(data, p.MaximumWriteLength(true))
+		} else if len(data) > maxAttributeLength {
+			log.Println("value too long", len(data))
+			return
+		}
+		for _, chunk := range parts {
+			ble.sendCBMsg(msg, xpc.Dict{
+				"kCBMsgArgDeviceUUID":                p.Uuid,
+				"kCBMsgArgCharacteristicHandle":      c.Handle,
+				"kCBMsgArgCharacteristicValueHandle": c.ValueHandle,
+				"kCBMsgArgData":                      chunk,
+				"kCBMsgArgType":                      writeType,
+			})
+		}
+	}
+}
+
+// discovered returns a copy of a peripheral and of one of its
+// characteristics, logging what is missing
+func (ble *BLE) discovered(deviceUuid xpc.UUID, serviceUuid, characteristicUuid string) (Peripheral, ServiceCharacteristic, bool) {
+	ble.mu.Lock()
+	defer ble.mu.Unlock()
+	p, ok := ble.peripherals[deviceUuid.String()]
+	if !ok {
+		log.Println("no peripheral", deviceUuid)
+		return Peripheral{}, ServiceCharacteristic{}, false
+	}
+	c, ok := p.characteristic(serviceUuid, characteristicUuid)
+	if !ok {
+		return *p, ServiceCharacteristic{}, false
+	}
+	return *p, *c, true
+}
+
+// characteristic returns a discovered characteristic, logging what is missing
+func (p Peripheral) characteristic(serviceUuid, characteristicUuid string) (*ServiceCharacteristic, bool) {
+	s, ok := p.Services[serviceUuid]
+	if !ok {
+		s, ok = p.restored[serviceUuid]
+	}
+	if !ok {
+		log.Println("no service", serviceUuid)
+		return nil, false
+	}
+	c, ok := s.Characteristics[characteristicUuid]
+	if !ok {
+		log.Println("no characteristic", characteristicUuid)
+		return nil, false
+	}
+	return c, true
+}
+
+// serviceSets returns the discovered services and those restored but not
+// confirmed yet
+func (p Peripheral) serviceSets() []map[interface{}]*ServiceHandle {
+	if p.restored == nil {
+		return []map[interface{}]*ServiceHandle{p.Services}
+	}
+	return []map[interface{}]*ServiceHandle{p.Services, p.restored}
+}
+
+// characteristicAt returns the characteristic declared at handle, or whose
+// value is at handle
+func (p Peripheral) characteristicAt(handle int) (*ServiceHandle, *ServiceCharacteristic, bool) {
+	for _, services := range p.serviceSets() {
+		for _, s := range services {
+			if c, ok := s.Characteristics[handle]; ok {
+				return s, c, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// chunks splits data into parts of at most n bytes, an empty value is one
+// empty part
+func chunks(data []byte, n int) [][]byte {
+	var parts [][]byte
+	for len(data) > n {
+		parts = append(parts, data[:n])
+		data = data[n:]
+	}
+	return append(parts, data)
+}
 
+// enable or disable notifications, values arrive as "read" events with IsNotification set
+func (ble *BLE) Notify(deviceUuid xpc.UUID, serviceUuid, characteristicUuid string, notify bool) {
+	msg := notifyMsg
+	if ble.utsname.Release >= "18." {
+		msg = 103
+	}
+	if p, c, ok := ble.discovered(deviceUuid, serviceUuid, characteristicUuid); ok {
+		state := 0
+		if notify {
+			state = 1
+		}
 		ble.sendCBMsg(msg, xpc.Dict{
 			"kCBMsgArgDeviceUUID":                p.Uuid,
 			"kCBMsgArgCharacteristicHandle":      c.Handle,
 			"kCBMsgArgCharacteristicValueHandle": c.ValueHandle,
+			"kCBMsgArgState":                     state,
 		})
-	} else {
-		log.Println("no peripheral", deviceUuid)
 	}
 }
 
+// lockedCharacteristic returns the local characteristic registered with
+// attribute id, taking ble.mu
+func (ble *BLE) lockedCharacteristic(attributeId int) (Characteristic, bool) {
+	ble.mu.Lock()
+	defer ble.mu.Unlock()
+	return ble.characteristic(attributeId)
+}
+
+// characteristic returns the local characteristic registered with attribute
+// id, ble.mu is held
+func (ble *BLE) characteristic(attributeId int) (Characteristic, bool) {
+	if attributeId <= 0 || attributeId >= len(ble.attributeIds) {
+		return Characteristic{}, false
+	}
+	a, ok := ble.db.Attribute(ble.attributeIds[attributeId])
+	if !ok || a.Characteristic == nil || a.Handle != a.ValueHandle {
+		return Characteristic{}, false
+	}
+	return *a.Characteristic, true
+}
+
+// Database returns the attribute database of the services set, nil before
+// SetServices
+func (ble *BLE) Database() *Database {
+	ble.mu.Lock()
+	defer ble.mu.Unlock()
+	return ble.db
+}
+
+// notify subscribed centrals of a new characteristic value, returns false
+// if no central is subscribed. A "readyToUpdate" event follows once the
+// transmit queue has room for the next value.
+func (ble *BLE) UpdateValue(uuid xpc.UUID, data []byte) bool {
+	attributeId, ok := ble.subscribed(uuid)
+	if !ok {
+		return false
+	}
+	ble.sendCBMsg(updateValueMsg, xpc.Dict{
+		"kCBMsgArgUUIDs":       [][]byte{},
+		"kCBMsgArgAttributeID": attributeId,
+		"kCBMsgArgData":        data,
+	})
+	return true
+}
+
+// subscribed returns the attribute id of a local characteristic with
+// subscribed centrals
+func (ble *BLE) subscribed(uuid xpc.UUID) (int, bool) {
+	ble.mu.Lock()
+	defer ble.mu.Unlock()
+	for attributeId := range ble.attributeIds {
+		if c, ok := ble.characteristic(attributeId); ok && c.uuid == uuid {
+			return attributeId, len(ble.subscribers[attributeId]) > 0
+		}
+	}
+	return 0, false
+}
+
 // remove all services
 func (ble *BLE) RemoveServices() {
 	ble.sendCBMsg(removeServicesMsg, nil)
+	ble.mu.Lock()
+	ble.subscribers = map[int][]xpc.UUID{}
+	ble.mu.Unlock()
 }
 
 // set services
+//
+// Replacing services keeps the subscriptions outside of the changed handle
+// range. The range is indicated with a Service Changed characteristic among
+// the services, backends with a Generic Attribute service of their own
+// indicate it themselves.
 func (ble *BLE) SetServices(services []Service) {
-	ble.RemoveServices()
-	ble.attributes = xpc.Array{nil}
+	db, err := NewDatabase(services...)
+	if err != nil {
+		log.Println("error:", err)
+		return
+	}
+	ble.sendCBMsg(removeServicesMsg, nil)
+
+	ble.mu.Lock()
+	old, oldIds, subscribers := ble.db, ble.attributeIds, ble.subscribers
+	ble.subscribers = map[int][]xpc.UUID{}
+	ble.db = db
+
+	// attribute ids follow the database order of services and their
+	// characteristic values
+	ble.attributeIds = []uint16{0}
+	serviceIds := map[xpc.UUID]int{}
+	n := 0
+	for _, a := range db.Attributes() {
+		switch {
+		case a.Type == PrimaryServiceUUID || a.Type == SecondaryServiceUUID:
+			if _, ok := serviceIds[services[n].uuid]; !ok {
+				serviceIds[services[n].uuid] = len(ble.attributeIds)
+			}
+			n++
+		case a.Characteristic == nil || a.Handle != a.ValueHandle:
+			continue
+		}
+		ble.attributeIds = append(ble.attributeIds, a.Handle)
+	}
+	var start, end uint16
+	changed := old != nil
+	if old != nil {
+		start, end, changed = old.Changed(db)
+	}
+	for id, centrals := range subscribers {
+		if id >= len(oldIds) || id >= len(ble.attributeIds) || oldIds[id] != ble.attributeIds[id] {
+			continue
+		}
+		if h := oldIds[id]; !changed || h < start || h > end {
+			ble.subscribers[id] = centrals
+		}
+	}
+	ble.mu.Unlock()
 
 	attributeId := 1
 
 	for _, service := range services {
+		serviceType := 1 // 1 => primary, 0 => secondary
+		if service.secondary {
+			serviceType = 0
+		}
+		includes := []int{}
+		for _, uuid := range service.includes {
+			includes = append(includes, serviceIds[uuid])
+		}
 		arg := xpc.Dict{
 			"kCBMsgArgAttributeID":     attributeId,
-			"kCBMsgArgAttributeIDs":    []int{},
+			"kCBMsgArgAttributeIDs":    includes,
 			"kCBMsgArgCharacteristics": nil,
-			"kCBMsgArgType":            1, // 1 => primary, 0 => excluded
+			"kCBMsgArgType":            serviceType,
 			"kCBMsgArgUUID":            service.uuid.String(),
 		}
 
-		ble.attributes = append(ble.attributes, service)
-		ble.lastServiceAttributeId = attributeId
 		attributeId += 1
 
 		characteristics := xpc.Array{}
@@ -819,6 +1416,10 @@ func (ble *BLE) SetServices(services []Service) {
 
 			descriptors := xpc.Array{}
 			for _, descriptor := range characteristic.descriptors {
+				if managedDescriptors[descriptor.uuid] {
+					log.Println("skip managed descriptor", descriptor.uuid)
+					continue
+				}
 				descriptors = append(descriptors, xpc.Dict{"kCBMsgArgData": descriptor.value, "kCBMsgArgUUID": descriptor.uuid.String()})
 			}
 
@@ -831,7 +1432,6 @@ func (ble *BLE) SetServices(services []Service) {
 				"kCBMsgArgUUID":                     characteristic.uuid.String(),
 			}
 
-			ble.attributes = append(ble.attributes, characteristic)
 			characteristics = append(characteristics, characteristicArg)
 
 			attributeId += 1
@@ -840,4 +1440,8 @@ func (ble *BLE) SetServices(services []Service) {
 		arg["kCBMsgArgCharacteristics"] = characteristics
 		ble.sendCBMsg(setServicesMsg, arg) // remove all services
 	}
+
+	if changed {
+		ble.UpdateValue(ServiceChangedUUID, ServiceChanged(start, end))
+	}
 }
@@ -1,6 +1,11 @@
 package goble
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dim13/goble/xpc"
+)
 
 func TestPropertyStringer(t *testing.T) {
 	testCases := []struct {
@@ -17,3 +22,161 @@ func TestPropertyStringer(t *testing.T) {
 		}
 	}
 }
+
+// transport drops the messages sent to blued
+type transport struct{}
+
+func (transport) Send(msg interface{}, verbose bool) {}
+
+func TestSubscribe(t *testing.T) {
+	ble := NewWithTransport(transport{}, "")
+	ble.SetServices([]Service{NewService(UUID16(0x180f), NewCharacteristic(UUID16(0x2a19), Read|Notify|Indicate, nil))})
+	events := make(chan Event, 4)
+	ble.Listen(func(ev Event) bool {
+		events <- ev
+		return false
+	})
+	central := xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	subscribe := func(id int, args xpc.Dict) {
+		args["kCBMsgArgAttributeID"] = int64(2)
+		args["kCBMsgArgCentralUUID"] = central
+		ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(id), "kCBMsgArgs": args}, nil)
+	}
+
+	// notifications are preferred over indications
+	for i := 0; i < 2; i++ {
+		subscribe(subscribeEvt, xpc.Dict{})
+		if ev := <-events; ev.Name != "subscribe" || ev.Configuration != Notifications {
+			t.Errorf("got %v %v, want subscribe %v", ev.Name, ev.Configuration, Notifications)
+		}
+	}
+	if n := len(ble.subscribers[2]); n != 1 {
+		t.Errorf("got %d subscribers, want 1", n)
+	}
+	subscribe(unsubscribeEvt, xpc.Dict{})
+	<-events
+	if ble.UpdateValue(UUID16(0x2a19), []byte{1}) {
+		t.Error("update sent without subscribers")
+	}
+}
+
+func TestWriteUnknown(t *testing.T) {
+	r := &recorder{}
+	ble := NewWithTransport(r, "")
+	device := xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	ble.peripherals[device.String()] = &Peripheral{
+		Uuid: device,
+		Services: map[interface{}]*ServiceHandle{
+			"180f": {Characteristics: map[interface{}]*ServiceCharacteristic{}},
+		},
+	}
+	ble.Write(device, "180a", "2a29", []byte{1}, false)
+	ble.Write(device, "180f", "2a19", []byte{1}, false)
+	ble.Notify(device, "180f", "2a19", true)
+	ble.Read(device, "180a", "2a29")
+	ble.ReadDescriptor(device, "180f", "2a19", "2902")
+	if len(r.messages) != 0 {
+		t.Errorf("got %v", r.messages)
+	}
+}
+
+func TestSetServicesChanged(t *testing.T) {
+	r := &recorder{}
+	ble := NewWithTransport(r, "")
+	gatt := NewService(GenericAttributeUUID, NewCharacteristic(ServiceChangedUUID, Indicate, nil))
+	battery := NewService(UUID16(0x180f), NewCharacteristic(UUID16(0x2a19), Read|Notify, nil))
+	ble.SetServices([]Service{gatt, battery})
+	central := xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	for _, id := range []int{2, 4} {
+		ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(subscribeEvt), "kCBMsgArgs": xpc.Dict{
+			"kCBMsgArgAttributeID": int64(id),
+			"kCBMsgArgCentralUUID": central,
+		}}, nil)
+	}
+
+	r.messages = nil
+	info := NewService(UUID16(0x180a), NewCharacteristic(UUID16(0x2a29), Read, []byte("goble")))
+	ble.SetServices([]Service{gatt, battery, info})
+	last := r.messages[len(r.messages)-1]
+	args := last["kCBMsgArgs"].(xpc.Dict)
+	if last["kCBMsgId"] != updateValueMsg || args["kCBMsgArgAttributeID"] != 2 {
+		t.Fatalf("got %v, want service changed update", last)
+	}
+	if got, want := args["kCBMsgArgData"].([]byte), ServiceChanged(9, 11); !bytes.Equal(got, want) {
+		t.Errorf("got range %x, want %x", got, want)
+	}
+	if !ble.UpdateValue(UUID16(0x2a19), []byte{1}) {
+		t.Error("subscription outside of the changed range dropped")
+	}
+
+	ble.SetServices([]Service{gatt, info})
+	if ble.UpdateValue(UUID16(0x2a19), []byte{1}) {
+		t.Error("update sent for a removed characteristic")
+	}
+}
+
+func TestReadValueReleased(t *testing.T) {
+	r := &recorder{}
+	ble := NewWithTransport(r, "")
+	reads := 0
+	long := NewCharacteristic(UUID16(0x2a29), Read, nil)
+	long.HandleReadValue(func(central xpc.UUID) ([]byte, error) {
+		reads++
+		return make([]byte, 40), nil
+	})
+	ble.SetServices([]Service{NewService(UUID16(0x180a), long)})
+	central := xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	read := func(offset int) []byte {
+		r.messages = nil
+		ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(readRequestEvt), "kCBMsgArgs": xpc.Dict{
+			"kCBMsgArgAttributeID":   int64(2),
+			"kCBMsgArgOffset":        int64(offset),
+			"kCBMsgArgCentralUUID":   central,
+			"kCBMsgArgTransactionID": int64(1),
+		}}, nil)
+		return r.messages[0]["kCBMsgArgs"].(xpc.Dict)["kCBMsgArgData"].([]byte)
+	}
+
+	read(0)
+	if v := read(22); len(v) != 18 || reads != 1 {
+		t.Errorf("got %d bytes after %d reads, want 18 after 1", len(v), reads)
+	}
+	// the short response ended the read
+	read(22)
+	if reads != 2 {
+		t.Errorf("got %d reads, want 2", reads)
+	}
+}
+
+func TestWriteLong(t *testing.T) {
+	r := &recorder{}
+	ble := NewWithTransport(r, "")
+	device := xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	ble.peripherals[device.String()] = &Peripheral{
+		Uuid: device,
+		Mtu:  defaultMTU,
+		Services: map[interface{}]*ServiceHandle{
+			"180f": {Characteristics: map[interface{}]*ServiceCharacteristic{"2a19": {}}},
+		},
+	}
+	testCases := []struct {
+		size            int
+		withoutResponse bool
+		messages        int
+	}{
+		{100, true, 5},
+		{100, false, 1},
+		{maxAttributeLength, false, 1},
+		{maxAttributeLength + 1, false, 0},
+	}
+	for _, tc := range testCases {
+		r.messages = nil
+		ble.Write(device, "180f", "2a19", make([]byte, tc.size), tc.withoutResponse)
+		if len(r.messages) != tc.messages {
+			t.Errorf("%d bytes: got %d writes, want %d", tc.size, len(r.messages), tc.messages)
+		}
+	}
+	if mtu := ble.Mtu(device); mtu != defaultMTU {
+		t.Errorf("got MTU %d, want %d", mtu, defaultMTU)
+	}
+}
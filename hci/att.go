@@ -0,0 +1,493 @@
+package hci
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"sync"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+// receive MTU announced as client, it fits an LE data length extended PDU
+const maxMTU = 247
+
+// queue passes items from the run goroutine to another one without
+// blocking it
+type queue struct {
+	mu     sync.Mutex
+	items  []interface{}
+	wake   chan struct{}
+	closed bool
+}
+
+func newQueue() *queue {
+	return &queue{wake: make(chan struct{}, 1)}
+}
+
+func (q *queue) put(v interface{}) {
+	q.mu.Lock()
+	if !q.closed {
+		q.items = append(q.items, v)
+	}
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// get waits for the next item, false once the queue is closed
+func (q *queue) get() (interface{}, bool) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, false
+		}
+		if len(q.items) > 0 {
+			v := q.items[0]
+			q.items = q.items[1:]
+			q.mu.Unlock()
+			return v, true
+		}
+		q.mu.Unlock()
+		<-q.wake
+	}
+}
+
+func (q *queue) done() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// close drops the waiting items
+func (q *queue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.items = nil
+	q.mu.Unlock()
+	q.signal()
+}
+
+// bearer carries the PDUs of the ATT client or server of a link
+type bearer struct {
+	d  *Device
+	c  *conn
+	rx *queue
+}
+
+func (b *bearer) Read(p []byte) (int, error) {
+	v, ok := b.rx.get()
+	if !ok {
+		return 0, io.EOF
+	}
+	return copy(p, v.([]byte)), nil
+}
+
+func (b *bearer) Write(p []byte) (int, error) {
+	if b.rx.done() {
+		return 0, io.ErrClosedPipe
+	}
+	pdu := append([]byte(nil), p...)
+	b.d.do(func() {
+		if b.d.conns[b.c.handle] == b.c {
+			b.d.sendL2CAP(b.c, cidATT, pdu)
+		}
+	})
+	return len(p), nil
+}
+
+func (b *bearer) Close() error {
+	b.rx.close()
+	return nil
+}
+
+// characteristic discovered on a peripheral
+type characteristic struct {
+	uuid               []byte // little-endian
+	props              byte
+	handle, value, end uint16
+	config             uint16 // client configuration descriptor, 0 if unknown
+	described          bool   // descriptors were discovered
+}
+
+// le converts a little-endian attribute uuid to the big-endian bytes goble
+// expects, and back
+func le(uuid []byte) []byte {
+	r := make([]byte, len(uuid))
+	for i, b := range uuid {
+		r[len(uuid)-1-i] = b
+	}
+	return r
+}
+
+// uuidBytes returns the big-endian bytes of a uuid, 16-bit for assigned
+// numbers
+func uuidBytes(uuid xpc.UUID) []byte {
+	if n, ok := goble.Short(uuid); ok {
+		return []byte{byte(n >> 8), byte(n)}
+	}
+	return uuid.Bytes()
+}
+
+// att passes an ATT PDU to the client or the server of a link, responses,
+// notifications and indications have odd opcodes
+func (d *Device) att(c *conn, pdu []byte) {
+	if pdu[0]&1 == 1 {
+		c.clientRx.put(pdu)
+		return
+	}
+	c.serverRx.put(pdu)
+}
+
+// deliver emits an event from another goroutine
+func (d *Device) deliver(id int, args xpc.Dict) {
+	d.do(func() { d.emit(id, args) })
+}
+
+// exchangeMTU negotiates the ATT MTU and reports it with mtuChange
+func (d *Device) exchangeMTU(c *conn) {
+	mtu, err := c.client.ExchangeMTU(maxMTU)
+	if err != nil {
+		return
+	}
+	d.deliver(mtuChangeEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID": c.addr.uuid(),
+		"kCBMsgArgATTMTU":     int64(mtu),
+	})
+}
+
+// request runs a GATT client message of goble on the worker of a link
+func (d *Device) request(c *conn, id int, args xpc.Dict) {
+	switch id {
+	case discoverServicesMsg:
+		d.discoverServices(c, args)
+	case discoverCharacteristicsMsg:
+		d.discoverCharacteristics(c, args)
+	case discoverDescriptorsMsg:
+		d.discoverDescriptors(c, args)
+	case readMsg:
+		d.readValue(c, args)
+	case writeMsg:
+		d.writeValue(c, args)
+	case readDescriptorMsg:
+		d.readDescriptor(c, args)
+	case notifyMsg:
+		d.notify(c, args)
+	}
+}
+
+// matches reports whether a uuid is in the list sent by goble, an empty list
+// matches everything
+func matches(uuid []byte, list interface{}) bool {
+	uuids, _ := list.([]string)
+	if len(uuids) == 0 {
+		return true
+	}
+	s := hex.EncodeToString(le(uuid))
+	for _, u := range uuids {
+		if goble.EqualUUID(s, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverServices reads all primary services by group type
+func (d *Device) discoverServices(c *conn, args xpc.Dict) {
+	filter := args["kCBMsgArgUUIDs"]
+	services := xpc.Array{}
+	var status error
+	for start := uint16(1); ; {
+		groups, err := c.client.ReadByGroupType(start, 0xffff, goble.PrimaryServiceUUID)
+		if err != nil {
+			if err != goble.ErrAttributeNotFound {
+				status = err
+			}
+			break
+		}
+		last := uint16(0)
+		for _, g := range groups {
+			if matches(g.Value, filter) {
+				services = append(services, xpc.Dict{
+					"kCBMsgArgUUID":               le(g.Value),
+					"kCBMsgArgServiceStartHandle": int64(g.Handle),
+					"kCBMsgArgServiceEndHandle":   int64(g.End),
+				})
+			}
+			last = g.End
+		}
+		if last == 0xffff || last < start {
+			break
+		}
+		start = last + 1
+	}
+	d.deliver(servicesDiscoverEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID": c.addr.uuid(),
+		"kCBMsgArgServices":   services,
+		"kCBMsgArgResult":     int64(result(status)),
+	})
+}
+
+// discoverCharacteristics reads the characteristic declarations of a service
+func (d *Device) discoverCharacteristics(c *conn, args xpc.Dict) {
+	start := uint16(intArg(args, "kCBMsgArgServiceStartHandle"))
+	end := uint16(intArg(args, "kCBMsgArgServiceEndHandle"))
+	filter := args["kCBMsgArgUUIDs"]
+
+	var found []*characteristic
+	var status error
+	for from := start; ; {
+		data, err := c.client.ReadByType(from, end, goble.CharacteristicUUID)
+		if err != nil {
+			if err != goble.ErrAttributeNotFound {
+				status = err
+			}
+			break
+		}
+		last := uint16(0)
+		for _, a := range data {
+			if len(a.Value) < 5 {
+				continue
+			}
+			found = append(found, &characteristic{
+				handle: a.Handle,
+				props:  a.Value[0],
+				value:  binary.LittleEndian.Uint16(a.Value[1:]),
+				uuid:   a.Value[3:],
+			})
+			last = a.Handle
+		}
+		if last < from || last >= end {
+			break
+		}
+		from = last + 1
+	}
+
+	characteristics := xpc.Array{}
+	for i, ch := range found {
+		ch.end = end
+		if i+1 < len(found) {
+			ch.end = found[i+1].handle - 1
+		}
+		c.setCharacteristic(ch)
+		if matches(ch.uuid, filter) {
+			characteristics = append(characteristics, xpc.Dict{
+				"kCBMsgArgUUID":                      le(ch.uuid),
+				"kCBMsgArgCharacteristicHandle":      int64(ch.handle),
+				"kCBMsgArgCharacteristicValueHandle": int64(ch.value),
+				"kCBMsgArgCharacteristicProperties":  int64(ch.props),
+			})
+		}
+	}
+	d.deliver(characteristicsDiscoverEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID":         c.addr.uuid(),
+		"kCBMsgArgServiceStartHandle": int64(start),
+		"kCBMsgArgCharacteristics":    characteristics,
+		"kCBMsgArgResult":             int64(result(status)),
+	})
+}
+
+// findInformation lists the descriptors of a characteristic
+func (d *Device) findInformation(c *conn, ch *characteristic) xpc.Array {
+	descriptors := xpc.Array{}
+	defer func() { ch.described = true }()
+	for from := ch.value + 1; from != 0 && from <= ch.end; {
+		info, err := c.client.FindInformation(from, ch.end)
+		if err != nil {
+			return descriptors
+		}
+		last := uint16(0)
+		for _, i := range info {
+			if declaration(i.UUID) {
+				// the next characteristic or service starts
+				ch.end = i.Handle - 1
+				return descriptors
+			}
+			if i.UUID == goble.ClientConfigurationUUID {
+				ch.config = i.Handle
+			}
+			descriptors = append(descriptors, xpc.Dict{
+				"kCBMsgArgUUID":             uuidBytes(i.UUID),
+				"kCBMsgArgDescriptorHandle": int64(i.Handle),
+			})
+			last = i.Handle
+		}
+		if last < from {
+			break
+		}
+		from = last + 1
+	}
+	return descriptors
+}
+
+// restore reads the declaration of a characteristic known to the central
+// from a cache, but not discovered on this connection
+func (d *Device) restore(c *conn, args xpc.Dict) (*characteristic, error) {
+	handle := uint16(intArg(args, "kCBMsgArgCharacteristicHandle"))
+	if handle == 0 {
+		return nil, goble.ErrInvalidHandle
+	}
+	v, err := c.client.Read(handle)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) < 5 {
+		return nil, goble.ErrInvalidHandle
+	}
+	ch := &characteristic{
+		handle: handle,
+		props:  v[0],
+		value:  binary.LittleEndian.Uint16(v[1:]),
+		uuid:   v[3:],
+		end:    0xffff, // until the next declaration
+	}
+	c.setCharacteristic(ch)
+	return ch, nil
+}
+
+// declaration reports whether an attribute type declares a service,
+// include or characteristic
+func declaration(uuid xpc.UUID) bool {
+	switch uuid {
+	case goble.PrimaryServiceUUID, goble.SecondaryServiceUUID, goble.IncludeUUID, goble.CharacteristicUUID:
+		return true
+	}
+	return false
+}
+
+// characteristic returns the characteristic addressed by a message,
+// restoring it if it was not discovered
+func (d *Device) characteristic(c *conn, args xpc.Dict) (*characteristic, error) {
+	c.mu.Lock()
+	ch, ok := c.chars[uint16(intArg(args, "kCBMsgArgCharacteristicHandle"))]
+	c.mu.Unlock()
+	if ok {
+		return ch, nil
+	}
+	return d.restore(c, args)
+}
+
+func (c *conn) setCharacteristic(ch *characteristic) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chars[ch.handle] = ch
+}
+
+func (d *Device) discoverDescriptors(c *conn, args xpc.Dict) {
+	descriptors := xpc.Array{}
+	ch, err := d.characteristic(c, args)
+	if err == nil {
+		descriptors = d.findInformation(c, ch)
+	}
+	d.deliver(descriptorsDiscoverEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID":           c.addr.uuid(),
+		"kCBMsgArgCharacteristicHandle": int64(intArg(args, "kCBMsgArgCharacteristicHandle")),
+		"kCBMsgArgDescriptors":          descriptors,
+		"kCBMsgArgResult":               int64(result(err)),
+	})
+}
+
+func (d *Device) readValue(c *conn, args xpc.Dict) {
+	var value []byte
+	ch, err := d.characteristic(c, args)
+	if err == nil {
+		value, err = c.client.ReadLong(ch.value)
+	}
+	d.deliver(readEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID":           c.addr.uuid(),
+		"kCBMsgArgCharacteristicHandle": int64(intArg(args, "kCBMsgArgCharacteristicHandle")),
+		"kCBMsgArgData":                 append([]byte{}, value...),
+		"kCBMsgArgIsNotification":       int64(0),
+		"kCBMsgArgResult":               int64(result(err)),
+	})
+}
+
+func (d *Device) readDescriptor(c *conn, args xpc.Dict) {
+	handle := intArg(args, "kCBMsgArgDescriptorHandle")
+	value, err := c.client.ReadLong(uint16(handle))
+	d.deliver(descriptorReadEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID":       c.addr.uuid(),
+		"kCBMsgArgDescriptorHandle": int64(handle),
+		"kCBMsgArgData":             append([]byte{}, value...),
+		"kCBMsgArgResult":           int64(result(err)),
+	})
+}
+
+// writeValue writes with a write request, or with prepared writes if the
+// value is too long for one
+func (d *Device) writeValue(c *conn, args xpc.Dict) {
+	ch, err := d.characteristic(c, args)
+	data, _ := args["kCBMsgArgData"].([]byte)
+	if intArg(args, "kCBMsgArgType") != 0 {
+		if err == nil {
+			c.client.WriteCommand(ch.value, data)
+		}
+		return
+	}
+	if err == nil {
+		err = c.client.WriteLong(ch.value, data)
+	}
+	d.deliver(writeEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID":           c.addr.uuid(),
+		"kCBMsgArgCharacteristicHandle": int64(intArg(args, "kCBMsgArgCharacteristicHandle")),
+		"kCBMsgArgResult":               int64(result(err)),
+	})
+}
+
+// notify writes the client characteristic configuration, finding it first
+// if descriptors were not discovered
+func (d *Device) notify(c *conn, args xpc.Dict) {
+	state := intArg(args, "kCBMsgArgState")
+	ch, err := d.characteristic(c, args)
+	if err == nil {
+		if !ch.described {
+			d.findInformation(c, ch)
+		}
+		err = goble.ErrAttributeNotFound
+		if ch.config != 0 {
+			var value uint16
+			if state != 0 {
+				value = uint16(goble.Notifications)
+				if ch.props&byte(goble.Notify) == 0 {
+					value = uint16(goble.Indications)
+				}
+			}
+			err = c.client.Write(ch.config, le16(value))
+		}
+	}
+	if err != nil {
+		state = 0
+	}
+	d.deliver(notifyEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID":           c.addr.uuid(),
+		"kCBMsgArgCharacteristicHandle": int64(intArg(args, "kCBMsgArgCharacteristicHandle")),
+		"kCBMsgArgState":                int64(state),
+		"kCBMsgArgResult":               int64(result(err)),
+	})
+}
+
+// notification delivers a Handle Value Notification or Indication
+func (d *Device) notification(c *conn, handle uint16, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.chars {
+		if ch.value == handle {
+			d.deliver(readEvt, xpc.Dict{
+				"kCBMsgArgDeviceUUID":           c.addr.uuid(),
+				"kCBMsgArgCharacteristicHandle": int64(ch.handle),
+				"kCBMsgArgData":                 append([]byte{}, value...),
+				"kCBMsgArgIsNotification":       int64(1),
+				"kCBMsgArgResult":               int64(0),
+			})
+			return
+		}
+	}
+}
@@ -0,0 +1,315 @@
+// Package hci runs goble on a Bluetooth controller driven directly over HCI,
+// for Linux and other systems without blued.
+//
+// The blued messages sent by goble.BLE are translated to HCI commands and
+// ATT requests, and controller events back to the events goble expects, so
+// the BLE API and event names are the same as on OSX:
+//
+//	dev, err := hci.Open(0) // hci0, must be down
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	ble := dev.BLE()
+//	ble.Init()
+//
+// Any io.ReadWriter carrying H4 framed packets (a packet type byte followed by
+// the packet) can be used with New, e.g. a UART or a virtual controller.
+package hci
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+// H4 packet types
+const (
+	commandPacket = 0x01
+	aclPacket     = 0x02
+	eventPacket   = 0x04
+)
+
+// commands
+const (
+	opDisconnect            = 0x0406
+	opSetEventMask          = 0x0c01
+	opReset                 = 0x0c03
+	opReadBufferSize        = 0x1005
+	opReadBDAddr            = 0x1009
+	opReadRSSI              = 0x1405
+	opLESetEventMask        = 0x2001
+	opLEReadBufferSize      = 0x2002
+	opLESetAdvParameters    = 0x2006
+	opLESetAdvData          = 0x2008
+	opLESetScanResponseData = 0x2009
+	opLESetAdvEnable        = 0x200a
+	opLESetScanParameters   = 0x200b
+	opLESetScanEnable       = 0x200c
+	opLECreateConnection    = 0x200d
+	opLECreateConnCancel    = 0x200e
+)
+
+// events
+const (
+	evtDisconnectionComplete = 0x05
+	evtCommandComplete       = 0x0e
+	evtCommandStatus         = 0x0f
+	evtNumCompletedPackets   = 0x13
+	evtLEMeta                = 0x3e
+
+	leConnectionComplete = 0x01
+	leAdvertisingReport  = 0x02
+)
+
+// time allowed for the controller to answer a command
+const commandTimeout = 2 * time.Second
+
+var ErrTimeout = errors.New("hci: command timeout")
+
+// Status is an HCI error code returned by the controller
+type Status byte
+
+var statusNames = map[Status]string{
+	0x01: "unknown command",
+	0x02: "unknown connection identifier",
+	0x03: "hardware failure",
+	0x05: "authentication failure",
+	0x07: "memory capacity exceeded",
+	0x08: "connection timeout",
+	0x0c: "command disallowed",
+	0x12: "invalid parameters",
+	0x13: "remote user terminated connection",
+	0x16: "connection terminated by local host",
+	0x3e: "connection failed to be established",
+}
+
+func (s Status) Error() string {
+	if name, ok := statusNames[s]; ok {
+		return "hci: " + name
+	}
+	return fmt.Sprintf("hci: status %#02x", byte(s))
+}
+
+// Device is a controller, it implements goble.Transport
+type Device struct {
+	rw  io.ReadWriter
+	ble *goble.BLE
+
+	wmu sync.Mutex // serializes packets
+
+	cmu     sync.Mutex
+	pending map[uint16]chan []byte // command responses by opcode
+
+	mu     sync.Mutex
+	queue  []func()
+	wake   chan struct{}
+	closed bool
+
+	// owned by the run goroutine
+	host
+}
+
+// New drives the controller connected to rw
+func New(rw io.ReadWriter) *Device {
+	d := &Device{
+		rw:      rw,
+		pending: map[uint16]chan []byte{},
+		wake:    make(chan struct{}, 1),
+	}
+	d.host.init()
+	d.ble = goble.NewWithTransport(d, "")
+	go d.run()
+	go d.read()
+	return d
+}
+
+// BLE returns the goble instance using the controller
+func (d *Device) BLE() *goble.BLE {
+	return d.ble
+}
+
+// Close stops the device and closes the underlying connection, if it is an
+// io.Closer
+func (d *Device) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	d.queue = nil
+	d.mu.Unlock()
+	d.signal()
+	if c, ok := d.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// do queues fn on the run goroutine
+func (d *Device) do(fn func()) {
+	d.mu.Lock()
+	if !d.closed {
+		d.queue = append(d.queue, fn)
+	}
+	d.mu.Unlock()
+	d.signal()
+}
+
+func (d *Device) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Device) run() {
+	for range d.wake {
+		for {
+			d.mu.Lock()
+			if d.closed {
+				d.mu.Unlock()
+				return
+			}
+			if len(d.queue) == 0 {
+				d.mu.Unlock()
+				break
+			}
+			fn := d.queue[0]
+			d.queue = d.queue[1:]
+			d.mu.Unlock()
+			fn()
+		}
+	}
+}
+
+// write sends a packet
+func (d *Device) write(typ byte, b []byte) error {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	_, err := d.rw.Write(append([]byte{typ}, b...))
+	return err
+}
+
+// command sends a command and waits for its completion, returning the
+// parameters of Command Complete or the status of Command Status
+func (d *Device) command(op uint16, params []byte) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	d.cmu.Lock()
+	d.pending[op] = ch
+	d.cmu.Unlock()
+	defer func() {
+		d.cmu.Lock()
+		delete(d.pending, op)
+		d.cmu.Unlock()
+	}()
+
+	b := make([]byte, 3, 3+len(params))
+	binary.LittleEndian.PutUint16(b, op)
+	b[2] = byte(len(params))
+	if err := d.write(commandPacket, append(b, params...)); err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-ch:
+		if len(r) > 0 && r[0] != 0 {
+			return r, Status(r[0])
+		}
+		return r, nil
+	case <-time.After(commandTimeout):
+		return nil, ErrTimeout
+	}
+}
+
+// complete delivers the response of a command
+func (d *Device) complete(op uint16, r []byte) {
+	d.cmu.Lock()
+	ch, ok := d.pending[op]
+	d.cmu.Unlock()
+	if ok {
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+}
+
+// split returns the first complete packet of b
+func split(b []byte) (typ byte, pkt, rest []byte, ok bool) {
+	if len(b) == 0 {
+		return 0, nil, b, false
+	}
+	var hdr, n int
+	switch b[0] {
+	case commandPacket:
+		if hdr = 3; len(b) > hdr {
+			n = int(b[3])
+		}
+	case aclPacket:
+		if hdr = 4; len(b) > hdr {
+			n = int(binary.LittleEndian.Uint16(b[3:]))
+		}
+	case eventPacket:
+		if hdr = 2; len(b) > hdr {
+			n = int(b[2])
+		}
+	default:
+		// out of sync, drop everything
+		return 0, nil, nil, false
+	}
+	if len(b) < 1+hdr+n {
+		return 0, nil, b, false
+	}
+	return b[0], b[1 : 1+hdr+n], b[1+hdr+n:], true
+}
+
+// read dispatches packets from the controller
+func (d *Device) read() {
+	buf := make([]byte, 4096)
+	var b []byte
+	for {
+		n, err := d.rw.Read(buf)
+		if err != nil {
+			d.do(func() { d.fail(err) })
+			return
+		}
+		b = append(b, buf[:n]...)
+		for {
+			typ, pkt, rest, ok := split(b)
+			b = rest
+			if !ok {
+				break
+			}
+			d.packet(typ, pkt)
+		}
+	}
+}
+
+func (d *Device) packet(typ byte, pkt []byte) {
+	switch typ {
+	case eventPacket:
+		code, params := pkt[0], pkt[2:]
+		switch {
+		case code == evtCommandComplete && len(params) >= 3:
+			d.complete(binary.LittleEndian.Uint16(params[1:]), params[3:])
+		case code == evtCommandStatus && len(params) >= 4:
+			d.complete(binary.LittleEndian.Uint16(params[2:]), params[:1])
+		default:
+			d.do(func() { d.event(code, params) })
+		}
+	case aclPacket:
+		h := binary.LittleEndian.Uint16(pkt)
+		data := pkt[4:]
+		d.do(func() { d.acl(h&0x0fff, byte(h>>12)&3, data) })
+	}
+}
+
+// fail reports a lost controller
+func (d *Device) fail(err error) {
+	log.Println("hci:", err)
+	d.emit(stateChangeEvt, xpc.Dict{"kCBMsgArgState": int64(poweredOff)})
+}
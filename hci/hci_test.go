@@ -0,0 +1,514 @@
+package hci
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/internal/central"
+	"github.com/dim13/goble/xpc"
+)
+
+// radio links virtual controllers
+type radio struct {
+	mu          sync.Mutex
+	controllers []*controller
+}
+
+// controller is a scripted virtual controller
+type controller struct {
+	radio *radio
+	addr  [6]byte
+	rw    net.Conn
+	wmu   sync.Mutex
+
+	// guarded by radio.mu
+	fail        map[uint16]Status // commands failing with a status
+	refuse      Status            // status of connections to create
+	ops         []uint16          // commands received
+	advertising bool
+	adv, scan   []byte
+	scanning    bool
+	links       map[uint16]*controller // peers by connection handle
+}
+
+func (r *radio) attach(addr byte) (*controller, *Device) {
+	host, ctrl := net.Pipe()
+	c := &controller{
+		radio: r,
+		addr:  [6]byte{addr, 0x22, 0x33, 0x44, 0x55, 0x66},
+		rw:    ctrl,
+		fail:  map[uint16]Status{},
+		links: map[uint16]*controller{},
+	}
+	r.mu.Lock()
+	r.controllers = append(r.controllers, c)
+	r.mu.Unlock()
+	go c.run()
+	return c, New(host)
+}
+
+func (c *controller) send(typ byte, b []byte) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.rw.Write(append([]byte{typ}, b...))
+}
+
+func (c *controller) event(code byte, params ...byte) {
+	c.send(eventPacket, append([]byte{code, byte(len(params))}, params...))
+}
+
+func (c *controller) complete(op uint16, params ...byte) {
+	c.event(evtCommandComplete, append([]byte{1, byte(op), byte(op >> 8)}, params...)...)
+}
+
+func (c *controller) status(op uint16, status Status) {
+	c.event(evtCommandStatus, byte(status), 1, byte(op), byte(op>>8))
+}
+
+// report sends the advertising data of p, radio.mu is held
+func (c *controller) report(p *controller) {
+	for typ, data := range map[byte][]byte{0x00: p.adv, 0x04: p.scan} {
+		params := []byte{leAdvertisingReport, 1, typ, 0}
+		params = append(append(params, p.addr[:]...), byte(len(data)))
+		params = append(append(params, data...), byte(0xc4)) // -60 dBm
+		go c.event(evtLEMeta, params...)
+	}
+}
+
+func (c *controller) run() {
+	buf := make([]byte, 4096)
+	var b []byte
+	for {
+		n, err := c.rw.Read(buf)
+		if err != nil {
+			return
+		}
+		b = append(b, buf[:n]...)
+		for {
+			typ, pkt, rest, ok := split(b)
+			b = rest
+			if !ok {
+				break
+			}
+			if typ == commandPacket {
+				c.command(binary.LittleEndian.Uint16(pkt), pkt[3:])
+			} else if typ == aclPacket {
+				c.acl(pkt)
+			}
+		}
+	}
+}
+
+func (c *controller) command(op uint16, params []byte) {
+	r := c.radio
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c.ops = append(c.ops, op)
+	if s, ok := c.fail[op]; ok {
+		c.complete(op, byte(s))
+		return
+	}
+	switch op {
+	case opLEReadBufferSize:
+		c.complete(op, 0, 27, 0, 4)
+	case opReadBDAddr:
+		c.complete(op, append([]byte{0}, c.addr[:]...)...)
+	case opLESetAdvData:
+		c.adv = append([]byte(nil), params[1:1+params[0]]...)
+		c.complete(op, 0)
+	case opLESetScanResponseData:
+		c.scan = append([]byte(nil), params[1:1+params[0]]...)
+		c.complete(op, 0)
+	case opLESetAdvEnable:
+		c.advertising = params[0] == 1
+		c.complete(op, 0)
+		for _, p := range r.controllers {
+			if c.advertising && p.scanning {
+				p.report(c)
+			}
+		}
+	case opLESetScanEnable:
+		c.scanning = params[0] == 1
+		c.complete(op, 0)
+		for _, p := range r.controllers {
+			if c.scanning && p.advertising {
+				c.report(p)
+			}
+		}
+	case opLECreateConnection:
+		c.status(op, 0)
+		if c.refuse != 0 {
+			ev := []byte{leConnectionComplete, byte(c.refuse), 0, 0, 0, 0}
+			ev = append(append(ev, params[6:12]...), 0, 0, 0, 0, 0, 0, 0)
+			go c.event(evtLEMeta, ev...)
+			return
+		}
+		for _, p := range r.controllers {
+			if p.advertising && bytes.Equal(p.addr[:], params[6:12]) {
+				p.advertising = false
+				const handle = 0x0040
+				c.links[handle], p.links[handle] = p, c
+				for role, ctrl := range []*controller{c, p} {
+					peer := c.addr
+					if role == 0 {
+						peer = p.addr
+					}
+					ev := []byte{leConnectionComplete, 0, byte(handle), 0, byte(role), 0}
+					ev = append(append(ev, peer[:]...), 0x18, 0, 0, 0, 0xf4, 0x01, 0)
+					go ctrl.event(evtLEMeta, ev...)
+				}
+			}
+		}
+	case opDisconnect:
+		c.status(op, 0)
+		handle := binary.LittleEndian.Uint16(params)
+		if p, ok := c.links[handle]; ok {
+			delete(c.links, handle)
+			delete(p.links, handle)
+			go c.event(evtDisconnectionComplete, 0, byte(handle), byte(handle>>8), 0x16)
+			go p.event(evtDisconnectionComplete, 0, byte(handle), byte(handle>>8), params[2])
+		}
+	case opReadRSSI:
+		c.complete(op, 0, params[0], params[1], 0xce) // -50 dBm
+	default:
+		c.complete(op, 0)
+	}
+}
+
+// acl forwards data to the peer and returns the buffer
+func (c *controller) acl(pkt []byte) {
+	handle := binary.LittleEndian.Uint16(pkt) & 0x0fff
+	c.radio.mu.Lock()
+	p, ok := c.links[handle]
+	c.radio.mu.Unlock()
+	if !ok {
+		return
+	}
+	// the peer receives start fragments as flushable
+	fwd := append([]byte(nil), pkt...)
+	if pkt[1]>>4&3 == pbFirst {
+		fwd[1] |= 0x02 << 4
+	}
+	p.send(aclPacket, fwd)
+	c.event(evtNumCompletedPackets, 1, byte(handle), byte(handle>>8), 1, 0)
+}
+
+// listen collects the events of ble
+func listen(ble *goble.BLE) <-chan goble.Event {
+	events := make(chan goble.Event, 64)
+	ble.Listen(func(ev goble.Event) bool {
+		events <- ev
+		return false
+	})
+	return events
+}
+
+func wait(t *testing.T, events <-chan goble.Event, name string) goble.Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Name == name {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %v event", name)
+		}
+	}
+}
+
+var (
+	batteryUUID = goble.UUID16(0x180f)
+	levelUUID   = goble.UUID16(0x2a19)
+	commandUUID = xpc.MustUUID("6e400002b5a3f393e0a9e50e24dcca9e")
+)
+
+func TestCentralPeripheral(t *testing.T) {
+	var r radio
+	_, pdev := r.attach(0x01)
+	defer pdev.Close()
+	_, cdev := r.attach(0x02)
+	defer cdev.Close()
+
+	// peripheral
+	p := pdev.BLE()
+	pevents := listen(p)
+	p.Init()
+	if ev := wait(t, pevents, "stateChange"); ev.State != "poweredOn" {
+		t.Fatalf("got state %v", ev.State)
+	}
+	level := goble.NewCharacteristic(levelUUID, goble.Read|goble.Notify, nil)
+	level.HandleRead(func(central xpc.UUID, offset int) ([]byte, error) {
+		return []byte{77}, nil
+	})
+	written := make(chan []byte, 1)
+	command := goble.NewCharacteristic(commandUUID, goble.Write, nil)
+	command.HandleWrite(func(central xpc.UUID, data []byte, offset int, withoutResponse bool) error {
+		written <- data
+		return nil
+	})
+	p.SetServices([]goble.Service{goble.NewService(batteryUUID, level, command)})
+	p.StartAdvertising("goble", []xpc.UUID{batteryUUID})
+	wait(t, pevents, "advertisingStart")
+
+	// central
+	c := cdev.BLE()
+	c.SetGATTCache(goble.NewGATTCache())
+	cevents := listen(c)
+	c.Init()
+	wait(t, cevents, "stateChange")
+	c.StartScanning([]xpc.UUID{batteryUUID}, false)
+	ev := wait(t, cevents, "discover")
+	if ad := ev.Peripheral.Advertisement; ad.LocalName != "goble" || len(ad.ServiceUuids) != 1 || ad.ServiceUuids[0] != "180f" {
+		t.Fatalf("got advertisement %+v", ad)
+	}
+	if ev.DeviceUUID.String() != "00000000000000000000665544332201" {
+		t.Errorf("got device %v", ev.DeviceUUID)
+	}
+	if ev.Peripheral.Rssi != -60 {
+		t.Errorf("got rssi %v", ev.Peripheral.Rssi)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := central.Dial(ctx, c, ev.DeviceUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := wait(t, cevents, "mtuChange"); ev.Mtu != maxMTU {
+		t.Errorf("got mtu %v", ev.Mtu)
+	}
+
+	s, err := conn.DiscoverService(ctx, batteryUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch, ok := s.Characteristic(levelUUID.String()); !ok || ch.Properties != goble.Read|goble.Notify {
+		t.Fatalf("got characteristic %+v", ch)
+	}
+	data, err := conn.Read(ctx, s, levelUUID)
+	if err != nil || !bytes.Equal(data, []byte{77}) {
+		t.Fatalf("got read %v, %v", data, err)
+	}
+
+	if err := conn.Write(ctx, s, commandUUID, []byte("hello"), false); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-written; string(data) != "hello" {
+		t.Errorf("got write %q", data)
+	}
+	long := bytes.Repeat([]byte("0123456789"), 30)
+	if err := conn.Write(ctx, s, commandUUID, long, false); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-written; !bytes.Equal(data, long) {
+		t.Errorf("got long write of %d bytes", len(data))
+	}
+	if err := conn.Write(ctx, s, levelUUID, []byte{1}, false); err != goble.ErrWriteNotPermitted {
+		t.Errorf("got write error %v", err)
+	}
+
+	notified := make(chan []byte, 1)
+	if err := conn.Subscribe(ctx, s, levelUUID, func(b []byte) { notified <- b }); err != nil {
+		t.Fatal(err)
+	}
+	if ev := wait(t, pevents, "subscribe"); ev.CharacteristicUuid != levelUUID.String() {
+		t.Errorf("got subscribe %v", ev.CharacteristicUuid)
+	}
+	if !p.UpdateValue(levelUUID, []byte{76}) {
+		t.Fatal("no subscriber")
+	}
+	select {
+	case b := <-notified:
+		if !bytes.Equal(b, []byte{76}) {
+			t.Errorf("got notification %v", b)
+		}
+	case <-ctx.Done():
+		t.Fatal("no notification")
+	}
+
+	all, err := conn.DiscoverAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	battery, _ := all.Service(batteryUUID.String())
+	data, err = conn.ReadDescriptor(ctx, battery, levelUUID, goble.ClientConfigurationUUID)
+	if err != nil || !bytes.Equal(data, []byte{1, 0}) {
+		t.Errorf("got descriptor read %v, %v", data, err)
+	}
+
+	c.UpdateRssi(ev.DeviceUUID)
+	if ev := wait(t, cevents, "rssiUpdate"); ev.Peripheral.Rssi != -50 {
+		t.Errorf("got rssi %v", ev.Peripheral.Rssi)
+	}
+
+	conn.Close()
+	wait(t, cevents, "disconnect")
+	wait(t, pevents, "unsubscribe")
+
+	// services restored from the cache are used without discovery
+	p.StartAdvertising("goble", []xpc.UUID{batteryUUID})
+	wait(t, pevents, "advertisingStart")
+	if conn, err = central.Dial(ctx, c, ev.DeviceUUID); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	restored, ok := c.RestoreServices(ev.DeviceUUID)
+	if !ok {
+		t.Fatal("services not cached")
+	}
+	s, _ = restored.Service(batteryUUID.String())
+	if data, err := conn.Read(ctx, s, levelUUID); err != nil || !bytes.Equal(data, []byte{77}) {
+		t.Fatalf("got restored read %v, %v", data, err)
+	}
+	if err := conn.Subscribe(ctx, s, levelUUID, func(b []byte) { notified <- b }); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, pevents, "subscribe")
+
+	// a stale cached characteristic fails instead of leaving goble waiting
+	stale, _ := s.Characteristic(levelUUID.String())
+	stale.Handle = 0xfff0
+	s.Characteristics[stale.Handle] = stale
+	if err := conn.Write(ctx, s, levelUUID, []byte{1}, false); err != goble.ErrInvalidHandle {
+		t.Errorf("got %v, want %v", err, goble.ErrInvalidHandle)
+	}
+}
+
+func TestInitFailure(t *testing.T) {
+	var r radio
+	ctrl, dev := r.attach(0x01)
+	defer dev.Close()
+	ctrl.fail[opReset] = 0x03
+
+	ble := dev.BLE()
+	events := listen(ble)
+	ble.Init()
+	if ev := wait(t, events, "stateChange"); ev.State != "unsupported" {
+		t.Errorf("got state %v", ev.State)
+	}
+}
+
+func TestConnectFailure(t *testing.T) {
+	var r radio
+	_, pdev := r.attach(0x01)
+	defer pdev.Close()
+	cctrl, cdev := r.attach(0x02)
+	defer cdev.Close()
+
+	p := pdev.BLE()
+	pevents := listen(p)
+	p.Init()
+	wait(t, pevents, "stateChange")
+	p.StartAdvertising("goble", nil)
+	wait(t, pevents, "advertisingStart")
+
+	c := cdev.BLE()
+	cevents := listen(c)
+	c.Init()
+	wait(t, cevents, "stateChange")
+	c.StartScanning(nil, false)
+	ev := wait(t, cevents, "discover")
+
+	r.mu.Lock()
+	cctrl.refuse = 0x3e // connection failed to be established
+	r.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := central.Dial(ctx, c, ev.DeviceUUID); err != central.ErrDisconnected {
+		t.Fatalf("got %v, want %v", err, central.ErrDisconnected)
+	}
+	if ev := wait(t, cevents, "disconnect"); ev.Result != 0x3e {
+		t.Errorf("got status %#x", ev.Result)
+	}
+	// scanning resumes once the connect is over
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !cctrl.scanning {
+		t.Error("scanning not resumed")
+	}
+}
+
+func TestAdvertiseTooLong(t *testing.T) {
+	var r radio
+	ctrl, dev := r.attach(0x01)
+	defer dev.Close()
+
+	ble := dev.BLE()
+	events := listen(ble)
+	ble.Init()
+	wait(t, events, "stateChange")
+	dev.Send(xpc.Dict{
+		"kCBMsgId": startAdvertisingMsg,
+		"kCBMsgArgs": xpc.Dict{
+			"kCBAdvDataServiceData": xpc.Array{[]byte{0xfe, 0xaa}, make([]byte, 40)},
+		},
+	}, false)
+	ble.StartAdvertising("goble", nil)
+	ble.StopAdvertising()
+	wait(t, events, "advertisingStop")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	started := 0
+	for _, op := range ctrl.ops {
+		if op == opLESetAdvParameters {
+			started++
+		}
+	}
+	if started != 1 {
+		t.Errorf("advertising started %d times, want once", started)
+	}
+}
+
+func TestFragmentation(t *testing.T) {
+	var r radio
+	_, dev := r.attach(0x01)
+	defer dev.Close()
+	ble := dev.BLE()
+	events := listen(ble)
+	ble.Init()
+	wait(t, events, "stateChange")
+
+	// a long l2cap frame is split in 27 byte ACL packets, sent while the
+	// controller has buffers
+	done := make(chan struct{})
+	dev.do(func() {
+		defer close(done)
+		c := newConn(0x0040, true)
+		dev.conns[c.handle] = c
+		credits := dev.credits
+		dev.sendL2CAP(c, cidATT, make([]byte, 100))
+		if n := len(dev.backlog) + credits - dev.credits; n != 4 {
+			t.Errorf("got %v packets", n)
+		}
+		if dev.inflight[c.handle] != credits {
+			t.Errorf("got %v packets in flight", dev.inflight[c.handle])
+		}
+		dev.completed(c.handle, 4)
+		dev.flush()
+		if len(dev.backlog) != 0 {
+			t.Errorf("got %v packets waiting", len(dev.backlog))
+		}
+	})
+	<-done
+}
+
+func TestSplit(t *testing.T) {
+	b := []byte{eventPacket, 0x0e, 4, 1, 0x03, 0x0c, 0, aclPacket, 0x40, 0x00, 1}
+	typ, pkt, rest, ok := split(b)
+	if !ok || typ != eventPacket || len(pkt) != 6 || len(rest) != 4 {
+		t.Fatalf("got %v %x %x %v", typ, pkt, rest, ok)
+	}
+	if _, _, rest, ok = split(rest); ok || len(rest) != 4 {
+		t.Fatalf("split incomplete packet: %x %v", rest, ok)
+	}
+}
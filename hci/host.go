@@ -0,0 +1,590 @@
+package hci
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/adv"
+	"github.com/dim13/goble/xpc"
+)
+
+// blued messages, as sent by goble to darwin releases before 14
+const (
+	initMsg                    = 1
+	startAdvertisingMsg        = 8
+	stopAdvertisingMsg         = 9
+	setServicesMsg             = 10
+	removeServicesMsg          = 12
+	respondToRequestMsg        = 13
+	updateValueMsg             = 15
+	startScanningMsg           = 29
+	stopScanningMsg            = 30
+	connectMsg                 = 31
+	disconnectMsg              = 32
+	updateRssiMsg              = 43
+	discoverServicesMsg        = 44
+	discoverCharacteristicsMsg = 61
+	readMsg                    = 64
+	writeMsg                   = 65
+	notifyMsg                  = 67
+	discoverDescriptorsMsg     = 69
+	readDescriptorMsg          = 76
+)
+
+// blued events
+const (
+	stateChangeEvt             = 6
+	advertisingStartEvt        = 16
+	advertisingStopEvt         = 17
+	readRequestEvt             = 19
+	writeRequestEvt            = 20
+	subscribeEvt               = 21
+	unsubscribeEvt             = 22
+	readyToUpdateEvt           = 23
+	discoverEvt                = 37
+	connectEvt                 = 38
+	disconnectEvt              = 40
+	mtuChangeEvt               = 53
+	servicesDiscoverEvt        = 54
+	rssiUpdateEvt              = 55
+	characteristicsDiscoverEvt = 63
+	readEvt                    = 70
+	writeEvt                   = 71
+	notifyEvt                  = 73
+	descriptorsDiscoverEvt     = 75
+	descriptorReadEvt          = 79
+)
+
+// adapter states, as in CBManagerState
+const (
+	unsupported = 2
+	poweredOff  = 4
+	poweredOn   = 5
+)
+
+// address is a device address and its type (0 public, 1 random)
+type address struct {
+	typ  byte
+	addr [6]byte // over-the-air (little-endian) order
+}
+
+// uuid stands in for the address in goble, like the identifiers CoreBluetooth
+// assigns: the type in byte 9 and the address in the last 6 bytes
+func (a address) uuid() xpc.UUID {
+	var u xpc.UUID
+	u[9] = a.typ
+	for i, b := range a.addr {
+		u[15-i] = b
+	}
+	return u
+}
+
+func (a address) String() string {
+	b := a.addr
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", b[5], b[4], b[3], b[2], b[1], b[0])
+}
+
+// report is the latest advertising data of a device
+type report struct {
+	connectable bool
+	adv, scan   []byte
+}
+
+// host is the state of the run goroutine
+type host struct {
+	addr     [6]byte
+	aclMTU   int
+	credits  int
+	backlog  [][]byte       // ACL packets waiting for controller buffers
+	inflight map[uint16]int // ACL packets sent by connection handle
+
+	scanning   bool
+	scan       []byte   // scan enable parameters
+	resume     bool     // scanning paused by a connect
+	filter     []string // service uuids to scan for
+	connecting xpc.UUID // device being connected to
+	reports    map[address]*report
+	devices    map[xpc.UUID]address
+	conns      map[uint16]*conn
+
+	server
+}
+
+func (h *host) init() {
+	h.reports = map[address]*report{}
+	h.devices = map[xpc.UUID]address{}
+	h.conns = map[uint16]*conn{}
+	h.inflight = map[uint16]int{}
+	h.server.init()
+}
+
+// Send receives a message from goble (implements goble.Transport)
+func (d *Device) Send(msg interface{}, verbose bool) {
+	if m, ok := msg.(xpc.Dict); ok {
+		d.do(func() { d.handle(m) })
+	}
+}
+
+// emit delivers an event to goble
+func (d *Device) emit(id int, args xpc.Dict) {
+	d.ble.HandleXpcEvent(xpc.Dict{
+		"kCBMsgId":   int64(id),
+		"kCBMsgArgs": args,
+	}, nil)
+}
+
+// intArg returns an integer argument sent by goble
+func intArg(args xpc.Dict, k string) int {
+	switch v := args[k].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	}
+	return 0
+}
+
+// handle translates a message from goble
+func (d *Device) handle(m xpc.Dict) {
+	args, _ := m["kCBMsgArgs"].(xpc.Dict)
+	switch intArg(m, "kCBMsgId") {
+	case initMsg:
+		d.reset()
+	case startScanningMsg:
+		d.startScanning(args)
+	case stopScanningMsg:
+		d.scanning, d.resume = false, false
+		d.command(opLESetScanEnable, []byte{0, 0})
+	case connectMsg:
+		d.connect(args)
+	case disconnectMsg:
+		d.disconnect(args)
+	case updateRssiMsg:
+		if c := d.conn(args); c != nil {
+			if r, err := d.command(opReadRSSI, le16(c.handle)); err == nil && len(r) >= 4 {
+				d.emit(rssiUpdateEvt, xpc.Dict{
+					"kCBMsgArgDeviceUUID": c.addr.uuid(),
+					"kCBMsgArgData":       int64(int8(r[3])),
+				})
+			}
+		}
+	case discoverServicesMsg, discoverCharacteristicsMsg, discoverDescriptorsMsg,
+		readMsg, writeMsg, readDescriptorMsg, notifyMsg:
+		if c := d.conn(args); c != nil {
+			id := intArg(m, "kCBMsgId")
+			c.jobs.put(func() { d.request(c, id, args) })
+		}
+	case startAdvertisingMsg:
+		d.startAdvertising(args)
+	case stopAdvertisingMsg:
+		_, err := d.command(opLESetAdvEnable, []byte{0})
+		d.emit(advertisingStopEvt, xpc.Dict{"kCBMsgArgResult": int64(result(err))})
+	case setServicesMsg:
+		d.services = append(d.services, args)
+		d.changeServices()
+	case removeServicesMsg:
+		d.services = nil
+		d.changeServices()
+	case respondToRequestMsg:
+		d.respond(args)
+	case updateValueMsg:
+		d.updateValue(args)
+	}
+}
+
+// result converts a command or ATT error to a blued result code
+func result(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case Status:
+		return int(e)
+	case goble.ATTError:
+		return int(e)
+	}
+	return int(goble.ErrUnlikely)
+}
+
+func le16(v uint16) []byte {
+	return []byte{byte(v), byte(v >> 8)}
+}
+
+// reset initializes the controller
+func (d *Device) reset() {
+	state := poweredOn
+	if err := d.setup(); err != nil {
+		log.Println("hci: init:", err)
+		state = unsupported
+	}
+	d.emit(stateChangeEvt, xpc.Dict{"kCBMsgArgState": int64(state)})
+}
+
+func (d *Device) setup() error {
+	if _, err := d.command(opReset, nil); err != nil {
+		return err
+	}
+	// default events and LE meta events
+	mask := []byte{0xff, 0xff, 0xfb, 0xff, 0x07, 0xf8, 0xbf, 0x3d}
+	if _, err := d.command(opSetEventMask, mask); err != nil {
+		return err
+	}
+	if _, err := d.command(opLESetEventMask, []byte{0x1f, 0, 0, 0, 0, 0, 0, 0}); err != nil {
+		return err
+	}
+
+	r, err := d.command(opLEReadBufferSize, nil)
+	if err != nil || len(r) < 4 {
+		return fmt.Errorf("read buffer size: %v", err)
+	}
+	d.aclMTU, d.credits = int(binary.LittleEndian.Uint16(r[1:])), int(r[3])
+	if d.aclMTU == 0 {
+		// buffers shared with BR/EDR
+		r, err := d.command(opReadBufferSize, nil)
+		if err != nil || len(r) < 8 {
+			return fmt.Errorf("read buffer size: %v", err)
+		}
+		d.aclMTU, d.credits = int(binary.LittleEndian.Uint16(r[1:])), int(binary.LittleEndian.Uint16(r[4:]))
+	}
+
+	r, err = d.command(opReadBDAddr, nil)
+	if err != nil || len(r) < 7 {
+		return fmt.Errorf("read address: %v", err)
+	}
+	copy(d.addr[:], r[1:])
+	return nil
+}
+
+func (d *Device) startScanning(args xpc.Dict) {
+	d.filter, _ = args["kCBMsgArgUUIDs"].([]string)
+	options, _ := args["kCBMsgArgOptions"].(xpc.Dict)
+	duplicates := intArg(options, "kCBScanOptionAllowDuplicates") != 0
+
+	// active scanning, 10 ms interval and window
+	params := []byte{0x01, 0x10, 0x00, 0x10, 0x00, 0x00, 0x00}
+	if _, err := d.command(opLESetScanParameters, params); err != nil {
+		log.Println("hci: scan parameters:", err)
+		return
+	}
+	filter := byte(1)
+	if duplicates {
+		filter = 0
+	}
+	d.scan = []byte{1, filter}
+	if _, err := d.command(opLESetScanEnable, d.scan); err != nil {
+		log.Println("hci: scan enable:", err)
+		return
+	}
+	d.scanning = true
+}
+
+// resumeScanning enables scanning paused by a connect
+func (d *Device) resumeScanning() {
+	if !d.resume {
+		return
+	}
+	d.resume = false
+	if _, err := d.command(opLESetScanEnable, d.scan); err != nil {
+		log.Println("hci: scan enable:", err)
+		return
+	}
+	d.scanning = true
+}
+
+// advertisingReport handles LE Advertising Report events
+func (d *Device) advertisingReport(b []byte) {
+	if len(b) < 1 {
+		return
+	}
+	n, b := int(b[0]), b[1:]
+	for i := 0; i < n && len(b) >= 9; i++ {
+		typ := b[0]
+		var a address
+		a.typ = b[1]
+		copy(a.addr[:], b[2:8])
+		l := int(b[8])
+		if len(b) < 10+l {
+			return
+		}
+		data := append([]byte(nil), b[9:9+l]...)
+		rssi := int8(b[9+l])
+		b = b[10+l:]
+
+		r, ok := d.reports[a]
+		if !ok {
+			r = &report{}
+			d.reports[a] = r
+		}
+		switch typ {
+		case 0x00, 0x01: // ADV_IND, ADV_DIRECT_IND
+			r.connectable, r.adv = true, data
+		case 0x02, 0x03: // ADV_SCAN_IND, ADV_NONCONN_IND
+			r.connectable, r.adv = false, data
+		case 0x04: // SCAN_RSP
+			r.scan = data
+		}
+		d.devices[a.uuid()] = a
+		if d.scanning {
+			d.discover(a, r, int(rssi))
+		}
+	}
+}
+
+// discover emits a discover event with the advertising data of a device
+func (d *Device) discover(a address, r *report, rssi int) {
+	p, _ := adv.Parse(r.adv)
+	if s, err := adv.Parse(r.scan); err == nil {
+		p = append(p, s...)
+	}
+
+	data := xpc.Dict{"kCBAdvDataIsConnectable": int64(0)}
+	if r.connectable {
+		data["kCBAdvDataIsConnectable"] = int64(1)
+	}
+	if name, _ := p.LocalName(); name != "" {
+		data["kCBAdvDataLocalName"] = name
+	}
+	if tx, ok := p.TxPower(); ok {
+		data["kCBAdvDataTxPowerLevel"] = int64(tx)
+	}
+	if md, ok := p.Get(adv.TypeManufacturerData); ok {
+		data["kCBAdvDataManufacturerData"] = md
+	}
+	uuids, _ := p.UUIDs()
+	if len(uuids) > 0 {
+		list := xpc.Array{}
+		for _, u := range uuids {
+			list = append(list, []byte(u))
+		}
+		data["kCBAdvDataServiceUUIDs"] = list
+	}
+	if sd := p.ServiceData(); len(sd) > 0 {
+		list := xpc.Array{}
+		for _, s := range sd {
+			list = append(list, []byte(s.UUID), s.Data)
+		}
+		data["kCBAdvDataServiceData"] = list
+	}
+
+	if len(d.filter) > 0 {
+		found := false
+		for _, u := range uuids {
+			for _, f := range d.filter {
+				found = found || goble.EqualUUID(u.String(), f)
+			}
+		}
+		if !found {
+			return
+		}
+	}
+
+	d.emit(discoverEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID":        a.uuid(),
+		"kCBMsgArgRssi":              int64(rssi),
+		"kCBMsgArgAdvertisementData": data,
+	})
+}
+
+func (d *Device) startAdvertising(args xpc.Dict) {
+	a := goble.NewAdvertisingData()
+	if name, ok := args["kCBAdvDataLocalName"].(string); ok {
+		a.SetLocalName(name)
+	}
+	if uuids, ok := args["kCBAdvDataServiceUUIDs"].([][]byte); ok {
+		for _, u := range uuids {
+			a.AddServiceUUID(uuidOf(u))
+		}
+	}
+	if sd, ok := args["kCBAdvDataServiceData"].(xpc.Array); ok {
+		for i := 0; i+1 < len(sd); i += 2 {
+			u, _ := sd[i].([]byte)
+			data, _ := sd[i+1].([]byte)
+			a.AddServiceData(uuidOf(u), data)
+		}
+	}
+	if b, ok := args["kCBAdvDataAppleMfgData"].([]byte); ok {
+		p, _ := adv.Parse(b)
+		for _, md := range p.ManufacturerData() {
+			a.SetManufacturerData(md.CompanyID, md.Data)
+		}
+	}
+	if b, ok := args["kCBAdvDataAppleBeaconKey"].([]byte); ok {
+		a.SetManufacturerData(0x004c, append([]byte{0x02, byte(len(b))}, b...))
+	}
+	if _, ok := args["kCBAdvDataTxPowerLevel"]; ok {
+		a.SetTxPower(int8(intArg(args, "kCBAdvDataTxPowerLevel")))
+	}
+
+	err := d.advertise(a)
+	d.emit(advertisingStartEvt, xpc.Dict{"kCBMsgArgResult": int64(result(err))})
+}
+
+func (d *Device) advertise(a *goble.AdvertisingData) error {
+	payload, err := a.Build()
+	if err != nil {
+		return err
+	}
+	// connectable undirected, 100 ms interval, all channels
+	params := []byte{0xa0, 0x00, 0xa0, 0x00, 0x00, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0x07, 0x00}
+	if _, err := d.command(opLESetAdvParameters, params); err != nil {
+		return err
+	}
+	for op, data := range map[uint16][]byte{
+		opLESetAdvData:          payload.Advertising,
+		opLESetScanResponseData: payload.ScanResponse,
+	} {
+		b := make([]byte, 1+adv.MaxLength)
+		b[0] = byte(copy(b[1:], data))
+		if _, err := d.command(op, b); err != nil {
+			return err
+		}
+	}
+	_, err = d.command(opLESetAdvEnable, []byte{1})
+	return err
+}
+
+// uuidOf converts uuid bytes sent by goble
+func uuidOf(b []byte) xpc.UUID {
+	if len(b) == 2 {
+		return goble.UUID16(binary.BigEndian.Uint16(b))
+	}
+	return xpc.NewUUID(b)
+}
+
+// conn returns the connection to the device addressed by a message
+func (d *Device) conn(args xpc.Dict) *conn {
+	uuid, _ := args["kCBMsgArgDeviceUUID"].(xpc.UUID)
+	for _, c := range d.conns {
+		if c.addr.uuid() == uuid {
+			return c
+		}
+	}
+	return nil
+}
+
+func (d *Device) connect(args xpc.Dict) {
+	uuid, _ := args["kCBMsgArgDeviceUUID"].(xpc.UUID)
+	a, ok := d.devices[uuid]
+	if !ok {
+		log.Println("hci: unknown device", uuid)
+		return
+	}
+	if d.scanning {
+		// most controllers can't scan and initiate at once, scanning
+		// resumes when the connection is complete
+		d.command(opLESetScanEnable, []byte{0, 0})
+		d.scanning, d.resume = false, true
+	}
+	d.connecting = uuid
+
+	// 60 ms scan interval, 30 ms window, 30-50 ms connection interval,
+	// 5 s supervision timeout
+	params := []byte{0x60, 0x00, 0x30, 0x00, 0x00, a.typ}
+	params = append(params, a.addr[:]...)
+	params = append(params, 0x00, 0x18, 0x00, 0x28, 0x00, 0x00, 0x00, 0xf4, 0x01, 0x00, 0x00, 0x00, 0x00)
+	if _, err := d.command(opLECreateConnection, params); err != nil {
+		log.Println("hci: connect:", err)
+		d.connectFailed(result(err))
+		d.resumeScanning()
+	}
+}
+
+// connectFailed tells goble that the pending connect failed, which it
+// sees as a disconnect
+func (d *Device) connectFailed(status int) {
+	if d.connecting == (xpc.UUID{}) {
+		return
+	}
+	d.emit(disconnectEvt, xpc.Dict{
+		"kCBMsgArgDeviceUUID": d.connecting,
+		"kCBMsgArgResult":     int64(status),
+	})
+	d.connecting = xpc.UUID{}
+}
+
+func (d *Device) disconnect(args xpc.Dict) {
+	c := d.conn(args)
+	if c == nil {
+		// still connecting
+		d.command(opLECreateConnCancel, nil)
+		return
+	}
+	if _, err := d.command(opDisconnect, append(le16(c.handle), 0x13)); err != nil {
+		log.Println("hci: disconnect:", err)
+	}
+}
+
+// event handles controller events
+func (d *Device) event(code byte, params []byte) {
+	switch code {
+	case evtDisconnectionComplete:
+		if len(params) >= 4 && params[0] == 0 {
+			d.disconnected(binary.LittleEndian.Uint16(params[1:]) & 0x0fff)
+		}
+	case evtNumCompletedPackets:
+		if len(params) >= 1 {
+			for i, b := 0, params[1:]; i < int(params[0]) && len(b) >= 4; i, b = i+1, b[4:] {
+				d.completed(binary.LittleEndian.Uint16(b)&0x0fff, int(binary.LittleEndian.Uint16(b[2:])))
+			}
+			d.flush()
+		}
+	case evtLEMeta:
+		if len(params) < 1 {
+			return
+		}
+		switch params[0] {
+		case leConnectionComplete:
+			d.connected(params[1:])
+		case leAdvertisingReport:
+			d.advertisingReport(params[1:])
+		}
+	}
+}
+
+// connected handles LE Connection Complete events
+func (d *Device) connected(b []byte) {
+	if len(b) < 11 {
+		return
+	}
+	central := b[3] == 0
+	if central {
+		d.resumeScanning()
+	}
+	if b[0] != 0 {
+		// failed or cancelled
+		if central {
+			d.connectFailed(int(b[0]))
+		}
+		return
+	}
+	c := newConn(binary.LittleEndian.Uint16(b[1:])&0x0fff, central)
+	c.addr.typ = b[4]
+	copy(c.addr.addr[:], b[5:11])
+	d.conns[c.handle] = c
+	d.devices[c.addr.uuid()] = c.addr
+	d.open(c)
+
+	if c.central {
+		d.connecting = xpc.UUID{}
+		d.emit(connectEvt, xpc.Dict{"kCBMsgArgDeviceUUID": c.addr.uuid()})
+		c.jobs.put(func() { d.exchangeMTU(c) })
+	}
+}
+
+// disconnected handles Disconnection Complete events
+func (d *Device) disconnected(handle uint16) {
+	c, ok := d.conns[handle]
+	if !ok {
+		return
+	}
+	delete(d.conns, handle)
+	d.drop(handle)
+	c.close()
+	d.cancel(c)
+	if c.central {
+		d.emit(disconnectEvt, xpc.Dict{"kCBMsgArgDeviceUUID": c.addr.uuid()})
+		return
+	}
+	d.unsubscribeAll(c)
+}
@@ -0,0 +1,240 @@
+package hci
+
+import (
+	"encoding/binary"
+	"log"
+	"sync"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/att"
+)
+
+// L2CAP channels of LE links
+const (
+	cidATT       = 0x0004
+	cidSignaling = 0x0005
+)
+
+// ACL packet boundary flags
+const (
+	pbFirst      = 0x00 // first fragment, host to controller
+	pbContinuing = 0x01
+)
+
+// signaling command rejected as not understood
+const signalingReject = 0x01
+
+// conn is an LE link
+type conn struct {
+	handle  uint16
+	addr    address
+	central bool // we are the central
+	rx      []byte
+
+	// ATT client and server, PDUs are passed by the run goroutine
+	client             *att.Client
+	server             *att.Server
+	clientRx, serverRx *queue
+	jobs               *queue // blocking ATT operations, run in order
+
+	mu    sync.Mutex
+	chars map[uint16]*characteristic // by declaration handle
+	ids   []int                      // goble attribute ids of served values
+}
+
+func newConn(handle uint16, central bool) *conn {
+	return &conn{
+		handle:  handle,
+		central: central,
+		chars:   map[uint16]*characteristic{},
+	}
+}
+
+// open starts the ATT client and server of a link
+func (d *Device) open(c *conn) {
+	c.clientRx, c.serverRx, c.jobs = newQueue(), newQueue(), newQueue()
+	c.client = att.NewClient(&bearer{d: d, c: c, rx: c.clientRx})
+	c.client.HandleNotification(func(handle uint16, value []byte, indication bool) {
+		d.notification(c, handle, value)
+	})
+
+	c.ids = d.ids()
+	s, err := att.NewServer(&bearer{d: d, c: c, rx: c.serverRx}, c.addr.uuid(), d.gattServices(c)...)
+	if err != nil {
+		log.Println("hci: services:", err)
+		c.ids = nil
+		s, _ = att.NewServer(&bearer{d: d, c: c, rx: c.serverRx}, c.addr.uuid())
+	}
+	c.server = s
+	c.server.HandleSubscription(func(handle uint16, config goble.ClientConfiguration) {
+		d.subscription(c, handle, config)
+	})
+	go func() {
+		if err := c.server.Serve(); err != nil {
+			log.Println("hci:", err)
+		}
+	}()
+
+	go func() {
+		for {
+			job, ok := c.jobs.get()
+			if !ok {
+				return
+			}
+			job.(func())()
+		}
+	}()
+}
+
+// close ends the ATT client, server and operations of a link
+func (c *conn) close() {
+	c.jobs.close()
+	c.clientRx.close()
+	c.serverRx.close()
+}
+
+func (c *conn) setIDs(ids []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids = ids
+}
+
+// values returns the served value handles with goble attribute ids, they
+// follow those of the Generic Attribute service added by the server
+func (c *conn) values() []uint16 {
+	c.mu.Lock()
+	n := len(c.ids)
+	c.mu.Unlock()
+	var handles []uint16
+	for _, a := range c.server.Database().Attributes() {
+		if a.Characteristic != nil && a.Handle == a.ValueHandle {
+			handles = append(handles, a.Handle)
+		}
+	}
+	if len(handles) < n {
+		return nil
+	}
+	return handles[len(handles)-n:]
+}
+
+// attributeID returns the goble attribute id of a served value handle
+func (c *conn) attributeID(handle uint16) (int, bool) {
+	values := c.values()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, h := range values {
+		if h == handle && i < len(c.ids) {
+			return c.ids[i], true
+		}
+	}
+	return 0, false
+}
+
+// valueHandle returns the served value handle of a goble attribute id
+func (c *conn) valueHandle(id int) (uint16, bool) {
+	values := c.values()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, v := range c.ids {
+		if v == id && i < len(values) {
+			return values[i], true
+		}
+	}
+	return 0, false
+}
+
+// acl reassembles L2CAP frames
+func (d *Device) acl(handle uint16, pb byte, data []byte) {
+	c, ok := d.conns[handle]
+	if !ok {
+		return
+	}
+	if pb == pbContinuing {
+		c.rx = append(c.rx, data...)
+	} else {
+		c.rx = append([]byte(nil), data...)
+	}
+	if len(c.rx) < 4 {
+		return
+	}
+	n := int(binary.LittleEndian.Uint16(c.rx))
+	if len(c.rx) < 4+n {
+		return
+	}
+	cid, payload := binary.LittleEndian.Uint16(c.rx[2:]), c.rx[4:4+n]
+	c.rx = nil
+
+	switch cid {
+	case cidATT:
+		if len(payload) > 0 {
+			d.att(c, payload)
+		}
+	case cidSignaling:
+		// requests are even, reject them all
+		if len(payload) >= 4 && payload[0]&1 == 0 {
+			d.sendL2CAP(c, cidSignaling, []byte{signalingReject, payload[1], 2, 0, 0, 0})
+		}
+	}
+}
+
+// sendL2CAP sends a frame in ACL fragments the controller can take
+func (d *Device) sendL2CAP(c *conn, cid uint16, payload []byte) {
+	frame := make([]byte, 4, 4+len(payload))
+	binary.LittleEndian.PutUint16(frame, uint16(len(payload)))
+	binary.LittleEndian.PutUint16(frame[2:], cid)
+	frame = append(frame, payload...)
+
+	size := d.aclMTU
+	if size <= 0 {
+		size = 27
+	}
+	pb := uint16(pbFirst)
+	for len(frame) > 0 {
+		n := len(frame)
+		if n > size {
+			n = size
+		}
+		pkt := make([]byte, 4, 4+n)
+		binary.LittleEndian.PutUint16(pkt, c.handle|pb<<12)
+		binary.LittleEndian.PutUint16(pkt[2:], uint16(n))
+		d.backlog = append(d.backlog, append(pkt, frame[:n]...))
+		frame, pb = frame[n:], pbContinuing
+	}
+	d.flush()
+}
+
+// flush sends ACL packets while the controller has buffers
+func (d *Device) flush() {
+	for len(d.backlog) > 0 && d.credits > 0 {
+		pkt := d.backlog[0]
+		if err := d.write(aclPacket, pkt); err != nil {
+			log.Println("hci:", err)
+		}
+		d.backlog = d.backlog[1:]
+		d.credits--
+		d.inflight[binary.LittleEndian.Uint16(pkt)&0x0fff]++
+	}
+}
+
+// completed returns controller buffers of sent packets
+func (d *Device) completed(handle uint16, n int) {
+	if n > d.inflight[handle] {
+		n = d.inflight[handle]
+	}
+	d.inflight[handle] -= n
+	d.credits += n
+}
+
+// drop discards the packets of a closed link, the controller frees the
+// buffers of those in flight
+func (d *Device) drop(handle uint16) {
+	backlog := d.backlog[:0]
+	for _, pkt := range d.backlog {
+		if binary.LittleEndian.Uint16(pkt)&0x0fff != handle {
+			backlog = append(backlog, pkt)
+		}
+	}
+	d.backlog = backlog
+	d.credits += d.inflight[handle]
+	delete(d.inflight, handle)
+}
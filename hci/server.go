@@ -0,0 +1,282 @@
+package hci
+
+import (
+	"log"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+// a request forwarded to goble and not answered within 30 seconds fails
+const transactionTimeout = 30 * time.Second
+
+// transaction is a request forwarded to goble
+type transaction struct {
+	conn *conn
+	done chan xpc.Dict // respondToRequest arguments, closed on disconnect
+}
+
+// server is the local GATT database
+type server struct {
+	services     []xpc.Dict // arguments of setServices
+	changing     bool       // services are applied to the links
+	transactions map[int]*transaction
+	transaction  int
+}
+
+func (s *server) init() {
+	s.services = nil
+	s.transactions = map[int]*transaction{}
+}
+
+// properties converts the characteristic properties sent by goble, which
+// mark notifications and indications requiring encryption apart
+func properties(p int) goble.Property {
+	props := goble.Property(p & 0xff)
+	if p&0x100 != 0 {
+		props |= goble.Notify
+	}
+	if p&0x200 != 0 {
+		props |= goble.Indicate
+	}
+	return props
+}
+
+// uuidArg returns a uuid string sent by goble
+func uuidArg(args xpc.Dict, k string) xpc.UUID {
+	s, _ := args[k].(string)
+	return xpc.MakeUUID(s)
+}
+
+// ids returns the goble attribute ids of the characteristic values, in
+// database order
+func (s *server) ids() []int {
+	var ids []int
+	for _, svc := range s.services {
+		characteristics, _ := svc["kCBMsgArgCharacteristics"].(xpc.Array)
+		for _, v := range characteristics {
+			if c, ok := v.(xpc.Dict); ok {
+				ids = append(ids, intArg(c, "kCBMsgArgAttributeID"))
+			}
+		}
+	}
+	return ids
+}
+
+// gattServices builds the services set by goble for a link, reads and
+// writes of values without static data are forwarded to goble. Included
+// services are those set so far.
+func (d *Device) gattServices(c *conn) []goble.Service {
+	uuids := map[int]xpc.UUID{}
+	for _, svc := range d.services {
+		uuids[intArg(svc, "kCBMsgArgAttributeID")] = uuidArg(svc, "kCBMsgArgUUID")
+	}
+
+	var services []goble.Service
+	for _, svc := range d.services {
+		var characteristics []goble.Characteristic
+		list, _ := svc["kCBMsgArgCharacteristics"].(xpc.Array)
+		for _, v := range list {
+			if args, ok := v.(xpc.Dict); ok {
+				characteristics = append(characteristics, d.gattCharacteristic(c, args))
+			}
+		}
+		uuid := uuidArg(svc, "kCBMsgArgUUID")
+		s := goble.NewService(uuid, characteristics...)
+		if intArg(svc, "kCBMsgArgType") == 0 {
+			s = goble.NewSecondaryService(uuid, characteristics...)
+		}
+		includes, _ := svc["kCBMsgArgAttributeIDs"].([]int)
+		for _, id := range includes {
+			if uuid, ok := uuids[id]; ok {
+				s.Include(uuid)
+			}
+		}
+		services = append(services, s)
+	}
+	return services
+}
+
+// gattCharacteristic builds a characteristic set by goble
+func (d *Device) gattCharacteristic(c *conn, args xpc.Dict) goble.Characteristic {
+	id := intArg(args, "kCBMsgArgAttributeID")
+	data, _ := args["kCBMsgArgData"].([]byte)
+	ch := goble.NewCharacteristic(uuidArg(args, "kCBMsgArgUUID"), properties(intArg(args, "kCBMsgArgCharacteristicProperties")), data)
+	if data == nil {
+		ch.HandleRead(func(central xpc.UUID, offset int) ([]byte, error) {
+			return d.forward(c, readRequestEvt, xpc.Dict{
+				"kCBMsgArgAttributeID": int64(id),
+				"kCBMsgArgOffset":      int64(offset),
+				"kCBMsgArgCentralUUID": central,
+				"kCBMsgArgATTMTU":      int64(c.server.MTU()),
+			})
+		})
+	}
+	ch.HandleWrite(func(central xpc.UUID, data []byte, offset int, withoutResponse bool) error {
+		ignore := int64(0)
+		if withoutResponse {
+			ignore = 1
+		}
+		args := xpc.Dict{
+			"kCBMsgArgCentralUUID": central,
+			"kCBMsgArgATTWrites": xpc.Array{xpc.Dict{
+				"kCBMsgArgAttributeID":    int64(id),
+				"kCBMsgArgData":           data,
+				"kCBMsgArgIgnoreResponse": ignore,
+				"kCBMsgArgOffset":         int64(offset),
+			}},
+		}
+		if withoutResponse {
+			args["kCBMsgArgTransactionID"] = int64(0)
+			d.deliver(writeRequestEvt, args)
+			return nil
+		}
+		_, err := d.forward(c, writeRequestEvt, args)
+		return err
+	})
+	descriptors, _ := args["kCBMsgArgDescriptors"].(xpc.Array)
+	for _, v := range descriptors {
+		if desc, ok := v.(xpc.Dict); ok {
+			value, _ := desc["kCBMsgArgData"].([]byte)
+			ch.AddDescriptor(uuidArg(desc, "kCBMsgArgUUID"), append([]byte{}, value...))
+		}
+	}
+	return ch
+}
+
+// services sent by goble one by one are applied to the links together
+const settle = 10 * time.Millisecond
+
+// changeServices applies the services to the links once goble has removed
+// and set them all
+func (d *Device) changeServices() {
+	if d.changing {
+		return
+	}
+	d.changing = true
+	time.AfterFunc(settle, func() {
+		d.do(func() {
+			d.changing = false
+			for _, c := range d.conns {
+				d.applyServices(c)
+			}
+		})
+	})
+}
+
+// applyServices replaces the services of a link, a client subscribed to
+// Service Changed is indicated the changed range
+func (d *Device) applyServices(c *conn) {
+	services, ids := d.gattServices(c), d.ids()
+	c.jobs.put(func() {
+		c.setIDs(ids)
+		if err := c.server.SetServices(services...); err != nil {
+			log.Println("hci: services:", err)
+		}
+	})
+}
+
+// forward passes a request to goble and waits for respondToRequest
+func (d *Device) forward(c *conn, id int, args xpc.Dict) ([]byte, error) {
+	done := make(chan xpc.Dict, 1)
+	d.do(func() {
+		if d.conns[c.handle] != c {
+			close(done)
+			return
+		}
+		d.transaction++
+		d.transactions[d.transaction] = &transaction{conn: c, done: done}
+		args["kCBMsgArgTransactionID"] = int64(d.transaction)
+		d.emit(id, args)
+	})
+	select {
+	case r, ok := <-done:
+		if !ok {
+			return nil, goble.ErrUnlikely
+		}
+		if res := intArg(r, "kCBMsgArgResult"); res != 0 {
+			return nil, goble.ATTError(res)
+		}
+		data, _ := r["kCBMsgArgData"].([]byte)
+		return data, nil
+	case <-time.After(transactionTimeout):
+		return nil, goble.ErrUnlikely
+	}
+}
+
+// respond completes a forwarded request
+func (d *Device) respond(args xpc.Dict) {
+	id := intArg(args, "kCBMsgArgTransactionID")
+	if t, ok := d.transactions[id]; ok {
+		delete(d.transactions, id)
+		t.done <- args
+	}
+}
+
+// cancel fails the forwarded requests of a closed link
+func (d *Device) cancel(c *conn) {
+	for id, t := range d.transactions {
+		if t.conn == c {
+			delete(d.transactions, id)
+			close(t.done)
+		}
+	}
+}
+
+// subscription tells goble about a changed client configuration
+func (d *Device) subscription(c *conn, handle uint16, config goble.ClientConfiguration) {
+	id, ok := c.attributeID(handle)
+	if !ok {
+		return
+	}
+	if config == 0 {
+		d.deliver(unsubscribeEvt, xpc.Dict{
+			"kCBMsgArgAttributeID": int64(id),
+			"kCBMsgArgCentralUUID": c.addr.uuid(),
+		})
+		return
+	}
+	d.deliver(subscribeEvt, xpc.Dict{
+		"kCBMsgArgAttributeID": int64(id),
+		"kCBMsgArgCentralUUID": c.addr.uuid(),
+		"kCBMsgArgATTMTU":      int64(c.server.MTU()),
+	})
+}
+
+// unsubscribeAll drops the subscriptions of a disconnected central
+func (d *Device) unsubscribeAll(c *conn) {
+	for _, h := range c.values() {
+		if c.server.Subscription(h) != 0 {
+			id, _ := c.attributeID(h)
+			d.emit(unsubscribeEvt, xpc.Dict{
+				"kCBMsgArgAttributeID": int64(id),
+				"kCBMsgArgCentralUUID": c.addr.uuid(),
+			})
+		}
+	}
+}
+
+// updateValue notifies or indicates subscribed centrals, the notifications
+// are queued at once so the device is ready for the next update
+func (d *Device) updateValue(args xpc.Dict) {
+	id := intArg(args, "kCBMsgArgAttributeID")
+	data, _ := args["kCBMsgArgData"].([]byte)
+	known := false
+	for _, v := range d.ids() {
+		known = known || v == id
+	}
+	if !known {
+		log.Println("hci: unknown attribute", id)
+		return
+	}
+	for _, c := range d.conns {
+		c := c
+		c.jobs.put(func() {
+			if h, ok := c.valueHandle(id); ok && c.server.Subscription(h) != 0 {
+				c.server.Notify(h, data)
+			}
+		})
+	}
+	d.emit(readyToUpdateEvt, xpc.Dict{})
+}
@@ -0,0 +1,46 @@
+package hci
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	btprotoHCI     = 1
+	hciChannelUser = 1
+)
+
+// struct sockaddr_hci
+type sockaddrHCI struct {
+	family  uint16
+	dev     uint16
+	channel uint16
+}
+
+// Open takes exclusive control of the controller hciN over an HCI user
+// channel socket. The device must be down (hciconfig hciN down) and the
+// process needs CAP_NET_ADMIN.
+func Open(index int) (*Device, error) {
+	fd, err := syscall.Socket(syscall.AF_BLUETOOTH, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, btprotoHCI)
+	if err != nil {
+		return nil, fmt.Errorf("hci: socket: %v", err)
+	}
+	addr := sockaddrHCI{
+		family:  syscall.AF_BLUETOOTH,
+		dev:     uint16(index),
+		channel: hciChannelUser,
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_BIND, uintptr(fd), uintptr(unsafe.Pointer(&addr)), unsafe.Sizeof(addr))
+	if errno != 0 {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("hci: bind hci%d: %v", index, errno)
+	}
+	// non-blocking, so Close interrupts pending reads
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("hci: %v", err)
+	}
+	return New(os.NewFile(uintptr(fd), fmt.Sprintf("hci%d", index))), nil
+}
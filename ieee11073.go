@@ -0,0 +1,119 @@
+package goble
+
+import (
+	"math"
+	"strconv"
+)
+
+// IEEE-11073 20601 16-bit SFLOAT: 4-bit exponent, 12-bit mantissa
+type SFloat uint16
+
+// special SFLOAT values
+const (
+	SFloatNaN      SFloat = 0x07ff
+	SFloatNRes     SFloat = 0x0800 // not at this resolution
+	SFloatInf      SFloat = 0x07fe
+	SFloatNegInf   SFloat = 0x0802
+	sfloatReserved SFloat = 0x0801
+)
+
+// NewSFloat encodes mantissa * 10^exponent, mantissa must be in [-2045, 2045]
+// and exponent in [-8, 7]
+func NewSFloat(mantissa int16, exponent int8) SFloat {
+	return SFloat(uint16(exponent)<<12 | uint16(mantissa)&0x0fff)
+}
+
+// Mantissa returns the signed 12-bit mantissa
+func (f SFloat) Mantissa() int16 {
+	return int16(f<<4) >> 4
+}
+
+// Exponent returns the signed 4-bit exponent
+func (f SFloat) Exponent() int8 {
+	return int8(f>>8) >> 4
+}
+
+// Float64 returns the value, NaN for NaN, NRes and reserved values
+func (f SFloat) Float64() float64 {
+	switch f & 0x0fff {
+	case SFloatNaN, SFloatNRes, sfloatReserved:
+		return math.NaN()
+	case SFloatInf:
+		return math.Inf(1)
+	case SFloatNegInf:
+		return math.Inf(-1)
+	}
+	return scale(float64(f.Mantissa()), f.Exponent())
+}
+
+func (f SFloat) String() string {
+	return special11073(f.Float64(), f&0x0fff == SFloatNRes)
+}
+
+// IEEE-11073 20601 32-bit FLOAT: 8-bit exponent, 24-bit mantissa
+type Float uint32
+
+// special FLOAT values
+const (
+	FloatNaN      Float = 0x007fffff
+	FloatNRes     Float = 0x00800000 // not at this resolution
+	FloatInf      Float = 0x007ffffe
+	FloatNegInf   Float = 0x00800002
+	floatReserved Float = 0x00800001
+)
+
+// NewFloat encodes mantissa * 10^exponent, mantissa must be in
+// [-8388605, 8388605]
+func NewFloat(mantissa int32, exponent int8) Float {
+	return Float(uint32(uint8(exponent))<<24 | uint32(mantissa)&0x00ffffff)
+}
+
+// Mantissa returns the signed 24-bit mantissa
+func (f Float) Mantissa() int32 {
+	return int32(f<<8) >> 8
+}
+
+// Exponent returns the signed 8-bit exponent
+func (f Float) Exponent() int8 {
+	return int8(f >> 24)
+}
+
+// Float64 returns the value, NaN for NaN, NRes and reserved values
+func (f Float) Float64() float64 {
+	switch f & 0x00ffffff {
+	case FloatNaN, FloatNRes, floatReserved:
+		return math.NaN()
+	case FloatInf:
+		return math.Inf(1)
+	case FloatNegInf:
+		return math.Inf(-1)
+	}
+	return scale(float64(f.Mantissa()), f.Exponent())
+}
+
+func (f Float) String() string {
+	return special11073(f.Float64(), f&0x00ffffff == FloatNRes)
+}
+
+// scale returns v * 10^exponent, dividing for negative exponents to keep
+// decimal fractions exact where possible
+func scale(v float64, exponent int8) float64 {
+	if exponent < 0 {
+		return v / math.Pow10(-int(exponent))
+	}
+	return v * math.Pow10(int(exponent))
+}
+
+func special11073(v float64, nres bool) string {
+	switch {
+	case nres:
+		return "NRes"
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+INFINITY"
+	case math.IsInf(v, -1):
+		return "-INFINITY"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,63 @@
+package goble
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSFloat(t *testing.T) {
+	testCases := []struct {
+		f    SFloat
+		want string
+	}{
+		{0x0000, "0"},
+		{0xf171, "36.9"}, // 369 * 10^-1
+		{0x0fff, "-1"},
+		{0x2001, "100"},
+		{0xe7ff, "NaN"},
+		{0x0800, "NRes"},
+		{0x07fe, "+INFINITY"},
+		{0x0802, "-INFINITY"},
+		{0x0801, "NaN"}, // reserved
+		{NewSFloat(-2045, -8), "-2.045e-05"},
+	}
+	for _, tc := range testCases {
+		if got := tc.f.String(); got != tc.want {
+			t.Errorf("%04x: got %v, want %v", uint16(tc.f), got, tc.want)
+		}
+	}
+}
+
+func TestNewSFloat(t *testing.T) {
+	f := NewSFloat(-125, -2)
+	if f.Mantissa() != -125 || f.Exponent() != -2 || f.Float64() != -1.25 {
+		t.Errorf("%04x: got %v e%v = %v", uint16(f), f.Mantissa(), f.Exponent(), f.Float64())
+	}
+}
+
+func TestFloat(t *testing.T) {
+	testCases := []struct {
+		f    Float
+		want float64
+	}{
+		{0x00000000, 0},
+		{0xfe000e72, 36.98},
+		{0x02fffffe, -200},
+		{0x007ffffe, math.Inf(1)},
+		{0x00800002, math.Inf(-1)},
+		{NewFloat(-8388605, 3), -8388605000},
+	}
+	for _, tc := range testCases {
+		if got := tc.f.Float64(); got != tc.want {
+			t.Errorf("%08x: got %v, want %v", uint32(tc.f), got, tc.want)
+		}
+	}
+	for _, f := range []Float{FloatNaN, FloatNRes, 0x00800001, 0x017fffff} {
+		if got := f.Float64(); !math.IsNaN(got) {
+			t.Errorf("%08x: got %v, want NaN", uint32(f), got)
+		}
+	}
+	if got := FloatNRes.String(); got != "NRes" {
+		t.Errorf("got %v, want NRes", got)
+	}
+}
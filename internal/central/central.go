@@ -0,0 +1,348 @@
+// Package central runs request/response exchanges with a remote peripheral
+// on top of the goble event stream.
+package central
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	ErrDisconnected = errors.New("peripheral disconnected")
+	ErrNotFound     = errors.New("attribute not found")
+)
+
+// Conn is a connection to a peripheral, requests are serialized
+type Conn struct {
+	ble      *goble.BLE
+	device   xpc.UUID
+	cancel   func()
+	attached bool // connected by someone else
+
+	mu  sync.Mutex // serializes requests
+	mtu int32      // ATT MTU, atomic
+
+	pmu     sync.Mutex
+	pending func(goble.Event) bool // takes the response of the request in progress
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	smu         sync.Mutex
+	subscribers map[string]func([]byte) // by characteristic uuid
+}
+
+func newConn(ble *goble.BLE, device xpc.UUID) *Conn {
+	c := &Conn{
+		ble:         ble,
+		device:      device,
+		mtu:         int32(ble.Mtu(device)),
+		done:        make(chan struct{}),
+		subscribers: map[string]func([]byte){},
+	}
+	c.cancel = ble.Listen(c.handle)
+	return c
+}
+
+// Dial connects to a discovered peripheral
+func Dial(ctx context.Context, ble *goble.BLE, device xpc.UUID) (*Conn, error) {
+	c := newConn(ble, device)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.request(ctx, func() { ble.Connect(device) }, func(ev goble.Event) bool { return ev.Name == "connect" }); err != nil {
+		c.cancel()
+		if err != ErrDisconnected {
+			ble.Disconnect(device)
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// Attach uses a peripheral that is already connected, Close leaves the
+// connection up
+func Attach(ble *goble.BLE, device xpc.UUID) *Conn {
+	c := newConn(ble, device)
+	c.attached = true
+	return c
+}
+
+// handle routes the events of this peripheral, called from the event loop
+func (c *Conn) handle(ev goble.Event) bool {
+	if ev.DeviceUUID != c.device {
+		return false
+	}
+	switch {
+	case ev.Name == "mtuChange":
+		atomic.StoreInt32(&c.mtu, int32(ev.Mtu))
+		return false
+	case ev.Name == "disconnect":
+		c.closeOnce.Do(func() { close(c.done) })
+		return true
+	case ev.Name == "read" && ev.IsNotification:
+		c.smu.Lock()
+		fn, ok := c.subscribers[strings.ToLower(ev.CharacteristicUuid)]
+		c.smu.Unlock()
+		if ok {
+			fn(ev.Data)
+		}
+		return false
+	}
+	c.pmu.Lock()
+	if c.pending != nil && c.pending(ev) {
+		c.pending = nil
+	}
+	c.pmu.Unlock()
+	return false
+}
+
+// request sends a request and returns the first event matching fn, which is
+// handed over from the event loop however many other events arrive. Events
+// before the request are not taken for its response.
+func (c *Conn) request(ctx context.Context, send func(), fn func(goble.Event) bool) (goble.Event, error) {
+	response := make(chan goble.Event, 1)
+	c.pmu.Lock()
+	c.pending = func(ev goble.Event) bool {
+		if !fn(ev) {
+			return false
+		}
+		response <- ev
+		return true
+	}
+	c.pmu.Unlock()
+	defer func() {
+		c.pmu.Lock()
+		c.pending = nil
+		c.pmu.Unlock()
+	}()
+
+	send()
+	select {
+	case ev := <-response:
+		return ev, nil
+	case <-c.done:
+		return goble.Event{}, ErrDisconnected
+	case <-ctx.Done():
+		return goble.Event{}, ctx.Err()
+	}
+}
+
+// Done is closed when the peripheral disconnects or the connection is closed
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// MTU returns the ATT MTU negotiated with the peripheral
+func (c *Conn) MTU() int {
+	return int(atomic.LoadInt32(&c.mtu))
+}
+
+// MaximumWriteLength returns the longest value written to the peripheral
+// in one piece, Write splits longer values without response and rejects
+// longer values with response
+func (c *Conn) MaximumWriteLength(withoutResponse bool) int {
+	return goble.Peripheral{Mtu: c.MTU()}.MaximumWriteLength(withoutResponse)
+}
+
+// Device returns the peripheral uuid
+func (c *Conn) Device() xpc.UUID {
+	return c.device
+}
+
+// DiscoverService discovers a service and its characteristics
+func (c *Conn) DiscoverService(ctx context.Context, uuid xpc.UUID) (*goble.ServiceHandle, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ev, err := c.request(ctx, func() { c.ble.DiscoverServices(c.device, []xpc.UUID{uuid}) }, func(ev goble.Event) bool { return ev.Name == "servicesDiscover" })
+	if err != nil {
+		return nil, err
+	}
+	s, ok := ev.Peripheral.Service(uuid.String())
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	_, err = c.request(ctx, func() { c.ble.DiscoverCharacteristics(c.device, s.Uuid, nil) }, func(ev goble.Event) bool {
+		return ev.Name == "characteristicsDiscover" && ev.ServiceUuid == s.Uuid
+	})
+	return s, err
+}
+
+// DiscoverAll discovers all services with their characteristics and
+// descriptors
+func (c *Conn) DiscoverAll(ctx context.Context) (goble.Peripheral, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ev, err := c.request(ctx, func() { c.ble.DiscoverServices(c.device, nil) }, func(ev goble.Event) bool { return ev.Name == "servicesDiscover" })
+	if err != nil {
+		return goble.Peripheral{}, err
+	}
+	p := ev.Peripheral
+	for _, s := range p.GATTTree().Services {
+		if _, err := c.request(ctx, func() { c.ble.DiscoverCharacteristics(c.device, s.UUID, nil) }, func(ev goble.Event) bool {
+			return ev.Name == "characteristicsDiscover" && ev.ServiceUuid == s.UUID
+		}); err != nil {
+			return p, err
+		}
+	}
+	for _, s := range p.GATTTree().Services {
+		for _, ch := range s.Characteristics {
+			if _, err := c.request(ctx, func() { c.ble.DiscoverDescriptors(c.device, s.UUID, ch.UUID) }, func(ev goble.Event) bool {
+				return ev.Name == "descriptorsDiscover" && ev.ServiceUuid == s.UUID && ev.CharacteristicUuid == ch.UUID
+			}); err != nil {
+				return p, err
+			}
+		}
+	}
+	return p, nil
+}
+
+// Read reads a characteristic value
+func (c *Conn) Read(ctx context.Context, s *goble.ServiceHandle, uuid xpc.UUID) ([]byte, error) {
+	ch, ok := s.Characteristic(uuid.String())
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return c.ReadHandle(ctx, ch.Handle)
+}
+
+// ReadHandle reads the value of the characteristic declared at handle
+func (c *Conn) ReadHandle(ctx context.Context, handle int) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ev, err := c.request(ctx, func() { c.ble.ReadHandle(c.device, handle) }, func(ev goble.Event) bool {
+		return ev.Name == "read" && ev.Handle == handle
+	})
+	if err == nil && ev.Result != 0 {
+		err = goble.ATTError(ev.Result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ev.Data, nil
+}
+
+// ReadDescriptor reads a descriptor value
+func (c *Conn) ReadDescriptor(ctx context.Context, s *goble.ServiceHandle, characteristic, descriptor xpc.UUID) ([]byte, error) {
+	ch, ok := s.Characteristic(characteristic.String())
+	if !ok {
+		return nil, ErrNotFound
+	}
+	d, ok := ch.Descriptor(descriptor.String())
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return c.ReadDescriptorHandle(ctx, d.Handle)
+}
+
+// ReadDescriptorHandle reads the value of the descriptor at handle
+func (c *Conn) ReadDescriptorHandle(ctx context.Context, handle int) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ev, err := c.request(ctx, func() { c.ble.ReadDescriptorHandle(c.device, handle) }, func(ev goble.Event) bool {
+		return ev.Name == "descriptorRead" && ev.Handle == handle
+	})
+	if err == nil && ev.Result != 0 {
+		err = goble.ATTError(ev.Result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ev.Data, nil
+}
+
+// Write writes a characteristic value and waits for the response, unless
+// withoutResponse is set
+func (c *Conn) Write(ctx context.Context, s *goble.ServiceHandle, uuid xpc.UUID, data []byte, withoutResponse bool) error {
+	ch, ok := s.Characteristic(uuid.String())
+	if !ok {
+		return ErrNotFound
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !withoutResponse && len(data) > c.MaximumWriteLength(false) {
+		return goble.ErrInvalidAttributeValueLength
+	}
+	if withoutResponse {
+		c.ble.Write(c.device, s.Uuid, ch.Uuid, data, true)
+		return nil
+	}
+	ev, err := c.request(ctx, func() { c.ble.Write(c.device, s.Uuid, ch.Uuid, data, false) }, func(ev goble.Event) bool {
+		return ev.Name == "write" && ev.CharacteristicUuid == ch.Uuid
+	})
+	if err == nil && ev.Result != 0 {
+		err = goble.ATTError(ev.Result)
+	}
+	return err
+}
+
+// Subscribe enables notifications, fn is called from the event loop and must
+// not block
+func (c *Conn) Subscribe(ctx context.Context, s *goble.ServiceHandle, uuid xpc.UUID, fn func([]byte)) error {
+	ch, ok := s.Characteristic(uuid.String())
+	if !ok {
+		return ErrNotFound
+	}
+	c.smu.Lock()
+	c.subscribers[strings.ToLower(ch.Uuid)] = fn
+	c.smu.Unlock()
+
+	err := c.notify(ctx, s, ch, true)
+	if err != nil {
+		c.smu.Lock()
+		delete(c.subscribers, strings.ToLower(ch.Uuid))
+		c.smu.Unlock()
+	}
+	return err
+}
+
+// Unsubscribe disables notifications
+func (c *Conn) Unsubscribe(ctx context.Context, s *goble.ServiceHandle, uuid xpc.UUID) error {
+	ch, ok := s.Characteristic(uuid.String())
+	if !ok {
+		return ErrNotFound
+	}
+	c.smu.Lock()
+	delete(c.subscribers, strings.ToLower(ch.Uuid))
+	c.smu.Unlock()
+	return c.notify(ctx, s, ch, false)
+}
+
+func (c *Conn) notify(ctx context.Context, s *goble.ServiceHandle, ch *goble.ServiceCharacteristic, enable bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ev, err := c.request(ctx, func() { c.ble.Notify(c.device, s.Uuid, ch.Uuid, enable) }, func(ev goble.Event) bool {
+		return ev.Name == "notify" && ev.CharacteristicUuid == ch.Uuid
+	})
+	if err == nil && ev.Result != 0 {
+		err = goble.ATTError(ev.Result)
+	}
+	return err
+}
+
+// Close disconnects from the peripheral, unless attached
+func (c *Conn) Close() error {
+	select {
+	case <-c.done:
+	default:
+		if !c.attached {
+			c.ble.Disconnect(c.device)
+		}
+	}
+	c.cancel()
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
@@ -0,0 +1,55 @@
+package central
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+var deviceUUID = xpc.MustUUID("0123456789abcdef0123456789abcdef")
+
+// flood answers a connect after a flood of advertisements of the device
+type flood struct {
+	ble *goble.BLE
+}
+
+func (f *flood) event(id int, args xpc.Dict) {
+	f.ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(id), "kCBMsgArgs": args}, nil)
+}
+
+func (f *flood) advertise() {
+	f.event(37, xpc.Dict{
+		"kCBMsgArgDeviceUUID":        deviceUUID,
+		"kCBMsgArgRssi":              int64(-60),
+		"kCBMsgArgAdvertisementData": xpc.Dict{"kCBAdvDataLocalName": "flood"},
+	})
+}
+
+func (f *flood) Send(msg interface{}, verbose bool) {
+	if msg.(xpc.Dict)["kCBMsgId"] != 31 { // connect
+		return
+	}
+	go func() {
+		for i := 0; i < 1000; i++ {
+			f.advertise()
+		}
+		f.event(38, xpc.Dict{"kCBMsgArgDeviceUUID": deviceUUID})
+	}()
+}
+
+func TestDialFlood(t *testing.T) {
+	f := &flood{}
+	f.ble = goble.NewWithTransport(f, "")
+	f.ble.StartScanning(nil, true)
+	f.advertise()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, f.ble, deviceUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.cancel()
+}
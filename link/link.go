@@ -0,0 +1,405 @@
+// Package link keeps connections to a set of peripherals alive. Dropped
+// links are reconnected with exponential backoff, services are discovered
+// again and notifications re-subscribed.
+//
+//	m := link.New(ble)
+//	m.Add(link.Device{
+//		UUID: p.Uuid,
+//		Subscriptions: []link.Subscription{{
+//			Service:        heartrate.ServiceUUID,
+//			Characteristic: heartrate.MeasurementUUID,
+//			Handler:        func(b []byte) { ... },
+//		}},
+//	})
+//
+// Peripherals have to be discovered once before they are added, blued
+// reconnects to known peripherals without scanning. With a GATT cache set on
+// BLE, services are restored from it instead of being discovered.
+package link
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/internal/central"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	ErrNotConnected = errors.New("peripheral not connected")
+	ErrUnknown      = errors.New("peripheral not managed")
+)
+
+// State of a link
+type State int
+
+const (
+	Disconnected State = iota
+	Connecting
+	Connected
+)
+
+func (s State) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Subscription to notifications of a characteristic, restored on every
+// connect. Handler is called from the event loop and must not block.
+type Subscription struct {
+	Service        xpc.UUID
+	Characteristic xpc.UUID
+	Handler        func([]byte)
+}
+
+// Device is a peripheral to keep connected
+type Device struct {
+	UUID          xpc.UUID
+	Services      []xpc.UUID // discovered on connect, besides those subscribed to
+	Subscriptions []Subscription
+}
+
+// Stats of a link
+type Stats struct {
+	State          State
+	Attempts       int   // connect attempts
+	Connects       int   // successful connects
+	Disconnects    int   // links lost
+	Failures       int   // failed attempts and quickly lost links since the last stable link
+	LastError      error // error of the last failed attempt or lost link
+	LastConnect    time.Time
+	LastDisconnect time.Time
+	Backoff        time.Duration // delay before the next attempt
+}
+
+// Manager keeps links to peripherals, the exported fields are read when a
+// link is set up or reconnected
+type Manager struct {
+	MinBackoff     time.Duration // delay after the first failed attempt
+	MaxBackoff     time.Duration // upper bound of the delay
+	Jitter         float64       // fraction the delay is randomly varied by
+	ConnectTimeout time.Duration // time allowed to connect and set up a link
+	MinUptime      time.Duration // a link lost sooner counts as a failed attempt
+
+	// OnStateChange is called with the new state of a link, from the
+	// goroutine of the link
+	OnStateChange func(device xpc.UUID, state State)
+
+	ble *goble.BLE
+
+	mu      sync.Mutex
+	devices map[xpc.UUID]*device
+}
+
+// device is a managed link
+type device struct {
+	Device
+	stop chan struct{}
+	done chan struct{}
+
+	// guarded by Manager.mu
+	stats    Stats
+	conn     *central.Conn
+	services map[xpc.UUID]*goble.ServiceHandle
+}
+
+// New creates a manager backing off from 1 s to 1 min with 20 % jitter,
+// links lost within 10 s back off as well
+func New(ble *goble.BLE) *Manager {
+	return &Manager{
+		MinBackoff:     time.Second,
+		MaxBackoff:     time.Minute,
+		Jitter:         0.2,
+		ConnectTimeout: 30 * time.Second,
+		MinUptime:      10 * time.Second,
+		ble:            ble,
+		devices:        map[xpc.UUID]*device{},
+	}
+}
+
+// Add starts keeping a peripheral connected, a device already added is
+// replaced
+func (m *Manager) Add(d Device) {
+	dev := &device{
+		Device: d,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	m.mu.Lock()
+	old, ok := m.devices[d.UUID]
+	m.devices[d.UUID] = dev
+	m.mu.Unlock()
+	if ok {
+		close(old.stop)
+		<-old.done
+	}
+	go m.run(dev)
+}
+
+// Remove disconnects a peripheral and stops reconnecting it
+func (m *Manager) Remove(uuid xpc.UUID) {
+	m.mu.Lock()
+	dev, ok := m.devices[uuid]
+	delete(m.devices, uuid)
+	m.mu.Unlock()
+	if ok {
+		close(dev.stop)
+		<-dev.done
+	}
+}
+
+// Close removes all peripherals
+func (m *Manager) Close() {
+	for _, uuid := range m.Devices() {
+		m.Remove(uuid)
+	}
+}
+
+// Devices returns the managed peripherals
+func (m *Manager) Devices() []xpc.UUID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var uuids []xpc.UUID
+	for uuid := range m.devices {
+		uuids = append(uuids, uuid)
+	}
+	return uuids
+}
+
+// Stats returns the state and statistics of a link
+func (m *Manager) Stats(uuid xpc.UUID) (Stats, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dev, ok := m.devices[uuid]
+	if !ok {
+		return Stats{}, false
+	}
+	return dev.stats, true
+}
+
+// Read reads a characteristic of a connected peripheral
+func (m *Manager) Read(ctx context.Context, uuid, service, characteristic xpc.UUID) ([]byte, error) {
+	conn, s, err := m.service(uuid, service)
+	if err != nil {
+		return nil, err
+	}
+	return conn.Read(ctx, s, characteristic)
+}
+
+// Write writes a characteristic of a connected peripheral
+func (m *Manager) Write(ctx context.Context, uuid, service, characteristic xpc.UUID, data []byte, withoutResponse bool) error {
+	conn, s, err := m.service(uuid, service)
+	if err != nil {
+		return err
+	}
+	return conn.Write(ctx, s, characteristic, data, withoutResponse)
+}
+
+// service returns the connection and a discovered service of a peripheral
+func (m *Manager) service(uuid, service xpc.UUID) (*central.Conn, *goble.ServiceHandle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dev, ok := m.devices[uuid]
+	if !ok {
+		return nil, nil, ErrUnknown
+	}
+	if dev.conn == nil {
+		return nil, nil, ErrNotConnected
+	}
+	s, ok := dev.services[service]
+	if !ok {
+		return nil, nil, central.ErrNotFound
+	}
+	return dev.conn, s, nil
+}
+
+// update changes the stats of a link, calling OnStateChange if the state
+// changed
+func (m *Manager) update(dev *device, fn func(s *Stats)) {
+	m.mu.Lock()
+	state := dev.stats.State
+	fn(&dev.stats)
+	changed := dev.stats.State != state
+	state = dev.stats.State
+	onStateChange := m.OnStateChange
+	m.mu.Unlock()
+	if changed && onStateChange != nil {
+		onStateChange(dev.UUID, state)
+	}
+}
+
+// run keeps a link up until it is removed
+func (m *Manager) run(dev *device) {
+	defer close(dev.done)
+	for {
+		select {
+		case <-dev.stop:
+			return
+		default:
+		}
+		m.update(dev, func(s *Stats) {
+			s.State = Connecting
+			s.Attempts++
+		})
+		conn, err := m.connect(dev)
+		if err != nil {
+			var backoff time.Duration
+			m.update(dev, func(s *Stats) {
+				s.State = Disconnected
+				s.Failures++
+				s.LastError = err
+				s.Backoff = m.backoff(s.Failures)
+				backoff = s.Backoff
+			})
+			select {
+			case <-time.After(backoff):
+				continue
+			case <-dev.stop:
+				return
+			}
+		}
+
+		select {
+		case <-conn.Done():
+			var backoff time.Duration
+			m.update(dev, func(s *Stats) {
+				s.State = Disconnected
+				s.Disconnects++
+				s.LastDisconnect = time.Now()
+				s.LastError = central.ErrDisconnected
+				if s.LastDisconnect.Sub(s.LastConnect) < m.MinUptime {
+					s.Failures++
+					s.Backoff = m.backoff(s.Failures)
+				} else {
+					s.Failures = 0
+					s.Backoff = 0
+				}
+				backoff = s.Backoff
+			})
+			m.mu.Lock()
+			dev.conn, dev.services = nil, nil
+			m.mu.Unlock()
+			select {
+			case <-time.After(backoff):
+			case <-dev.stop:
+				return
+			}
+		case <-dev.stop:
+			conn.Close()
+			m.update(dev, func(s *Stats) { s.State = Disconnected })
+			return
+		}
+	}
+}
+
+// connect dials a peripheral, discovers its services and subscribes
+func (m *Manager) connect(dev *device) (*central.Conn, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if m.ConnectTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, m.ConnectTimeout)
+		defer cancel()
+	}
+	go func() {
+		select {
+		case <-dev.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	conn, err := central.Dial(ctx, m.ble, dev.UUID)
+	if err != nil {
+		return nil, err
+	}
+	services := map[xpc.UUID]*goble.ServiceHandle{}
+	cached, restored := m.restore(ctx, conn, dev.UUID)
+	discover := func(uuid xpc.UUID) error {
+		if _, ok := services[uuid]; ok {
+			return nil
+		}
+		if s, ok := cached.Service(uuid.String()); ok && restored && len(s.Characteristics) > 0 {
+			services[uuid] = s
+			return nil
+		}
+		s, err := conn.DiscoverService(ctx, uuid)
+		services[uuid] = s
+		return err
+	}
+	for _, uuid := range dev.Services {
+		if err := discover(uuid); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	for _, sub := range dev.Subscriptions {
+		if err := discover(sub.Service); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := conn.Subscribe(ctx, services[sub.Service], sub.Characteristic, sub.Handler); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	dev.conn, dev.services = conn, services
+	m.mu.Unlock()
+	m.update(dev, func(s *Stats) {
+		s.State = Connected
+		s.Connects++
+		s.LastConnect = time.Now()
+		s.Backoff = 0
+	})
+	return conn, nil
+}
+
+// restore returns the services of a peripheral kept in the GATT cache of
+// BLE, unless its database hash changed since they were discovered
+func (m *Manager) restore(ctx context.Context, conn *central.Conn, uuid xpc.UUID) (goble.Peripheral, bool) {
+	p, ok := m.ble.RestoreServices(uuid)
+	if !ok {
+		return p, false
+	}
+	if s, ok := p.Service(goble.GenericAttributeUUID.String()); ok {
+		if _, ok := s.Characteristic(goble.DatabaseHashUUID.String()); ok {
+			// a differing hash invalidates the cached services
+			if _, err := conn.Read(ctx, s, goble.DatabaseHashUUID); err != nil {
+				return p, false
+			}
+			if t, ok := m.ble.GATTCache().Load(uuid); !ok || len(t.Services) == 0 {
+				return p, false
+			}
+		}
+	}
+	return p, true
+}
+
+// backoff returns the delay after a number of failed attempts, doubling
+// from MinBackoff up to MaxBackoff and varied by Jitter
+func (m *Manager) backoff(failures int) time.Duration {
+	d := m.MinBackoff
+	for i := 1; i < failures && d < m.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > m.MaxBackoff {
+		d = m.MaxBackoff
+	}
+	if m.Jitter > 0 {
+		d += time.Duration(float64(d) * m.Jitter * (2*rand.Float64() - 1))
+	}
+	return d
+}
@@ -0,0 +1,327 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/simulator"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	deviceUUID  = xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	serviceUUID = goble.UUID16(0x180f)
+	levelUUID   = goble.UUID16(0x2a19)
+)
+
+// discovered returns a simulated BLE that knows p
+func discovered(t *testing.T, p *simulator.Peripheral) (*simulator.Simulator, *goble.BLE) {
+	sim := simulator.New(simulator.Mojave)
+	sim.Add(p)
+	ble := sim.BLE()
+	found := make(chan bool, 1)
+	cancel := ble.Listen(func(ev goble.Event) bool {
+		if ev.Name == "discover" {
+			select {
+			case found <- true:
+			default:
+			}
+		}
+		return false
+	})
+	defer cancel()
+	ble.Init()
+	ble.StartScanning(nil, false)
+	select {
+	case <-found:
+	case <-time.After(time.Second):
+		t.Fatal("not discovered")
+	}
+	ble.StopScanning()
+	return sim, ble
+}
+
+func await(t *testing.T, states <-chan State, want State) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case s := <-states:
+			if s == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("not %v", want)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	p := &simulator.Peripheral{
+		UUID: deviceUUID,
+		Services: []*simulator.Service{{
+			UUID: serviceUUID,
+			Characteristics: []*simulator.Characteristic{{
+				UUID:       levelUUID,
+				Properties: goble.Read | goble.Notify,
+				Value:      []byte{100},
+			}},
+		}},
+	}
+	sim, ble := discovered(t, p)
+	defer sim.Close()
+
+	m := New(ble)
+	m.MinBackoff = time.Millisecond
+	states := make(chan State, 16)
+	m.OnStateChange = func(device xpc.UUID, s State) { states <- s }
+	notified := make(chan []byte, 4)
+	m.Add(Device{
+		UUID: deviceUUID,
+		Subscriptions: []Subscription{{
+			Service:        serviceUUID,
+			Characteristic: levelUUID,
+			Handler:        func(b []byte) { notified <- b },
+		}},
+	})
+	defer m.Close()
+
+	for i := 1; i <= 2; i++ {
+		await(t, states, Connected)
+		p.Notify(levelUUID, []byte{byte(i)})
+		select {
+		case b := <-notified:
+			if !bytes.Equal(b, []byte{byte(i)}) {
+				t.Errorf("got notification %v", b)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no notification after connect %d", i)
+		}
+		if i == 1 {
+			data, err := m.Read(context.Background(), deviceUUID, serviceUUID, levelUUID)
+			if err != nil || !bytes.Equal(data, []byte{100}) {
+				t.Errorf("got read %v, %v", data, err)
+			}
+			p.Disconnect()
+			await(t, states, Disconnected)
+		}
+	}
+	s, _ := m.Stats(deviceUUID)
+	// the link was lost right after the connect
+	if s.Attempts != 2 || s.Connects != 2 || s.Disconnects != 1 || s.Failures != 1 {
+		t.Errorf("got stats %+v", s)
+	}
+
+	m.Remove(deviceUUID)
+	if _, ok := m.Stats(deviceUUID); ok {
+		t.Error("removed device has stats")
+	}
+	if _, err := m.Read(context.Background(), deviceUUID, serviceUUID, levelUUID); err != ErrUnknown {
+		t.Errorf("got %v, want %v", err, ErrUnknown)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &simulator.Peripheral{UUID: deviceUUID, NotConnectable: true}
+	sim, ble := discovered(t, p)
+	defer sim.Close()
+
+	m := New(ble)
+	m.MinBackoff = time.Millisecond
+	m.MaxBackoff = 4 * time.Millisecond
+	m.Jitter = 0
+	m.ConnectTimeout = 5 * time.Millisecond
+	for i, want := range []time.Duration{1, 2, 4, 4} {
+		if d := m.backoff(i + 1); d != want*time.Millisecond {
+			t.Errorf("%d failures: got %v, want %v", i+1, d, want*time.Millisecond)
+		}
+	}
+	m.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := m.backoff(3); d < 2*time.Millisecond || d > 6*time.Millisecond {
+			t.Fatalf("got %v with jitter", d)
+		}
+	}
+
+	m.Add(Device{UUID: deviceUUID})
+	defer m.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s, _ := m.Stats(deviceUUID)
+		if s.Failures >= 3 {
+			if s.State == Connected || s.Connects != 0 || s.LastError == nil {
+				t.Errorf("got stats %+v", s)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got stats %+v", s)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := m.Read(context.Background(), deviceUUID, serviceUUID, levelUUID); err != ErrNotConnected {
+		t.Errorf("got %v, want %v", err, ErrNotConnected)
+	}
+}
+
+func TestCache(t *testing.T) {
+	p := &simulator.Peripheral{
+		UUID: deviceUUID,
+		Services: []*simulator.Service{{
+			UUID: serviceUUID,
+			Characteristics: []*simulator.Characteristic{{
+				UUID:       levelUUID,
+				Properties: goble.Read | goble.Notify,
+				Value:      []byte{100},
+			}},
+		}},
+	}
+	sim, ble := discovered(t, p)
+	defer sim.Close()
+	ble.SetGATTCache(goble.NewGATTCache())
+
+	m := New(ble)
+	m.MinBackoff = time.Millisecond
+	states := make(chan State, 16)
+	m.OnStateChange = func(device xpc.UUID, s State) { states <- s }
+	m.Add(Device{UUID: deviceUUID, Services: []xpc.UUID{serviceUUID}})
+	defer m.Close()
+
+	await(t, states, Connected)
+	p.Disconnect()
+	await(t, states, Disconnected)
+	await(t, states, Connected)
+
+	discoveries := 0
+	for _, msg := range sim.Messages() {
+		if msg["kCBMsgId"] == 72 { // discoverServices since darwin 18
+			discoveries++
+		}
+	}
+	if discoveries != 1 {
+		t.Errorf("services discovered %d times, want 1", discoveries)
+	}
+	data, err := m.Read(context.Background(), deviceUUID, serviceUUID, levelUUID)
+	if err != nil || !bytes.Equal(data, []byte{100}) {
+		t.Errorf("got read %v, %v", data, err)
+	}
+}
+
+func TestScanWhileManaging(t *testing.T) {
+	sim := simulator.New(simulator.Mojave)
+	defer sim.Close()
+	var peripherals []*simulator.Peripheral
+	for _, uuid := range []xpc.UUID{
+		xpc.MustUUID("0123456789abcdef0123456789abcde1"),
+		xpc.MustUUID("0123456789abcdef0123456789abcde2"),
+	} {
+		p := &simulator.Peripheral{
+			UUID:          uuid,
+			Advertisement: simulator.Advertisement{LocalName: "sensor"},
+			Services: []*simulator.Service{{
+				UUID: serviceUUID,
+				Characteristics: []*simulator.Characteristic{{
+					UUID:       levelUUID,
+					Properties: goble.Read | goble.Notify,
+					Value:      []byte{100},
+				}},
+			}},
+		}
+		sim.Add(p)
+		peripherals = append(peripherals, p)
+	}
+	ble := sim.BLE()
+	ble.SetGATTCache(goble.NewGATTCache())
+	discovered := make(chan xpc.UUID, 64)
+	ble.Listen(func(ev goble.Event) bool {
+		if ev.Name == "discover" {
+			select {
+			case discovered <- ev.DeviceUUID:
+			default:
+			}
+		}
+		return false
+	})
+	ble.Init()
+	ble.StartScanning(nil, true)
+	for seen := map[xpc.UUID]bool{}; len(seen) < len(peripherals); {
+		select {
+		case uuid := <-discovered:
+			seen[uuid] = true
+		case <-time.After(time.Second):
+			t.Fatal("not discovered")
+		}
+	}
+
+	m := New(ble)
+	m.MinBackoff = time.Millisecond
+	connected := make(chan xpc.UUID, 16)
+	m.OnStateChange = func(device xpc.UUID, s State) {
+		if s == Connected {
+			connected <- device
+		}
+	}
+	for _, p := range peripherals {
+		m.Add(Device{
+			UUID: p.UUID,
+			Subscriptions: []Subscription{{
+				Service:        serviceUUID,
+				Characteristic: levelUUID,
+				Handler:        func([]byte) {},
+			}},
+		})
+	}
+	defer m.Close()
+
+	// advertisements keep coming while the links are set up and used
+	done := make(chan struct{})
+	defer close(done)
+	for _, p := range peripherals {
+		go func(p *simulator.Peripheral) {
+			for rssi := -60; ; rssi-- {
+				select {
+				case <-done:
+					return
+				case <-time.After(time.Millisecond):
+				}
+				p.SetRSSI(rssi)
+				p.Advertise()
+				p.Notify(levelUUID, []byte{1})
+			}
+		}(p)
+	}
+	go func() {
+		// and new peripherals show up
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			uuid := xpc.MustUUID("0123456789abcdef0123456789abcdef")
+			uuid[0], uuid[1] = byte(i), byte(i>>8)
+			sim.Add(&simulator.Peripheral{UUID: uuid, Advertisement: simulator.Advertisement{LocalName: "other"}})
+		}
+	}()
+
+	for i := 0; i < 2*len(peripherals); i++ {
+		select {
+		case <-connected:
+		case <-time.After(time.Second):
+			t.Fatal("not connected")
+		}
+		if i < len(peripherals) {
+			// one more round with the links lost
+			peripherals[i].Disconnect()
+		}
+	}
+	for _, p := range peripherals {
+		data, err := m.Read(context.Background(), p.UUID, serviceUUID, levelUUID)
+		if err != nil || !bytes.Equal(data, []byte{100}) {
+			t.Errorf("%v: got read %v, %v", p.UUID, data, err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Package cts implements the Current Time Service (0x1805).
+package cts
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/internal/central"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	ServiceUUID                  = goble.UUID16(0x1805)
+	CurrentTimeUUID              = goble.UUID16(0x2a2b)
+	LocalTimeInformationUUID     = goble.UUID16(0x2a0f)
+	ReferenceTimeInformationUUID = goble.UUID16(0x2a14)
+)
+
+// Server serves the local clock
+type Server struct {
+	ble *goble.BLE
+
+	mu        sync.Mutex
+	now       func() time.Time
+	reference goble.ReferenceTimeInformation
+	updated   time.Time // last reference update
+}
+
+// NewServer creates a server for the local clock, add its Service to the
+// services passed to BLE.SetServices
+func NewServer(ble *goble.BLE) *Server {
+	return &Server{
+		ble:       ble,
+		now:       time.Now,
+		reference: goble.ReferenceTimeInformation{Accuracy: 255},
+	}
+}
+
+// SetClock replaces the time source, time.Now by default
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	s.now = now
+	s.mu.Unlock()
+}
+
+// SetReference records the source and accuracy (in 1/8 s) of the last
+// synchronization of the clock, which happened now
+func (s *Server) SetReference(source, accuracy uint8) {
+	s.mu.Lock()
+	s.reference.Source = source
+	s.reference.Accuracy = accuracy
+	s.updated = s.now()
+	s.mu.Unlock()
+}
+
+// currentTime returns the wall clock in its zone
+func (s *Server) currentTime(reason goble.AdjustReason) (goble.CurrentTime, goble.LocalTimeInformation) {
+	s.mu.Lock()
+	t := s.now()
+	s.mu.Unlock()
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return goble.CurrentTime{Time: wall, AdjustReason: reason}, goble.LocalTimeInformationOf(t)
+}
+
+func (s *Server) referenceTime() goble.ReferenceTimeInformation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.reference
+	r.DaysSinceUpdate, r.HoursSinceUpdate = 255, 255
+	if !s.updated.IsZero() {
+		since := s.now().Sub(s.updated)
+		if days := int(since / (24 * time.Hour)); days < 255 {
+			r.DaysSinceUpdate = uint8(days)
+			r.HoursSinceUpdate = uint8(since % (24 * time.Hour) / time.Hour)
+		}
+	}
+	return r
+}
+
+// Service returns the GATT service
+func (s *Server) Service() goble.Service {
+	current := goble.NewCharacteristic(CurrentTimeUUID, goble.Read|goble.Notify, nil)
+	current.HandleRead(func(_ xpc.UUID, offset int) ([]byte, error) {
+		ct, _ := s.currentTime(0)
+		return value(ct.Bytes(), offset)
+	})
+	local := goble.NewCharacteristic(LocalTimeInformationUUID, goble.Read, nil)
+	local.HandleRead(func(_ xpc.UUID, offset int) ([]byte, error) {
+		_, lt := s.currentTime(0)
+		return value(lt.Bytes(), offset)
+	})
+	reference := goble.NewCharacteristic(ReferenceTimeInformationUUID, goble.Read, nil)
+	reference.HandleRead(func(_ xpc.UUID, offset int) ([]byte, error) {
+		return value(s.referenceTime().Bytes(), offset)
+	})
+	return goble.NewService(ServiceUUID, current, local, reference)
+}
+
+func value(b []byte, offset int) ([]byte, error) {
+	if offset > len(b) {
+		return nil, goble.ErrInvalidOffset
+	}
+	return b[offset:], nil
+}
+
+// TimeChanged notifies subscribed centrals that the clock was adjusted
+func (s *Server) TimeChanged(reason goble.AdjustReason) bool {
+	ct, _ := s.currentTime(reason)
+	return s.ble.UpdateValue(CurrentTimeUUID, ct.Bytes())
+}
+
+// Client reads the Current Time Service of a connected peripheral
+type Client struct {
+	conn    *central.Conn
+	service *goble.ServiceHandle
+}
+
+// New discovers the Current Time Service of a connected peripheral
+func New(ctx context.Context, ble *goble.BLE, p goble.Peripheral) (*Client, error) {
+	c := &Client{conn: central.Attach(ble, p.Uuid)}
+	var err error
+	if c.service, err = c.conn.DiscoverService(ctx, ServiceUUID); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) read(ctx context.Context, uuid xpc.UUID) (interface{}, error) {
+	data, err := c.conn.Read(ctx, c.service, uuid)
+	if err != nil {
+		return nil, err
+	}
+	return goble.Decode(uuid.String(), data)
+}
+
+// CurrentTime reads the Current Time characteristic
+func (c *Client) CurrentTime(ctx context.Context) (goble.CurrentTime, error) {
+	v, err := c.read(ctx, CurrentTimeUUID)
+	if err != nil {
+		return goble.CurrentTime{}, err
+	}
+	return v.(goble.CurrentTime), nil
+}
+
+// LocalTimeInformation reads the optional Local Time Information characteristic
+func (c *Client) LocalTimeInformation(ctx context.Context) (goble.LocalTimeInformation, error) {
+	v, err := c.read(ctx, LocalTimeInformationUUID)
+	if err != nil {
+		return goble.LocalTimeInformation{}, err
+	}
+	return v.(goble.LocalTimeInformation), nil
+}
+
+// ReferenceTimeInformation reads the optional Reference Time Information characteristic
+func (c *Client) ReferenceTimeInformation(ctx context.Context) (goble.ReferenceTimeInformation, error) {
+	v, err := c.read(ctx, ReferenceTimeInformationUUID)
+	if err != nil {
+		return goble.ReferenceTimeInformation{}, err
+	}
+	return v.(goble.ReferenceTimeInformation), nil
+}
+
+// Time reads the peripheral clock. The time is placed in a fixed zone when
+// the peripheral has Local Time Information, otherwise the wall clock is
+// returned in time.Local.
+func (c *Client) Time(ctx context.Context) (time.Time, error) {
+	ct, err := c.CurrentTime(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := time.Local
+	if _, ok := c.service.Characteristic(LocalTimeInformationUUID.String()); ok {
+		lt, err := c.LocalTimeInformation(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if offset, ok := lt.Offset(); ok {
+			loc = time.FixedZone("", int(offset/time.Second))
+		}
+	}
+	t := ct.Time
+	if t.IsZero() {
+		return t, nil
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc), nil
+}
+
+// Close releases the client, the peripheral stays connected
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,192 @@
+package cts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/simulator"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	deviceUUID  = xpc.MustUUID("00112233445566778899aabbccddeeff")
+	centralUUID = xpc.MustUUID("ffeeddccbbaa99887766554433221100")
+	nepal       = time.FixedZone("NPT", 5*3600+45*60)
+)
+
+// transport keeps the messages sent to blued
+type transport struct {
+	messages []xpc.Dict
+}
+
+func (tr *transport) Send(msg interface{}, verbose bool) {
+	tr.messages = append(tr.messages, msg.(xpc.Dict))
+}
+
+// last returns the arguments of the last message with id
+func (tr *transport) last(id int) xpc.Dict {
+	for i := len(tr.messages) - 1; i >= 0; i-- {
+		if tr.messages[i]["kCBMsgId"] == id {
+			return tr.messages[i]["kCBMsgArgs"].(xpc.Dict)
+		}
+	}
+	return nil
+}
+
+// event delivers a blued event to ble
+func event(ble *goble.BLE, id int, args xpc.Dict) {
+	ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(id), "kCBMsgArgs": args}, nil)
+}
+
+func TestServer(t *testing.T) {
+	tr := &transport{}
+	ble := goble.NewWithTransport(tr, "")
+	s := NewServer(ble)
+	now := time.Date(2020, 7, 1, 12, 30, 15, int(time.Second/2), nepal)
+	s.SetClock(func() time.Time { return now })
+	ble.SetServices([]goble.Service{s.Service()})
+
+	// attribute ids: service 1, current time 2, local time 3, reference 4
+	read := func(id int) interface{} {
+		event(ble, 19, xpc.Dict{
+			"kCBMsgArgAttributeID":   int64(id),
+			"kCBMsgArgCentralUUID":   centralUUID,
+			"kCBMsgArgTransactionID": int64(id),
+		})
+		args := tr.last(13)
+		if args == nil || args["kCBMsgArgResult"] != 0 {
+			t.Fatalf("attribute %d: got response %v", id, args)
+		}
+		v, err := goble.Decode([]xpc.UUID{CurrentTimeUUID, LocalTimeInformationUUID, ReferenceTimeInformationUUID}[id-2].String(), args["kCBMsgArgData"].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	ct := read(2).(goble.CurrentTime)
+	if want := time.Date(2020, 7, 1, 12, 30, 15, int(time.Second/2), time.UTC); !ct.Time.Equal(want) || ct.AdjustReason != 0 {
+		t.Errorf("got %v, want %v", ct, want)
+	}
+	if lt := read(3).(goble.LocalTimeInformation); lt != (goble.LocalTimeInformation{TimeZone: 23}) {
+		t.Errorf("got %v", lt)
+	}
+	if rt := read(4).(goble.ReferenceTimeInformation); rt.Accuracy != 255 || rt.DaysSinceUpdate != 255 {
+		t.Errorf("got %+v", rt)
+	}
+	s.SetReference(goble.TimeSourceNTP, 8)
+	now = now.Add(26 * time.Hour)
+	want := goble.ReferenceTimeInformation{Source: goble.TimeSourceNTP, Accuracy: 8, DaysSinceUpdate: 1, HoursSinceUpdate: 2}
+	if rt := read(4).(goble.ReferenceTimeInformation); rt != want {
+		t.Errorf("got %+v, want %+v", rt, want)
+	}
+
+	if s.TimeChanged(goble.ManualTimeUpdate) {
+		t.Error("notified without subscribers")
+	}
+	event(ble, 21, xpc.Dict{
+		"kCBMsgArgAttributeID": int64(2),
+		"kCBMsgArgCentralUUID": centralUUID,
+	})
+	if !s.TimeChanged(goble.ManualTimeUpdate | goble.ChangeOfTimeZone) {
+		t.Fatal("not notified")
+	}
+	args := tr.last(15)
+	if args["kCBMsgArgAttributeID"] != 2 {
+		t.Errorf("got %v", args)
+	}
+	v, err := goble.Decode(CurrentTimeUUID.String(), args["kCBMsgArgData"].([]byte))
+	if ct := v.(goble.CurrentTime); err != nil || ct.AdjustReason != goble.ManualTimeUpdate|goble.ChangeOfTimeZone || ct.Time.Day() != 2 {
+		t.Errorf("got %v, %v", ct, err)
+	}
+}
+
+// connected returns a simulated BLE connected to a peripheral with services
+func connected(t *testing.T, ctx context.Context, services ...*simulator.Service) (*simulator.Simulator, *goble.BLE) {
+	p := &simulator.Peripheral{UUID: deviceUUID, Services: services}
+	sim := simulator.New(simulator.Mojave)
+	sim.Add(p)
+
+	ble := sim.BLE()
+	ready := make(chan bool, 1)
+	ble.Listen(func(ev goble.Event) bool {
+		switch ev.Name {
+		case "discover":
+			ble.StopScanning()
+			ble.Connect(ev.Peripheral.Uuid)
+		case "connect":
+			ready <- true
+			return true
+		}
+		return false
+	})
+	ble.StartScanning(nil, false)
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		sim.Close()
+		t.Fatal("not connected")
+	}
+	return sim, ble
+}
+
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wall := time.Date(2020, 7, 1, 12, 30, 15, 0, time.UTC)
+	current := goble.CurrentTime{Time: wall, AdjustReason: goble.ExternalReferenceTimeUpdate}
+	sim, ble := connected(t, ctx, &simulator.Service{
+		UUID: ServiceUUID,
+		Characteristics: []*simulator.Characteristic{
+			{UUID: CurrentTimeUUID, Properties: goble.Read | goble.Notify, Value: current.Bytes()},
+			{UUID: LocalTimeInformationUUID, Properties: goble.Read, Value: goble.LocalTimeInformation{TimeZone: 23}.Bytes()},
+		},
+	})
+	defer sim.Close()
+
+	c, err := New(ctx, ble, goble.Peripheral{Uuid: deviceUUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ct, err := c.CurrentTime(ctx)
+	if err != nil || !ct.Time.Equal(wall) || ct.AdjustReason != current.AdjustReason {
+		t.Errorf("got %v, %v", ct, err)
+	}
+	got, err := c.Time(ctx)
+	if want := time.Date(2020, 7, 1, 12, 30, 15, 0, nepal); err != nil || !got.Equal(want) {
+		t.Errorf("got %v, %v, want %v", got, err, want)
+	}
+	if _, err := c.ReferenceTimeInformation(ctx); err == nil {
+		t.Error("read missing reference time information")
+	}
+}
+
+func TestClientWithoutLocalTime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wall := time.Date(2020, 7, 1, 12, 30, 15, 0, time.UTC)
+	sim, ble := connected(t, ctx, &simulator.Service{
+		UUID: ServiceUUID,
+		Characteristics: []*simulator.Characteristic{
+			{UUID: CurrentTimeUUID, Properties: goble.Read, Value: goble.CurrentTime{Time: wall}.Bytes()},
+		},
+	})
+	defer sim.Close()
+
+	c, err := New(ctx, ble, goble.Peripheral{Uuid: deviceUUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	got, err := c.Time(ctx)
+	if err != nil || got.Location() != time.Local || got.Hour() != 12 || got.Minute() != 30 {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package deviceinfo reads the Device Information (0x180A) and Battery
+// (0x180F) services of a connected peripheral.
+package deviceinfo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/internal/central"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	ServiceUUID          = goble.UUID16(0x180a)
+	SystemIDUUID         = goble.UUID16(0x2a23)
+	ModelNumberUUID      = goble.UUID16(0x2a24)
+	SerialNumberUUID     = goble.UUID16(0x2a25)
+	FirmwareRevisionUUID = goble.UUID16(0x2a26)
+	HardwareRevisionUUID = goble.UUID16(0x2a27)
+	SoftwareRevisionUUID = goble.UUID16(0x2a28)
+	ManufacturerNameUUID = goble.UUID16(0x2a29)
+	PnPIDUUID            = goble.UUID16(0x2a50)
+
+	BatteryServiceUUID = goble.UUID16(0x180f)
+	BatteryLevelUUID   = goble.UUID16(0x2a19)
+)
+
+// time allowed to unsubscribe on Close
+const closeTimeout = 2 * time.Second
+
+// DeviceInfo holds the values present in the Device Information service
+type DeviceInfo struct {
+	ManufacturerName string
+	ModelNumber      string
+	SerialNumber     string
+	FirmwareRevision string
+	HardwareRevision string
+	SoftwareRevision string
+	SystemID         *goble.SystemID
+	PnPID            *goble.PnPID
+	Battery          int // percent, -1 without Battery service
+}
+
+// Client reads device information from a connected peripheral
+type Client struct {
+	conn    *central.Conn
+	info    *goble.ServiceHandle
+	battery *goble.ServiceHandle
+
+	mu     sync.Mutex
+	levels chan goble.BatteryLevel
+}
+
+// New discovers the Device Information and Battery services of a connected
+// peripheral, it fails with central.ErrNotFound if neither is present
+func New(ctx context.Context, ble *goble.BLE, p goble.Peripheral) (*Client, error) {
+	c := &Client{conn: central.Attach(ble, p.Uuid)}
+	var err error
+	for _, s := range []struct {
+		uuid   xpc.UUID
+		handle **goble.ServiceHandle
+	}{
+		{ServiceUUID, &c.info},
+		{BatteryServiceUUID, &c.battery},
+	} {
+		*s.handle, err = c.conn.DiscoverService(ctx, s.uuid)
+		if err != nil && err != central.ErrNotFound {
+			c.conn.Close()
+			return nil, err
+		}
+	}
+	if c.info == nil && c.battery == nil {
+		c.conn.Close()
+		return nil, central.ErrNotFound
+	}
+	return c, nil
+}
+
+// Read reads a connected peripheral's device information and battery level
+func Read(ctx context.Context, ble *goble.BLE, p goble.Peripheral) (DeviceInfo, error) {
+	c, err := New(ctx, ble, p)
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+	defer c.Close()
+	return c.DeviceInfo(ctx)
+}
+
+// DeviceInfo reads all characteristics present
+func (c *Client) DeviceInfo(ctx context.Context) (DeviceInfo, error) {
+	info := DeviceInfo{Battery: -1}
+	if c.info != nil {
+		for _, f := range []struct {
+			uuid xpc.UUID
+			set  func(interface{})
+		}{
+			{ManufacturerNameUUID, func(v interface{}) { info.ManufacturerName = v.(string) }},
+			{ModelNumberUUID, func(v interface{}) { info.ModelNumber = v.(string) }},
+			{SerialNumberUUID, func(v interface{}) { info.SerialNumber = v.(string) }},
+			{FirmwareRevisionUUID, func(v interface{}) { info.FirmwareRevision = v.(string) }},
+			{HardwareRevisionUUID, func(v interface{}) { info.HardwareRevision = v.(string) }},
+			{SoftwareRevisionUUID, func(v interface{}) { info.SoftwareRevision = v.(string) }},
+			{SystemIDUUID, func(v interface{}) { id := v.(goble.SystemID); info.SystemID = &id }},
+			{PnPIDUUID, func(v interface{}) { id := v.(goble.PnPID); info.PnPID = &id }},
+		} {
+			if _, ok := c.info.Characteristic(f.uuid.String()); !ok {
+				continue
+			}
+			v, err := c.read(ctx, c.info, f.uuid)
+			if err != nil {
+				return info, err
+			}
+			f.set(v)
+		}
+	}
+	if c.battery != nil {
+		level, err := c.BatteryLevel(ctx)
+		if err != nil {
+			return info, err
+		}
+		info.Battery = int(level)
+	}
+	return info, nil
+}
+
+func (c *Client) read(ctx context.Context, s *goble.ServiceHandle, uuid xpc.UUID) (interface{}, error) {
+	data, err := c.conn.Read(ctx, s, uuid)
+	if err != nil {
+		return nil, err
+	}
+	return goble.Decode(uuid.String(), data)
+}
+
+// BatteryLevel reads the battery level
+func (c *Client) BatteryLevel(ctx context.Context) (goble.BatteryLevel, error) {
+	if c.battery == nil {
+		return 0, central.ErrNotFound
+	}
+	v, err := c.read(ctx, c.battery, BatteryLevelUUID)
+	if err != nil {
+		return 0, err
+	}
+	return v.(goble.BatteryLevel), nil
+}
+
+// SubscribeBattery delivers battery level notifications until Close or
+// disconnect, levels are dropped while the channel is full
+func (c *Client) SubscribeBattery(ctx context.Context) (<-chan goble.BatteryLevel, error) {
+	if c.battery == nil {
+		return nil, central.ErrNotFound
+	}
+	c.mu.Lock()
+	if c.levels == nil {
+		c.levels = make(chan goble.BatteryLevel, 4)
+		go func(levels chan goble.BatteryLevel) {
+			<-c.conn.Done()
+			c.mu.Lock()
+			close(levels)
+			c.levels = nil
+			c.mu.Unlock()
+		}(c.levels)
+	}
+	levels := c.levels
+	c.mu.Unlock()
+
+	err := c.conn.Subscribe(ctx, c.battery, BatteryLevelUUID, func(data []byte) {
+		v, err := goble.Decode(BatteryLevelUUID.String(), data)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.levels != nil {
+			select {
+			case c.levels <- v.(goble.BatteryLevel):
+			default:
+			}
+		}
+	})
+	return levels, err
+}
+
+// Close unsubscribes from battery notifications, the peripheral stays connected
+func (c *Client) Close() error {
+	c.mu.Lock()
+	subscribed := c.levels != nil
+	c.mu.Unlock()
+	if subscribed {
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		c.conn.Unsubscribe(ctx, c.battery, BatteryLevelUUID)
+		cancel()
+	}
+	return c.conn.Close()
+}
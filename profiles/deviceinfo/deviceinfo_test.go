@@ -0,0 +1,144 @@
+package deviceinfo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/internal/central"
+	"github.com/dim13/goble/simulator"
+	"github.com/dim13/goble/xpc"
+)
+
+var deviceUUID = xpc.MustUUID("00112233445566778899aabbccddeeff")
+
+// connected returns a simulated BLE connected to a peripheral with services
+func connected(t *testing.T, ctx context.Context, services ...*simulator.Service) (*simulator.Simulator, *simulator.Peripheral, *goble.BLE, goble.Peripheral) {
+	p := &simulator.Peripheral{UUID: deviceUUID, Services: services}
+	sim := simulator.New(simulator.Mojave)
+	sim.Add(p)
+
+	ble := sim.BLE()
+	ready := make(chan bool, 1)
+	ble.Listen(func(ev goble.Event) bool {
+		switch ev.Name {
+		case "discover":
+			ble.StopScanning()
+			ble.Connect(ev.Peripheral.Uuid)
+		case "connect":
+			ready <- true
+			return true
+		}
+		return false
+	})
+	ble.StartScanning(nil, false)
+	select {
+	case <-ready:
+		return sim, p, ble, goble.Peripheral{Uuid: deviceUUID}
+	case <-ctx.Done():
+		sim.Close()
+		t.Fatal("not connected")
+	}
+	return nil, nil, nil, goble.Peripheral{}
+}
+
+func batteryService(level byte) *simulator.Service {
+	return &simulator.Service{
+		UUID: BatteryServiceUUID,
+		Characteristics: []*simulator.Characteristic{
+			{UUID: BatteryLevelUUID, Properties: goble.Read | goble.Notify, Value: []byte{level}},
+		},
+	}
+}
+
+func TestDeviceInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sim, p, ble, peripheral := connected(t, ctx, &simulator.Service{
+		UUID: ServiceUUID,
+		Characteristics: []*simulator.Characteristic{
+			{UUID: ManufacturerNameUUID, Properties: goble.Read, Value: []byte("goble")},
+			{UUID: ModelNumberUUID, Properties: goble.Read, Value: []byte("sim-1")},
+			{UUID: PnPIDUUID, Properties: goble.Read, Value: []byte{0x01, 0x4c, 0x00, 0x34, 0x12, 0x00, 0x01}},
+		},
+	}, batteryService(87))
+	defer sim.Close()
+
+	c, err := New(ctx, ble, peripheral)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := c.DeviceInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ManufacturerName != "goble" || info.ModelNumber != "sim-1" || info.SerialNumber != "" || info.SystemID != nil {
+		t.Errorf("got %+v", info)
+	}
+	if want := (goble.PnPID{VendorIDSource: 1, VendorID: 0x004c, ProductID: 0x1234, ProductVersion: 0x0100}); info.PnPID == nil || *info.PnPID != want {
+		t.Errorf("got PnP ID %v, want %v", info.PnPID, want)
+	}
+	if info.Battery != 87 {
+		t.Errorf("got battery %d, want 87", info.Battery)
+	}
+
+	levels, err := c.SubscribeBattery(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Notify(BatteryLevelUUID, []byte{50})
+	select {
+	case level := <-levels:
+		if level != 50 {
+			t.Errorf("got level %v, want 50", level)
+		}
+	case <-ctx.Done():
+		t.Fatal("no battery notification")
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sim, _, ble, peripheral := connected(t, ctx, &simulator.Service{
+		UUID: ServiceUUID,
+		Characteristics: []*simulator.Characteristic{
+			{UUID: ManufacturerNameUUID, Properties: goble.Read, Value: []byte("goble")},
+		},
+	})
+	defer sim.Close()
+
+	c, err := New(ctx, ble, peripheral)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	info, err := c.DeviceInfo(ctx)
+	if err != nil || info.ManufacturerName != "goble" || info.Battery != -1 {
+		t.Errorf("got %+v, %v", info, err)
+	}
+	if _, err := c.BatteryLevel(ctx); err != central.ErrNotFound {
+		t.Errorf("got %v, want %v", err, central.ErrNotFound)
+	}
+	if _, err := c.SubscribeBattery(ctx); err != central.ErrNotFound {
+		t.Errorf("got %v, want %v", err, central.ErrNotFound)
+	}
+}
+
+func TestNoServices(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sim, _, ble, peripheral := connected(t, ctx, &simulator.Service{UUID: goble.UUID16(0x1805)})
+	defer sim.Close()
+
+	if _, err := New(ctx, ble, peripheral); err != central.ErrNotFound {
+		t.Errorf("got %v, want %v", err, central.ErrNotFound)
+	}
+}
@@ -0,0 +1,124 @@
+// Package heartrate implements a Heart Rate Service (0x180D) client.
+package heartrate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/internal/central"
+)
+
+var (
+	ServiceUUID            = goble.UUID16(0x180d)
+	MeasurementUUID        = goble.UUID16(0x2a37)
+	BodySensorLocationUUID = goble.UUID16(0x2a38)
+	ControlPointUUID       = goble.UUID16(0x2a39)
+)
+
+// Heart Rate Control Point commands
+const resetEnergyExpended = 0x01
+
+// time allowed to unsubscribe on Close
+const closeTimeout = 2 * time.Second
+
+// Client is connected to a heart rate sensor
+type Client struct {
+	conn    *central.Conn
+	service *goble.ServiceHandle
+
+	mu           sync.Mutex
+	measurements chan goble.HeartRateMeasurement
+	closed       bool
+}
+
+// Connect connects to a discovered peripheral and subscribes to Heart Rate
+// Measurement notifications
+func Connect(ctx context.Context, ble *goble.BLE, p goble.Peripheral) (*Client, error) {
+	conn, err := central.Dial(ctx, ble, p.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:         conn,
+		measurements: make(chan goble.HeartRateMeasurement, 16),
+	}
+	if c.service, err = conn.DiscoverService(ctx, ServiceUUID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Subscribe(ctx, c.service, MeasurementUUID, c.measurement); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go func() {
+		<-conn.Done()
+		c.close()
+	}()
+	return c, nil
+}
+
+// measurement decodes a notification, dropping it if the channel is full
+func (c *Client) measurement(data []byte) {
+	v, err := goble.Decode(MeasurementUUID.String(), data)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.measurements <- v.(goble.HeartRateMeasurement):
+	default:
+	}
+}
+
+func (c *Client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.measurements)
+	}
+}
+
+// Measurements delivers decoded measurements, it is closed on disconnect.
+// Measurements are dropped while the channel is full.
+func (c *Client) Measurements() <-chan goble.HeartRateMeasurement {
+	return c.measurements
+}
+
+// BodySensorLocation reads the location of the sensor
+func (c *Client) BodySensorLocation(ctx context.Context) (goble.BodySensorLocation, error) {
+	data, err := c.conn.Read(ctx, c.service, BodySensorLocationUUID)
+	if err != nil {
+		return 0, err
+	}
+	v, err := goble.Decode(BodySensorLocationUUID.String(), data)
+	if err != nil {
+		return 0, err
+	}
+	return v.(goble.BodySensorLocation), nil
+}
+
+// ResetEnergyExpended resets the accumulated energy expended to zero
+func (c *Client) ResetEnergyExpended(ctx context.Context) error {
+	return c.conn.Write(ctx, c.service, ControlPointUUID, []byte{resetEnergyExpended}, false)
+}
+
+// Close unsubscribes and disconnects
+func (c *Client) Close() error {
+	select {
+	case <-c.conn.Done():
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		c.conn.Unsubscribe(ctx, c.service, MeasurementUUID)
+		cancel()
+	}
+	err := c.conn.Close()
+	c.close()
+	return err
+}
@@ -0,0 +1,82 @@
+package heartrate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/simulator"
+	"github.com/dim13/goble/xpc"
+)
+
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reset := make(chan []byte, 1)
+	p := &simulator.Peripheral{
+		UUID:          xpc.MustUUID("00112233445566778899aabbccddeeff"),
+		Advertisement: simulator.Advertisement{ServiceUUIDs: []xpc.UUID{ServiceUUID}},
+		Services: []*simulator.Service{{
+			UUID: ServiceUUID,
+			Characteristics: []*simulator.Characteristic{
+				{UUID: MeasurementUUID, Properties: goble.Notify},
+				{UUID: BodySensorLocationUUID, Properties: goble.Read, Value: []byte{1}},
+				{
+					UUID:       ControlPointUUID,
+					Properties: goble.Write,
+					OnWrite: func(data []byte, withoutResponse bool) error {
+						reset <- data
+						return nil
+					},
+				},
+			},
+		}},
+	}
+	sim := simulator.New(simulator.Mojave)
+	defer sim.Close()
+	sim.Add(p)
+
+	ble := sim.BLE()
+	discovered := make(chan goble.Peripheral, 1)
+	ble.Listen(func(ev goble.Event) bool {
+		if ev.Name == "discover" {
+			discovered <- ev.Peripheral
+			return true
+		}
+		return false
+	})
+	ble.StartScanning([]xpc.UUID{ServiceUUID}, false)
+
+	c, err := Connect(ctx, ble, <-discovered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc, err := c.BodySensorLocation(ctx)
+	if err != nil || loc != goble.BodySensorLocation(1) {
+		t.Errorf("got location %v, %v", loc, err)
+	}
+	if err := c.ResetEnergyExpended(ctx); err != nil {
+		t.Error(err)
+	}
+	if data := <-reset; len(data) != 1 || data[0] != resetEnergyExpended {
+		t.Errorf("got control point %v", data)
+	}
+
+	p.Notify(MeasurementUUID, []byte{0x00, 72})
+	select {
+	case m := <-c.Measurements():
+		if m.HeartRate != 72 {
+			t.Errorf("got heart rate %v", m.HeartRate)
+		}
+	case <-ctx.Done():
+		t.Fatal("no measurement")
+	}
+
+	p.Disconnect()
+	for range c.Measurements() {
+	}
+	c.Close()
+}
@@ -0,0 +1,272 @@
+// Package nus implements the Nordic UART Service, a byte stream over GATT.
+//
+// Dial returns a net.Conn to a peripheral exposing the service, Server
+// exposes the service on the local peripheral.
+package nus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/internal/central"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	ServiceUUID = xpc.MustUUID("6e400001b5a3f393e0a9e50e24dcca9e")
+	RXUUID      = xpc.MustUUID("6e400002b5a3f393e0a9e50e24dcca9e") // written by the central
+	TXUUID      = xpc.MustUUID("6e400003b5a3f393e0a9e50e24dcca9e") // notified by the peripheral
+)
+
+// writes without response sent before waiting for a write response
+const window = 8
+
+// time allowed to unsubscribe on Close
+const closeTimeout = 2 * time.Second
+
+// time allowed for a notification to be taken by the transmit queue
+const readyTimeout = 2 * time.Second
+
+var ErrNotSubscribed = errors.New("nus: no central subscribed")
+
+// Conn is a stream to a peripheral, it implements net.Conn
+type Conn struct {
+	conn    *central.Conn
+	service *goble.ServiceHandle
+	rx      *stream
+
+	wmu           sync.Mutex
+	writeDeadline time.Time
+}
+
+// Dial connects to a discovered peripheral and subscribes to its TX characteristic
+func Dial(ctx context.Context, ble *goble.BLE, p goble.Peripheral) (*Conn, error) {
+	conn, err := central.Dial(ctx, ble, p.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{conn: conn, rx: newStream()}
+	if c.service, err = conn.DiscoverService(ctx, ServiceUUID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Subscribe(ctx, c.service, TXUUID, c.rx.push); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go func() {
+		<-conn.Done()
+		c.rx.close(io.EOF)
+	}()
+	return c, nil
+}
+
+// Read reads data notified by the peripheral
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.rx.Read(p)
+}
+
+// Write writes p to the RX characteristic in chunks of MTU-3 bytes. Chunks
+// are written without response, every window-th and the last chunk with
+// response to wait for the peripheral to catch up.
+func (c *Conn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	ctx := context.Background()
+	if !c.writeDeadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.writeDeadline)
+		defer cancel()
+	}
+
+	size := c.conn.MTU() - 3
+	n := 0
+	for i := 0; n < len(p); i++ {
+		chunk := p[n:]
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+		last := n+len(chunk) == len(p)
+		withoutResponse := !last && (i+1)%window != 0
+		if err := c.conn.Write(ctx, c.service, RXUUID, chunk, withoutResponse); err != nil {
+			if err == context.DeadlineExceeded {
+				err = errTimeout
+			}
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+// Close unsubscribes and disconnects
+func (c *Conn) Close() error {
+	select {
+	case <-c.conn.Done():
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		c.conn.Unsubscribe(ctx, c.service, TXUUID)
+		cancel()
+	}
+	c.rx.close(io.EOF)
+	return c.conn.Close()
+}
+
+func (c *Conn) LocalAddr() net.Addr  { return Addr{} }
+func (c *Conn) RemoteAddr() net.Addr { return Addr(c.conn.Device()) }
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.rx.setDeadline(t)
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.wmu.Lock()
+	c.writeDeadline = t
+	c.wmu.Unlock()
+	return nil
+}
+
+// Server is the local side of the service: data written by centrals to RX is
+// read with Read, Write notifies subscribed centrals on TX.
+type Server struct {
+	ble    *goble.BLE
+	rx     *stream
+	ready  chan struct{} // signaled when the next notification can be sent
+	cancel func()
+
+	wmu sync.Mutex // serializes Write
+
+	mu         sync.Mutex
+	mtu        int
+	subscribed int
+}
+
+// NewServer creates a server, add its Service to the services passed to
+// BLE.SetServices
+func NewServer(ble *goble.BLE) *Server {
+	s := &Server{ble: ble, rx: newStream(), ready: make(chan struct{}, 1), mtu: 23}
+	s.cancel = ble.Listen(s.handle)
+	return s
+}
+
+func (s *Server) handle(ev goble.Event) bool {
+	if ev.Name == "readyToUpdate" {
+		s.signal()
+		return false
+	}
+	if !goble.EqualUUID(ev.CharacteristicUuid, TXUUID.String()) {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch ev.Name {
+	case "subscribe":
+		s.subscribed++
+		if s.subscribed == 1 || ev.Mtu < s.mtu {
+			s.mtu = ev.Mtu
+		}
+	case "unsubscribe":
+		if s.subscribed > 0 {
+			s.subscribed--
+		}
+		s.signal() // a pending Write fails on its next notification
+	}
+	return false
+}
+
+func (s *Server) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until the transmit queue takes the last notification
+func (s *Server) wait() error {
+	t := time.NewTimer(readyTimeout)
+	defer t.Stop()
+	select {
+	case <-s.ready:
+		return nil
+	case <-t.C:
+		return errTimeout
+	}
+}
+
+// Service returns the GATT service
+func (s *Server) Service() goble.Service {
+	rx := goble.NewCharacteristic(RXUUID, goble.Write|goble.WriteWithoutResponse, nil)
+	rx.HandleWrite(func(_ xpc.UUID, data []byte, offset int, _ bool) error {
+		if offset != 0 {
+			return goble.ErrAttributeNotLong
+		}
+		s.rx.push(data)
+		return nil
+	})
+	tx := goble.NewCharacteristic(TXUUID, goble.Notify, nil)
+	return goble.NewService(ServiceUUID, rx, tx)
+}
+
+// Read reads data written by centrals
+func (s *Server) Read(p []byte) (int, error) {
+	return s.rx.Read(p)
+}
+
+// Write notifies p to subscribed centrals in chunks of MTU-3 bytes, each
+// chunk waits for the ready to update event of the one before, so bursts are
+// not dropped by a full transmit queue
+func (s *Server) Write(p []byte) (int, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	select {
+	case <-s.ready: // left over from an unsubscribe
+	default:
+	}
+
+	s.mu.Lock()
+	size, subscribed := s.mtu-3, s.subscribed > 0
+	s.mu.Unlock()
+	if !subscribed {
+		return 0, ErrNotSubscribed
+	}
+	n := 0
+	for n < len(p) {
+		chunk := p[n:]
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+		if !s.ble.UpdateValue(TXUUID, chunk) {
+			return n, ErrNotSubscribed
+		}
+		if err := s.wait(); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+// SetReadDeadline sets the deadline for Read
+func (s *Server) SetReadDeadline(t time.Time) error {
+	s.rx.setDeadline(t)
+	return nil
+}
+
+// Close stops the server, pending and future reads return io.EOF
+func (s *Server) Close() error {
+	s.cancel()
+	s.rx.close(io.EOF)
+	return nil
+}
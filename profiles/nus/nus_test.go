@@ -0,0 +1,223 @@
+package nus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/simulator"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	deviceUUID  = xpc.MustUUID("00112233445566778899aabbccddeeff")
+	centralUUID = xpc.MustUUID("ffeeddccbbaa99887766554433221100")
+)
+
+// write is a write received by the simulated RX characteristic
+type write struct {
+	data            []byte
+	withoutResponse bool
+}
+
+// dial connects to a simulated peripheral exposing the service
+func dial(t *testing.T, ctx context.Context, onWrite func(data []byte, withoutResponse bool) error) (*simulator.Simulator, *simulator.Peripheral, *Conn) {
+	p := &simulator.Peripheral{
+		UUID:          deviceUUID,
+		Advertisement: simulator.Advertisement{ServiceUUIDs: []xpc.UUID{ServiceUUID}},
+		Services: []*simulator.Service{{
+			UUID: ServiceUUID,
+			Characteristics: []*simulator.Characteristic{{
+				UUID:       RXUUID,
+				Properties: goble.Write | goble.WriteWithoutResponse,
+				OnWrite:    onWrite,
+			}, {
+				UUID:       TXUUID,
+				Properties: goble.Notify,
+			}},
+		}},
+	}
+	sim := simulator.New(simulator.Mojave)
+	sim.Add(p)
+
+	ble := sim.BLE()
+	discovered := make(chan goble.Peripheral, 1)
+	ble.Listen(func(ev goble.Event) bool {
+		if ev.Name == "discover" {
+			discovered <- ev.Peripheral
+			return true
+		}
+		return false
+	})
+	ble.StartScanning([]xpc.UUID{ServiceUUID}, false)
+
+	c, err := Dial(ctx, ble, <-discovered)
+	if err != nil {
+		sim.Close()
+		t.Fatal(err)
+	}
+	return sim, p, c
+}
+
+func TestDial(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	writes := make(chan write, 16)
+	sim, p, c := dial(t, ctx, func(data []byte, withoutResponse bool) error {
+		writes <- write{data, withoutResponse}
+		return nil
+	})
+	defer sim.Close()
+
+	p.Notify(TXUUID, []byte("hello "))
+	p.Notify(TXUUID, []byte("world"))
+	buf := make([]byte, 11)
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "hello world" {
+		t.Errorf("got %q, %v", buf, err)
+	}
+
+	// 10 chunks of MTU-3 bytes, the window-th and the last with response
+	data := bytes.Repeat([]byte{'x'}, 10*20)
+	if n, err := c.Write(data); n != len(data) || err != nil {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	for i := 0; i < 10; i++ {
+		w := <-writes
+		if len(w.data) != 20 {
+			t.Errorf("chunk %d: got %d bytes, want 20", i, len(w.data))
+		}
+		if want := i != window-1 && i != 9; w.withoutResponse != want {
+			t.Errorf("chunk %d: got without response %v, want %v", i, w.withoutResponse, want)
+		}
+	}
+
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, err := c.Read(buf); err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
+
+func TestDialWriteDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the peripheral stalls on the first write with response
+	stall := make(chan struct{})
+	sim, _, c := dial(t, ctx, func(data []byte, withoutResponse bool) error {
+		if !withoutResponse {
+			<-stall
+		}
+		return nil
+	})
+	defer sim.Close()
+	defer close(stall)
+
+	c.SetWriteDeadline(time.Now().Add(50 * time.Millisecond))
+	n, err := c.Write(bytes.Repeat([]byte{'x'}, 2*window*20))
+	if e, ok := err.(interface{ Timeout() bool }); !ok || !e.Timeout() {
+		t.Errorf("got %v, want timeout", err)
+	}
+	if n >= window*20 {
+		t.Errorf("wrote %d bytes past the window", n)
+	}
+}
+
+// transport answers the messages of a local peripheral, notifications are
+// passed to the test, which reports the transmit queue ready
+type transport struct {
+	updates chan []byte
+}
+
+func (tr *transport) Send(msg interface{}, verbose bool) {
+	m := msg.(xpc.Dict)
+	if m["kCBMsgId"] == 15 {
+		args := m["kCBMsgArgs"].(xpc.Dict)
+		tr.updates <- args["kCBMsgArgData"].([]byte)
+	}
+}
+
+// event delivers a blued event to ble
+func event(ble *goble.BLE, id int, args xpc.Dict) {
+	ble.HandleXpcEvent(xpc.Dict{"kCBMsgId": int64(id), "kCBMsgArgs": args}, nil)
+}
+
+func TestServer(t *testing.T) {
+	tr := &transport{updates: make(chan []byte, 4)}
+	ble := goble.NewWithTransport(tr, "")
+	s := NewServer(ble)
+	defer s.Close()
+	ble.SetServices([]goble.Service{s.Service()})
+	// events reach the server first, it listens before the test
+	subscribed := make(chan string, 1)
+	ble.Listen(func(ev goble.Event) bool {
+		if ev.Name == "subscribe" || ev.Name == "unsubscribe" {
+			subscribed <- ev.Name
+		}
+		return false
+	})
+
+	if _, err := s.Write([]byte("x")); err != ErrNotSubscribed {
+		t.Errorf("got %v, want %v", err, ErrNotSubscribed)
+	}
+
+	// attribute ids: service 1, RX 2, TX 3
+	event(ble, 20, xpc.Dict{
+		"kCBMsgArgCentralUUID":   centralUUID,
+		"kCBMsgArgTransactionID": int64(1),
+		"kCBMsgArgATTWrites": xpc.Array{xpc.Dict{
+			"kCBMsgArgAttributeID":    int64(2),
+			"kCBMsgArgData":           []byte("ping"),
+			"kCBMsgArgIgnoreResponse": int64(1),
+		}},
+	})
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(s, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("got %q, %v", buf, err)
+	}
+
+	event(ble, 21, xpc.Dict{
+		"kCBMsgArgAttributeID": int64(3),
+		"kCBMsgArgCentralUUID": centralUUID,
+		"kCBMsgArgATTMTU":      int64(23),
+	})
+	<-subscribed
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Write(bytes.Repeat([]byte{'x'}, 50))
+		done <- err
+	}()
+	for i, want := range []int{20, 20, 10} {
+		if data := <-tr.updates; len(data) != want {
+			t.Errorf("notification %d: got %d bytes, want %d", i, len(data), want)
+		}
+		select {
+		case <-tr.updates:
+			t.Fatalf("notification %d: sent before ready", i+1)
+		case <-time.After(20 * time.Millisecond):
+		}
+		event(ble, 23, xpc.Dict{})
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+
+	// an unsubscribe ends a Write waiting for the transmit queue
+	go func() {
+		_, err := s.Write(bytes.Repeat([]byte{'x'}, 50))
+		done <- err
+	}()
+	<-tr.updates
+	event(ble, 22, xpc.Dict{
+		"kCBMsgArgAttributeID": int64(3),
+		"kCBMsgArgCentralUUID": centralUUID,
+	})
+	if err := <-done; err != ErrNotSubscribed {
+		t.Errorf("got %v, want %v", err, ErrNotSubscribed)
+	}
+}
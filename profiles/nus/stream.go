@@ -0,0 +1,106 @@
+package nus
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dim13/goble/xpc"
+)
+
+// Addr is the address of a peripheral or central
+type Addr xpc.UUID
+
+func (a Addr) Network() string { return "ble" }
+func (a Addr) String() string  { return xpc.UUID(a).String() }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var errTimeout net.Error = timeoutError{}
+
+// stream buffers received data for Read
+type stream struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	ready    chan struct{} // signaled when data arrives or the stream closes
+	err      error         // returned once the buffer is drained
+	deadline time.Time
+}
+
+func newStream() *stream {
+	return &stream{ready: make(chan struct{}, 1)}
+}
+
+func (s *stream) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// push appends received data, it never blocks
+func (s *stream) push(b []byte) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.buf.Write(b)
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+// close makes Read return err after the buffered data
+func (s *stream) close(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *stream) setDeadline(t time.Time) {
+	s.mu.Lock()
+	s.deadline = t
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	for {
+		s.mu.Lock()
+		if s.buf.Len() > 0 {
+			n, _ := s.buf.Read(p)
+			if s.buf.Len() > 0 {
+				s.signal()
+			}
+			s.mu.Unlock()
+			return n, nil
+		}
+		err, deadline := s.err, s.deadline
+		s.mu.Unlock()
+
+		if err != nil {
+			return 0, err
+		}
+		if deadline.IsZero() {
+			<-s.ready
+			continue
+		}
+		d := time.Until(deadline)
+		if d <= 0 {
+			return 0, errTimeout
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-s.ready:
+			t.Stop()
+		case <-t.C:
+			return 0, errTimeout
+		}
+	}
+}
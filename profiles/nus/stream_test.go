@@ -0,0 +1,48 @@
+package nus
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	s := newStream()
+	go func() {
+		s.push([]byte("hello "))
+		s.push([]byte("world"))
+		s.close(io.EOF)
+	}()
+
+	var got []byte
+	buf := make([]byte, 4)
+	for {
+		n, err := s.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(got) != "hello world" {
+		t.Errorf("got %q, want %q", got, "hello world")
+	}
+}
+
+func TestStreamDeadline(t *testing.T) {
+	s := newStream()
+	s.setDeadline(time.Now().Add(20 * time.Millisecond))
+	_, err := s.Read(make([]byte, 1))
+	if e, ok := err.(net.Error); !ok || !e.Timeout() {
+		t.Fatalf("got %v, want timeout", err)
+	}
+
+	s.setDeadline(time.Time{})
+	go s.push([]byte{1})
+	if n, err := s.Read(make([]byte, 1)); n != 1 || err != nil {
+		t.Errorf("got %v %v", n, err)
+	}
+}
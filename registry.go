@@ -0,0 +1,107 @@
+package goble
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var ErrInvalidUUID = errors.New("invalid uuid")
+
+// names registered by the application, consulted before the SIG tables
+var registry = struct {
+	sync.RWMutex
+	services        map[string]AssignedNumber
+	characteristics map[string]AssignedNumber
+	descriptors     map[string]AssignedNumber
+}{
+	services:        map[string]AssignedNumber{},
+	characteristics: map[string]AssignedNumber{},
+	descriptors:     map[string]AssignedNumber{},
+}
+
+func register(m map[string]AssignedNumber, uuid, name, typ string) error {
+	key := shortUUID(uuid)
+	if _, err := hex.DecodeString(key); err != nil || (len(key) != 4 && len(key) != 32) {
+		return ErrInvalidUUID
+	}
+	registry.Lock()
+	m[key] = AssignedNumber{Name: name, Type: typ}
+	registry.Unlock()
+	return nil
+}
+
+func registered(m map[string]AssignedNumber, uuid string) (AssignedNumber, bool) {
+	registry.RLock()
+	n, ok := m[shortUUID(uuid)]
+	registry.RUnlock()
+	return n, ok
+}
+
+// RegisterService names a (vendor) service uuid, overriding the SIG name
+func RegisterService(uuid, name, typ string) error {
+	return register(registry.services, uuid, name, typ)
+}
+
+// RegisterCharacteristic names a (vendor) characteristic uuid, overriding the SIG name
+func RegisterCharacteristic(uuid, name, typ string) error {
+	return register(registry.characteristics, uuid, name, typ)
+}
+
+// RegisterDescriptor names a (vendor) descriptor uuid, overriding the SIG name
+func RegisterDescriptor(uuid, name, typ string) error {
+	return register(registry.descriptors, uuid, name, typ)
+}
+
+type registeredName struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// LoadNames registers the names read from a JSON document of the form
+//
+//	{
+//		"services": [{"uuid": "6e400001-b5a3-f393-e0a9-e50e24dcca9e", "name": "Nordic UART"}],
+//		"characteristics": [{"uuid": "...", "name": "...", "type": "..."}],
+//		"descriptors": [...]
+//	}
+func LoadNames(r io.Reader) error {
+	var doc struct {
+		Services        []registeredName `json:"services"`
+		Characteristics []registeredName `json:"characteristics"`
+		Descriptors     []registeredName `json:"descriptors"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+	for _, list := range []struct {
+		names    []registeredName
+		register func(uuid, name, typ string) error
+	}{
+		{doc.Services, RegisterService},
+		{doc.Characteristics, RegisterCharacteristic},
+		{doc.Descriptors, RegisterDescriptor},
+	} {
+		for _, n := range list.names {
+			if err := list.register(n.UUID, n.Name, n.Type); err != nil {
+				return fmt.Errorf("%s: %w", n.UUID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadNamesFile registers the names read from a JSON file, see LoadNames
+func LoadNamesFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return LoadNames(f)
+}
@@ -0,0 +1,91 @@
+package goble
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// unregister removes names registered by a test
+func unregister(m map[string]AssignedNumber, uuids ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, uuid := range uuids {
+		delete(m, shortUUID(uuid))
+	}
+}
+
+func TestRegisterService(t *testing.T) {
+	const uuid = "6E400001-B5A3-F393-E0A9-E50E24DCCA9E"
+	defer unregister(registry.services, uuid)
+	if err := RegisterService(uuid, "Nordic UART", "com.nordicsemi.service.uart"); err != nil {
+		t.Fatal(err)
+	}
+	n, ok := LookupService("6e400001b5a3f393e0a9e50e24dcca9e")
+	if !ok || n.Name != "Nordic UART" || n.Type != "com.nordicsemi.service.uart" {
+		t.Errorf("got %v %v", n, ok)
+	}
+	if err := RegisterService("not a uuid", "", ""); err != ErrInvalidUUID {
+		t.Errorf("got %v, want %v", err, ErrInvalidUUID)
+	}
+}
+
+func TestRegisterOverride(t *testing.T) {
+	defer unregister(registry.characteristics, "2a19")
+	if err := RegisterCharacteristic("2A19", "Charge", ""); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := LookupCharacteristic("2a19"); n.Name != "Charge" {
+		t.Errorf("got %q, want Charge", n.Name)
+	}
+}
+
+func TestLoadNames(t *testing.T) {
+	doc := `{
+		"services": [{"uuid": "0000fff0-0000-1000-8000-00805f9b34fb", "name": "Widget"}],
+		"characteristics": [{"uuid": "12345678123456781234567812345678", "name": "Knob", "type": "com.example.knob"}],
+		"descriptors": [{"uuid": "fff2", "name": "Knob Range"}]
+	}`
+	defer unregister(registry.services, "fff0")
+	defer unregister(registry.characteristics, "12345678123456781234567812345678")
+	defer unregister(registry.descriptors, "fff2")
+	if err := LoadNames(strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		lookup func(string) (AssignedNumber, bool)
+		uuid   string
+		name   string
+	}{
+		{LookupService, "fff0", "Widget"},
+		{LookupCharacteristic, "12345678-1234-5678-1234-567812345678", "Knob"},
+		{LookupDescriptor, "0xFFF2", "Knob Range"},
+	} {
+		if n, ok := tc.lookup(tc.uuid); !ok || n.Name != tc.name {
+			t.Errorf("%s: got %q %v, want %q", tc.uuid, n.Name, ok, tc.name)
+		}
+	}
+
+	err := LoadNames(strings.NewReader(`{"services": [{"uuid": "xyz"}]}`))
+	if !errors.Is(err, ErrInvalidUUID) {
+		t.Errorf("got %v, want %v", err, ErrInvalidUUID)
+	}
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	defer unregister(registry.descriptors, "fff3")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterDescriptor("fff3", "Concurrent", "")
+		}()
+		go func() {
+			defer wg.Done()
+			LookupDescriptor("fff3")
+		}()
+	}
+	wg.Wait()
+}
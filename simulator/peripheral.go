@@ -0,0 +1,205 @@
+package simulator
+
+import (
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+// Advertisement is the advertising data of a virtual peripheral
+type Advertisement struct {
+	LocalName        string
+	TxPowerLevel     int
+	ManufacturerData []byte
+	ServiceUUIDs     []xpc.UUID
+	ServiceData      map[xpc.UUID][]byte
+}
+
+// Peripheral is a virtual peripheral, its fields must not be changed once
+// it is added to a Simulator
+type Peripheral struct {
+	UUID          xpc.UUID
+	Advertisement Advertisement
+	RSSI          int
+	Services      []*Service
+
+	// NotConnectable peripherals advertise, but connect requests are
+	// never answered
+	NotConnectable bool
+	// ConnectLatency delays the connect event
+	ConnectLatency time.Duration
+	// MTU is reported with an mtuChange event after the connect, if set
+	MTU int
+
+	sim       *Simulator
+	connected bool
+	handles   map[int]*Characteristic // by declaration and value handle
+}
+
+// Service is a primary service of a virtual peripheral
+type Service struct {
+	UUID            xpc.UUID
+	Characteristics []*Characteristic
+
+	startHandle, endHandle int
+}
+
+// Characteristic of a virtual peripheral.
+//
+// Reads return Value, unless OnRead is set; writes replace Value, unless
+// OnWrite is set. Errors returned by the hooks are reported as the ATT result,
+// use a goble.ATTError to choose the code.
+type Characteristic struct {
+	UUID        xpc.UUID
+	Properties  goble.Property
+	Value       []byte
+	Descriptors []*Descriptor
+
+	OnRead  func() ([]byte, error)
+	OnWrite func(data []byte, withoutResponse bool) error
+
+	handle, valueHandle int
+	notifying           bool
+}
+
+// Descriptor of a virtual characteristic
+type Descriptor struct {
+	UUID  xpc.UUID
+	Value []byte
+
+	handle int
+}
+
+// Advertise sends a new advertisement of p, if scanning
+func (p *Peripheral) Advertise() {
+	p.sim.do(func() { p.sim.advertise(p) })
+}
+
+// SetRSSI changes the signal strength reported by discover and rssi updates
+func (p *Peripheral) SetRSSI(rssi int) {
+	p.sim.do(func() { p.RSSI = rssi })
+}
+
+// Disconnect drops the connection from the peripheral side
+func (p *Peripheral) Disconnect() {
+	p.sim.do(func() { p.sim.disconnect(p) })
+}
+
+// Notify sends a notification, it is dropped unless the central subscribed
+// to the characteristic
+func (p *Peripheral) Notify(uuid xpc.UUID, data []byte) {
+	p.sim.do(func() {
+		if !p.connected {
+			return
+		}
+		for _, s := range p.Services {
+			for _, c := range s.Characteristics {
+				if c.UUID == uuid && c.notifying {
+					p.sim.emit(p.sim.eventID(70, 95), xpc.Dict{
+						"kCBMsgArgDeviceUUID":           p.UUID,
+						"kCBMsgArgCharacteristicHandle": int64(c.handle),
+						"kCBMsgArgData":                 data,
+						"kCBMsgArgIsNotification":       int64(1),
+						"kCBMsgArgResult":               int64(0),
+					})
+					return
+				}
+			}
+		}
+	})
+}
+
+// assign numbers the attributes like a GATT server would
+func (p *Peripheral) assign() {
+	p.handles = map[int]*Characteristic{}
+	h := 1
+	for _, s := range p.Services {
+		s.startHandle = h
+		for _, c := range s.Characteristics {
+			c.handle, c.valueHandle = h+1, h+2
+			h += 2
+			p.handles[c.handle] = c
+			p.handles[c.valueHandle] = c
+			for _, d := range c.Descriptors {
+				h++
+				d.handle = h
+			}
+		}
+		s.endHandle = h
+		h++
+	}
+}
+
+// service returns the service starting at handle
+func (p *Peripheral) service(handle int) *Service {
+	for _, s := range p.Services {
+		if s.startHandle == handle {
+			return s
+		}
+	}
+	return nil
+}
+
+// read returns the value of c, or the ATT error code
+func (c *Characteristic) read() ([]byte, int) {
+	if c.Properties&goble.Read == 0 {
+		return nil, int(goble.ErrReadNotPermitted)
+	}
+	if c.OnRead != nil {
+		data, err := c.OnRead()
+		return data, result(err)
+	}
+	return c.Value, 0
+}
+
+// write stores data in c, or returns the ATT error code
+func (c *Characteristic) write(data []byte, withoutResponse bool) int {
+	allowed := goble.Write
+	if withoutResponse {
+		allowed = goble.WriteWithoutResponse
+	}
+	if c.Properties&allowed == 0 {
+		return int(goble.ErrWriteNotPermitted)
+	}
+	if c.OnWrite != nil {
+		return result(c.OnWrite(data, withoutResponse))
+	}
+	c.Value = append([]byte(nil), data...)
+	return 0
+}
+
+// result converts a hook error to an ATT result code
+func result(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case goble.ATTError:
+		return int(e)
+	}
+	return int(goble.ErrUnlikely)
+}
+
+// uuidBytes returns the 2 byte form of assigned numbers and 16 bytes otherwise,
+// as blued does
+func uuidBytes(uuid xpc.UUID) []byte {
+	if n, ok := goble.Short(uuid); ok {
+		return []byte{byte(n >> 8), byte(n)}
+	}
+	return uuid.Bytes()
+}
+
+// matches reports whether uuid is in the requested list, an empty list
+// matches everything
+func matches(uuid xpc.UUID, uuids interface{}) bool {
+	list, _ := uuids.([]string)
+	if len(list) == 0 {
+		return true
+	}
+	for _, u := range list {
+		if goble.EqualUUID(u, uuid.String()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,589 @@
+// Package simulator answers the blued messages sent by goble with the events
+// of virtual peripherals, to test central code without a Mac or devices.
+//
+//	sim := simulator.New(simulator.Mojave)
+//	sim.Add(&simulator.Peripheral{...})
+//	ble := sim.BLE()
+//	ble.Init()
+//
+// Messages are handled and events delivered in order on a single goroutine,
+// the Read and Write hooks of characteristics run there too.
+package simulator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+// darwin releases, the message and event ids of blued depend on them
+const (
+	ElCapitan  = "15.6.0"
+	Sierra     = "16.7.0"
+	HighSierra = "17.7.0"
+	Mojave     = "18.7.0"
+	Catalina   = "19.6.0"
+)
+
+// adapter states, as in CBManagerState
+const (
+	poweredOff = 4
+	poweredOn  = 5
+)
+
+// Simulator is a goble.Transport backed by virtual peripherals
+type Simulator struct {
+	ble      *goble.BLE
+	release  string
+	requests map[int]string
+
+	mu       sync.Mutex
+	queue    []func()
+	wake     chan struct{}
+	closed   bool
+	messages []xpc.Dict
+
+	// owned by the run goroutine
+	peripherals []*Peripheral
+	powered     bool
+	scanning    bool
+	filter      interface{} // service uuids to scan for
+}
+
+// New creates a simulator speaking the protocol of a darwin release
+func New(release string) *Simulator {
+	s := &Simulator{
+		release:  release,
+		requests: requests(release),
+		wake:     make(chan struct{}, 1),
+		powered:  true,
+	}
+	s.ble = goble.NewWithTransport(s, release)
+	go s.run()
+	return s
+}
+
+// BLE returns the goble instance connected to the simulator
+func (s *Simulator) BLE() *goble.BLE {
+	return s.ble
+}
+
+// Close stops the simulator, further messages are dropped
+func (s *Simulator) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.queue = nil
+	s.mu.Unlock()
+	s.signal()
+}
+
+// Messages returns the messages received so far
+func (s *Simulator) Messages() []xpc.Dict {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]xpc.Dict(nil), s.messages...)
+}
+
+// Send receives a message from goble (implements goble.Transport)
+func (s *Simulator) Send(msg interface{}, verbose bool) {
+	m, ok := msg.(xpc.Dict)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	s.messages = append(s.messages, m)
+	s.mu.Unlock()
+	s.do(func() { s.handle(m) })
+}
+
+// do queues fn on the run goroutine
+func (s *Simulator) do(fn func()) {
+	s.mu.Lock()
+	if !s.closed {
+		s.queue = append(s.queue, fn)
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *Simulator) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Simulator) run() {
+	for range s.wake {
+		for {
+			s.mu.Lock()
+			if s.closed {
+				s.mu.Unlock()
+				return
+			}
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			fn := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			fn()
+		}
+	}
+}
+
+// Add makes a peripheral available, it is discovered if scanning
+func (s *Simulator) Add(p *Peripheral) {
+	p.sim = s
+	p.assign()
+	s.do(func() {
+		s.peripherals = append(s.peripherals, p)
+		s.advertise(p)
+	})
+}
+
+// Remove takes a peripheral away, disconnecting it
+func (s *Simulator) Remove(p *Peripheral) {
+	s.do(func() {
+		s.disconnect(p)
+		for i, q := range s.peripherals {
+			if q == p {
+				s.peripherals = append(s.peripherals[:i], s.peripherals[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+// SetPowered switches the adapter on or off, switching off disconnects all
+// peripherals
+func (s *Simulator) SetPowered(on bool) {
+	s.do(func() {
+		s.powered = on
+		state := poweredOn
+		if !on {
+			state = poweredOff
+			s.scanning = false
+			for _, p := range s.peripherals {
+				s.disconnect(p)
+			}
+		}
+		s.emit(6, xpc.Dict{"kCBMsgArgState": int64(state)})
+	})
+}
+
+// emit delivers an event to goble
+func (s *Simulator) emit(id int, args xpc.Dict) {
+	s.ble.HandleXpcEvent(xpc.Dict{
+		"kCBMsgId":   int64(id),
+		"kCBMsgArgs": args,
+	}, nil)
+}
+
+// eventID picks the id used before or since darwin 18
+func (s *Simulator) eventID(old, id int) int {
+	if s.release >= "18." {
+		return id
+	}
+	return old
+}
+
+// requests maps message ids to operations
+func requests(release string) map[int]string {
+	r := map[int]string{
+		1:  "init",
+		8:  "startAdvertising",
+		9:  "stopAdvertising",
+		10: "setServices",
+		12: "removeServices",
+		13: "respondToRequest",
+		15: "updateValue",
+	}
+	add := func(ids map[string]int) {
+		for op, id := range ids {
+			r[id] = op
+		}
+	}
+	if release >= "18." {
+		add(map[string]int{
+			"startScanning":           46,
+			"stopScanning":            47,
+			"connect":                 48,
+			"disconnect":              49,
+			"updateRssi":              71,
+			"discoverServices":        72,
+			"discoverCharacteristics": 87,
+			"discoverDescriptors":     94,
+			"read":                    100,
+			"readDescriptor":          106,
+			"write":                   101,
+			"notify":                  103,
+		})
+		if release >= "19." {
+			delete(r, 46)
+			delete(r, 47)
+			add(map[string]int{"startScanning": 51, "stopScanning": 52})
+		}
+		return r
+	}
+	add(map[string]int{
+		"startScanning":           29,
+		"stopScanning":            30,
+		"connect":                 31,
+		"disconnect":              32,
+		"updateRssi":              43,
+		"discoverServices":        44,
+		"discoverCharacteristics": 61,
+		"discoverDescriptors":     69,
+		"read":                    64,
+		"readDescriptor":          76,
+		"write":                   65,
+		"notify":                  67,
+	})
+	if release >= "17." {
+		// shared with discoverServices, told apart in handle
+		r[44] = "startScanning"
+	}
+	return r
+}
+
+// handle answers a message from goble
+func (s *Simulator) handle(m xpc.Dict) {
+	id, _ := m["kCBMsgId"].(int)
+	args, _ := m["kCBMsgArgs"].(xpc.Dict)
+
+	op := s.requests[id]
+	if op == "startScanning" && args.Contains("kCBMsgArgDeviceUUID") {
+		op = "discoverServices"
+	}
+	if op == "init" {
+		state := poweredOff
+		if s.powered {
+			state = poweredOn
+		}
+		s.emit(6, xpc.Dict{"kCBMsgArgState": int64(state)})
+		return
+	}
+	if !s.powered {
+		return
+	}
+
+	switch op {
+	case "startScanning":
+		s.scanning = true
+		s.filter = args["kCBMsgArgUUIDs"]
+		for _, p := range s.peripherals {
+			s.advertise(p)
+		}
+	case "stopScanning":
+		s.scanning = false
+	case "connect":
+		s.connect(s.peripheral(args))
+	case "disconnect":
+		s.disconnect(s.peripheral(args))
+	case "updateRssi":
+		if p := s.peripheral(args); p != nil && p.connected {
+			s.emit(55, xpc.Dict{
+				"kCBMsgArgDeviceUUID": p.UUID,
+				"kCBMsgArgData":       int64(p.RSSI),
+			})
+		}
+	case "discoverServices":
+		s.discoverServices(s.peripheral(args), args)
+	case "discoverCharacteristics":
+		s.discoverCharacteristics(s.peripheral(args), args)
+	case "discoverDescriptors":
+		s.discoverDescriptors(s.peripheral(args), args)
+	case "read":
+		s.read(s.peripheral(args), args)
+	case "readDescriptor":
+		s.readDescriptor(s.peripheral(args), args)
+	case "write":
+		s.write(s.peripheral(args), args)
+	case "notify":
+		s.notify(s.peripheral(args), args)
+	}
+}
+
+// peripheral returns the peripheral addressed by a message
+func (s *Simulator) peripheral(args xpc.Dict) *Peripheral {
+	uuid, _ := args["kCBMsgArgDeviceUUID"].(xpc.UUID)
+	for _, p := range s.peripherals {
+		if p.UUID == uuid {
+			return p
+		}
+	}
+	return nil
+}
+
+// advertise emits a discover event for p if it matches the scan
+func (s *Simulator) advertise(p *Peripheral) {
+	if !s.scanning {
+		return
+	}
+	ad := p.Advertisement
+	if list, _ := s.filter.([]string); len(list) > 0 {
+		found := false
+		for _, uuid := range ad.ServiceUUIDs {
+			found = found || matches(uuid, list)
+		}
+		if !found {
+			return
+		}
+	}
+
+	data := xpc.Dict{"kCBAdvDataIsConnectable": int64(1)}
+	if p.NotConnectable {
+		data["kCBAdvDataIsConnectable"] = int64(0)
+	}
+	if ad.LocalName != "" {
+		data["kCBAdvDataLocalName"] = ad.LocalName
+	}
+	if ad.TxPowerLevel != 0 {
+		data["kCBAdvDataTxPowerLevel"] = int64(ad.TxPowerLevel)
+	}
+	if len(ad.ManufacturerData) > 0 {
+		data["kCBAdvDataManufacturerData"] = ad.ManufacturerData
+	}
+	if len(ad.ServiceUUIDs) > 0 {
+		uuids := xpc.Array{}
+		for _, uuid := range ad.ServiceUUIDs {
+			uuids = append(uuids, uuidBytes(uuid))
+		}
+		data["kCBAdvDataServiceUUIDs"] = uuids
+	}
+	if len(ad.ServiceData) > 0 {
+		sdata := xpc.Array{}
+		for uuid, d := range ad.ServiceData {
+			sdata = append(sdata, uuidBytes(uuid), d)
+		}
+		data["kCBAdvDataServiceData"] = sdata
+	}
+
+	id := 37
+	if s.release >= "19." {
+		id = 51
+	} else if s.release >= "18." {
+		id = 48
+	}
+	s.emit(id, xpc.Dict{
+		"kCBMsgArgDeviceUUID":        p.UUID,
+		"kCBMsgArgRssi":              int64(p.RSSI),
+		"kCBMsgArgAdvertisementData": data,
+	})
+}
+
+// connect answers a connect request after the peripheral latency
+func (s *Simulator) connect(p *Peripheral) {
+	if p == nil || p.NotConnectable {
+		return
+	}
+	connected := func() {
+		if !s.powered {
+			return
+		}
+		p.connected = true
+		s.emit(s.eventID(38, 67), xpc.Dict{"kCBMsgArgDeviceUUID": p.UUID})
+		if p.MTU > 0 {
+			s.emit(53, xpc.Dict{"kCBMsgArgDeviceUUID": p.UUID, "kCBMsgArgATTMTU": int64(p.MTU)})
+		}
+	}
+	if p.ConnectLatency > 0 {
+		time.AfterFunc(p.ConnectLatency, func() { s.do(connected) })
+		return
+	}
+	connected()
+}
+
+// disconnect emits a disconnect event if p is connected
+func (s *Simulator) disconnect(p *Peripheral) {
+	if p == nil || !p.connected {
+		return
+	}
+	p.connected = false
+	for _, svc := range p.Services {
+		for _, c := range svc.Characteristics {
+			c.notifying = false
+		}
+	}
+	s.emit(40, xpc.Dict{"kCBMsgArgDeviceUUID": p.UUID})
+}
+
+func (s *Simulator) discoverServices(p *Peripheral, args xpc.Dict) {
+	if p == nil || !p.connected {
+		return
+	}
+	services := xpc.Array{}
+	for _, svc := range p.Services {
+		if matches(svc.UUID, args["kCBMsgArgUUIDs"]) {
+			services = append(services, xpc.Dict{
+				"kCBMsgArgUUID":               uuidBytes(svc.UUID),
+				"kCBMsgArgServiceStartHandle": int64(svc.startHandle),
+				"kCBMsgArgServiceEndHandle":   int64(svc.endHandle),
+			})
+		}
+	}
+	s.emit(s.eventID(54, 82), xpc.Dict{
+		"kCBMsgArgDeviceUUID": p.UUID,
+		"kCBMsgArgServices":   services,
+		"kCBMsgArgResult":     int64(0),
+	})
+}
+
+func (s *Simulator) discoverCharacteristics(p *Peripheral, args xpc.Dict) {
+	if p == nil || !p.connected {
+		return
+	}
+	start, _ := args["kCBMsgArgServiceStartHandle"].(int)
+	svc := p.service(start)
+	if svc == nil {
+		return
+	}
+	characteristics := xpc.Array{}
+	for _, c := range svc.Characteristics {
+		if matches(c.UUID, args["kCBMsgArgUUIDs"]) {
+			characteristics = append(characteristics, xpc.Dict{
+				"kCBMsgArgUUID":                      uuidBytes(c.UUID),
+				"kCBMsgArgCharacteristicHandle":      int64(c.handle),
+				"kCBMsgArgCharacteristicValueHandle": int64(c.valueHandle),
+				"kCBMsgArgCharacteristicProperties":  int64(c.Properties),
+			})
+		}
+	}
+	s.emit(s.eventID(63, 89), xpc.Dict{
+		"kCBMsgArgDeviceUUID":         p.UUID,
+		"kCBMsgArgServiceStartHandle": int64(start),
+		"kCBMsgArgCharacteristics":    characteristics,
+		"kCBMsgArgResult":             int64(0),
+	})
+}
+
+// characteristic returns the characteristic addressed by a message
+func (p *Peripheral) characteristic(args xpc.Dict) *Characteristic {
+	if p == nil || !p.connected {
+		return nil
+	}
+	handle, _ := args["kCBMsgArgCharacteristicHandle"].(int)
+	return p.handles[handle]
+}
+
+// descriptor returns the descriptor addressed by a message and its
+// characteristic
+func (p *Peripheral) descriptor(args xpc.Dict) (*Characteristic, *Descriptor) {
+	if p == nil || !p.connected {
+		return nil, nil
+	}
+	handle, _ := args["kCBMsgArgDescriptorHandle"].(int)
+	for _, s := range p.Services {
+		for _, c := range s.Characteristics {
+			for _, d := range c.Descriptors {
+				if d.handle == handle {
+					return c, d
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *Simulator) discoverDescriptors(p *Peripheral, args xpc.Dict) {
+	c := p.characteristic(args)
+	if c == nil {
+		return
+	}
+	descriptors := xpc.Array{}
+	for _, d := range c.Descriptors {
+		descriptors = append(descriptors, xpc.Dict{
+			"kCBMsgArgUUID":             uuidBytes(d.UUID),
+			"kCBMsgArgDescriptorHandle": int64(d.handle),
+		})
+	}
+	s.emit(s.eventID(75, 99), xpc.Dict{
+		"kCBMsgArgDeviceUUID":           p.UUID,
+		"kCBMsgArgCharacteristicHandle": int64(c.handle),
+		"kCBMsgArgDescriptors":          descriptors,
+		"kCBMsgArgResult":               int64(0),
+	})
+}
+
+func (s *Simulator) readDescriptor(p *Peripheral, args xpc.Dict) {
+	c, d := p.descriptor(args)
+	if d == nil {
+		return
+	}
+	data := d.Value
+	if d.UUID == goble.ClientConfigurationUUID {
+		data = []byte{0, 0}
+		if c.notifying {
+			data[0] = 1
+		}
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	s.emit(s.eventID(79, 102), xpc.Dict{
+		"kCBMsgArgDeviceUUID":       p.UUID,
+		"kCBMsgArgDescriptorHandle": int64(d.handle),
+		"kCBMsgArgData":             data,
+		"kCBMsgArgResult":           int64(0),
+	})
+}
+
+func (s *Simulator) read(p *Peripheral, args xpc.Dict) {
+	c := p.characteristic(args)
+	if c == nil {
+		return
+	}
+	data, res := c.read()
+	if data == nil {
+		data = []byte{}
+	}
+	s.emit(s.eventID(70, 95), xpc.Dict{
+		"kCBMsgArgDeviceUUID":           p.UUID,
+		"kCBMsgArgCharacteristicHandle": int64(c.handle),
+		"kCBMsgArgData":                 data,
+		"kCBMsgArgIsNotification":       int64(0),
+		"kCBMsgArgResult":               int64(res),
+	})
+}
+
+func (s *Simulator) write(p *Peripheral, args xpc.Dict) {
+	c := p.characteristic(args)
+	if c == nil {
+		return
+	}
+	data, _ := args["kCBMsgArgData"].([]byte)
+	withoutResponse, _ := args["kCBMsgArgType"].(int)
+	res := c.write(data, withoutResponse != 0)
+	if withoutResponse != 0 {
+		return
+	}
+	s.emit(s.eventID(71, 96), xpc.Dict{
+		"kCBMsgArgDeviceUUID":           p.UUID,
+		"kCBMsgArgCharacteristicHandle": int64(c.handle),
+		"kCBMsgArgResult":               int64(res),
+	})
+}
+
+func (s *Simulator) notify(p *Peripheral, args xpc.Dict) {
+	c := p.characteristic(args)
+	if c == nil {
+		return
+	}
+	state, _ := args["kCBMsgArgState"].(int)
+	res := 0
+	if c.Properties&(goble.Notify|goble.Indicate) == 0 {
+		res = int(goble.ErrRequestNotSupported)
+		state = 0
+	}
+	c.notifying = state != 0
+	s.emit(s.eventID(73, 98), xpc.Dict{
+		"kCBMsgArgDeviceUUID":           p.UUID,
+		"kCBMsgArgCharacteristicHandle": int64(c.handle),
+		"kCBMsgArgState":                int64(state),
+		"kCBMsgArgResult":               int64(res),
+	})
+}
@@ -0,0 +1,287 @@
+package simulator
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dim13/goble"
+	"github.com/dim13/goble/xpc"
+)
+
+var (
+	deviceUUID  = xpc.MustUUID("0123456789abcdef0123456789abcdef")
+	serviceUUID = goble.UUID16(0x180f)
+	levelUUID   = goble.UUID16(0x2a19)
+	customUUID  = xpc.MustUUID("6e400001b5a3f393e0a9e50e24dcca9e")
+)
+
+func testPeripheral() *Peripheral {
+	return &Peripheral{
+		UUID: deviceUUID,
+		RSSI: -42,
+		Advertisement: Advertisement{
+			LocalName:    "battery",
+			ServiceUUIDs: []xpc.UUID{serviceUUID},
+		},
+		Services: []*Service{{
+			UUID: serviceUUID,
+			Characteristics: []*Characteristic{
+				{
+					UUID:        levelUUID,
+					Properties:  goble.Read | goble.Notify,
+					Value:       []byte{99},
+					Descriptors: []*Descriptor{{UUID: goble.ClientConfigurationUUID}},
+				},
+				{
+					UUID:       customUUID,
+					Properties: goble.Write | goble.WriteWithoutResponse,
+				},
+			},
+		}},
+	}
+}
+
+// listen collects the events of ble
+func listen(ble *goble.BLE) <-chan goble.Event {
+	events := make(chan goble.Event, 64)
+	ble.Listen(func(ev goble.Event) bool {
+		events <- ev
+		return false
+	})
+	return events
+}
+
+func wait(t *testing.T, events <-chan goble.Event, name string) goble.Event {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Name == name {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %v event", name)
+		}
+	}
+}
+
+func TestSimulator(t *testing.T) {
+	for _, release := range []string{ElCapitan, HighSierra, Mojave, Catalina} {
+		t.Run(release, func(t *testing.T) {
+			sim := New(release)
+			defer sim.Close()
+			p := testPeripheral()
+			sim.Add(p)
+
+			ble := sim.BLE()
+			events := listen(ble)
+			ble.Init()
+			if ev := wait(t, events, "stateChange"); ev.State != "poweredOn" {
+				t.Fatalf("got state %v", ev.State)
+			}
+
+			ble.StartScanning([]xpc.UUID{serviceUUID}, false)
+			ev := wait(t, events, "discover")
+			if ev.DeviceUUID != deviceUUID || ev.Peripheral.Rssi != -42 {
+				t.Fatalf("got %v rssi %v", ev.DeviceUUID, ev.Peripheral.Rssi)
+			}
+			if ad := ev.Peripheral.Advertisement; ad.LocalName != "battery" || len(ad.ServiceUuids) != 1 || ad.ServiceUuids[0] != "180f" {
+				t.Fatalf("got advertisement %+v", ad)
+			}
+			ble.StopScanning()
+
+			ble.Connect(deviceUUID)
+			wait(t, events, "connect")
+
+			ble.DiscoverServices(deviceUUID, nil)
+			ev = wait(t, events, "servicesDiscover")
+			s, ok := ev.Peripheral.Service(serviceUUID.String())
+			if !ok {
+				t.Fatal("service not discovered")
+			}
+			ble.DiscoverCharacteristics(deviceUUID, s.Uuid, nil)
+			ev = wait(t, events, "characteristicsDiscover")
+			c, ok := s.Characteristic(levelUUID.String())
+			if !ok || c.Properties != goble.Read|goble.Notify {
+				t.Fatalf("got characteristic %+v", c)
+			}
+			ble.DiscoverDescriptors(deviceUUID, s.Uuid, c.Uuid)
+			wait(t, events, "descriptorsDiscover")
+			if len(c.Descriptors) != 2 {
+				t.Fatalf("got descriptors %v", c.Descriptors)
+			}
+
+			ble.Read(deviceUUID, s.Uuid, c.Uuid)
+			if ev := wait(t, events, "read"); !bytes.Equal(ev.Data, []byte{99}) || ev.IsNotification {
+				t.Fatalf("got read %v", ev.Data)
+			}
+
+			ble.Notify(deviceUUID, s.Uuid, c.Uuid, true)
+			if ev := wait(t, events, "notify"); !ev.IsNotification || ev.Result != 0 {
+				t.Fatalf("got notify %+v", ev)
+			}
+			p.Notify(levelUUID, []byte{98})
+			if ev := wait(t, events, "read"); !bytes.Equal(ev.Data, []byte{98}) || !ev.IsNotification {
+				t.Fatalf("got notification %v", ev.Data)
+			}
+
+			w, _ := s.Characteristic(customUUID.String())
+			ble.Write(deviceUUID, s.Uuid, w.Uuid, []byte("hello"), false)
+			if ev := wait(t, events, "write"); ev.Result != 0 {
+				t.Fatalf("got write result %v", ev.Result)
+			}
+			ble.Write(deviceUUID, s.Uuid, c.Uuid, []byte{1}, false)
+			if ev := wait(t, events, "write"); goble.ATTError(ev.Result) != goble.ErrWriteNotPermitted {
+				t.Fatalf("got write result %v", ev.Result)
+			}
+			if !bytes.Equal(p.Services[0].Characteristics[1].Value, []byte("hello")) {
+				t.Fatalf("got value %q", p.Services[0].Characteristics[1].Value)
+			}
+
+			p.Disconnect()
+			if ev := wait(t, events, "disconnect"); ev.DeviceUUID != deviceUUID {
+				t.Fatalf("got disconnect %v", ev.DeviceUUID)
+			}
+		})
+	}
+}
+
+func TestHooks(t *testing.T) {
+	sim := New(Mojave)
+	defer sim.Close()
+	p := testPeripheral()
+	c := p.Services[0].Characteristics[0]
+	c.OnRead = func() ([]byte, error) { return []byte{50}, nil }
+	w := p.Services[0].Characteristics[1]
+	w.OnWrite = func(data []byte, withoutResponse bool) error {
+		if !withoutResponse {
+			return goble.ErrInvalidAttributeValueLength
+		}
+		p.Notify(levelUUID, data)
+		return nil
+	}
+	p.ConnectLatency = 20 * time.Millisecond
+	sim.Add(p)
+
+	ble := sim.BLE()
+	events := listen(ble)
+	ble.Init()
+	ble.StartScanning(nil, false)
+	wait(t, events, "discover")
+
+	start := time.Now()
+	ble.Connect(deviceUUID)
+	wait(t, events, "connect")
+	if d := time.Since(start); d < p.ConnectLatency {
+		t.Errorf("connected after %v", d)
+	}
+
+	ble.DiscoverServices(deviceUUID, []xpc.UUID{serviceUUID})
+	s, _ := wait(t, events, "servicesDiscover").Peripheral.Service("180f")
+	ble.DiscoverCharacteristics(deviceUUID, s.Uuid, nil)
+	wait(t, events, "characteristicsDiscover")
+
+	ble.Read(deviceUUID, s.Uuid, "2a19")
+	if ev := wait(t, events, "read"); !bytes.Equal(ev.Data, []byte{50}) {
+		t.Fatalf("got read %v", ev.Data)
+	}
+
+	custom := customUUID.String()
+	ble.Write(deviceUUID, s.Uuid, custom, []byte{1}, false)
+	if ev := wait(t, events, "write"); goble.ATTError(ev.Result) != goble.ErrInvalidAttributeValueLength {
+		t.Fatalf("got write result %v", ev.Result)
+	}
+
+	ble.Notify(deviceUUID, s.Uuid, "2a19", true)
+	wait(t, events, "notify")
+	ble.Write(deviceUUID, s.Uuid, custom, []byte{7}, true)
+	if ev := wait(t, events, "read"); !ev.IsNotification || !bytes.Equal(ev.Data, []byte{7}) {
+		t.Fatalf("got notification %+v", ev)
+	}
+
+	sim.SetPowered(false)
+	wait(t, events, "disconnect")
+	if ev := wait(t, events, "stateChange"); ev.State != "poweredOff" {
+		t.Fatalf("got state %v", ev.State)
+	}
+}
+
+func TestNotConnectable(t *testing.T) {
+	sim := New(HighSierra)
+	defer sim.Close()
+	p := testPeripheral()
+	p.NotConnectable = true
+	sim.Add(p)
+
+	ble := sim.BLE()
+	events := listen(ble)
+	ble.StartScanning([]xpc.UUID{customUUID}, false)
+	ble.StartScanning(nil, false)
+	if ev := wait(t, events, "discover"); ev.Peripheral.Connectable {
+		t.Fatal("peripheral is connectable")
+	}
+
+	ble.Connect(deviceUUID)
+	select {
+	case ev := <-events:
+		t.Fatalf("got %v event", ev.Name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRequests(t *testing.T) {
+	// on darwin 17 start scanning and discover services share an id
+	r := requests(HighSierra)
+	if r[44] != "startScanning" || r[61] != "discoverCharacteristics" {
+		t.Errorf("got %v", r)
+	}
+	r = requests(Catalina)
+	if r[51] != "startScanning" || r[46] != "" || r[103] != "notify" {
+		t.Errorf("got %v", r)
+	}
+}
+
+func TestMTU(t *testing.T) {
+	for _, release := range []string{ElCapitan, HighSierra, Mojave, Catalina} {
+		t.Run(release, func(t *testing.T) {
+			sim := New(release)
+			defer sim.Close()
+			p := testPeripheral()
+			p.MTU = 50
+			var parts []int
+			p.Services[0].Characteristics[1].OnWrite = func(data []byte, withoutResponse bool) error {
+				parts = append(parts, len(data))
+				return nil
+			}
+			sim.Add(p)
+
+			ble := sim.BLE()
+			events := listen(ble)
+			ble.Init()
+			ble.StartScanning(nil, false)
+			if ev := wait(t, events, "discover"); ev.Peripheral.MaximumWriteLength(true) != 20 {
+				t.Errorf("got %v before connect", ev.Peripheral.MaximumWriteLength(true))
+			}
+			ble.Connect(deviceUUID)
+			wait(t, events, "connect")
+			ev := wait(t, events, "mtuChange")
+			if ev.Mtu != 50 || ev.Peripheral.MaximumWriteLength(true) != 47 || ev.Peripheral.MaximumWriteLength(false) != 512 {
+				t.Fatalf("got mtu %v", ev.Mtu)
+			}
+
+			ble.DiscoverServices(deviceUUID, nil)
+			s, _ := wait(t, events, "servicesDiscover").Peripheral.Service("180f")
+			ble.DiscoverCharacteristics(deviceUUID, s.Uuid, nil)
+			wait(t, events, "characteristicsDiscover")
+			ble.Write(deviceUUID, s.Uuid, customUUID.String(), make([]byte, 100), true)
+			ble.Write(deviceUUID, s.Uuid, customUUID.String(), nil, false)
+			wait(t, events, "write")
+			if !reflect.DeepEqual(parts, []int{47, 47, 6, 0}) {
+				t.Errorf("got writes of %v bytes", parts)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package xpc
+
+// #include "xpc_wrapper.h"
+import "C"
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"unsafe"
+)
+
+type XPC struct {
+	conn C.xpc_connection_t
+}
+
+func (x *XPC) Send(msg interface{}, verbose bool) {
+	C.XpcSendMessage(x.conn, goToXpc(msg), C.bool(true), C.bool(verbose))
+}
+
+var (
+	typeOfUUID  = reflect.TypeOf(UUID{})
+	typeOfBytes = reflect.TypeOf([]byte{})
+
+	handlers = map[uintptr]XpcEventHandler{}
+)
+
+func XpcConnect(service string, eh XpcEventHandler) XPC {
+	// func XpcConnect(service string, eh XpcEventHandler) C.xpc_connection_t {
+	ctx := uintptr(unsafe.Pointer(&eh))
+	handlers[ctx] = eh
+
+	cservice := C.CString(service)
+	defer C.free(unsafe.Pointer(cservice))
+	// return C.XpcConnect(cservice, C.uintptr_t(ctx))
+	return XPC{conn: C.XpcConnect(cservice, C.uintptr_t(ctx))}
+}
+
+//export handleXpcEvent
+func handleXpcEvent(event C.xpc_object_t, p C.ulong) {
+	//log.Printf("handleXpcEvent %#v %#v\n", event, p)
+
+	t := C.xpc_get_type(event)
+
+	eh := handlers[uintptr(p)]
+	if eh == nil {
+		//log.Println("no handler for", p)
+		return
+	}
+
+	if t == C.TYPE_ERROR {
+		switch event {
+		case C.ERROR_CONNECTION_INVALID:
+			// The client process on the other end of the connection has either
+			// crashed or cancelled the connection. After receiving this error,
+			// the connection is in an invalid state, and you do not need to
+			// call xpc_connection_cancel(). Just tear down any associated state
+			// here.
+			//log.Println("connection invalid")
+			eh.HandleXpcEvent(nil, ErrConnectionInvalid)
+		case C.ERROR_CONNECTION_INTERRUPTED:
+			//log.Println("connection interrupted")
+			eh.HandleXpcEvent(nil, ErrConnectionInterrupted)
+		case C.ERROR_CONNECTION_TERMINATED:
+			// Handle per-connection termination cleanup.
+			//log.Println("connection terminated")
+			eh.HandleXpcEvent(nil, ErrConnectionTerminated)
+		default:
+			//log.Println("got some error", event)
+			eh.HandleXpcEvent(nil, fmt.Errorf("%v", event))
+		}
+	} else {
+		eh.HandleXpcEvent(xpcToGo(event).(Dict), nil)
+	}
+}
+
+// goToXpc converts a go object to an xpc object
+func goToXpc(o interface{}) C.xpc_object_t {
+	return valueToXpc(reflect.ValueOf(o))
+}
+
+// valueToXpc converts a go Value to an xpc object
+//
+// note that not all the types are supported, but only the subset required for Blued
+func valueToXpc(val reflect.Value) C.xpc_object_t {
+	if !val.IsValid() {
+		return nil
+	}
+
+	var xv C.xpc_object_t
+
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		xv = C.xpc_int64_create(C.int64_t(val.Int()))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		xv = C.xpc_int64_create(C.int64_t(val.Uint()))
+
+	case reflect.String:
+		xv = C.xpc_string_create(C.CString(val.String()))
+
+	case reflect.Map:
+		xv = C.xpc_dictionary_create(nil, nil, 0)
+		for _, k := range val.MapKeys() {
+			v := valueToXpc(val.MapIndex(k))
+			C.xpc_dictionary_set_value(xv, C.CString(k.String()), v)
+			if v != nil {
+				C.xpc_release(v)
+			}
+		}
+
+	case reflect.Array, reflect.Slice:
+		if val.Type() == typeOfUUID {
+			// Array of bytes
+			var uuid [16]byte
+			reflect.Copy(reflect.ValueOf(uuid[:]), val)
+			xv = C.xpc_uuid_create(C.ptr_to_uuid(unsafe.Pointer(&uuid[0])))
+		} else if val.Type() == typeOfBytes {
+			// slice of bytes
+			xv = C.xpc_data_create(unsafe.Pointer(val.Pointer()), C.size_t(val.Len()))
+		} else {
+			xv = C.xpc_array_create(nil, 0)
+			l := val.Len()
+
+			for i := 0; i < l; i++ {
+				v := valueToXpc(val.Index(i))
+				C.xpc_array_append_value(xv, v)
+				if v != nil {
+					C.xpc_release(v)
+				}
+			}
+		}
+
+	case reflect.Interface, reflect.Ptr:
+		xv = valueToXpc(val.Elem())
+
+	default:
+		log.Fatalf("unsupported %#v", val.String())
+	}
+
+	return xv
+}
+
+//export arraySet
+func arraySet(u C.uintptr_t, i C.int, v C.xpc_object_t) {
+	a := *(*Array)(unsafe.Pointer(uintptr(u)))
+	a[i] = xpcToGo(v)
+}
+
+//export dictSet
+func dictSet(u C.uintptr_t, k *C.char, v C.xpc_object_t) {
+	d := *(*Dict)(unsafe.Pointer(uintptr(u)))
+	d[C.GoString(k)] = xpcToGo(v)
+}
+
+// xpcToGo converts an xpc object to a go object
+//
+// note that not all the types are supported, but only the subset required for Blued
+func xpcToGo(v C.xpc_object_t) interface{} {
+	t := C.xpc_get_type(v)
+
+	switch t {
+	case C.TYPE_ARRAY:
+		a := make(Array, C.int(C.xpc_array_get_count(v)))
+		p := uintptr(unsafe.Pointer(&a))
+		C.XpcArrayApply(C.uintptr_t(p), v)
+		return a
+
+	case C.TYPE_DATA:
+		return C.GoBytes(C.xpc_data_get_bytes_ptr(v), C.int(C.xpc_data_get_length(v)))
+
+	case C.TYPE_DICT:
+		d := make(Dict)
+		p := uintptr(unsafe.Pointer(&d))
+		C.XpcDictApply(C.uintptr_t(p), v)
+		return d
+
+	case C.TYPE_INT64:
+		return int64(C.xpc_int64_get_value(v))
+
+	case C.TYPE_STRING:
+		return C.GoString(C.xpc_string_get_string_ptr(v))
+
+	case C.TYPE_UUID:
+		a := [16]byte{}
+		C.XpcUUIDGetBytes(unsafe.Pointer(&a), v)
+		return UUID(a)
+
+	default:
+		log.Fatalf("unexpected type %#v, value %#v", t, v)
+	}
+
+	return nil
+}
+
+// xpc_release is needed by tests, since they can't use CGO
+func xpc_release(xv C.xpc_object_t) {
+	C.xpc_release(xv)
+}
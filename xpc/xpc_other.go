@@ -0,0 +1,14 @@
+//go:build !darwin
+// +build !darwin
+
+package xpc
+
+// XPC is only available on darwin; elsewhere the connection is always invalid
+type XPC struct{}
+
+func (x *XPC) Send(msg interface{}, verbose bool) {}
+
+func XpcConnect(service string, eh XpcEventHandler) XPC {
+	go eh.HandleXpcEvent(nil, ErrConnectionInvalid)
+	return XPC{}
+}